@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultAuditFile é o arquivo de auditoria usado quando a configuração não informa outro.
+const DefaultAuditFile = "logs/audit.log"
+
+// Auditor registra chamadas negadas em um arquivo, uma por linha.
+type Auditor struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewAuditor cria um Auditor que escreve em path (ou DefaultAuditFile, se vazio).
+func NewAuditor(path string) *Auditor {
+	if path == "" {
+		path = DefaultAuditFile
+	}
+	return &Auditor{path: path}
+}
+
+// SetPath troca o arquivo de auditoria (ex: após recarregar a configuração).
+func (a *Auditor) SetPath(path string) {
+	if path == "" {
+		path = DefaultAuditFile
+	}
+	a.mu.Lock()
+	a.path = path
+	a.mu.Unlock()
+}
+
+// Deny registra uma chamada negada.
+// Formato: "<timestamp> DENY client=<cliente> addr=<endereço> method=<método> list=<lista> reason=<motivo>".
+func (a *Auditor) Deny(client, remoteAddr, method, listID string, reason error) error {
+	if client == "" {
+		client = "-"
+	}
+	line := fmt.Sprintf("%s DENY client=%q addr=%s method=%s list=%q reason=%q\n",
+		time.Now().Format(time.RFC3339Nano), client, remoteAddr, method, listID, reason.Error())
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return fmt.Errorf("erro ao criar diretório de auditoria: %w", err)
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir arquivo de auditoria %s: %w", a.path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(line); err != nil {
+		return fmt.Errorf("erro ao escrever no arquivo de auditoria %s: %w", a.path, err)
+	}
+	return nil
+}
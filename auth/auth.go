@@ -0,0 +1,128 @@
+// Package auth autentica clientes por token e aplica as regras de acesso
+// (ACL) por lista definidas na configuração.
+package auth
+
+import (
+	"strings"
+	"sync"
+
+	"sd-miniprojeto-1/config"
+	"sd-miniprojeto-1/structures"
+)
+
+// Permission é uma permissão sobre listas.
+type Permission string
+
+// Permissões concedidas pelas regras de acesso. PermAdmin implica todas as outras.
+const (
+	PermRead   Permission = "read"
+	PermAppend Permission = "append"
+	PermRemove Permission = "remove"
+	PermAdmin  Permission = "admin"
+)
+
+// Identity é um cliente autenticado.
+type Identity struct {
+	Client    string   // Nome do cliente (ou "anonymous" com autenticação desativada).
+	Roles     []string // Papéis atribuídos ao token.
+	Namespace string   // Namespace das listas do cliente.
+}
+
+// Authorizer resolve tokens e verifica permissões. A configuração pode ser
+// trocada a qualquer momento com Update, e vale já para a próxima chamada.
+type Authorizer struct {
+	mu     sync.RWMutex
+	cfg    config.AuthConfig
+	tokens map[string]config.TokenConfig
+}
+
+// NewAuthorizer cria um Authorizer com a configuração informada.
+func NewAuthorizer(cfg config.AuthConfig) *Authorizer {
+	a := &Authorizer{}
+	a.Update(cfg)
+	return a
+}
+
+// Update troca a configuração de autenticação (tokens, papéis e regras).
+func (a *Authorizer) Update(cfg config.AuthConfig) {
+	tokens := make(map[string]config.TokenConfig, len(cfg.Tokens))
+	for _, tok := range cfg.Tokens {
+		tokens[tok.Token] = tok
+	}
+
+	a.mu.Lock()
+	a.cfg, a.tokens = cfg, tokens
+	a.mu.Unlock()
+}
+
+// Enabled informa se a autenticação está ativa.
+func (a *Authorizer) Enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.cfg.Enabled
+}
+
+// Authenticate resolve o token para uma identidade. Com a autenticação
+// desativada, qualquer token (inclusive vazio) resulta em um cliente anônimo.
+func (a *Authorizer) Authenticate(token string) (*Identity, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if !a.cfg.Enabled {
+		return &Identity{Client: "anonymous", Namespace: structures.DefaultNamespace}, nil
+	}
+	if token == "" {
+		return nil, structures.NewError(structures.CodeUnauthorized, "token de acesso ausente")
+	}
+	tok, ok := a.tokens[token]
+	if !ok {
+		return nil, structures.NewError(structures.CodeUnauthorized, "token de acesso inválido")
+	}
+	return &Identity{Client: tok.Client, Roles: tok.Roles, Namespace: structures.NamespaceName(tok.Namespace)}, nil
+}
+
+// Authorize autentica o token e verifica se ele tem a permissão sobre a lista.
+// O token é resolvido a cada chamada, para que revogações valham imediatamente.
+func (a *Authorizer) Authorize(token string, perm Permission, listID string) (*Identity, error) {
+	identity, err := a.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+	if !a.Allowed(identity, perm, listID) {
+		return identity, structures.NewError(structures.CodePermissionDenied,
+			"cliente '%s' sem permissão '%s' na lista '%s'", identity.Client, perm, listID)
+	}
+	return identity, nil
+}
+
+// Allowed verifica se a identidade tem a permissão sobre a lista.
+func (a *Authorizer) Allowed(identity *Identity, perm Permission, listID string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if !a.cfg.Enabled {
+		return true
+	}
+	for _, role := range identity.Roles {
+		for _, rule := range a.cfg.Roles[role] {
+			if !matchList(rule.Lists, listID) {
+				continue
+			}
+			for _, granted := range rule.Permissions {
+				if Permission(granted) == perm || Permission(granted) == PermAdmin {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// matchList verifica se o ID casa com o padrão da regra: ID exato,
+// prefixo terminado em "*" ou "*" para todas as listas.
+func matchList(pattern, listID string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(listID, prefix)
+	}
+	return pattern == listID
+}
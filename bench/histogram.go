@@ -0,0 +1,137 @@
+// Package bench reúne o que o gerador de carga (loadgen.go) usa para medir e
+// relatar: histogramas de latência com precisão relativa fixa e o relatório
+// em JSON, que permite comparar execuções.
+package bench
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits define a precisão: cada potência de 2 é dividida em
+// 2^subBucketBits buckets, o que limita o erro relativo dos quantis a
+// 1/2^subBucketBits (< 1%).
+const subBucketBits = 7
+
+const subBuckets = 1 << subBucketBits
+
+// Histogram conta latências em buckets log-lineares, como o HdrHistogram:
+// valores abaixo de subBuckets nanossegundos são exatos e, acima disso, cada
+// potência de 2 tem subBuckets buckets de mesma largura. Não é seguro para
+// uso concorrente; cada cliente mantém o seu e eles são combinados com Merge.
+type Histogram struct {
+	counts   []uint64
+	count    uint64
+	sum      float64 // Em nanossegundos.
+	min, max time.Duration
+}
+
+// NewHistogram cria um histograma vazio.
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]uint64, (64-subBucketBits+1)*subBuckets)}
+}
+
+// bucketOf retorna o bucket de v (em nanossegundos, não negativo).
+func bucketOf(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	// Os subBucketBits bits mais significativos depois do primeiro escolhem o bucket.
+	shift := bits.Len64(v) - subBucketBits - 1
+	return (shift+1)*subBuckets + int(v>>shift) - subBuckets
+}
+
+// bucketLimit retorna o maior valor do bucket b.
+func bucketLimit(b int) uint64 {
+	if b < subBuckets {
+		return uint64(b)
+	}
+	shift := b/subBuckets - 1
+	base := uint64(b%subBuckets+subBuckets) << shift
+	return base + (uint64(1) << shift) - 1
+}
+
+// Record registra uma latência; valores negativos contam como zero.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.counts[bucketOf(uint64(d))]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += float64(d)
+}
+
+// Merge soma as contagens de o às deste histograma.
+func (h *Histogram) Merge(o *Histogram) {
+	if o.count == 0 {
+		return
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if o.max > h.max {
+		h.max = o.max
+	}
+	h.count += o.count
+	h.sum += o.sum
+}
+
+// Count retorna a quantidade de latências registradas.
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Min retorna a menor latência registrada.
+func (h *Histogram) Min() time.Duration {
+	return h.min
+}
+
+// Max retorna a maior latência registrada.
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Mean retorna a média das latências.
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / float64(h.count))
+}
+
+// Quantile retorna a latência abaixo da qual (ou igual) está a fração q das
+// observações, com o erro relativo do bucket; o resultado nunca passa de Max.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for b, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			limit := time.Duration(bucketLimit(b))
+			if limit > h.max {
+				limit = h.max
+			}
+			if limit < h.min {
+				limit = h.min
+			}
+			return limit
+		}
+	}
+	return h.max
+}
@@ -0,0 +1,170 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report é o resultado de uma execução do gerador de carga, no formato
+// exportado em JSON.
+type Report struct {
+	Started  time.Time         `json:"started"`
+	Config   map[string]string `json:"config"`   // Opções da execução, para saber o que se compara.
+	Duration time.Duration     `json:"duration"` // Período medido (sem o aquecimento).
+	Total    Stats             `json:"total"`
+	Ops      map[string]Stats  `json:"ops"` // Por operação.
+}
+
+// Stats resume as chamadas de uma operação (ou de todas). As latências estão
+// em nanossegundos no JSON, como time.Duration.
+type Stats struct {
+	Count      uint64            `json:"count"`
+	Errors     uint64            `json:"errors"`          // Falhas de transporte e erros inesperados.
+	Codes      map[string]uint64 `json:"codes,omitempty"` // Respostas com erro tipado, por código (NOT_FOUND, EMPTY...).
+	Throughput float64           `json:"throughput"`      // Chamadas por segundo.
+	Mean       time.Duration     `json:"mean"`
+	Min        time.Duration     `json:"min"`
+	P50        time.Duration     `json:"p50"`
+	P90        time.Duration     `json:"p90"`
+	P99        time.Duration     `json:"p99"`
+	P999       time.Duration     `json:"p999"`
+	Max        time.Duration     `json:"max"`
+}
+
+// Summarize calcula as estatísticas de h no período informado.
+func Summarize(h *Histogram, errors uint64, codes map[string]uint64, period time.Duration) Stats {
+	s := Stats{
+		Count:  h.Count(),
+		Errors: errors,
+		Mean:   h.Mean(),
+		Min:    h.Min(),
+		P50:    h.Quantile(0.50),
+		P90:    h.Quantile(0.90),
+		P99:    h.Quantile(0.99),
+		P999:   h.Quantile(0.999),
+		Max:    h.Max(),
+	}
+	if len(codes) > 0 {
+		s.Codes = codes
+	}
+	if period > 0 {
+		s.Throughput = float64(h.Count()) / period.Seconds()
+	}
+	return s
+}
+
+// ReadReport lê um relatório salvo com WriteFile.
+func ReadReport(path string) (Report, error) {
+	var r Report
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("relatório inválido em %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// WriteFile salva o relatório em JSON.
+func (r Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// opNames retorna as operações do relatório em ordem alfabética.
+func (r Report) opNames() []string {
+	names := make([]string, 0, len(r.Ops))
+	for name := range r.Ops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Print exibe o relatório como tabela.
+func (r Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operação\tchamadas\terros\tops/s\tmédia\tp50\tp99\tp999\tmáx\t")
+	row := func(name string, s Stats) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\t\n", name, s.Count, s.Errors, s.Throughput,
+			latency(s.Mean), latency(s.P50), latency(s.P99), latency(s.P999), latency(s.Max))
+	}
+	for _, name := range r.opNames() {
+		row(name, r.Ops[name])
+	}
+	row("total", r.Total)
+	tw.Flush()
+
+	for _, name := range r.opNames() {
+		if codes := r.Ops[name].Codes; len(codes) > 0 {
+			parts := make([]string, 0, len(codes))
+			for code, n := range codes {
+				parts = append(parts, fmt.Sprintf("%s=%d", code, n))
+			}
+			sort.Strings(parts)
+			fmt.Fprintf(w, "Respostas de erro em %s: %s\n", name, strings.Join(parts, ", "))
+		}
+	}
+}
+
+// Compare exibe as variações desta execução em relação a base, por operação:
+// vazão e latências (positivo: mais alto que na base).
+func (r Report) Compare(w io.Writer, base Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operação\tops/s\tp50\tp99\tp999\t")
+	row := func(name string, s, b Stats) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", name, change(s.Throughput, b.Throughput),
+			change(float64(s.P50), float64(b.P50)), change(float64(s.P99), float64(b.P99)), change(float64(s.P999), float64(b.P999)))
+	}
+	for _, name := range r.opNames() {
+		if b, ok := base.Ops[name]; ok {
+			row(name, r.Ops[name], b)
+		}
+	}
+	row("total", r.Total, base.Total)
+	tw.Flush()
+
+	keys := make([]string, 0, len(r.Config))
+	for key := range r.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "seed" {
+			continue // Muda a cada execução sem alterar a carga.
+		}
+		if baseValue, ok := base.Config[key]; ok && baseValue != r.Config[key] {
+			fmt.Fprintf(w, "Atenção: %s difere da base (%s, na base %s).\n", key, r.Config[key], baseValue)
+		}
+	}
+}
+
+func change(value, base float64) string {
+	if base == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", (value-base)/base*100)
+}
+
+// latency formata a latência com três algarismos significativos.
+func latency(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	case d >= time.Microsecond:
+		return d.Round(10 * time.Nanosecond).String()
+	}
+	return d.String()
+}
@@ -2,252 +2,1125 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"net"
-	"net/rpc"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"sd-miniprojeto-1/client"
+	"sd-miniprojeto-1/lineedit"
 	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/tlsutil"
 )
 
 const (
 	serverAddress       = "localhost:1234"
-	reconnectionTimeout = 30 * time.Second // Tempo máximo para tentar reconectar.
-	retryDelay          = 2 * time.Second  // Atraso entre tentativas de reconexão.
+	reconnectionTimeout = 30 * time.Second // Tempo máximo padrão para tentar reconectar.
+	retryDelay          = 2 * time.Second  // Atraso máximo entre tentativas de reconexão.
 )
 
-// clientConn guarda a conexão ativa com o servidor.
-var clientConn *rpc.Client
+// Códigos de saída dos modos não interativos.
+const (
+	exitOK          = 0 // Todos os comandos tiveram sucesso.
+	exitFailed      = 1 // O servidor respondeu com erro (NOT_FOUND, EMPTY...).
+	exitUsage       = 2 // Comando ou opção malformados.
+	exitUnavailable = 3 // Servidor inacessível ou conexão perdida.
+)
 
-// tryConnect tenta conectar ao servidor uma vez.
-func tryConnect() (*rpc.Client, error) {
-	c, err := rpc.DialHTTP("tcp", serverAddress)
+// Opções de linha de comando.
+var (
+	addrFlag          = flag.String("addr", serverAddress, "Endereço do servidor.")
+	tlsFlag           = flag.Bool("tls", false, "Conecta via TLS (implícito se -tls-ca ou -tls-cert forem informados).")
+	tlsCAFlag         = flag.String("tls-ca", "", "CA para verificar o certificado do servidor (PEM).")
+	tlsCertFlag       = flag.String("tls-cert", "", "Certificado do cliente para mTLS (PEM).")
+	tlsKeyFlag        = flag.String("tls-key", "", "Chave do certificado do cliente (PEM).")
+	tlsServerNameFlag = flag.String("tls-server-name", "", "Nome esperado no certificado do servidor (padrão: host de -addr).")
+	tokenFlag         = flag.String("token", os.Getenv("REMOTE_LIST_TOKEN"), "Token de acesso (padrão: $REMOTE_LIST_TOKEN).")
+	timeoutFlag       = flag.Duration("timeout", reconnectionTimeout, "Tempo máximo de cada comando, incluindo as tentativas de reconexão.")
+	fileFlag          = flag.String("f", "", "Executa os comandos do arquivo (- para a entrada padrão) e sai.")
+	outputFlag        = flag.String("output", "text", "Formato da saída dos modos não interativos: text ou json.")
+	keepGoingFlag     = flag.Bool("keep-going", false, "Nos modos não interativos, continua após um comando com erro.")
+	historyFlag       = flag.String("history", defaultHistoryPath(), "Arquivo do histórico de comandos do modo interativo (vazio desativa).")
+	watchIntervalFlag = flag.Duration("watch-interval", 500*time.Millisecond, "Intervalo entre as consultas do WATCH.")
+)
+
+// defaultHistoryPath retorna o arquivo padrão do histórico, no diretório do usuário.
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".remote_list_history")
+}
+
+// clientTLSConfig monta a configuração TLS a partir das flags, ou nil se o TLS não foi pedido.
+func clientTLSConfig() (*tls.Config, error) {
+	if !*tlsFlag && *tlsCAFlag == "" && *tlsCertFlag == "" {
+		return nil, nil
+	}
+	return tlsutil.ClientConfig(*tlsCAFlag, *tlsCertFlag, *tlsKeyFlag, *tlsServerNameFlag)
+}
+
+// newClient cria o cliente da biblioteca para o endereço, com as mensagens de
+// reconexão do CLI escritas em notices. As tentativas são limitadas pelo
+// prazo do contexto de cada comando.
+func newClient(addr string, notices io.Writer) (*client.Client, error) {
+	tlsConfig, err := clientTLSConfig()
 	if err != nil {
 		return nil, err
 	}
-	return c, nil
+
+	opts := client.DefaultOptions(addr)
+	opts.TLSConfig = tlsConfig
+	opts.Token = *tokenFlag
+	opts.PoolSize = 1
+	opts.MaxAttempts = 0
+	opts.InitialBackoff = 500 * time.Millisecond
+	opts.MaxBackoff = retryDelay
+	opts.OnRetry = func(attempt int, err error, delay time.Duration) {
+		if client.RetryAfter(err) > 0 {
+			fmt.Fprintf(notices, "Servidor ocupado: %v. Tentando novamente em %v...\n", err, delay.Truncate(time.Millisecond))
+			return
+		}
+		fmt.Fprintf(notices, "Erro na conexão: %v. Tentando novamente em %v...\n", err, delay.Truncate(time.Millisecond))
+	}
+	return client.New(opts)
 }
 
-// isConnectionError verifica se um erro indica falha na conexão.
-func isConnectionError(err error) bool {
-	if err == nil {
-		return false
+// commandContext cria o contexto de um comando, limitado ao tempo de reconexão.
+func commandContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), *timeoutFlag)
+}
+
+// printError exibe o erro de um comando, com dica quando a conexão não pôde ser restabelecida.
+func printError(command string, err error) {
+	fmt.Printf("Erro no %s: %v\n", command, err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Printf("Não foi possível conectar/reconectar após %v. Verifique se o servidor está em execução.\n", *timeoutFlag)
 	}
+}
 
-	if errors.Is(err, io.EOF) ||
-		strings.Contains(err.Error(), "connection reset by peer") ||
-		strings.Contains(err.Error(), "connection refused") ||
-		strings.Contains(err.Error(), "broken pipe") ||
-		strings.Contains(err.Error(), "connection is shut down") ||
-		strings.Contains(err.Error(), "i/o timeout") {
-		return true
+// adminUsage descreve o subcomando admin.
+const adminUsage = `Uso: client [flags] admin <comando>
+Comandos:
+  stats              Estado do servidor (listas, memória, log, snapshot)
+  snapshot           Salva um snapshot imediatamente
+  compact            Salva um snapshot e compacta o log
+  clients            Conexões de clientes abertas
+  log-level <nível>  Troca o nível de log (debug, info, warn, error)
+  reload             Relê o arquivo de configuração do servidor`
+
+// runAdmin executa um comando do serviço Admin e retorna o código de saída.
+func runAdmin(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(adminUsage)
+		return 2
 	}
 
-	var netErr *net.OpError
-	if errors.As(err, &netErr) {
-		return true
+	rlClient, err := newClient(*addrFlag, os.Stdout)
+	if err != nil {
+		fmt.Println("Erro ao criar cliente:", err)
+		return 1
 	}
-	
-	// Erros RPC que não são de conexão (ex: método não encontrado).
-	if strings.HasPrefix(err.Error(), "rpc:") {
-		return false
+	defer rlClient.Close()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	command := strings.ToLower(args[0])
+	switch {
+	case command == "stats" && len(args) == 1:
+		stats, err := rlClient.Stats(ctx)
+		if err != nil {
+			printError("STATS", err)
+			return 1
+		}
+		fmt.Printf("Servidor:    %s (uptime %v)\n", stats.ServerID, stats.Uptime.Truncate(time.Second))
+		fmt.Printf("Listas:      %d (%d elementos)\n", stats.Lists, stats.Elements)
+		namespaces := make([]string, 0, len(stats.Namespaces))
+		for name := range stats.Namespaces {
+			namespaces = append(namespaces, name)
+		}
+		sort.Strings(namespaces)
+		for _, name := range namespaces {
+			usage := stats.Namespaces[name]
+			fmt.Printf("  %-12s %d listas, %d elementos\n", name, usage.Lists, usage.Elements)
+		}
+		fmt.Printf("Memória:     heap %d KiB, sistema %d KiB, %d goroutines\n", stats.HeapBytes/1024, stats.SysBytes/1024, stats.Goroutines)
+		fmt.Printf("Log:         LSN %d, %d bytes, nível %s\n", stats.LastLSN, stats.LogBytes, stats.LogLevel)
+		if stats.LastSnapshot.IsZero() {
+			fmt.Println("Snapshot:    nenhum")
+		} else {
+			fmt.Printf("Snapshot:    %s (há %v), %d gerações retidas\n", stats.LastSnapshot.Format(time.RFC3339), stats.SnapshotAge.Truncate(time.Second), stats.Snapshots)
+		}
+
+	case command == "snapshot" && len(args) == 1:
+		snapshot, err := rlClient.ForceSnapshot(ctx)
+		if err != nil {
+			printError("SNAPSHOT", err)
+			return 1
+		}
+		if snapshot.Skipped {
+			fmt.Printf("Nenhuma alteração desde o último snapshot; %s continua valendo (cobre o log até %s, LSN %d).\n",
+				snapshot.Path, snapshot.CoveredUntil.Format(time.RFC3339Nano), snapshot.LastLSN)
+			break
+		}
+		kind := "completo"
+		if snapshot.Delta {
+			kind = "incremental"
+		}
+		fmt.Printf("Snapshot %s salvo em %s em %v (cobre o log até %s, LSN %d).\n",
+			kind, snapshot.Path, snapshot.Duration.Truncate(time.Millisecond), snapshot.CoveredUntil.Format(time.RFC3339Nano), snapshot.LastLSN)
+
+	case command == "compact" && len(args) == 1:
+		result, err := rlClient.CompactLog(ctx)
+		if err != nil {
+			printError("COMPACT", err)
+			return 1
+		}
+		fmt.Printf("Log compactado: %d entradas mantidas, %d descartadas, %d -> %d bytes.\n",
+			result.EntriesKept, result.EntriesFreed, result.BytesBefore, result.BytesAfter)
+
+	case command == "clients" && len(args) == 1:
+		clients, err := rlClient.ListClients(ctx)
+		if err != nil {
+			printError("CLIENTS", err)
+			return 1
+		}
+		fmt.Printf("%-22s %-5s %-16s %-12s %-8s %s\n", "ENDEREÇO", "PROTO", "CLIENTE", "NAMESPACE", "CHAMADAS", "CONECTADO HÁ")
+		for _, c := range clients {
+			name := c.Client
+			if name == "" {
+				name = "(revogado)"
+			}
+			fmt.Printf("%-22s %-5s %-16s %-12s %-8d %v\n", c.RemoteAddr, c.Protocol, name, c.Namespace, c.Calls, time.Since(c.ConnectedAt).Truncate(time.Second))
+		}
+
+	case command == "log-level" && len(args) == 2:
+		previous, err := rlClient.SetLogLevel(ctx, args[1])
+		if err != nil {
+			printError("LOG-LEVEL", err)
+			return 1
+		}
+		fmt.Printf("Nível de log alterado de %s para %s.\n", previous, strings.ToLower(args[1]))
+
+	case command == "reload" && len(args) == 1:
+		reply, err := rlClient.ReloadConfig(ctx)
+		if err != nil {
+			printError("RELOAD", err)
+			return 1
+		}
+		fmt.Printf("Configuração recarregada de %s.\n", reply.Path)
+
+	default:
+		fmt.Println(adminUsage)
+		return 2
 	}
+	return 0
+}
+
+// --- Comandos ---
 
-	return false
+// commandUsage descreve os comandos de lista, na ordem da ajuda.
+var commandUsage = []struct{ name, usage string }{
+	{"APPEND", "APPEND <list_id> <valor>"},
+	{"GET", "GET <list_id> <indice>"},
+	{"GETAT", "GETAT <list_id> <indice> <horário RFC3339>"},
+	{"REMOVE", "REMOVE <list_id>"},
+	{"SIZE", "SIZE <list_id>"},
+	{"RANGE", "RANGE <list_id> <inicio> <fim>"},
+	{"DELETE", "DELETE <list_id>"},
+	{"KEYS", "KEYS [<padrão>]"},
+	{"SUM", "SUM <list_id> [<inicio> <fim>]"},
+	{"MIN", "MIN <list_id> [<inicio> <fim>]"},
+	{"MAX", "MAX <list_id> [<inicio> <fim>]"},
+	{"AVG", "AVG <list_id> [<inicio> <fim>]"},
+	{"COUNT", "COUNT <list_id> [<operador> <valor>] [<inicio> <fim>]"},
+	{"HISTOGRAM", "HISTOGRAM <list_id> <limites separados por vírgula> [<inicio> <fim>]"},
+	{"INDEXOF", "INDEXOF <list_id> <valor>"},
+	{"LASTINDEXOF", "LASTINDEXOF <list_id> <valor>"},
+	{"CONTAINS", "CONTAINS <list_id> <valor>"},
+	{"FINDALL", "FINDALL <list_id> <valor>"},
+	{"PING", "PING"},
+	{"WATCH", "WATCH <list_id>"},
+	{"CONNECT", "CONNECT <endereço>"},
 }
 
-// ensureConnected garante uma conexão ativa, reconectando se necessário.
-func ensureConnected(initialCall bool) bool {
-	if clientConn != nil {
-		var dummyValue int
-		err := clientConn.Call("RemoteList.Get", structures.GetArgs{ListID: "lista_dummy_ping"}, &dummyValue)
-		
-		if err == nil || !isConnectionError(err) {
-			return true // Conexão ativa ou erro de lógica.
+// aggregateMethods associa os comandos de agregação simples aos métodos RPC.
+var aggregateMethods = map[string]string{"SUM": "Sum", "MIN": "Min", "MAX": "Max", "AVG": "Avg"}
+
+// searchMethods associa os comandos de busca aos métodos RPC.
+var searchMethods = map[string]string{"INDEXOF": "IndexOf", "LASTINDEXOF": "LastIndexOf", "CONTAINS": "Contains", "FINDALL": "FindAll"}
+
+// argCounts retorna as quantidades de argumentos aceitas pelo uso: os
+// obrigatórios ("<...>") mais os de cada grupo opcional ("[...]"), em ordem.
+func argCounts(usage string) []int {
+	required, optional, depth := 0, []int{}, 0
+	for _, word := range strings.Fields(usage)[1:] {
+		if strings.HasPrefix(word, "[") {
+			depth++
+			optional = append(optional, 0)
+		}
+		if strings.HasPrefix(strings.TrimLeft(word, "["), "<") {
+			if depth > 0 {
+				optional[len(optional)-1]++
+			} else {
+				required++
+			}
+		}
+		if strings.HasSuffix(word, "]") {
+			depth--
 		}
-		
-		fmt.Println("Conexão RPC inativa. Tentando reconectar...")
-		clientConn.Close()
-		clientConn = nil
 	}
+	counts := []int{required}
+	for _, n := range optional {
+		counts = append(counts, counts[len(counts)-1]+n)
+	}
+	return counts
+}
 
-	startTime := time.Now()
-	attemptCount := 0
+// usageError é um comando malformado; não chega a ser enviado ao servidor.
+type usageError string
 
-	for time.Since(startTime) < reconnectionTimeout {
-		attemptCount++
+func (e usageError) Error() string { return string(e) }
+
+// command é um comando de lista já validado, com os argumentos na forma da
+// operação de lote correspondente.
+type command struct {
+	name string   // Nome em maiúsculas (APPEND, GET...).
+	args []string // Argumentos como digitados, para a saída.
+	op   structures.BatchOp
+}
 
-		if !initialCall || (initialCall && attemptCount > 1) {
-			remainingTime := reconnectionTimeout - time.Since(startTime).Truncate(time.Second)
-			fmt.Printf("Tentando conectar... Tempo restante: %v\n", remainingTime)
+// parseCommand valida um comando de lista (separado em palavras).
+func parseCommand(parts []string) (command, error) {
+	cmd := command{name: strings.ToUpper(parts[0]), args: parts[1:]}
+	usage := ""
+	for _, c := range commandUsage {
+		if c.name == cmd.name {
+			usage = c.usage
 		}
+	}
+	if usage == "" {
+		names := make([]string, 0, len(commandUsage)+1)
+		for _, c := range commandUsage {
+			names = append(names, c.name)
+		}
+		return cmd, usageError(fmt.Sprintf("Comando desconhecido. Use %s, BATCH ou EXIT.", strings.Join(names, ", ")))
+	}
+	if !slices.Contains(argCounts(usage), len(cmd.args)) {
+		return cmd, usageError("Uso: " + usage)
+	}
 
-		c, err := tryConnect()
-		if err == nil {
-			fmt.Println("Conexão estabelecida!")
-			clientConn = c
-			return true
+	var invalid error // Primeiro argumento inválido.
+	integer := func(text, what string) int {
+		n, err := strconv.Atoi(text)
+		if err != nil && invalid == nil {
+			invalid = usageError(fmt.Sprintf("Erro: %s deve ser um número inteiro. %v", what, err))
 		}
-		
-		if time.Since(startTime) + retryDelay < reconnectionTimeout {
-			fmt.Printf("Erro na conexão: %v. Tentando novamente em %v...\n", err, retryDelay)
-			time.Sleep(retryDelay)
-		} else {
-			fmt.Printf("Erro na conexão: %v. Tempo limite de reconexão atingido.\n", err)
+		return n
+	}
+	// span lê o intervalo opcional das agregações.
+	span := func(op *structures.BatchOp, args []string) {
+		if len(args) == 2 {
+			op.Bounded, op.Start, op.Stop = true, integer(args[0], "Início"), integer(args[1], "Fim")
 		}
 	}
+	var op structures.BatchOp
+	switch cmd.name {
+	case "APPEND":
+		op = structures.BatchOp{Method: "Append", ListID: cmd.args[0], Value: integer(cmd.args[1], "Valor")}
+	case "GET":
+		op = structures.BatchOp{Method: "Get", ListID: cmd.args[0], Index: integer(cmd.args[1], "Índice")}
+	case "GETAT":
+		op = structures.BatchOp{Method: "GetAt", ListID: cmd.args[0], Index: integer(cmd.args[1], "Índice")}
+		asOf, err := time.Parse(time.RFC3339Nano, cmd.args[2])
+		if err != nil && invalid == nil {
+			invalid = usageError(fmt.Sprintf("Erro: Horário deve estar no formato RFC3339 (ex: 2024-05-01T12:00:00Z). %v", err))
+		}
+		op.AsOf = asOf
+	case "REMOVE":
+		op = structures.BatchOp{Method: "Remove", ListID: cmd.args[0]}
+	case "SIZE":
+		op = structures.BatchOp{Method: "Size", ListID: cmd.args[0]}
+	case "RANGE":
+		op = structures.BatchOp{Method: "Range", ListID: cmd.args[0], Start: integer(cmd.args[1], "Início"), Stop: integer(cmd.args[2], "Fim")}
+	case "DELETE":
+		op = structures.BatchOp{Method: "Delete", ListID: cmd.args[0]}
+	case "KEYS":
+		op = structures.BatchOp{Method: "Keys"}
+		if len(cmd.args) == 1 {
+			op.Pattern = cmd.args[0]
+		}
+	case "SUM", "MIN", "MAX", "AVG":
+		op = structures.BatchOp{Method: aggregateMethods[cmd.name], ListID: cmd.args[0]}
+		span(&op, cmd.args[1:])
+	case "COUNT":
+		op = structures.BatchOp{Method: "Count", ListID: cmd.args[0]}
+		rest := cmd.args[1:]
+		if len(rest) >= 2 && strings.ContainsAny(rest[0], "<>=!") {
+			op.Op, op.Value = rest[0], integer(rest[1], "Valor")
+			rest = rest[2:]
+		}
+		if len(rest) != 0 && len(rest) != 2 {
+			return cmd, usageError("Uso: " + usage)
+		}
+		span(&op, rest)
+	case "HISTOGRAM":
+		op = structures.BatchOp{Method: "Histogram", ListID: cmd.args[0]}
+		for _, bound := range strings.Split(cmd.args[1], ",") {
+			op.Bounds = append(op.Bounds, integer(bound, "Limite"))
+		}
+		span(&op, cmd.args[2:])
+	case "INDEXOF", "LASTINDEXOF", "CONTAINS", "FINDALL":
+		op = structures.BatchOp{Method: searchMethods[cmd.name], ListID: cmd.args[0], Value: integer(cmd.args[1], "Valor")}
+	case "WATCH":
+		op = structures.BatchOp{Method: "Range", ListID: cmd.args[0], Start: 0, Stop: -1}
+	}
+	if invalid != nil {
+		return cmd, invalid
+	}
+	cmd.op = op
+	return cmd, nil
+}
 
-	fmt.Printf("Não foi possível conectar/reconectar após %v. Verifique se o servidor está em execução.\n", reconnectionTimeout)
-	return false
+// commandResult é a resposta de um comando; só os campos do comando são preenchidos.
+type commandResult struct {
+	value  int
+	values []int   // RANGE, HISTOGRAM e FINDALL.
+	mean   float64 // AVG.
+	found  bool    // CONTAINS.
+	keys   []string
+	ping   structures.PingReply // PING e CONNECT.
+
+	// Mudança observada pelo WATCH: elementos removidos do fim e acrescentados
+	// a ele desde a consulta anterior (value é o tamanho).
+	removed []int
+	added   []int
+	exists  bool
 }
 
-// callRPC faz uma chamada RPC, cuidando de reconexão e erros.
-func callRPC(serviceMethod string, args interface{}, reply interface{}) error {
-	if !ensureConnected(false) {
-		return fmt.Errorf("não foi possível estabelecer conexão RPC")
+// execute envia um comando ao servidor.
+func execute(rlClient *client.Client, cmd command) (commandResult, error) {
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	var r commandResult
+	var err error
+	op := cmd.op
+	switch cmd.name {
+	case "APPEND":
+		err = rlClient.Append(ctx, op.ListID, op.Value)
+	case "GET":
+		r.value, err = rlClient.Get(ctx, op.ListID, op.Index)
+	case "GETAT":
+		r.value, err = rlClient.GetAt(ctx, op.ListID, op.Index, op.AsOf)
+	case "REMOVE":
+		r.value, err = rlClient.Remove(ctx, op.ListID)
+	case "SIZE":
+		r.value, err = rlClient.Size(ctx, op.ListID)
+	case "RANGE", "WATCH":
+		r.values, err = rlClient.Range(ctx, op.ListID, op.Start, op.Stop)
+	case "DELETE":
+		err = rlClient.Delete(ctx, op.ListID)
+	case "KEYS":
+		r.keys, err = rlClient.Keys(ctx, op.Pattern)
+	case "SUM":
+		r.value, err = rlClient.Sum(ctx, op.ListID, aggregateSpan(op))
+	case "MIN":
+		r.value, err = rlClient.Min(ctx, op.ListID, aggregateSpan(op))
+	case "MAX":
+		r.value, err = rlClient.Max(ctx, op.ListID, aggregateSpan(op))
+	case "AVG":
+		r.mean, err = rlClient.Avg(ctx, op.ListID, aggregateSpan(op))
+	case "COUNT":
+		r.value, err = rlClient.Count(ctx, op.ListID, aggregateSpan(op), op.Op, op.Value)
+	case "HISTOGRAM":
+		r.values, err = rlClient.Histogram(ctx, op.ListID, aggregateSpan(op), op.Bounds)
+	case "INDEXOF":
+		r.value, err = rlClient.IndexOf(ctx, op.ListID, op.Value)
+	case "LASTINDEXOF":
+		r.value, err = rlClient.LastIndexOf(ctx, op.ListID, op.Value)
+	case "CONTAINS":
+		r.found, err = rlClient.Contains(ctx, op.ListID, op.Value)
+	case "FINDALL":
+		r.values, err = rlClient.FindAll(ctx, op.ListID, op.Value)
+	case "PING":
+		r.ping, err = rlClient.Ping(ctx)
 	}
+	return r, err
+}
+
+// aggregateSpan retorna os elementos considerados por uma agregação.
+func aggregateSpan(op structures.BatchOp) client.Span {
+	if op.Bounded {
+		return client.Between(op.Start, op.Stop)
+	}
+	return client.WholeList()
+}
 
-	err := clientConn.Call(serviceMethod, args, reply)
+// errNotExecuted marca as operações de um lote que não foram executadas
+// porque uma anterior falhou.
+var errNotExecuted = errors.New("não executado: uma operação anterior do lote falhou")
+
+// executeBatch envia os comandos em um único Batch e retorna o resultado (ou
+// o erro) de cada um. O erro retornado é o da chamada como um todo.
+func executeBatch(rlClient *client.Client, cmds []command, stopOnError bool) ([]commandResult, []error, error) {
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	ops := make([]structures.BatchOp, len(cmds))
+	for i, cmd := range cmds {
+		ops[i] = cmd.op
+	}
+	replies, err := rlClient.Batch(ctx, ops, stopOnError)
 	if err != nil {
-		if isConnectionError(err) {
-			fmt.Printf("Erro na chamada RPC (%s), conexão perdida: %v. Tentando reconectar e refazer...\n", serviceMethod, err)
-			
-			if clientConn != nil {
-				clientConn.Close()
-				clientConn = nil
-			}
-			
-			if !ensureConnected(false) {
-				return fmt.Errorf("falha ao refazer chamada RPC após reconexão: %v", err)
-			}
-			
-			err = clientConn.Call(serviceMethod, args, reply)
-			if err != nil {
-				return fmt.Errorf("falha na segunda tentativa de chamada RPC: %v", err)
+		return nil, nil, err
+	}
+	results := make([]commandResult, len(cmds))
+	errs := make([]error, len(cmds))
+	for i := range cmds {
+		if i >= len(replies) {
+			errs[i] = errNotExecuted
+			continue
+		}
+		if replies[i].Error != nil {
+			errs[i] = replies[i].Error
+			continue
+		}
+		results[i] = commandResult{value: replies[i].Value, values: replies[i].Values, mean: replies[i].Mean, found: replies[i].Found, keys: replies[i].Keys}
+	}
+	return results, errs, nil
+}
+
+// exitCode classifica o erro de um comando.
+func exitCode(err error) int {
+	var usage usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usage):
+		return exitUsage
+	case client.IsConnectionError(err), errors.Is(err, context.DeadlineExceeded), client.Is(err, structures.CodeUnavailable):
+		return exitUnavailable
+	}
+	return exitFailed
+}
+
+// --- Saída ---
+
+// output exibe o resultado de cada comando.
+type output interface {
+	success(cmd command, r commandResult)
+	failure(cmd command, err error)
+}
+
+// proseOutput é a saída do modo interativo, em frases.
+type proseOutput struct{}
+
+func (proseOutput) success(cmd command, r commandResult) {
+	op := cmd.op
+	switch cmd.name {
+	case "APPEND":
+		fmt.Printf("Sucesso: Valor %d adicionado à lista %s\n", op.Value, op.ListID)
+	case "GET":
+		fmt.Printf("Sucesso: Lista %s, Índice %d -> Valor: %d\n", op.ListID, op.Index, r.value)
+	case "GETAT":
+		fmt.Printf("Sucesso: Lista %s, Índice %d em %s -> Valor: %d\n", op.ListID, op.Index, op.AsOf.Format(time.RFC3339Nano), r.value)
+	case "REMOVE":
+		fmt.Printf("Sucesso: Valor %d removido da lista %s\n", r.value, op.ListID)
+	case "SIZE":
+		fmt.Printf("Sucesso: Lista %s -> Tamanho: %d\n", op.ListID, r.value)
+	case "RANGE":
+		fmt.Printf("Sucesso: Lista %s, Índices %d a %d -> Valores: %v\n", op.ListID, op.Start, op.Stop, r.values)
+	case "DELETE":
+		fmt.Printf("Sucesso: Lista %s removida\n", op.ListID)
+	case "KEYS":
+		fmt.Printf("Sucesso: %d listas: %s\n", len(r.keys), strings.Join(r.keys, " "))
+	case "SUM":
+		fmt.Printf("Sucesso: Lista %s%s -> Soma: %d\n", op.ListID, spanText(op), r.value)
+	case "MIN":
+		fmt.Printf("Sucesso: Lista %s%s -> Mínimo: %d\n", op.ListID, spanText(op), r.value)
+	case "MAX":
+		fmt.Printf("Sucesso: Lista %s%s -> Máximo: %d\n", op.ListID, spanText(op), r.value)
+	case "AVG":
+		fmt.Printf("Sucesso: Lista %s%s -> Média: %g\n", op.ListID, spanText(op), r.mean)
+	case "COUNT":
+		predicate := ""
+		if op.Op != "" {
+			predicate = fmt.Sprintf(", valores %s %d", op.Op, op.Value)
+		}
+		fmt.Printf("Sucesso: Lista %s%s%s -> Quantidade: %d\n", op.ListID, spanText(op), predicate, r.value)
+	case "HISTOGRAM":
+		buckets := make([]string, len(r.values))
+		for i, count := range r.values {
+			switch {
+			case i == 0:
+				buckets[i] = fmt.Sprintf("< %d: %d", op.Bounds[0], count)
+			case i == len(op.Bounds):
+				buckets[i] = fmt.Sprintf(">= %d: %d", op.Bounds[i-1], count)
+			default:
+				buckets[i] = fmt.Sprintf("[%d, %d): %d", op.Bounds[i-1], op.Bounds[i], count)
 			}
-		} else {
-			return fmt.Errorf("erro RPC de negócio: %v", err)
 		}
+		fmt.Printf("Sucesso: Lista %s%s -> Histograma: %s\n", op.ListID, spanText(op), strings.Join(buckets, "; "))
+	case "INDEXOF", "LASTINDEXOF":
+		if r.value < 0 {
+			fmt.Printf("Sucesso: Lista %s, Valor %d -> não encontrado\n", op.ListID, op.Value)
+			return
+		}
+		fmt.Printf("Sucesso: Lista %s, Valor %d -> Índice: %d\n", op.ListID, op.Value, r.value)
+	case "CONTAINS":
+		answer := "não"
+		if r.found {
+			answer = "sim"
+		}
+		fmt.Printf("Sucesso: Lista %s, Valor %d -> Presente: %s\n", op.ListID, op.Value, answer)
+	case "FINDALL":
+		fmt.Printf("Sucesso: Lista %s, Valor %d -> Índices: %v\n", op.ListID, op.Value, r.values)
+	case "PING":
+		fmt.Printf("Sucesso: %s (uptime %v, LSN %d)\n", r.ping.ServerID, r.ping.Uptime.Truncate(time.Second), r.ping.LastLSN)
+	case "CONNECT":
+		fmt.Printf("Conexão estabelecida com %s em %s (uptime %v, LSN %d)!\n", r.ping.ServerID, cmd.args[0], r.ping.Uptime.Truncate(time.Second), r.ping.LastLSN)
+	case "WATCH":
+		if !r.exists {
+			fmt.Printf("Lista %s: inexistente\n", op.ListID)
+			return
+		}
+		change := ""
+		if len(r.removed) > 0 {
+			change += fmt.Sprintf(", removidos %v", r.removed)
+		}
+		if len(r.added) > 0 {
+			change += fmt.Sprintf(", adicionados %v", r.added)
+		}
+		fmt.Printf("Lista %s: tamanho %d%s\n", op.ListID, r.value, change)
 	}
-	return nil
 }
 
-func main() {
-	fmt.Println("Bem-vindo ao Cliente RemoteList RPC!")
-	fmt.Println("Comandos disponíveis:")
-	fmt.Println("  APPEND <list_id> <valor>")
-	fmt.Println("  GET <list_id> <indice>")
-	fmt.Println("  REMOVE <list_id>")
-	fmt.Println("  SIZE <list_id>")
-	fmt.Println("  EXIT (para sair)")
-	fmt.Println("---------------------------------")
+func (proseOutput) failure(cmd command, err error) {
+	var usage usageError
+	if errors.As(err, &usage) {
+		fmt.Println(err)
+		return
+	}
+	printError(cmd.name, err)
+}
 
-	reader := bufio.NewReader(os.Stdin)
+// spanText descreve o intervalo de uma agregação, se houver.
+func spanText(op structures.BatchOp) string {
+	if !op.Bounded {
+		return ""
+	}
+	return fmt.Sprintf(", Índices %d a %d", op.Start, op.Stop)
+}
 
-	if !ensureConnected(true) { 
-		os.Exit(1)
+// textOutput escreve uma linha por comando na saída padrão, só com o
+// resultado (no WATCH, uma por elemento removido ou acrescentado), e os erros
+// na saída de erros.
+type textOutput struct{}
+
+func (textOutput) success(cmd command, r commandResult) {
+	switch cmd.name {
+	case "APPEND", "DELETE":
+		fmt.Println("OK")
+	case "AVG":
+		fmt.Println(strconv.FormatFloat(r.mean, 'g', -1, 64))
+	case "CONTAINS":
+		fmt.Println(r.found)
+	case "RANGE", "HISTOGRAM", "FINDALL":
+		values := make([]string, len(r.values))
+		for i, v := range r.values {
+			values[i] = strconv.Itoa(v)
+		}
+		fmt.Println(strings.Join(values, " "))
+	case "KEYS":
+		fmt.Println(strings.Join(r.keys, " "))
+	case "PING", "CONNECT":
+		fmt.Println(r.ping.ServerID)
+	case "WATCH":
+		for _, v := range r.removed {
+			fmt.Println("-", v)
+		}
+		for _, v := range r.added {
+			fmt.Println("+", v)
+		}
+	default:
+		fmt.Println(r.value)
 	}
+}
 
-	for {
-		fmt.Print("> ")
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input)
+func (textOutput) failure(cmd command, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", strings.Join(append([]string{cmd.name}, cmd.args...), " "), err)
+}
+
+// jsonOutput escreve um objeto JSON por comando (JSON lines), inclusive para
+// os que falharam.
+type jsonOutput struct {
+	enc *json.Encoder
+}
 
-		parts := strings.Fields(input)
+// jsonLine é a linha de um comando na saída JSON.
+type jsonLine struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	OK      bool              `json:"ok"`
+	Result  any               `json:"result,omitempty"`
+	Error   *structures.Error `json:"error,omitempty"`
+	Skipped bool              `json:"skipped,omitempty"` // Operação de lote não executada.
+}
 
-		if len(parts) == 0 {
-			continue
+func newJSONOutput() jsonOutput {
+	return jsonOutput{enc: json.NewEncoder(os.Stdout)}
+}
+
+func (o jsonOutput) success(cmd command, r commandResult) {
+	line := jsonLine{Command: cmd.name, Args: cmd.args, OK: true}
+	switch cmd.name {
+	case "APPEND", "DELETE":
+	case "AVG":
+		line.Result = r.mean
+	case "CONTAINS":
+		line.Result = r.found
+	case "RANGE", "HISTOGRAM", "FINDALL":
+		line.Result = append([]int{}, r.values...)
+	case "KEYS":
+		line.Result = append([]string{}, r.keys...)
+	case "WATCH":
+		line.Result = map[string]any{
+			"size":    r.value,
+			"exists":  r.exists,
+			"removed": append([]int{}, r.removed...),
+			"added":   append([]int{}, r.added...),
+		}
+	case "PING", "CONNECT":
+		line.Result = map[string]any{
+			"server_id":  r.ping.ServerID,
+			"started_at": r.ping.StartedAt,
+			"last_lsn":   r.ping.LastLSN,
+			"ready":      r.ping.Ready,
 		}
+	default:
+		line.Result = r.value
+	}
+	o.write(line)
+}
 
-		command := strings.ToUpper(parts[0])
+func (o jsonOutput) failure(cmd command, err error) {
+	line := jsonLine{Command: cmd.name, Args: cmd.args}
+	if errors.Is(err, errNotExecuted) {
+		line.Skipped = true
+		o.write(line)
+		return
+	}
+	typed, ok := client.AsError(err)
+	if !ok {
+		code := structures.CodeInternal
+		switch exitCode(err) {
+		case exitUsage:
+			code = structures.CodeInvalidArgument
+		case exitUnavailable:
+			code = structures.CodeUnavailable
+		}
+		typed = &structures.Error{Code: code, Message: err.Error()}
+	}
+	line.Error = typed
+	o.write(line)
+}
 
-		var err error 
+func (o jsonOutput) write(line jsonLine) {
+	if line.Args == nil {
+		line.Args = []string{}
+	}
+	o.enc.Encode(line)
+}
 
-		switch command {
-		case "APPEND":
-			if len(parts) != 3 {
-				fmt.Println("Uso: APPEND <list_id> <valor>")
-				continue
-			}
-			listID := parts[1]
-			value, parseErr := strconv.Atoi(parts[2])
-			if parseErr != nil {
-				fmt.Println("Erro: Valor deve ser um número inteiro.", parseErr)
-				continue
-			}
+// --- Sessão ---
+
+// session executa as linhas de comando, no modo interativo ou não: comandos
+// de lista, blocos BATCH ... END, CONNECT, WATCH e EXIT.
+type session struct {
+	client    *client.Client
+	addr      string    // Endereço do servidor atual.
+	notices   io.Writer // Mensagens de reconexão e avisos.
+	out       output
+	keepGoing bool // Se false, para no primeiro comando com erro (modos não interativos).
+	status    int  // Maior código de saída entre os comandos executados.
 
-			var replyBool bool
-			err = callRPC("RemoteList.Append", structures.AppendArgs{ListID: listID, Value: value}, &replyBool)
+	inBatch   bool
+	batch     []command
+	batchLine int // Linha do BATCH aberto, para a mensagem de bloco sem END.
+
+	listIDs   []string  // IDs das listas do servidor, para a complementação.
+	listIDsAt time.Time // Quando listIDs foi consultado.
+}
+
+// stopped informa se a sessão deve parar por causa de um erro.
+func (s *session) stopped() bool {
+	return s.status != exitOK && !s.keepGoing
+}
+
+// fail registra a falha de um comando.
+func (s *session) fail(cmd command, err error) {
+	s.out.failure(cmd, err)
+	if code := exitCode(err); code > s.status && !errors.Is(err, errNotExecuted) {
+		s.status = code
+	}
+}
+
+// handle processa uma linha; number, se positivo, identifica a linha nas
+// mensagens de erro de uso. Retorna false no EXIT.
+func (s *session) handle(line string, number int) bool {
+	parts := strings.Fields(line)
+	if len(parts) == 0 || strings.HasPrefix(parts[0], "#") {
+		return true
+	}
+	usageFailure := func(cmd command, err error) {
+		if number > 0 {
+			err = usageError(fmt.Sprintf("linha %d: %v", number, err))
+		}
+		s.fail(cmd, err)
+	}
+
+	name := strings.ToUpper(parts[0])
+	control := command{name: name, args: parts[1:]}
+	switch {
+	case name == "EXIT" && !s.inBatch:
+		return false
+	case name == "BATCH" && len(parts) == 1:
+		if s.inBatch {
+			usageFailure(control, usageError("Erro: BATCH dentro de outro BATCH."))
+			return true
+		}
+		s.inBatch, s.batch, s.batchLine = true, nil, number
+	case name == "END" && len(parts) == 1:
+		if !s.inBatch {
+			usageFailure(control, usageError("Erro: END sem BATCH."))
+			return true
+		}
+		s.inBatch = false
+		s.runBatch(s.batch)
+	case name == "EXIT", name == "BATCH", name == "END":
+		usageFailure(control, usageError(fmt.Sprintf("Erro: %s não é permitido aqui.", name)))
+	default:
+		cmd, err := parseCommand(parts)
+		switch {
+		case err != nil:
+			usageFailure(cmd, err)
+		case s.inBatch && (cmd.name == "PING" || cmd.name == "WATCH" || cmd.name == "CONNECT"):
+			usageFailure(cmd, usageError(fmt.Sprintf("Erro: %s não é permitido em BATCH.", cmd.name)))
+		case s.inBatch:
+			s.batch = append(s.batch, cmd)
+		case cmd.name == "CONNECT":
+			s.connect(cmd)
+		case cmd.name == "WATCH":
+			s.watch(cmd)
+		default:
+			r, err := execute(s.client, cmd)
 			if err != nil {
-				fmt.Printf("Erro no APPEND: %v\n", err)
+				s.fail(cmd, err)
 			} else {
-				fmt.Printf("Sucesso: Valor %d adicionado à lista %s\n", value, listID)
+				s.out.success(cmd, r)
 			}
+		}
+	}
+	return true
+}
+
+// connect troca o servidor da sessão pelo endereço do comando, se ele
+// responder; caso contrário, a sessão continua no servidor atual.
+func (s *session) connect(cmd command) {
+	addr := cmd.args[0]
+	rlClient, err := newClient(addr, s.notices)
+	if err != nil {
+		s.fail(cmd, usageError(fmt.Sprintf("Erro ao criar cliente: %v", err)))
+		return
+	}
+	var r commandResult
+	ctx, cancel := commandContext()
+	r.ping, err = rlClient.Ping(ctx)
+	cancel()
+	if err != nil {
+		rlClient.Close()
+		s.fail(cmd, err)
+		return
+	}
+	s.client.Close()
+	s.client, s.addr = rlClient, addr
+	s.listIDs, s.listIDsAt = nil, time.Time{}
+	s.out.success(cmd, r)
+}
+
+// watch acompanha a lista até o Ctrl-C (SIGINT), consultando-a a cada
+// -watch-interval e exibindo o estado inicial e cada mudança. Como as listas
+// só mudam no fim, a mudança é o que difere após o maior prefixo comum.
+func (s *session) watch(cmd command) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	fmt.Fprintf(s.notices, "Acompanhando a lista %s a cada %v (Ctrl-C para parar).\n", cmd.op.ListID, *watchIntervalFlag)
 
-		case "GET":
-			if len(parts) != 3 {
-				fmt.Println("Uso: GET <list_id> <indice>")
-				continue
+	ticker := time.NewTicker(*watchIntervalFlag)
+	defer ticker.Stop()
+	var previous []int
+	existed := false
+	for first := true; ; first = false {
+		current, err := execute(s.client, cmd)
+		exists := err == nil
+		if err != nil && !client.Is(err, structures.CodeNotFound) {
+			s.fail(cmd, err)
+			return
+		}
+		common := 0
+		for common < len(previous) && common < len(current.values) && previous[common] == current.values[common] {
+			common++
+		}
+		r := commandResult{value: len(current.values), removed: previous[common:], added: current.values[common:], exists: exists}
+		if first || exists != existed || len(r.removed) > 0 || len(r.added) > 0 {
+			s.out.success(cmd, r)
+		}
+		previous, existed = current.values, exists
+
+		select {
+		case <-interrupt:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// complete é o Completer do modo interativo: comandos na primeira palavra e
+// IDs de listas na segunda, para os comandos que recebem uma lista.
+func (s *session) complete(line string) (start int, candidates []string) {
+	start = strings.LastIndex(line, " ") + 1
+	word := line[start:]
+	fields := strings.Fields(line[:start])
+	switch {
+	case len(fields) == 0:
+		names := []string{"BATCH", "END", "EXIT"}
+		for _, c := range commandUsage {
+			names = append(names, c.name)
+		}
+		sort.Strings(names)
+		lower := word != "" && word == strings.ToLower(word)
+		for _, name := range names {
+			if strings.HasPrefix(name, strings.ToUpper(word)) {
+				if lower {
+					name = strings.ToLower(name)
+				}
+				candidates = append(candidates, name)
 			}
-			listID := parts[1]
-			index, parseErr := strconv.Atoi(parts[2])
-			if parseErr != nil {
-				fmt.Println("Erro: Índice deve ser um número inteiro.", parseErr)
-				continue
+		}
+	case len(fields) == 1:
+		for _, c := range commandUsage {
+			if c.name == strings.ToUpper(fields[0]) && strings.Contains(c.usage, "<list_id>") {
+				for _, listID := range s.knownListIDs() {
+					if strings.HasPrefix(listID, word) {
+						candidates = append(candidates, listID)
+					}
+				}
 			}
+		}
+	}
+	return start, candidates
+}
 
-			var value int
-			err = callRPC("RemoteList.Get", structures.GetArgs{ListID: listID, Index: index}, &value)
-			if err != nil {
-				fmt.Printf("Erro no GET: %v\n", err)
-			} else {
-				fmt.Printf("Sucesso: Lista %s, Índice %d -> Valor: %d\n", listID, index, value)
-			}
+// knownListIDs retorna os IDs das listas do servidor, consultados com KEYS
+// no máximo a cada poucos segundos. Falhas na consulta são ignoradas: a
+// complementação só fica sem as listas.
+func (s *session) knownListIDs() []string {
+	if time.Since(s.listIDsAt) < 5*time.Second {
+		return s.listIDs
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if keys, err := s.client.Keys(ctx, ""); err == nil {
+		s.listIDs, s.listIDsAt = keys, time.Now()
+	}
+	return s.listIDs
+}
 
-		case "REMOVE":
-			if len(parts) != 2 {
-				fmt.Println("Uso: REMOVE <list_id>")
-				continue
-			}
-			listID := parts[1]
+// runBatch envia os comandos de um bloco BATCH em uma única chamada e exibe
+// os resultados na ordem do bloco.
+func (s *session) runBatch(cmds []command) {
+	if len(cmds) == 0 {
+		return
+	}
+	results, errs, err := executeBatch(s.client, cmds, !s.keepGoing)
+	if err != nil {
+		s.fail(command{name: "BATCH"}, err)
+		return
+	}
+	for i, cmd := range cmds {
+		if errs[i] != nil {
+			s.fail(cmd, errs[i])
+		} else {
+			s.out.success(cmd, results[i])
+		}
+	}
+}
 
-			var removedValue int
-			err = callRPC("RemoteList.Remove", structures.RemoveArgs{ListID: listID}, &removedValue)
-			if err != nil {
-				fmt.Printf("Erro no REMOVE: %v\n", err)
-			} else {
-				fmt.Printf("Sucesso: Valor %d removido da lista %s\n", removedValue, listID)
-			}
+// finish encerra a sessão e retorna o código de saída; um BATCH sem END é
+// erro de uso (os comandos dele não são enviados).
+func (s *session) finish() int {
+	if s.inBatch {
+		err := usageError("Erro: BATCH sem END; os comandos do bloco não foram enviados.")
+		if s.batchLine > 0 {
+			err = usageError(fmt.Sprintf("linha %d: %v", s.batchLine, err))
+		}
+		s.inBatch = false
+		s.fail(command{name: "BATCH"}, err)
+	}
+	return s.status
+}
 
-		case "SIZE":
-			if len(parts) != 2 {
-				fmt.Println("Uso: SIZE <list_id>")
-				continue
-			}
-			listID := parts[1]
+// run executa os comandos lidos de r, um por linha, até o fim, um EXIT ou
+// (sem -keep-going) o primeiro erro.
+func (s *session) run(r io.Reader) int {
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		if !s.handle(scanner.Text(), number) {
+			return s.finish()
+		}
+		if s.stopped() {
+			return s.status // Um BATCH aberto é descartado sem outra mensagem.
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Erro ao ler comandos:", err)
+		if s.status < exitUsage {
+			s.status = exitUsage
+		}
+	}
+	return s.finish()
+}
 
-			var size int
-			err = callRPC("RemoteList.Size", structures.SizeArgs{ListID: listID}, &size)
-			if err != nil {
-				fmt.Printf("Erro no SIZE: %v\n", err)
-			} else {
-				fmt.Printf("Sucesso: Lista %s -> Tamanho: %d\n", listID, size)
-			}
+// runScript executa comandos sem interação: dos argumentos (um comando, ou
+// "batch" seguido de um comando por argumento), do arquivo de -f ou da
+// entrada padrão. Retorna o código de saída.
+func runScript(args []string) int {
+	var out output
+	switch *outputFlag {
+	case "text":
+		out = textOutput{}
+	case "json":
+		out = newJSONOutput()
+	default:
+		fmt.Fprintf(os.Stderr, "Formato de saída inválido: %s (use text ou json).\n", *outputFlag)
+		return exitUsage
+	}
+
+	rlClient, err := newClient(*addrFlag, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Erro ao criar cliente:", err)
+		return exitUsage
+	}
+	defer rlClient.Close()
+	s := &session{client: rlClient, addr: *addrFlag, notices: os.Stderr, out: out, keepGoing: *keepGoingFlag}
 
-		case "EXIT":
+	switch {
+	case len(args) > 0 && strings.EqualFold(args[0], "batch"):
+		s.handle("BATCH", 0)
+		for _, line := range args[1:] {
+			s.handle(line, 0)
+		}
+		s.handle("END", 0)
+		return s.finish()
+	case len(args) > 0:
+		s.handle(strings.Join(args, " "), 0)
+		return s.finish()
+	case *fileFlag != "" && *fileFlag != "-":
+		f, err := os.Open(*fileFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Erro ao abrir arquivo de comandos:", err)
+			return exitUsage
+		}
+		defer f.Close()
+		return s.run(f)
+	}
+	return s.run(os.Stdin)
+}
+
+// isTerminal informa se f é um terminal (e não um arquivo ou pipe).
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func main() {
+	flag.Parse()
+
+	switch {
+	case flag.Arg(0) == "admin":
+		os.Exit(runAdmin(flag.Args()[1:]))
+	case flag.NArg() > 0 || *fileFlag != "" || !isTerminal(os.Stdin):
+		os.Exit(runScript(flag.Args()))
+	}
+
+	fmt.Println("Bem-vindo ao Cliente RemoteList RPC!")
+	fmt.Println("Comandos disponíveis:")
+	for _, c := range commandUsage {
+		fmt.Println("  " + c.usage)
+	}
+	fmt.Println("  BATCH ... END (envia os comandos do bloco de uma vez)")
+	fmt.Println("  EXIT (para sair)")
+	fmt.Println("---------------------------------")
+
+	rlClient, err := newClient(*addrFlag, os.Stdout)
+	if err != nil {
+		fmt.Println("Erro ao criar cliente:", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := commandContext()
+	pingReply, err := rlClient.Ping(ctx)
+	cancel()
+	if err != nil {
+		printError("CONNECT", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Conexão estabelecida com %s (uptime %v, LSN %d)!\n", pingReply.ServerID, pingReply.Uptime.Truncate(time.Second), pingReply.LastLSN)
+
+	s := &session{client: rlClient, addr: *addrFlag, notices: os.Stdout, out: proseOutput{}, keepGoing: true}
+	defer func() { s.client.Close() }()
+
+	editor := lineedit.New(os.Stdin, os.Stdout)
+	editor.Complete = s.complete
+	if *historyFlag != "" {
+		if err := editor.LoadHistory(*historyFlag); err != nil {
+			fmt.Println("Aviso: histórico não carregado:", err)
+		}
+	}
+	for {
+		prompt := s.addr + "> "
+		if s.inBatch {
+			prompt = s.addr + "... "
+		}
+		input, err := editor.ReadLine(prompt)
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			continue
+		}
+		if err != nil {
+			fmt.Println("Saindo do cliente.")
+			return
+		}
+		if err := editor.AddHistory(input); err != nil {
+			fmt.Println("Aviso: histórico não gravado:", err)
+		}
+		if !s.handle(input, 0) {
 			fmt.Println("Saindo do cliente.")
-			if clientConn != nil {
-				clientConn.Close()
-			}
 			return
-
-		default:
-			fmt.Println("Comando desconhecido. Use APPEND, GET, REMOVE, SIZE ou EXIT.")
 		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// Métodos do serviço Admin. Exigem um token com a permissão "admin" sobre
+// todas as listas ("*") quando a autenticação está ativa.
+
+// ForceSnapshot pede ao servidor que salve um snapshot imediatamente.
+func (c *Client) ForceSnapshot(ctx context.Context) (structures.SnapshotReply, error) {
+	var reply structures.SnapshotReply
+	err := c.call(ctx, "Admin.ForceSnapshot", true, structures.AdminArgs{}, &reply)
+	return reply, err
+}
+
+// CompactLog salva um snapshot e remove do log as entradas cobertas por ele.
+func (c *Client) CompactLog(ctx context.Context) (structures.CompactLogReply, error) {
+	var reply structures.CompactLogReply
+	err := c.call(ctx, "Admin.CompactLog", true, structures.AdminArgs{}, &reply)
+	return reply, err
+}
+
+// Stats retorna o estado geral do servidor.
+func (c *Client) Stats(ctx context.Context) (structures.StatsReply, error) {
+	var reply structures.StatsReply
+	err := c.call(ctx, "Admin.Stats", true, structures.AdminArgs{}, &reply)
+	return reply, err
+}
+
+// ListClients retorna as conexões de clientes abertas no servidor.
+func (c *Client) ListClients(ctx context.Context) ([]structures.ClientInfo, error) {
+	var reply []structures.ClientInfo
+	err := c.call(ctx, "Admin.ListClients", true, structures.AdminArgs{}, &reply)
+	return reply, err
+}
+
+// SetLogLevel troca o nível de log do servidor e retorna o nível anterior.
+func (c *Client) SetLogLevel(ctx context.Context, level string) (string, error) {
+	var previous string
+	err := c.call(ctx, "Admin.SetLogLevel", true, structures.SetLogLevelArgs{Level: level}, &previous)
+	return previous, err
+}
+
+// ReloadConfig pede ao servidor que releia o arquivo de configuração.
+func (c *Client) ReloadConfig(ctx context.Context) (structures.ReloadConfigReply, error) {
+	var reply structures.ReloadConfigReply
+	err := c.call(ctx, "Admin.ReloadConfig", true, structures.AdminArgs{}, &reply)
+	return reply, err
+}
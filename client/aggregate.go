@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// Span escolhe os elementos considerados por uma agregação: a lista inteira
+// (WholeList) ou um intervalo de índices (Between).
+type Span struct {
+	bounded     bool
+	start, stop int
+}
+
+// WholeList considera todos os elementos da lista.
+func WholeList() Span {
+	return Span{}
+}
+
+// Between considera os elementos entre start e stop (inclusivos, aceitando
+// índices negativos), como em Range.
+func Between(start, stop int) Span {
+	return Span{bounded: true, start: start, stop: stop}
+}
+
+func (s Span) args(listID string) structures.AggregateArgs {
+	return structures.AggregateArgs{ListID: listID, Bounded: s.bounded, Start: s.start, Stop: s.stop}
+}
+
+// Sum retorna a soma dos elementos. Falha com OUT_OF_RANGE se a soma não couber em 64 bits.
+func (c *Client) Sum(ctx context.Context, listID string, span Span) (int, error) {
+	var sum int
+	err := c.call(ctx, "RemoteList.Sum", true, span.args(listID), &sum)
+	return sum, err
+}
+
+// Min retorna o menor elemento. Falha com EMPTY se não houver elementos.
+func (c *Client) Min(ctx context.Context, listID string, span Span) (int, error) {
+	var value int
+	err := c.call(ctx, "RemoteList.Min", true, span.args(listID), &value)
+	return value, err
+}
+
+// Max retorna o maior elemento. Falha com EMPTY se não houver elementos.
+func (c *Client) Max(ctx context.Context, listID string, span Span) (int, error) {
+	var value int
+	err := c.call(ctx, "RemoteList.Max", true, span.args(listID), &value)
+	return value, err
+}
+
+// Avg retorna a média dos elementos. Falha com EMPTY se não houver elementos.
+func (c *Client) Avg(ctx context.Context, listID string, span Span) (float64, error) {
+	var mean float64
+	err := c.call(ctx, "RemoteList.Avg", true, span.args(listID), &mean)
+	return mean, err
+}
+
+// Count retorna a quantidade de elementos v com "v op value" verdadeiro (op é
+// "<", "<=", ">", ">=", "==" ou "!="; vazio conta todos).
+func (c *Client) Count(ctx context.Context, listID string, span Span, op string, value int) (int, error) {
+	var count int
+	err := c.call(ctx, "RemoteList.Count", true, structures.CountArgs{
+		ListID: listID, Bounded: span.bounded, Start: span.start, Stop: span.stop, Op: op, Value: value,
+	}, &count)
+	return count, err
+}
+
+// Histogram retorna a quantidade de elementos em cada uma das len(bounds)+1
+// faixas definidas pelos limites, em ordem crescente (ver structures.HistogramArgs).
+func (c *Client) Histogram(ctx context.Context, listID string, span Span, bounds []int) ([]int, error) {
+	var counts []int
+	err := c.call(ctx, "RemoteList.Histogram", true, structures.HistogramArgs{
+		ListID: listID, Bounded: span.bounded, Start: span.start, Stop: span.stop, Bounds: bounds,
+	}, &counts)
+	return counts, err
+}
@@ -0,0 +1,263 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"reflect"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// errClientClosed é retornado por chamadas feitas após Close.
+var errClientClosed = errors.New("cliente fechado")
+
+// Options configura um Client.
+type Options struct {
+	Addresses      []string      // Endereços do servidor, tentados em ordem para failover.
+	PoolSize       int           // Quantidade de conexões mantidas abertas.
+	DialTimeout    time.Duration // Tempo máximo para abrir uma conexão.
+	MaxAttempts    int           // Tentativas por chamada; 0 = sem limite (apenas o contexto limita).
+	InitialBackoff time.Duration // Atraso base entre tentativas.
+	MaxBackoff     time.Duration // Atraso máximo entre tentativas.
+	TLSConfig      *tls.Config   // Se definido, conecta via TLS (ver tlsutil.ClientConfig).
+	Token          string        // Token de acesso, quando o servidor exige autenticação.
+
+	// OnRetry, se definido, é chamado antes de cada nova tentativa.
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// DefaultOptions retorna opções padrão para o endereço informado.
+func DefaultOptions(addresses ...string) Options {
+	if len(addresses) == 0 {
+		addresses = []string{"localhost:1234"}
+	}
+	return Options{
+		Addresses:      addresses,
+		PoolSize:       4,
+		DialTimeout:    5 * time.Second,
+		MaxAttempts:    4,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+	}
+}
+
+// Client é um cliente goroutine-safe do serviço RemoteList, com pool de
+// conexões, reconexão automática, backoff exponencial e failover.
+type Client struct {
+	opts Options
+	pool *pool
+}
+
+// New cria um Client. Nenhuma conexão é aberta até a primeira chamada.
+func New(opts Options) (*Client, error) {
+	if len(opts.Addresses) == 0 {
+		return nil, fmt.Errorf("nenhum endereço de servidor informado")
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 1
+	}
+	return &Client{
+		opts: opts,
+		pool: newPool(opts.Addresses, opts.PoolSize, opts.DialTimeout, opts.TLSConfig, opts.Token),
+	}, nil
+}
+
+// Dial cria um Client com as opções padrão e verifica a conexão com o servidor.
+func Dial(ctx context.Context, addresses ...string) (*Client, error) {
+	c, err := New(DefaultOptions(addresses...))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.Ping(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close fecha todas as conexões do pool.
+func (c *Client) Close() error {
+	c.pool.close()
+	return nil
+}
+
+// call executa uma chamada RPC com retentativas. Chamadas não idempotentes
+// só são refeitas quando a falha ocorreu antes do envio (ao discar) ou quando
+// o servidor as recusou sem executar (com espera sugerida), para evitar, por
+// exemplo, um Append duplicado quando apenas a resposta se perdeu.
+func (c *Client) call(ctx context.Context, method string, idempotent bool, args interface{}, reply interface{}) error {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		sent, err := c.callOnce(ctx, method, args, reply)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		// Chamadas recusadas por limite de taxa ou sobrecarga não foram executadas
+		// e podem ser refeitas (mesmo as não idempotentes), após a espera sugerida.
+		retryAfter := RetryAfter(err)
+		switch {
+		case retryAfter > 0:
+			err = Normalize(err)
+		case !IsConnectionError(err):
+			return Normalize(err)
+		case sent && !idempotent:
+			return fmt.Errorf("conexão perdida durante %s; a operação pode ter sido aplicada: %w", method, err)
+		}
+		lastErr = err
+
+		if c.opts.MaxAttempts > 0 && attempt >= c.opts.MaxAttempts {
+			break
+		}
+
+		delay := backoff(attempt, c.opts.InitialBackoff, c.opts.MaxBackoff)
+		if delay < retryAfter {
+			delay = retryAfter
+		}
+		if c.opts.OnRetry != nil {
+			c.opts.OnRetry(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (último erro: %v)", ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+	}
+	return fmt.Errorf("falha em %s após %d tentativas: %w", method, c.opts.MaxAttempts, lastErr)
+}
+
+// callOnce faz uma única tentativa. sent indica se a requisição chegou a ser
+// enviada. A resposta é decodificada em um valor novo, copiado para reply só
+// se a chamada terminar com sucesso: com o contexto cancelado, o net/rpc
+// ainda pode decodificar a resposta atrasada, e ela não pode escrever em
+// reply enquanto o chamador (ou a próxima tentativa) o usa.
+func (c *Client) callOnce(ctx context.Context, method string, args interface{}, reply interface{}) (sent bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s, err := c.pool.pick()
+	if err != nil {
+		return false, err
+	}
+	conn, _, err := c.pool.conn(ctx, s)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return false, err
+	}
+
+	attempt := reflect.New(reflect.TypeOf(reply).Elem())
+	call := conn.Go(method, args, attempt.Interface(), make(chan *rpc.Call, 1))
+	select {
+	case <-ctx.Done():
+		// A resposta, se chegar, vai para attempt e é descartada; a conexão
+		// continua utilizável.
+		return true, ctx.Err()
+	case <-call.Done:
+	}
+
+	if call.Error != nil {
+		if IsConnectionError(call.Error) {
+			c.pool.discard(s, conn)
+		}
+		return true, call.Error
+	}
+	reflect.ValueOf(reply).Elem().Set(attempt.Elem())
+	return true, nil
+}
+
+// Ping verifica a conexão e retorna a identidade e o estado do servidor.
+func (c *Client) Ping(ctx context.Context) (structures.PingReply, error) {
+	var reply structures.PingReply
+	err := c.call(ctx, "RemoteList.Ping", true, structures.PingArgs{}, &reply)
+	return reply, err
+}
+
+// Append adiciona um valor ao final da lista.
+func (c *Client) Append(ctx context.Context, listID string, value int) error {
+	var ok bool
+	return c.call(ctx, "RemoteList.Append", false, structures.AppendArgs{ListID: listID, Value: value}, &ok)
+}
+
+// Push adiciona os valores ao final da lista de uma vez (sem outras
+// alterações intercaladas) e retorna o tamanho dela em seguida.
+func (c *Client) Push(ctx context.Context, listID string, values ...int) (int, error) {
+	var size int
+	err := c.call(ctx, "RemoteList.Push", false, structures.PushArgs{ListID: listID, Values: values}, &size)
+	return size, err
+}
+
+// Get retorna o valor na posição informada da lista.
+func (c *Client) Get(ctx context.Context, listID string, index int) (int, error) {
+	var value int
+	err := c.call(ctx, "RemoteList.Get", true, structures.GetArgs{ListID: listID, Index: index}, &value)
+	return value, err
+}
+
+// GetAt retorna o valor que estava na posição informada da lista no instante
+// asOf. Exige o histórico ativo no servidor e asOf dentro do período retido.
+func (c *Client) GetAt(ctx context.Context, listID string, index int, asOf time.Time) (int, error) {
+	var value int
+	err := c.call(ctx, "RemoteList.GetAt", true, structures.GetAtArgs{ListID: listID, Index: index, AsOf: asOf}, &value)
+	return value, err
+}
+
+// Remove remove e retorna o último elemento da lista.
+func (c *Client) Remove(ctx context.Context, listID string) (int, error) {
+	var value int
+	err := c.call(ctx, "RemoteList.Remove", false, structures.RemoveArgs{ListID: listID}, &value)
+	return value, err
+}
+
+// Size retorna a quantidade de elementos da lista.
+func (c *Client) Size(ctx context.Context, listID string) (int, error) {
+	var size int
+	err := c.call(ctx, "RemoteList.Size", true, structures.SizeArgs{ListID: listID}, &size)
+	return size, err
+}
+
+// Range retorna os elementos entre start e stop (inclusivos, aceitando índices negativos).
+func (c *Client) Range(ctx context.Context, listID string, start, stop int) ([]int, error) {
+	var values []int
+	err := c.call(ctx, "RemoteList.Range", true, structures.RangeArgs{ListID: listID, Start: start, Stop: stop}, &values)
+	return values, err
+}
+
+// Delete remove a lista inteira.
+func (c *Client) Delete(ctx context.Context, listID string) error {
+	var ok bool
+	return c.call(ctx, "RemoteList.Delete", false, structures.DeleteArgs{ListID: listID}, &ok)
+}
+
+// Batch envia as operações em uma única chamada e retorna um resultado por
+// operação executada; os erros de cada operação vêm em BatchResult.Error, e o
+// erro retornado é só o da chamada. O lote só é refeito automaticamente se
+// todas as operações forem leituras.
+func (c *Client) Batch(ctx context.Context, ops []structures.BatchOp, stopOnError bool) ([]structures.BatchResult, error) {
+	idempotent := true
+	for _, op := range ops {
+		idempotent = idempotent && op.ReadOnly()
+	}
+	var reply structures.BatchReply
+	err := c.call(ctx, "RemoteList.Batch", idempotent, structures.BatchArgs{Ops: ops, StopOnError: stopOnError}, &reply)
+	return reply.Results, err
+}
+
+// Keys retorna os IDs das listas que casam com o padrão (vazio equivale a "*").
+func (c *Client) Keys(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	err := c.call(ctx, "RemoteList.Keys", true, structures.KeysArgs{Pattern: pattern}, &keys)
+	return keys, err
+}
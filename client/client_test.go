@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/rpc"
+	"reflect"
+	"testing"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// slowList responde Range só depois de release ser fechado, e avisa em
+// answered quando a resposta foi enviada.
+type slowList struct {
+	release  chan struct{}
+	answered chan struct{}
+}
+
+func (s *slowList) Range(args structures.RangeArgs, reply *[]int) error {
+	<-s.release
+	*reply = []int{1, 2, 3}
+	select {
+	case s.answered <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// serve atende o serviço "RemoteList" em net/rpc sobre HTTP CONNECT, como o
+// servidor. Retorna o endereço.
+func serve(t *testing.T, service any) string {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.RegisterName("RemoteList", service); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := &http.Server{Handler: server}
+	go httpServer.Serve(ln)
+	t.Cleanup(func() { httpServer.Close() })
+	return ln.Addr().String()
+}
+
+// TestCanceledCallDoesNotWriteReply confere que a resposta de uma chamada
+// cancelada, chegando depois, não é escrita no reply do chamador.
+func TestCanceledCallDoesNotWriteReply(t *testing.T) {
+	slow := &slowList{release: make(chan struct{}), answered: make(chan struct{}, 1)}
+	opts := DefaultOptions(serve(t, slow))
+	opts.MaxAttempts = 1
+	c, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	reply := []int{7}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = c.call(ctx, "RemoteList.Range", true, structures.RangeArgs{ListID: "a", Stop: -1}, &reply)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("erro %v, esperado DeadlineExceeded", err)
+	}
+
+	// O chamador volta a usar reply enquanto a resposta atrasada chega.
+	close(slow.release)
+	deadline := time.After(5 * time.Second)
+	for done := false; !done; {
+		reply[0]++
+		select {
+		case <-slow.answered:
+			done = true
+		case <-deadline:
+			t.Fatal("o servidor não respondeu")
+		default:
+		}
+	}
+	// Dá tempo para o cliente decodificar a resposta atrasada.
+	time.Sleep(50 * time.Millisecond)
+	if len(reply) != 1 {
+		t.Fatalf("reply alterado pela resposta da chamada cancelada: %v", reply)
+	}
+
+	// Uma chamada que termina escreve a resposta em reply.
+	if err := c.call(context.Background(), "RemoteList.Range", true, structures.RangeArgs{ListID: "a", Stop: -1}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(reply, want) {
+		t.Fatalf("reply = %v, esperado %v", reply, want)
+	}
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/rpc"
+	"strings"
+	"sync"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// rpcConnectedStatus é a resposta do servidor net/rpc ao CONNECT do handshake HTTP.
+const rpcConnectedStatus = "200 Connected to Go RPC"
+
+// dialHTTP abre uma conexão net/rpc sobre HTTP respeitando o contexto,
+// reproduzindo o handshake de rpc.DialHTTP. Com tlsConfig, a conexão usa TLS;
+// com token, o CONNECT leva o cabeçalho "Authorization: Bearer <token>".
+func dialHTTP(ctx context.Context, dialer *net.Dialer, addr string, tlsConfig *tls.Config, token string) (*rpc.Client, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		cfg := tlsConfig.Clone()
+		if cfg.ServerName == "" {
+			host, _, _ := net.SplitHostPort(addr)
+			cfg.ServerName = host
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, &net.OpError{Op: "tls-handshake", Net: "tcp", Addr: conn.RemoteAddr(), Err: err}
+		}
+		conn = tlsConn
+	}
+
+	// O handshake também deve respeitar o prazo do contexto.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	request := "CONNECT " + rpc.DefaultRPCPath + " HTTP/1.0\n"
+	if token != "" {
+		request += "Authorization: Bearer " + token + "\n"
+	}
+	io.WriteString(conn, request+"\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		// Credencial recusada: não é falha de conexão e não deve ser refeita.
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		conn.Close()
+		if typed := structures.ParseError(strings.TrimSpace(string(body))); typed != nil {
+			return nil, typed
+		}
+		return nil, structures.NewError(structures.CodeUnauthorized, "conexão recusada: %s", resp.Status)
+	}
+	if err == nil && resp.Status != rpcConnectedStatus {
+		err = fmt.Errorf("resposta inesperada do servidor: %s", resp.Status)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, &net.OpError{Op: "dial-http", Net: "tcp", Addr: conn.RemoteAddr(), Err: err}
+	}
+
+	conn.SetDeadline(time.Time{})
+	return rpc.NewClient(conn), nil
+}
+
+// IsConnectionError verifica se um erro indica falha na conexão (e não um erro do servidor).
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Erros tipados vêm do próprio servidor, logo a conexão está ativa.
+	if _, ok := AsError(err); ok {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, rpc.ErrShutdown) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "connection reset by peer") ||
+		strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "broken pipe")
+}
+
+// slot é uma posição do pool, com uma conexão multiplexada criada sob demanda.
+type slot struct {
+	mu   sync.Mutex
+	conn *rpc.Client
+	addr string
+}
+
+// pool mantém um número fixo de conexões net/rpc. Cada conexão suporta
+// chamadas concorrentes, e as chamadas são distribuídas em round-robin.
+type pool struct {
+	slots     []*slot
+	next      uint32
+	nextMu    sync.Mutex
+	addrs     []string
+	preferred int // Índice do último endereço que respondeu; protegido por nextMu.
+	dialer    *net.Dialer
+	tlsConfig *tls.Config
+	token     string
+	closed    bool
+}
+
+func newPool(addrs []string, size int, dialTimeout time.Duration, tlsConfig *tls.Config, token string) *pool {
+	p := &pool{
+		slots:     make([]*slot, size),
+		addrs:     addrs,
+		dialer:    &net.Dialer{Timeout: dialTimeout},
+		tlsConfig: tlsConfig,
+		token:     token,
+	}
+	for i := range p.slots {
+		p.slots[i] = &slot{}
+	}
+	return p
+}
+
+// pick escolhe o próximo slot em round-robin.
+func (p *pool) pick() (*slot, error) {
+	p.nextMu.Lock()
+	defer p.nextMu.Unlock()
+	if p.closed {
+		return nil, errClientClosed
+	}
+	s := p.slots[p.next%uint32(len(p.slots))]
+	p.next++
+	return s, nil
+}
+
+// conn retorna a conexão do slot, discando se necessário. Os endereços são
+// tentados a partir do último que funcionou (failover).
+func (p *pool) conn(ctx context.Context, s *slot) (*rpc.Client, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		return s.conn, s.addr, nil
+	}
+
+	p.nextMu.Lock()
+	start := p.preferred
+	p.nextMu.Unlock()
+
+	var lastErr error
+	for i := 0; i < len(p.addrs); i++ {
+		idx := (start + i) % len(p.addrs)
+		c, err := dialHTTP(ctx, p.dialer, p.addrs[idx], p.tlsConfig, p.token)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil || !IsConnectionError(err) {
+				break
+			}
+			continue
+		}
+
+		p.nextMu.Lock()
+		p.preferred = idx
+		p.nextMu.Unlock()
+
+		s.conn, s.addr = c, p.addrs[idx]
+		return c, s.addr, nil
+	}
+	return nil, "", lastErr
+}
+
+// discard fecha a conexão do slot se ela ainda for a informada.
+func (p *pool) discard(s *slot, c *rpc.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == c && c != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// close fecha todas as conexões do pool.
+func (p *pool) close() {
+	p.nextMu.Lock()
+	p.closed = true
+	p.nextMu.Unlock()
+
+	for _, s := range p.slots {
+		s.mu.Lock()
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+		s.mu.Unlock()
+	}
+}
+
+// backoff calcula o atraso da tentativa (a partir de 1) com crescimento
+// exponencial e "full jitter": um valor aleatório entre 0 e o limite.
+func backoff(attempt int, initial, maxDelay time.Duration) time.Duration {
+	delay := initial
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
@@ -0,0 +1,123 @@
+// Package client reúne utilitários para clientes do serviço RemoteList.
+package client
+
+import (
+	"errors"
+	"net/rpc"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// Reexporta os erros sentinela para que clientes não precisem importar structures.
+var (
+	ErrNotFound          = structures.ErrNotFound
+	ErrEmpty             = structures.ErrEmpty
+	ErrOutOfRange        = structures.ErrOutOfRange
+	ErrInvalidArgument   = structures.ErrInvalidArgument
+	ErrConflict          = structures.ErrConflict
+	ErrUnauthorized      = structures.ErrUnauthorized
+	ErrPermissionDenied  = structures.ErrPermissionDenied
+	ErrResourceExhausted = structures.ErrResourceExhausted
+	ErrUnavailable       = structures.ErrUnavailable
+	ErrInternal          = structures.ErrInternal
+)
+
+// AsError extrai o erro tipado de err. Erros do servidor chegam via net/rpc
+// como rpc.ServerError (texto), que é convertido com structures.ParseError.
+func AsError(err error) (*structures.Error, bool) {
+	if err == nil {
+		return nil, false
+	}
+
+	var typed *structures.Error
+	if errors.As(err, &typed) {
+		return typed, true
+	}
+
+	var serverErr rpc.ServerError
+	if errors.As(err, &serverErr) {
+		if parsed := structures.ParseError(string(serverErr)); parsed != nil {
+			return parsed, true
+		}
+	}
+	return nil, false
+}
+
+// Normalize converte erros de servidor recebidos como texto para *structures.Error,
+// permitindo usar errors.Is(err, client.ErrNotFound). Outros erros são mantidos.
+func Normalize(err error) error {
+	if typed, ok := AsError(err); ok {
+		return typed
+	}
+	return err
+}
+
+// Code retorna o código do erro, ou "" se err não for um erro tipado.
+func Code(err error) structures.ErrorCode {
+	if typed, ok := AsError(err); ok {
+		return typed.Code
+	}
+	return ""
+}
+
+// RetryAfter retorna a espera sugerida pelo servidor antes de uma nova
+// tentativa (ex: limite de taxa excedido), ou zero se não houver.
+func RetryAfter(err error) time.Duration {
+	if typed, ok := AsError(err); ok {
+		return typed.RetryAfter
+	}
+	return 0
+}
+
+// Is informa se err (tipado ou recebido como texto) tem o código informado.
+func Is(err error, code structures.ErrorCode) bool {
+	return Code(err) == code
+}
+
+// messages traduz os códigos de erro para mensagens curtas por idioma.
+var messages = map[string]map[structures.ErrorCode]string{
+	"pt": {
+		structures.CodeNotFound:          "lista não encontrada",
+		structures.CodeEmpty:             "lista vazia",
+		structures.CodeOutOfRange:        "índice fora dos limites",
+		structures.CodeInvalidArgument:   "argumento inválido",
+		structures.CodeConflict:          "conflito com o estado atual",
+		structures.CodeUnauthorized:      "não autenticado",
+		structures.CodePermissionDenied:  "permissão negada",
+		structures.CodeResourceExhausted: "limite excedido, tente novamente mais tarde",
+		structures.CodeUnavailable:       "servidor indisponível",
+		structures.CodeInternal:          "erro interno do servidor",
+	},
+	"en": {
+		structures.CodeNotFound:          "list not found",
+		structures.CodeEmpty:             "list is empty",
+		structures.CodeOutOfRange:        "index out of range",
+		structures.CodeInvalidArgument:   "invalid argument",
+		structures.CodeConflict:          "conflict with current state",
+		structures.CodeUnauthorized:      "unauthenticated",
+		structures.CodePermissionDenied:  "permission denied",
+		structures.CodeResourceExhausted: "limit exceeded, try again later",
+		structures.CodeUnavailable:       "server unavailable",
+		structures.CodeInternal:          "internal server error",
+	},
+}
+
+// Message retorna uma mensagem localizada ("pt" ou "en") para o erro.
+// Para idiomas ou erros desconhecidos, retorna a mensagem original.
+func Message(err error, lang string) string {
+	if err == nil {
+		return ""
+	}
+	typed, ok := AsError(err)
+	if !ok {
+		return err.Error()
+	}
+	if lang == "pt" && typed.Message != "" {
+		return typed.Message // A mensagem do servidor já está em português e é mais detalhada.
+	}
+	if msg, ok := messages[lang][typed.Code]; ok {
+		return msg
+	}
+	return typed.Error()
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// IndexOf retorna a primeira posição do valor na lista, ou -1 se ele não estiver nela.
+func (c *Client) IndexOf(ctx context.Context, listID string, value int) (int, error) {
+	var index int
+	err := c.call(ctx, "RemoteList.IndexOf", true, structures.SearchArgs{ListID: listID, Value: value}, &index)
+	return index, err
+}
+
+// LastIndexOf retorna a última posição do valor na lista, ou -1 se ele não estiver nela.
+func (c *Client) LastIndexOf(ctx context.Context, listID string, value int) (int, error) {
+	var index int
+	err := c.call(ctx, "RemoteList.LastIndexOf", true, structures.SearchArgs{ListID: listID, Value: value}, &index)
+	return index, err
+}
+
+// Contains informa se o valor está na lista.
+func (c *Client) Contains(ctx context.Context, listID string, value int) (bool, error) {
+	var found bool
+	err := c.call(ctx, "RemoteList.Contains", true, structures.SearchArgs{ListID: listID, Value: value}, &found)
+	return found, err
+}
+
+// FindAll retorna todas as posições do valor na lista, em ordem crescente.
+func (c *Client) FindAll(ctx context.Context, listID string, value int) ([]int, error) {
+	var positions []int
+	err := c.call(ctx, "RemoteList.FindAll", true, structures.SearchArgs{ListID: listID, Value: value}, &positions)
+	return positions, err
+}
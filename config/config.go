@@ -0,0 +1,325 @@
+// Package config carrega a configuração do servidor a partir de um arquivo JSON.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Config é a configuração do servidor. Todos os campos são opcionais.
+type Config struct {
+	TLS        TLSConfig        `json:"tls"`        // TLS do listener RPC (e do RESP, se ativo).
+	Auth       AuthConfig       `json:"auth"`       // Autenticação por token e controle de acesso.
+	Namespaces NamespacesConfig `json:"namespaces"` // Cotas por namespace.
+	Limits     LimitsConfig     `json:"limits"`     // Limites de taxa por cliente e de concorrência.
+	History    HistoryConfig    `json:"history"`    // Histórico de alterações para leituras no passado.
+	Aggregates AggregatesConfig `json:"aggregates"` // Listas com somas e extremos mantidos a cada alteração.
+	Search     SearchConfig     `json:"search"`     // Listas com índice de valores para as buscas.
+	Snapshots  SnapshotsConfig  `json:"snapshots"`  // Retenção das gerações de snapshot.
+	Storage    StorageConfig    `json:"storage"`    // Onde snapshots e log são guardados (lido só na inicialização).
+}
+
+// TLSConfig configura TLS e, opcionalmente, autenticação mútua (mTLS).
+type TLSConfig struct {
+	CertFile     string `json:"cert_file"`      // Certificado do servidor (PEM).
+	KeyFile      string `json:"key_file"`       // Chave privada do servidor (PEM).
+	ClientCAFile string `json:"client_ca_file"` // CAs aceitas para certificados de cliente (mTLS).
+	ClientAuth   string `json:"client_auth"`    // "require" (padrão com CA) ou "optional".
+}
+
+// AuthConfig define tokens de clientes e os papéis (roles) que eles recebem.
+type AuthConfig struct {
+	Enabled   bool                 `json:"enabled"`    // Exige token em todas as conexões.
+	AuditFile string               `json:"audit_file"` // Log de chamadas negadas (padrão: logs/audit.log).
+	Tokens    []TokenConfig        `json:"tokens"`     // Tokens aceitos.
+	Roles     map[string][]ACLRule `json:"roles"`      // Regras de acesso por papel.
+}
+
+// TokenConfig associa um token a um cliente, seus papéis e seu namespace.
+type TokenConfig struct {
+	Token     string   `json:"token"`
+	Client    string   `json:"client"` // Nome do cliente, usado em auditoria e métricas.
+	Roles     []string `json:"roles"`
+	Namespace string   `json:"namespace"` // Namespace das listas do cliente (padrão: "default").
+}
+
+// ACLRule concede permissões sobre uma lista ("compras"), um prefixo
+// terminado em "*" ("time-a/*") ou todas as listas ("*").
+type ACLRule struct {
+	Lists       string   `json:"lists"`
+	Permissions []string `json:"permissions"` // "read", "append", "remove" ou "admin".
+}
+
+// NamespacesConfig define as cotas dos namespaces.
+type NamespacesConfig struct {
+	DefaultQuota QuotaConfig            `json:"default_quota"` // Cota de namespaces sem cota própria.
+	Quotas       map[string]QuotaConfig `json:"quotas"`        // Cotas por namespace.
+}
+
+// QuotaConfig limita o uso de um namespace. Zero significa sem limite.
+type QuotaConfig struct {
+	MaxLists        int     `json:"max_lists"`          // Quantidade máxima de listas.
+	MaxElements     int     `json:"max_elements"`       // Total de elementos somando todas as listas.
+	MaxOpsPerSecond float64 `json:"max_ops_per_second"` // Operações por segundo (todas as chamadas do namespace).
+}
+
+// Quota retorna a cota do namespace: a específica, se houver, ou a padrão.
+func (n NamespacesConfig) Quota(namespace string) QuotaConfig {
+	if q, ok := n.Quotas[namespace]; ok {
+		return q
+	}
+	return n.DefaultQuota
+}
+
+// LimitsConfig define limites de taxa por cliente e o controle de admissão
+// do servidor. Campos zerados significam sem limite.
+type LimitsConfig struct {
+	PerClient      RateConfig            `json:"per_client"`       // Limite de cada cliente, somando todos os métodos.
+	PerMethod      map[string]RateConfig `json:"per_method"`       // Limite de cada cliente em um método (ex: "Append").
+	Clients        map[string]RateConfig `json:"clients"`          // Substitui per_client para clientes específicos.
+	MaxConcurrent  int                   `json:"max_concurrent"`   // Chamadas simultâneas no servidor.
+	MaxQueue       int                   `json:"max_queue"`        // Chamadas esperando vaga (padrão: max_concurrent).
+	QueueTimeoutMs int                   `json:"queue_timeout_ms"` // Espera por uma vaga antes de recusar (0: recusa na hora).
+}
+
+// RateConfig é um limite de taxa em token bucket.
+type RateConfig struct {
+	Rate  float64 `json:"rate"`  // Chamadas por segundo.
+	Burst int     `json:"burst"` // Rajada máxima (padrão: um segundo de chamadas).
+}
+
+// ClientRate retorna o limite total do cliente: o específico, se houver, ou per_client.
+func (l LimitsConfig) ClientRate(client string) RateConfig {
+	if r, ok := l.Clients[client]; ok {
+		return r
+	}
+	return l.PerClient
+}
+
+// HistoryConfig define por quanto tempo as alterações das listas ficam em
+// memória para leituras no passado (GetAt). Zero desativa.
+type HistoryConfig struct {
+	RetentionSeconds int `json:"retention_seconds"`
+}
+
+// Retention retorna o tempo de retenção do histórico.
+func (h HistoryConfig) Retention() time.Duration {
+	return time.Duration(h.RetentionSeconds) * time.Second
+}
+
+// AggregatesConfig escolhe, por padrões de path.Match sobre o ID (como
+// "metricas/*"), as listas cujas somas e extremos são mantidos a cada
+// alteração, para que Sum, Avg, Min e Max não percorram os elementos. Custa
+// cerca de quatro vezes a memória dos elementos dessas listas.
+type AggregatesConfig struct {
+	Incremental []string `json:"incremental"`
+}
+
+// SearchConfig escolhe, por padrões de path.Match sobre o ID, as listas com
+// índice de valores (as posições de cada valor), mantido a cada alteração
+// para que IndexOf, LastIndexOf, Contains e FindAll não percorram os
+// elementos. Custa algumas vezes a memória dos elementos dessas listas.
+type SearchConfig struct {
+	Indexed []string `json:"indexed"`
+}
+
+// SnapshotsConfig define quantas gerações de snapshot são mantidas: as
+// keep_last mais novas (padrão: 3) e a mais nova de cada uma das últimas
+// keep_hourly horas e keep_daily dias. full_every é a cada quantos
+// snapshots um é completo (padrão: 10); os demais são incrementais, e 1
+// desativa os incrementais.
+type SnapshotsConfig struct {
+	KeepLast   int `json:"keep_last"`
+	KeepHourly int `json:"keep_hourly"`
+	KeepDaily  int `json:"keep_daily"`
+	FullEvery  int `json:"full_every"`
+}
+
+// Backends de armazenamento aceitos em StorageConfig.
+const (
+	StorageFile   = "file"   // Arquivos em snapshots/ e logs/ (padrão).
+	StorageKV     = "kv"     // Armazenamento chave-valor embutido, com cada lista salva em separado.
+	StorageMemory = "memory" // Apenas em memória, para testes; nada sobrevive ao processo.
+)
+
+// StorageConfig escolhe os backends de snapshots e do log. snapshots aceita
+// "file", "kv" e "memory"; log aceita "file" e "memory". path é o arquivo do
+// backend "kv" (padrão: data/remote_list.kv).
+type StorageConfig struct {
+	Snapshots string `json:"snapshots"`
+	Log       string `json:"log"`
+	Path      string `json:"path"`
+}
+
+// DefaultKVPath é o arquivo do backend "kv" quando path não é informado.
+const DefaultKVPath = "data/remote_list.kv"
+
+// SnapshotsBackend retorna o backend de snapshots, com o padrão aplicado.
+func (s StorageConfig) SnapshotsBackend() string {
+	if s.Snapshots == "" {
+		return StorageFile
+	}
+	return s.Snapshots
+}
+
+// LogBackend retorna o backend do log, com o padrão aplicado.
+func (s StorageConfig) LogBackend() string {
+	if s.Log == "" {
+		return StorageFile
+	}
+	return s.Log
+}
+
+// KVPath retorna o arquivo do backend "kv", com o padrão aplicado.
+func (s StorageConfig) KVPath() string {
+	if s.Path == "" {
+		return DefaultKVPath
+	}
+	return s.Path
+}
+
+// ValidPermissions são as permissões aceitas nas regras de acesso.
+var ValidPermissions = map[string]bool{"read": true, "append": true, "remove": true, "admin": true}
+
+// Enabled informa se o TLS está configurado.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// Load lê e valida o arquivo de configuração. Um caminho vazio retorna a configuração padrão.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler configuração %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar configuração %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("configuração %s inválida: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// validate verifica combinações inválidas de campos.
+func (c *Config) validate() error {
+	t := c.TLS
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("tls: cert_file e key_file devem ser informados juntos")
+	}
+	if t.ClientCAFile != "" && !t.Enabled() {
+		return fmt.Errorf("tls: client_ca_file exige cert_file e key_file")
+	}
+	switch t.ClientAuth {
+	case "", "require", "optional":
+	default:
+		return fmt.Errorf("tls: client_auth deve ser \"require\" ou \"optional\", recebido %q", t.ClientAuth)
+	}
+
+	a := c.Auth
+	seen := make(map[string]bool)
+	for i, tok := range a.Tokens {
+		if tok.Token == "" {
+			return fmt.Errorf("auth: token %d está vazio", i)
+		}
+		if seen[tok.Token] {
+			return fmt.Errorf("auth: token do cliente %q repetido", tok.Client)
+		}
+		seen[tok.Token] = true
+		if strings.ContainsAny(tok.Namespace, " \t\n") {
+			return fmt.Errorf("auth: namespace %q do cliente %q contém espaços", tok.Namespace, tok.Client)
+		}
+		for _, role := range tok.Roles {
+			if _, ok := a.Roles[role]; !ok {
+				return fmt.Errorf("auth: papel %q do cliente %q não definido", role, tok.Client)
+			}
+		}
+	}
+	for role, rules := range a.Roles {
+		for _, rule := range rules {
+			if rule.Lists == "" {
+				return fmt.Errorf("auth: regra do papel %q sem campo lists", role)
+			}
+			for _, perm := range rule.Permissions {
+				if !ValidPermissions[perm] {
+					return fmt.Errorf("auth: permissão %q inválida no papel %q", perm, role)
+				}
+			}
+		}
+	}
+
+	n := c.Namespaces
+	if err := n.DefaultQuota.validate(); err != nil {
+		return fmt.Errorf("namespaces: default_quota: %w", err)
+	}
+	for name, q := range n.Quotas {
+		if name == "" || strings.ContainsAny(name, " \t\n") {
+			return fmt.Errorf("namespaces: nome de namespace %q inválido", name)
+		}
+		if err := q.validate(); err != nil {
+			return fmt.Errorf("namespaces: cota de %q: %w", name, err)
+		}
+	}
+
+	l := c.Limits
+	rates := map[string]RateConfig{"per_client": l.PerClient}
+	for method, r := range l.PerMethod {
+		rates["per_method."+method] = r
+	}
+	for client, r := range l.Clients {
+		rates["clients."+client] = r
+	}
+	for name, r := range rates {
+		if r.Rate < 0 || r.Burst < 0 {
+			return fmt.Errorf("limits: %s não pode ter valores negativos", name)
+		}
+	}
+	if l.MaxConcurrent < 0 || l.MaxQueue < 0 || l.QueueTimeoutMs < 0 {
+		return fmt.Errorf("limits: max_concurrent, max_queue e queue_timeout_ms não podem ser negativos")
+	}
+
+	if c.History.RetentionSeconds < 0 {
+		return fmt.Errorf("history: retention_seconds não pode ser negativo")
+	}
+	for _, pattern := range c.Aggregates.Incremental {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("aggregates: padrão %q inválido: %v", pattern, err)
+		}
+	}
+	for _, pattern := range c.Search.Indexed {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("search: padrão %q inválido: %v", pattern, err)
+		}
+	}
+	if sn := c.Snapshots; sn.KeepLast < 0 || sn.KeepHourly < 0 || sn.KeepDaily < 0 || sn.FullEvery < 0 {
+		return fmt.Errorf("snapshots: keep_last, keep_hourly, keep_daily e full_every não podem ser negativos")
+	}
+	switch c.Storage.SnapshotsBackend() {
+	case StorageFile, StorageKV, StorageMemory:
+	default:
+		return fmt.Errorf("storage: snapshots deve ser %q, %q ou %q, recebido %q", StorageFile, StorageKV, StorageMemory, c.Storage.Snapshots)
+	}
+	switch c.Storage.LogBackend() {
+	case StorageFile, StorageMemory:
+	default:
+		return fmt.Errorf("storage: log deve ser %q ou %q, recebido %q", StorageFile, StorageMemory, c.Storage.Log)
+	}
+	return nil
+}
+
+// validate rejeita limites negativos.
+func (q QuotaConfig) validate() error {
+	if q.MaxLists < 0 || q.MaxElements < 0 || q.MaxOpsPerSecond < 0 {
+		return fmt.Errorf("limites não podem ser negativos")
+	}
+	return nil
+}
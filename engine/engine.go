@@ -0,0 +1,333 @@
+// Package engine é o núcleo do servidor: aplica as operações às listas com o
+// registro no log e salva snapshots consistentes com ele. O servidor RPC (e o
+// listener RESP) o usam para as operações; a simulação determinística (pacote
+// sim) o executa com o relógio, o disco e o escalonamento simulados.
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"sd-miniprojeto-1/logging"
+	"sd-miniprojeto-1/storage"
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/utils"
+)
+
+// Engine aplica as operações sobre o estado recuperado.
+type Engine struct {
+	lists     *structures.RemoteList
+	snapshots storage.Snapshotter
+	logs      storage.LogStore
+
+	// mutationMu serializa as alterações (registro no log e aplicação) e a
+	// cópia do estado para o snapshot. Assim, as alterações são aplicadas
+	// na ordem do log, a mesma da recuperação, e o snapshot contém
+	// exatamente as operações registradas até ele.
+	mutationMu sync.Mutex
+
+	lastLoggedMu sync.Mutex
+	lastLogged   time.Time // Horário da última operação registrada no log.
+
+	yield func(point string) // Pontos de escalonamento; nil fora de simulações.
+}
+
+// New cria o Engine sobre o estado recuperado por storage.Recover.
+func New(recovery storage.Recovery, snapshots storage.Snapshotter, logs storage.LogStore) *Engine {
+	return &Engine{lists: recovery.State, snapshots: snapshots, logs: logs, lastLogged: recovery.LastTimestamp}
+}
+
+// Lists retorna o estado das listas.
+func (e *Engine) Lists() *structures.RemoteList {
+	return e.lists
+}
+
+// SetYield registra uma função chamada em cada ponto de escalonamento: antes
+// de adquirir o lock das alterações e entre as etapas que outras chamadas
+// podem intercalar (registro e aplicação; cópia, gravação e limpeza do
+// snapshot). A simulação determinística a usa para escolher a ordem das
+// chamadas concorrentes; com ela, o lock é adquirido sem bloquear, cedendo a
+// vez enquanto estiver ocupado. Deve ser chamada antes do primeiro uso.
+func (e *Engine) SetYield(yield func(point string)) {
+	e.yield = yield
+}
+
+func (e *Engine) point(name string) {
+	if e.yield != nil {
+		e.yield(name)
+	}
+}
+
+func (e *Engine) lock() {
+	if e.yield == nil {
+		e.mutationMu.Lock()
+		return
+	}
+	e.yield("lock")
+	for !e.mutationMu.TryLock() {
+		e.yield("lock")
+	}
+}
+
+// logAndTrack registra a operação no log e atualiza o horário da última
+// operação registrada.
+func (e *Engine) logAndTrack(entry utils.LogEntry) error {
+	if err := e.logs.Write(entry); err != nil {
+		return err
+	}
+	// Lido após a escrita, para não ser anterior ao timestamp da própria entrada.
+	operationTime := utils.Now()
+
+	e.lastLoggedMu.Lock()
+	if operationTime.After(e.lastLogged) {
+		e.lastLogged = operationTime
+	}
+	e.lastLoggedMu.Unlock()
+	return nil
+}
+
+// mutate registra a alteração no log e, só se o registro tiver sucesso, a
+// aplica. Uma alteração aplicada sem estar no log se perderia na próxima
+// recuperação; por isso a falha é devolvida ao cliente, que pode tentar de
+// novo (UNAVAILABLE: a alteração não aconteceu) ou não sabe o resultado
+// (INTERNAL: a entrada pode ter ficado no log). check, se não for nil, é
+// conferido antes do registro, com o lock adquirido. A entrada também é
+// montada com o lock, para que os timestamps sigam a ordem do log, da qual
+// dependem a cobertura dos snapshots e a compactação. IDs de lista inválidos
+// (veja structures.ValidateListID) são recusados antes de chegar ao log.
+func (e *Engine) mutate(description string, entry func() utils.LogEntry, check, apply func() error) error {
+	e.lock()
+	defer e.mutationMu.Unlock()
+	logEntry := entry()
+	if err := structures.ValidateListID(logEntry.ListID); err != nil {
+		return err
+	}
+	if check != nil {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	e.point("log")
+	if err := e.logAndTrack(logEntry); err != nil {
+		logging.Errorf("Erro ao logar %s: %v", description, err)
+		if errors.Is(err, utils.ErrLogUncertain) {
+			return structures.NewError(structures.CodeInternal, "falha ao registrar a operação no log; ela pode ter sido registrada")
+		}
+		return structures.NewError(structures.CodeUnavailable, "falha ao registrar a operação no log; ela não foi aplicada")
+	}
+	e.point("apply")
+	return apply()
+}
+
+// read registra a leitura no log e a executa. Leituras não alteram o estado:
+// uma falha no registro é só reportada.
+func (e *Engine) read(description string, entry utils.LogEntry, apply func() error) error {
+	if err := structures.ValidateListID(entry.ListID); err != nil {
+		return err
+	}
+	if err := e.logAndTrack(entry); err != nil {
+		logging.Errorf("Erro ao logar %s: %v", description, err)
+	}
+	e.point("apply")
+	return apply()
+}
+
+// Append adiciona um valor a uma lista.
+func (e *Engine) Append(args structures.AppendArgs, reply *bool) error {
+	// As cotas são conferidas antes do registro: operações recusadas não vão
+	// para o log, que na recuperação é reaplicado sem cotas.
+	check := func() error { return e.lists.CheckAppend(args) }
+	return e.mutate(fmt.Sprintf("APPEND para ListaID %s, Valor %d", args.ListID, args.Value), func() utils.LogEntry {
+		return utils.AppendEntry(args.Namespace, args.ListID, args.Value)
+	}, check, func() error {
+		return e.lists.Append(args, reply)
+	})
+}
+
+// Push adiciona vários valores a uma lista, com uma única entrada no log.
+func (e *Engine) Push(args structures.PushArgs, reply *int) error {
+	check := func() error { return e.lists.CheckPush(args) }
+	return e.mutate(fmt.Sprintf("PUSH para ListaID %s, %d valores", args.ListID, len(args.Values)), func() utils.LogEntry {
+		return utils.PushEntry(args.Namespace, args.ListID, args.Values)
+	}, check, func() error {
+		return e.lists.Push(args, reply)
+	})
+}
+
+// Remove remove o último elemento de uma lista.
+func (e *Engine) Remove(args structures.RemoveArgs, reply *int) error {
+	return e.mutate(fmt.Sprintf("REMOVE para ListaID %s", args.ListID), func() utils.LogEntry {
+		return utils.RemoveEntry(args.Namespace, args.ListID)
+	}, nil, func() error {
+		return e.lists.Remove(args, reply)
+	})
+}
+
+// Delete remove uma lista inteira.
+func (e *Engine) Delete(args structures.DeleteArgs, reply *bool) error {
+	return e.mutate(fmt.Sprintf("DELETE para ListaID %s", args.ListID), func() utils.LogEntry {
+		return utils.DeleteEntry(args.Namespace, args.ListID)
+	}, nil, func() error {
+		return e.lists.Delete(args, reply)
+	})
+}
+
+// Get obtém um valor de uma lista.
+func (e *Engine) Get(args structures.GetArgs, reply *int) error {
+	return e.read(fmt.Sprintf("GET para ListaID %s, Índice %d", args.ListID, args.Index), utils.GetEntry(args.Namespace, args.ListID, args.Index), func() error {
+		return e.lists.Get(args, reply)
+	})
+}
+
+// Size obtém a quantidade de elementos de uma lista.
+func (e *Engine) Size(args structures.SizeArgs, reply *int) error {
+	return e.read(fmt.Sprintf("SIZE para ListaID %s", args.ListID), utils.GetEntry(args.Namespace, args.ListID, 0), func() error {
+		return e.lists.Size(args, reply)
+	})
+}
+
+// Range obtém um intervalo de elementos de uma lista.
+func (e *Engine) Range(args structures.RangeArgs, reply *[]int) error {
+	return e.read(fmt.Sprintf("RANGE para ListaID %s", args.ListID), utils.GetEntry(args.Namespace, args.ListID, args.Start), func() error {
+		return e.lists.Range(args, reply)
+	})
+}
+
+// GetAt obtém um valor de uma lista em um instante do passado.
+func (e *Engine) GetAt(args structures.GetAtArgs, reply *int) error {
+	return e.read(fmt.Sprintf("GETAT para ListaID %s, Índice %d", args.ListID, args.Index), utils.GetEntry(args.Namespace, args.ListID, args.Index), func() error {
+		return e.lists.GetAt(args, reply)
+	})
+}
+
+// Sum soma os elementos de uma lista (ou de um intervalo dela).
+func (e *Engine) Sum(args structures.AggregateArgs, reply *int) error {
+	return e.read(fmt.Sprintf("SUM para ListaID %s", args.ListID), utils.GetEntry(args.Namespace, args.ListID, args.Start), func() error {
+		return e.lists.Sum(args, reply)
+	})
+}
+
+// Min obtém o menor elemento de uma lista (ou de um intervalo dela).
+func (e *Engine) Min(args structures.AggregateArgs, reply *int) error {
+	return e.read(fmt.Sprintf("MIN para ListaID %s", args.ListID), utils.GetEntry(args.Namespace, args.ListID, args.Start), func() error {
+		return e.lists.Min(args, reply)
+	})
+}
+
+// Max obtém o maior elemento de uma lista (ou de um intervalo dela).
+func (e *Engine) Max(args structures.AggregateArgs, reply *int) error {
+	return e.read(fmt.Sprintf("MAX para ListaID %s", args.ListID), utils.GetEntry(args.Namespace, args.ListID, args.Start), func() error {
+		return e.lists.Max(args, reply)
+	})
+}
+
+// Avg obtém a média dos elementos de uma lista (ou de um intervalo dela).
+func (e *Engine) Avg(args structures.AggregateArgs, reply *float64) error {
+	return e.read(fmt.Sprintf("AVG para ListaID %s", args.ListID), utils.GetEntry(args.Namespace, args.ListID, args.Start), func() error {
+		return e.lists.Avg(args, reply)
+	})
+}
+
+// Count conta os elementos de uma lista que satisfazem um predicado.
+func (e *Engine) Count(args structures.CountArgs, reply *int) error {
+	return e.read(fmt.Sprintf("COUNT para ListaID %s", args.ListID), utils.GetEntry(args.Namespace, args.ListID, args.Start), func() error {
+		return e.lists.Count(args, reply)
+	})
+}
+
+// Histogram conta os elementos de uma lista por faixa de valores.
+func (e *Engine) Histogram(args structures.HistogramArgs, reply *[]int) error {
+	return e.read(fmt.Sprintf("HISTOGRAM para ListaID %s", args.ListID), utils.GetEntry(args.Namespace, args.ListID, args.Start), func() error {
+		return e.lists.Histogram(args, reply)
+	})
+}
+
+// IndexOf obtém a primeira posição de um valor em uma lista.
+func (e *Engine) IndexOf(args structures.SearchArgs, reply *int) error {
+	return e.read(fmt.Sprintf("INDEXOF para ListaID %s, Valor %d", args.ListID, args.Value), utils.GetEntry(args.Namespace, args.ListID, 0), func() error {
+		return e.lists.IndexOf(args, reply)
+	})
+}
+
+// LastIndexOf obtém a última posição de um valor em uma lista.
+func (e *Engine) LastIndexOf(args structures.SearchArgs, reply *int) error {
+	return e.read(fmt.Sprintf("LASTINDEXOF para ListaID %s, Valor %d", args.ListID, args.Value), utils.GetEntry(args.Namespace, args.ListID, 0), func() error {
+		return e.lists.LastIndexOf(args, reply)
+	})
+}
+
+// Contains verifica se um valor está em uma lista.
+func (e *Engine) Contains(args structures.SearchArgs, reply *bool) error {
+	return e.read(fmt.Sprintf("CONTAINS para ListaID %s, Valor %d", args.ListID, args.Value), utils.GetEntry(args.Namespace, args.ListID, 0), func() error {
+		return e.lists.Contains(args, reply)
+	})
+}
+
+// FindAll obtém todas as posições de um valor em uma lista.
+func (e *Engine) FindAll(args structures.SearchArgs, reply *[]int) error {
+	return e.read(fmt.Sprintf("FINDALL para ListaID %s, Valor %d", args.ListID, args.Value), utils.GetEntry(args.Namespace, args.ListID, 0), func() error {
+		return e.lists.FindAll(args, reply)
+	})
+}
+
+// Snapshot salva um snapshot cobrindo as operações registradas no log até
+// agora. O estado é copiado com as alterações bloqueadas, para que contenha
+// exatamente as operações do log até coveredUntil: nenhuma registrada e
+// ainda não aplicada.
+func (e *Engine) Snapshot() (structures.SnapshotReply, error) {
+	start := time.Now()
+	e.lock()
+	e.lastLoggedMu.Lock()
+	coveredUntil := e.lastLogged
+	e.lastLoggedMu.Unlock()
+	lastLSN := e.logs.LastLSN()
+	state := e.lists.Clone()
+	e.mutationMu.Unlock()
+
+	e.point("save")
+	saved, err := e.snapshots.Save(state, coveredUntil, lastLSN)
+	if err != nil {
+		return structures.SnapshotReply{}, err
+	}
+	if !saved.Skipped {
+		e.point("forget")
+		// Os salvamentos seguintes partem deste, que já contém as remoções.
+		e.lists.ForgetDeleted(state.Version())
+	}
+	return structures.SnapshotReply{
+		Path:         saved.Path,
+		CoveredUntil: saved.CoveredUntil,
+		LastLSN:      saved.LSN,
+		Duration:     time.Since(start),
+		Delta:        saved.Delta,
+		Skipped:      saved.Skipped,
+	}, nil
+}
+
+// CompactLog salva um snapshot e remove do log as entradas cobertas pela
+// geração retida mais antiga, para que todas continuem utilizáveis na
+// recuperação.
+func (e *Engine) CompactLog() (structures.CompactLogReply, error) {
+	snapshot, err := e.Snapshot()
+	if err != nil {
+		return structures.CompactLogReply{}, fmt.Errorf("erro ao salvar snapshot: %w", err)
+	}
+	e.point("compact")
+	compactionPoint, err := e.snapshots.CompactionPoint()
+	if err != nil {
+		return structures.CompactLogReply{}, fmt.Errorf("erro ao ler snapshots: %w", err)
+	}
+	result, err := e.logs.Compact(compactionPoint)
+	if err != nil {
+		return structures.CompactLogReply{}, fmt.Errorf("erro ao compactar log: %w", err)
+	}
+	return structures.CompactLogReply{
+		Snapshot:     snapshot,
+		EntriesKept:  result.Kept,
+		EntriesFreed: result.Freed,
+		BytesBefore:  result.BytesBefore,
+		BytesAfter:   result.BytesAfter,
+	}, nil
+}
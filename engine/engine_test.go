@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"sd-miniprojeto-1/storage"
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/utils"
+)
+
+// start muda para um diretório temporário novo, preparado como o do
+// servidor, e recupera o estado dele (vazio na primeira vez), como em um
+// processo novo. O stdout da recuperação é descartado.
+func start(t *testing.T) *Engine {
+	t.Helper()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout := os.Stdout
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+
+	utils.Reset()
+	recovery, err := storage.Recover(storage.FileSnapshotter{}, storage.FileLogStore{})
+	if err != nil {
+		t.Fatalf("recuperação falhou: %v", err)
+	}
+	return New(recovery, storage.FileSnapshotter{}, storage.FileLogStore{})
+}
+
+func enterTempDir(t *testing.T) {
+	t.Helper()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+	for _, dir := range []string{"logs", "snapshots"} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// state retorna o conteúdo das listas, por "namespace/lista".
+func state(e *Engine) map[string][]int {
+	lists := make(map[string][]int)
+	e.Lists().EachList(func(namespace, listID string, elements []int) error {
+		lists[namespace+"/"+listID] = append([]int(nil), elements...)
+		return nil
+	})
+	return lists
+}
+
+// TestInvalidListIDs confere que IDs que mudariam de sentido no log (que
+// separa os campos por espaços e termina com "ns=<namespace>") são recusados
+// antes do registro, e que a recuperação reproduz o estado confirmado.
+func TestInvalidListIDs(t *testing.T) {
+	enterTempDir(t)
+	e := start(t)
+	if err := e.Append(structures.AppendArgs{Namespace: "evil", ListID: "a", Value: 1}, new(bool)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Append(structures.AppendArgs{ListID: "pedidos:2024/jan", Value: 2}, new(bool)); err != nil {
+		t.Fatalf("ID válido recusado: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		// Seria registrado como "Remove a ns=evil" e reaplicado na lista "a"
+		// do namespace "evil".
+		{"Remove com ns= no ID", func() error {
+			return e.Remove(structures.RemoveArgs{ListID: "a ns=evil"}, new(int))
+		}},
+		{"Delete com ns= no ID", func() error {
+			return e.Delete(structures.DeleteArgs{ListID: "a ns=evil"}, new(bool))
+		}},
+		// Seria confirmado, mas a linha não seria interpretada na recuperação.
+		{"Append com espaço no ID", func() error {
+			return e.Append(structures.AppendArgs{Namespace: "teamA", ListID: "x y", Value: 3}, new(bool))
+		}},
+		{"Push com tabulação no ID", func() error {
+			return e.Push(structures.PushArgs{ListID: "x\ty", Values: []int{4}}, new(int))
+		}},
+		{"Append com quebra de linha no ID", func() error {
+			return e.Append(structures.AppendArgs{ListID: "x\n1 2026-01-01T00:00:00Z Delete a", Value: 5}, new(bool))
+		}},
+		{"Append com caractere de controle no ID", func() error {
+			return e.Append(structures.AppendArgs{ListID: "x\x00", Value: 6}, new(bool))
+		}},
+		{"Append com ID vazio", func() error {
+			return e.Append(structures.AppendArgs{ListID: "", Value: 7}, new(bool))
+		}},
+		{"Get com espaço no ID", func() error {
+			return e.Get(structures.GetArgs{ListID: "a ns=evil", Index: 0}, new(int))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, structures.ErrInvalidArgument) {
+				t.Fatalf("erro %v, esperado INVALID_ARGUMENT", err)
+			}
+		})
+	}
+
+	want := map[string][]int{"evil/a": {1}, structures.DefaultNamespace + "/pedidos:2024/jan": {2}}
+	if got := state(e); !reflect.DeepEqual(got, want) {
+		t.Fatalf("estado %v, esperado %v", got, want)
+	}
+	if got := state(start(t)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("recuperado %v, esperado %v", got, want)
+	}
+}
+
+// TestInvalidListIDsInRemoteList confere a mesma recusa no RemoteList usado
+// diretamente, sem o engine.
+func TestInvalidListIDsInRemoteList(t *testing.T) {
+	rl := structures.NewRemoteList()
+	for _, listID := range []string{"", "a b", "a\tb", "a\x7f"} {
+		if err := rl.Append(structures.AppendArgs{ListID: listID, Value: 1}, new(bool)); !errors.Is(err, structures.ErrInvalidArgument) {
+			t.Errorf("Append(%q): erro %v, esperado INVALID_ARGUMENT", listID, err)
+		}
+	}
+	if lists, _ := rl.Stats(); lists != 0 {
+		t.Errorf("%d listas criadas com IDs inválidos", lists)
+	}
+}
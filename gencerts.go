@@ -0,0 +1,31 @@
+// gencerts gera uma CA local e certificados de servidor e cliente para testar
+// TLS e mTLS. Uso: go run gencerts.go -dir certs
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"sd-miniprojeto-1/tlsutil"
+)
+
+func main() {
+	dir := flag.String("dir", "certs", "Diretório de saída dos certificados.")
+	hosts := flag.String("hosts", "localhost,127.0.0.1,remote-list-server", "Hosts/IPs do certificado do servidor, separados por vírgula.")
+	clientName := flag.String("client-name", "remote-list-client", "Common Name do certificado do cliente.")
+	validFor := flag.Duration("valid-for", 365*24*time.Hour, "Validade dos certificados.")
+	flag.Parse()
+
+	files, err := tlsutil.GenerateCertificates(*dir, strings.Split(*hosts, ","), *clientName, *validFor)
+	if err != nil {
+		log.Fatalf("Erro ao gerar certificados: %v", err)
+	}
+
+	fmt.Println("Certificados gerados:")
+	fmt.Println("  CA:       ", files.CACert)
+	fmt.Println("  Servidor: ", files.ServerCert, files.ServerKey)
+	fmt.Println("  Cliente:  ", files.ClientCert, files.ClientKey)
+}
@@ -0,0 +1,93 @@
+// Package health expõe o estado de vida e de prontidão do servidor via HTTP.
+package health
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LivenessPath  = "/healthz" // Processo no ar.
+	ReadinessPath = "/readyz"  // Recuperação concluída e pronto para atender.
+)
+
+// Checker guarda o estado de prontidão do servidor.
+type Checker struct {
+	ready     atomic.Bool
+	startedAt time.Time
+}
+
+// NewChecker cria um Checker que começa não pronto.
+func NewChecker() *Checker {
+	return &Checker{startedAt: time.Now()}
+}
+
+// SetReady altera o estado de prontidão.
+func (c *Checker) SetReady(ready bool) {
+	c.ready.Store(ready)
+}
+
+// Ready informa se o servidor concluiu a recuperação.
+func (c *Checker) Ready() bool {
+	return c.ready.Load()
+}
+
+// StartedAt retorna o horário de início do processo.
+func (c *Checker) StartedAt() time.Time {
+	return c.startedAt
+}
+
+// Uptime retorna há quanto tempo o processo está no ar.
+func (c *Checker) Uptime() time.Duration {
+	return time.Since(c.startedAt)
+}
+
+// Register adiciona os endpoints /healthz e /readyz ao mux informado.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc(LivenessPath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok (uptime %s)\n", c.Uptime().Truncate(time.Second))
+	})
+	mux.HandleFunc(ReadinessPath, func(w http.ResponseWriter, r *http.Request) {
+		if !c.Ready() {
+			http.Error(w, "recuperando estado", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "pronto")
+	})
+}
+
+// Gate bloqueia o handler com 503 enquanto o servidor não estiver pronto.
+// Para o net/rpc, isso faz o handshake falhar e os clientes tentarem de novo.
+func (c *Checker) Gate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.Ready() {
+			http.Error(w, "servidor em recuperação", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Probe consulta o endpoint de prontidão em addr e retorna erro se não estiver pronto.
+// Usado como healthcheck em imagens sem shell ou curl. Se tlsConfig não for nil,
+// a consulta é feita via HTTPS.
+func Probe(addr string, tlsConfig *tls.Config) error {
+	client := &http.Client{Timeout: 3 * time.Second}
+	scheme := "http://"
+	if tlsConfig != nil {
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+		scheme = "https://"
+	}
+	resp, err := client.Get(scheme + addr + ReadinessPath)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("servidor não está pronto: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,415 @@
+// inspect examina o snapshot e o log de operações sem iniciar o servidor.
+// Uso: go run inspect.go [-snapshot arquivo] [-log arquivo] <comando> [opções]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/utils"
+)
+
+const usage = `Uso: go run inspect.go [-snapshot arquivo] [-log arquivo] <comando> [opções]
+Comandos:
+  snapshot list       Lista as gerações de snapshot
+  snapshot verify     Verifica os snapshots (checksums e estrutura) e exibe um resumo de cada um
+  snapshot dump       Exibe as listas da geração válida mais nova (-ns, -list, -limit, -json)
+  log print           Exibe o log com filtros (-list, -ns, -op, -since, -until, -from-lsn, -to-lsn, -invalid)
+  log verify          Verifica a integridade do log
+  replay              Aplica o log sobre o snapshot e exibe o estado resultante (-to, -out, -ns, -list, -limit, -json)
+Use "go run inspect.go <comando> -h" para ver as opções de cada comando.`
+
+var (
+	snapshotFlag = flag.String("snapshot", "", "Arquivo de snapshot (padrão: as gerações em snapshots/).")
+	logFlag      = flag.String("log", utils.LogPath(), "Arquivo de log de operações.")
+)
+
+func main() {
+	flag.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	command := args[0]
+	if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		command += " " + args[1]
+		args = args[1:]
+	}
+	args = args[1:]
+
+	switch command {
+	case "snapshot list":
+		os.Exit(listSnapshots())
+	case "snapshot verify":
+		os.Exit(verifySnapshot())
+	case "snapshot dump":
+		os.Exit(dumpSnapshot(args))
+	case "log print":
+		os.Exit(printLog(args))
+	case "log verify":
+		os.Exit(verifyLog())
+	case "replay":
+		os.Exit(replay(args))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// snapshotPaths retorna o snapshot de -snapshot ou, se vazio, todas as
+// gerações do diretório de snapshots, da mais nova para a mais antiga.
+func snapshotPaths() ([]string, error) {
+	if *snapshotFlag != "" {
+		return []string{*snapshotFlag}, nil
+	}
+	files, err := utils.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("nenhum snapshot encontrado")
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	return paths, nil
+}
+
+// listSnapshots exibe as gerações de snapshot do diretório de snapshots.
+func listSnapshots() int {
+	files, err := utils.Snapshots()
+	if err != nil {
+		fmt.Println("Erro ao listar snapshots:", err)
+		return 1
+	}
+	fmt.Printf("%-62s %-10s %-12s %-25s %s\n", "ARQUIVO", "LSN", "TIPO", "SALVO EM", "BYTES")
+	for _, file := range files {
+		kind := "completo"
+		if file.Delta {
+			kind = fmt.Sprintf("pai %d", file.Parent)
+		}
+		fmt.Printf("%-62s %-10d %-12s %-25s %d\n", file.Path, file.LSN, kind, file.ModTime.Format(time.RFC3339), file.Size)
+	}
+	return 0
+}
+
+// verifySnapshot lê cada snapshot inteiro e exibe um resumo.
+func verifySnapshot() int {
+	paths, err := snapshotPaths()
+	if err != nil {
+		fmt.Println("Erro:", err)
+		return 1
+	}
+
+	code := 0
+	for i, path := range paths {
+		if i > 0 {
+			fmt.Println()
+		}
+		rl, info, err := utils.ReadSnapshot(path)
+		if err != nil {
+			fmt.Println("Snapshot inválido:", err)
+			code = 1
+			continue
+		}
+
+		lists, elements := rl.Stats()
+		fmt.Printf("Snapshot:      %s (modificado em %s)\n", path, info.ModTime.Format(time.RFC3339))
+		fmt.Printf("Formato:       %s\n", info.Format)
+		if info.Format == utils.SnapshotFormatJSON {
+			fmt.Printf("Tamanho:       %d bytes comprimido, %d bytes de JSON\n", info.CompressedBytes, info.RawBytes)
+		} else {
+			fmt.Printf("Tamanho:       %d bytes\n", info.CompressedBytes)
+		}
+		fmt.Printf("Cobre o log:   até %s (LSN %d)\n", formatTime(info.LastLogTimestamp), info.LastLSN)
+		if info.Delta {
+			fmt.Printf("Cadeia:        pai LSN %d; %s + %d incrementais\n", info.Parent, info.Base, info.Deltas)
+		}
+		fmt.Printf("Conteúdo:      %d listas, %d elementos em %d namespaces\n", lists, elements, len(rl.Namespaces))
+		fmt.Println("Snapshot OK.")
+	}
+	return code
+}
+
+// listFilter seleciona listas por namespace e ID para dump e replay.
+type listFilter struct {
+	namespace string
+	listID    string
+	limit     int
+	json      bool
+}
+
+func (f *listFilter) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.namespace, "ns", "", "Exibe apenas o namespace informado.")
+	fs.StringVar(&f.listID, "list", "", "Exibe apenas a lista informada.")
+	fs.IntVar(&f.limit, "limit", 20, "Máximo de elementos exibidos por lista (0 exibe todos).")
+	fs.BoolVar(&f.json, "json", false, "Exibe as listas selecionadas em JSON, sem limite de elementos.")
+}
+
+// dumpSnapshot exibe as listas do snapshot.
+func dumpSnapshot(args []string) int {
+	var filter listFilter
+	fs := flag.NewFlagSet("snapshot dump", flag.ExitOnError)
+	filter.register(fs)
+	fs.Parse(args)
+
+	// Sem -snapshot, exibe a geração válida mais nova, a mesma que o servidor carregaria.
+	paths, err := snapshotPaths()
+	if err != nil {
+		fmt.Println("Erro:", err)
+		return 1
+	}
+	for _, path := range paths {
+		rl, info, err := utils.ReadSnapshot(path)
+		if err != nil {
+			fmt.Println("Snapshot inválido:", err)
+			continue
+		}
+		if !filter.json {
+			fmt.Printf("Snapshot %s cobre o log até %s (LSN %d).\n", path, formatTime(info.LastLogTimestamp), info.LastLSN)
+		}
+		return printLists(rl, filter)
+	}
+	return 1
+}
+
+// printLists exibe as listas de rl que passam pelo filtro, ordenadas por namespace e ID.
+func printLists(rl *structures.RemoteList, filter listFilter) int {
+	selected := make(map[string]map[string][]int)
+	for namespace, ns := range rl.Namespaces {
+		if filter.namespace != "" && namespace != structures.NamespaceName(filter.namespace) {
+			continue
+		}
+		for listID, list := range ns.Lists {
+			if filter.listID != "" && listID != filter.listID {
+				continue
+			}
+			if selected[namespace] == nil {
+				selected[namespace] = make(map[string][]int)
+			}
+			selected[namespace][listID] = list.Elements
+		}
+	}
+
+	if filter.json {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(selected); err != nil {
+			fmt.Println("Erro ao codificar JSON:", err)
+			return 1
+		}
+		return 0
+	}
+
+	if len(selected) == 0 {
+		if filter.listID != "" {
+			fmt.Printf("Lista %s não encontrada.\n", filter.listID)
+			return 1
+		}
+		fmt.Println("Nenhuma lista.")
+		return 0
+	}
+	for _, namespace := range sortedKeys(selected) {
+		fmt.Printf("Namespace %s:\n", namespace)
+		for _, listID := range sortedKeys(selected[namespace]) {
+			elements := selected[namespace][listID]
+			shown := elements
+			if filter.limit > 0 && len(shown) > filter.limit {
+				shown = shown[:filter.limit]
+			}
+			suffix := ""
+			if len(shown) < len(elements) {
+				suffix = fmt.Sprintf(" ... (+%d)", len(elements)-len(shown))
+			}
+			fmt.Printf("  %s (%d elementos): %v%s\n", listID, len(elements), shown, suffix)
+		}
+	}
+	return 0
+}
+
+// printLog exibe as entradas do log que passam pelos filtros.
+func printLog(args []string) int {
+	fs := flag.NewFlagSet("log print", flag.ExitOnError)
+	listID := fs.String("list", "", "Exibe apenas entradas da lista informada.")
+	namespace := fs.String("ns", "", "Exibe apenas entradas do namespace informado.")
+	operation := fs.String("op", "", "Exibe apenas a operação informada (Append, Remove, Delete ou Get/Size).")
+	sinceFlag := fs.String("since", "", "Exibe apenas entradas a partir deste horário (RFC3339).")
+	untilFlag := fs.String("until", "", "Exibe apenas entradas até este horário (RFC3339).")
+	fromLSN := fs.Uint64("from-lsn", 0, "Exibe apenas entradas com LSN maior ou igual.")
+	toLSN := fs.Uint64("to-lsn", 0, "Exibe apenas entradas com LSN menor ou igual (0 sem limite).")
+	invalid := fs.Bool("invalid", false, "Exibe também as linhas inválidas.")
+	fs.Parse(args)
+
+	since, err := parseTime(*sinceFlag)
+	if err != nil {
+		fmt.Println("Erro em -since:", err)
+		return 2
+	}
+	until, err := parseTime(*untilFlag)
+	if err != nil {
+		fmt.Println("Erro em -until:", err)
+		return 2
+	}
+
+	shown := 0
+	err = utils.ScanLog(*logFlag, func(record utils.LogRecord) error {
+		if record.Err != nil {
+			if *invalid {
+				fmt.Printf("linha %d inválida (%v): %s\n", record.Line, record.Err, record.Raw)
+			}
+			return nil
+		}
+		entry := record.Entry
+		switch {
+		case *listID != "" && entry.ListID != *listID,
+			*namespace != "" && structures.NamespaceName(entry.Namespace) != structures.NamespaceName(*namespace),
+			*operation != "" && !strings.EqualFold(entry.Operation, *operation),
+			!since.IsZero() && entry.Timestamp.Before(since),
+			!until.IsZero() && entry.Timestamp.After(until),
+			entry.LSN < *fromLSN,
+			*toLSN != 0 && entry.LSN > *toLSN:
+			return nil
+		}
+		fmt.Println(entry)
+		shown++
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Erro ao ler o log:", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%d entradas exibidas.\n", shown)
+	return 0
+}
+
+// verifyLog verifica a integridade do log e exibe os problemas encontrados.
+func verifyLog() int {
+	report, err := utils.CheckLog(*logFlag)
+	if err != nil {
+		fmt.Println("Erro ao ler o log:", err)
+		return 1
+	}
+
+	fmt.Printf("Log:           %s\n", *logFlag)
+	fmt.Printf("Entradas:      %d válidas de %d linhas (%d no formato antigo, sem LSN)\n", report.Entries, report.Lines, report.Legacy)
+	if report.Entries > 0 {
+		fmt.Printf("LSN:           %d a %d\n", report.FirstLSN, report.LastLSN)
+		fmt.Printf("Período:       %s a %s\n", formatTime(report.First), formatTime(report.Last))
+		operations := make([]string, 0, len(report.Operations))
+		for _, op := range sortedKeys(report.Operations) {
+			operations = append(operations, fmt.Sprintf("%s=%d", op, report.Operations[op]))
+		}
+		fmt.Printf("Operações:     %s\n", strings.Join(operations, " "))
+	}
+
+	for _, issue := range report.Issues {
+		kind := "ERRO"
+		if issue.Warning {
+			kind = "AVISO"
+		}
+		if issue.Line > 0 {
+			fmt.Printf("%s linha %d: %s\n", kind, issue.Line, issue.Message)
+		} else {
+			fmt.Printf("%s: %s\n", kind, issue.Message)
+		}
+	}
+	if !report.Valid() {
+		fmt.Println("Log com problemas.")
+		return 1
+	}
+	fmt.Println("Log OK.")
+	return 0
+}
+
+// replay reconstrói o estado como o servidor faz na recuperação: carrega o
+// snapshot (se existir) e aplica as entradas do log posteriores a ele. Com
+// -to, para no ponto informado (recuperação para um ponto no tempo).
+func replay(args []string) int {
+	var filter listFilter
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	filter.register(fs)
+	to := fs.String("to", "", "Aplica o log só até este ponto: horário RFC3339 ou lsn:<número>.")
+	out := fs.String("out", "", "Salva o estado resultante como snapshot neste arquivo.")
+	fs.Parse(args)
+
+	var target utils.RecoveryTarget
+	if *to != "" {
+		var err error
+		if target, err = utils.ParseRecoveryTarget(*to); err != nil {
+			fmt.Println("Erro em -to:", err)
+			return 2
+		}
+	}
+
+	rl, info, err := utils.RestoreAt(*snapshotFlag, *logFlag, target)
+	if err != nil {
+		fmt.Println("Erro na recuperação:", err)
+		return 1
+	}
+
+	if *out != "" {
+		// O snapshot cobre a última alteração aplicada e guarda o maior LSN do log,
+		// para que um servidor iniciado com ele (e log vazio) não repita LSNs.
+		coveredUntil := info.LastApplied.Timestamp
+		if coveredUntil.IsZero() {
+			coveredUntil = info.Since
+		}
+		if _, err := utils.WriteSnapshot(*out, rl, coveredUntil, info.LogLastLSN); err != nil {
+			fmt.Println("Erro ao salvar snapshot:", err)
+			return 1
+		}
+	}
+
+	if !filter.json {
+		if info.Snapshot != "" {
+			fmt.Printf("Partindo do snapshot %s.\n", info.Snapshot)
+		} else if info.SnapshotTooNew {
+			fmt.Println("Os snapshots são posteriores ao alvo e foram ignorados.")
+		}
+		fmt.Printf("Snapshot até %s + %d alterações do log aplicadas até %s (%d linhas inválidas ignoradas).\n",
+			formatTime(info.Since), info.Applied, target, info.Skipped)
+		if info.Applied > 0 {
+			fmt.Printf("Última alteração aplicada: %s\n", info.LastApplied)
+		}
+		if *out != "" {
+			fmt.Printf("Estado salvo em %s.\n", *out)
+		}
+	}
+	return printLists(rl, filter)
+}
+
+// parseTime interpreta um horário em RFC3339 (vazio retorna o instante zero).
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// formatTime formata um horário, indicando quando está ausente.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "(nenhum)"
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// sortedKeys retorna as chaves do mapa em ordem.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
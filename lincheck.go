@@ -0,0 +1,487 @@
+// lincheck executa clientes concorrentes contra o RemoteList, registra o
+// instante de chamada e de resposta de cada operação e verifica se o
+// histórico é linearizável em relação ao modelo sequencial de listas
+// (pacote lincheck). Se não for, exibe um contraexemplo mínimo.
+//
+// Uso: go run lincheck.go [-target rpc|local] [-spawn] [-clients 8] [-ops 200] [opções]
+//
+//	go run lincheck.go -target local              # RemoteList no próprio processo, sem RPC
+//	go run lincheck.go -spawn -restarts 3         # servidor em um diretório temporário, com quedas (kill -9)
+//	go run lincheck.go -addr localhost:1234       # servidor já em execução
+//	go run lincheck.go -check historico.json      # verifica um histórico salvo com -out
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"sd-miniprojeto-1/client"
+	"sd-miniprojeto-1/lincheck"
+	"sd-miniprojeto-1/structures"
+)
+
+var (
+	targetFlag       = flag.String("target", "rpc", "Onde executar as operações: rpc (servidor) ou local (RemoteList no processo).")
+	addrFlag         = flag.String("addr", "localhost:1234", "Endereço do servidor (alvo rpc).")
+	tokenFlag        = flag.String("token", os.Getenv("REMOTE_LIST_TOKEN"), "Token de acesso (padrão: $REMOTE_LIST_TOKEN).")
+	spawnFlag        = flag.Bool("spawn", false, "Compila e inicia o servidor em um diretório temporário (alvo rpc).")
+	serverFlag       = flag.String("server", "", "Binário do servidor para -spawn (padrão: compila server.go).")
+	restartsFlag     = flag.Int("restarts", 0, "Com -spawn, derruba (kill -9) e reinicia o servidor esse número de vezes durante a rodada.")
+	clientsFlag      = flag.Int("clients", 8, "Clientes concorrentes.")
+	opsFlag          = flag.Int("ops", 200, "Operações por cliente.")
+	listsFlag        = flag.Int("lists", 2, "Listas usadas; menos listas, mais disputa.")
+	mixFlag          = flag.String("mix", "append=40,remove=20,get=15,size=10,range=10,delete=5", "Proporção das operações.")
+	seedFlag         = flag.Int64("seed", time.Now().UnixNano(), "Semente das operações.")
+	runsFlag         = flag.Int("runs", 1, "Rodadas, cada uma com listas novas.")
+	timeoutFlag      = flag.Duration("timeout", 2*time.Second, "Tempo máximo de cada operação; ao esgotar, o resultado é desconhecido.")
+	checkTimeoutFlag = flag.Duration("check-timeout", time.Minute, "Tempo máximo da verificação de cada rodada.")
+	outFlag          = flag.String("out", "", "Salva o histórico (JSON) da última rodada verificada ou da primeira que falhar.")
+	checkFlag        = flag.String("check", "", "Só verifica o histórico salvo neste arquivo.")
+)
+
+// executor executa uma operação e retorna a resposta; known é falso se o
+// resultado não se sabe (a operação pode ou não ter sido aplicada).
+type executor interface {
+	execute(ctx context.Context, in lincheck.ListInput) (out lincheck.ListOutput, known bool)
+	close()
+}
+
+func main() {
+	flag.Parse()
+	model := lincheck.ListModel()
+
+	if *checkFlag != "" {
+		f, err := os.Open(*checkFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		history, err := lincheck.ReadListHistory(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		os.Exit(report(model, history))
+	}
+
+	mix, err := parseMix(*mixFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var server *spawnedServer
+	if *spawnFlag {
+		if server, err = spawnServer(); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao iniciar o servidor: %v\n", err)
+			os.Exit(1)
+		}
+		defer server.stop()
+		// Ctrl+C não deve deixar o servidor ocupando a porta.
+		interrupted := make(chan os.Signal, 1)
+		signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-interrupted
+			server.stop()
+			os.Exit(130)
+		}()
+	}
+
+	var local *structures.RemoteList
+	newExecutor := func() (executor, error) {
+		if *targetFlag == "local" {
+			return localExecutor{local}, nil
+		}
+		opts := client.DefaultOptions(*addrFlag)
+		opts.Token = *tokenFlag
+		opts.PoolSize = 1
+		c, err := client.New(opts)
+		if err != nil {
+			return nil, err
+		}
+		return rpcExecutor{c}, nil
+	}
+
+	for run := 0; run < *runsFlag; run++ {
+		seed := *seedFlag + int64(run)
+		local = structures.NewRemoteList()
+		fmt.Printf("Rodada %d (semente %d): %d clientes, %d operações cada, %d listas.\n", run+1, seed, *clientsFlag, *opsFlag, *listsFlag)
+
+		prefix := fmt.Sprintf("lincheck-%d-%d", time.Now().UnixNano(), run)
+		history, err := execute(seed, mix, newExecutor, server, prefix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Erro na rodada: %v\n", err)
+			os.Exit(1)
+		}
+		code := report(model, history)
+		if *outFlag != "" && (code != 0 || run == *runsFlag-1) {
+			if err := saveHistory(*outFlag, history); err != nil {
+				fmt.Fprintf(os.Stderr, "Erro ao salvar o histórico: %v\n", err)
+			} else {
+				fmt.Printf("Histórico salvo em %s.\n", *outFlag)
+			}
+		}
+		if code != 0 {
+			fmt.Printf("Reproduza a carga com -seed %d -runs 1 (a intercalação das operações pode variar).\n", seed)
+			os.Exit(code)
+		}
+	}
+}
+
+// report verifica o histórico, exibe o resultado e retorna o código de saída.
+func report(model lincheck.Model, history []lincheck.Operation) int {
+	pending := 0
+	for _, op := range history {
+		if op.Return == lincheck.Pending {
+			pending++
+		}
+	}
+	start := time.Now()
+	result := lincheck.Check(model, history, *checkTimeoutFlag)
+	fmt.Printf("%d operações (%d com resultado desconhecido) em %d listas: %s (verificado em %s).\n",
+		result.Operations, pending, result.Partitions, result.Outcome, time.Since(start).Round(time.Millisecond))
+
+	switch result.Outcome {
+	case lincheck.Illegal:
+		fmt.Printf("Contraexemplo (%d operações; sem qualquer uma das respostas, o histórico seria linearizável; \"?\" é resultado desconhecido):\n", len(result.Counterexample))
+		fmt.Print(lincheck.Format(model, result.Counterexample))
+		return 1
+	case lincheck.Unknown:
+		return 3
+	}
+	return 0
+}
+
+// execute roda os clientes da rodada e retorna o histórico. As listas levam
+// o prefixo informado, para que rodadas no mesmo servidor não se misturem.
+func execute(seed int64, mix []weightedOp, newExecutor func() (executor, error), server *spawnedServer, prefix string) ([]lincheck.Operation, error) {
+	recorder := lincheck.NewRecorder()
+	executors := make([]executor, *clientsFlag)
+	for i := range executors {
+		e, err := newExecutor()
+		if err != nil {
+			return nil, err
+		}
+		executors[i] = e
+		defer e.close()
+	}
+
+	stopRestarts := make(chan struct{})
+	restartsDone := make(chan error, 1)
+	go func() {
+		restartsDone <- restartLoop(server, seed, stopRestarts)
+	}()
+
+	var wg sync.WaitGroup
+	for i, e := range executors {
+		wg.Add(1)
+		go func(clientID int, e executor) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed*1000 + int64(clientID)))
+			for seq := 0; seq < *opsFlag; seq++ {
+				in := lincheck.ListInput{List: fmt.Sprintf("%s-%d", prefix, rng.Intn(*listsFlag)), Op: pick(mix, rng)}
+				switch in.Op {
+				case "Append":
+					in.Value = clientID*1_000_000 + seq // Valores únicos, para respostas inequívocas.
+				case "Get":
+					in.Index = rng.Intn(4) - 1
+				case "Range":
+					in.Start, in.Stop = rng.Intn(3), rng.Intn(5)-1
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+				call := recorder.Now()
+				out, known := e.execute(ctx, in)
+				cancel()
+				recorder.Record(clientID, call, in, out, !known)
+			}
+		}(i, e)
+	}
+	wg.Wait()
+	close(stopRestarts)
+	if err := <-restartsDone; err != nil {
+		return nil, err
+	}
+	return recorder.History(), nil
+}
+
+// restartLoop derruba e reinicia o servidor -restarts vezes, em intervalos
+// aleatórios, até stop ser fechado.
+func restartLoop(server *spawnedServer, seed int64, stop chan struct{}) error {
+	if server == nil || *restartsFlag <= 0 {
+		return nil
+	}
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < *restartsFlag; i++ {
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(time.Duration(50+rng.Intn(250)) * time.Millisecond):
+		}
+		if err := server.restart(); err != nil {
+			return err
+		}
+		fmt.Printf("  servidor reiniciado (%d/%d)\n", i+1, *restartsFlag)
+	}
+	return nil
+}
+
+// --- Operações ---
+
+type weightedOp struct {
+	op     string
+	weight int
+}
+
+func parseMix(value string) ([]weightedOp, error) {
+	names := map[string]string{"append": "Append", "get": "Get", "remove": "Remove", "size": "Size", "range": "Range", "delete": "Delete"}
+	var mix []weightedOp
+	for _, part := range strings.Split(value, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		op, known := names[strings.ToLower(name)]
+		w, err := strconv.Atoi(weight)
+		if !ok || !known || err != nil || w < 0 {
+			return nil, fmt.Errorf("proporção inválida %q (use operação=peso, com append, get, remove, size, range e delete)", part)
+		}
+		if w > 0 {
+			mix = append(mix, weightedOp{op, w})
+		}
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("nenhuma operação com peso positivo em %q", value)
+	}
+	return mix, nil
+}
+
+func pick(mix []weightedOp, rng *rand.Rand) string {
+	total := 0
+	for _, w := range mix {
+		total += w.weight
+	}
+	n := rng.Intn(total)
+	for _, w := range mix {
+		if n < w.weight {
+			return w.op
+		}
+		n -= w.weight
+	}
+	return mix[len(mix)-1].op
+}
+
+// definiteCodes são os erros que fazem parte do modelo; os demais deixam o
+// resultado desconhecido.
+var definiteCodes = map[structures.ErrorCode]bool{
+	structures.CodeNotFound:   true,
+	structures.CodeEmpty:      true,
+	structures.CodeOutOfRange: true,
+}
+
+// outcome converte o erro de uma operação na resposta do modelo.
+func outcome(out lincheck.ListOutput, err error) (lincheck.ListOutput, bool) {
+	if err == nil {
+		return out, true
+	}
+	if code := client.Code(err); definiteCodes[code] {
+		return lincheck.ListOutput{Err: string(code)}, true
+	}
+	return lincheck.ListOutput{}, false
+}
+
+// rpcExecutor executa as operações no servidor, pela biblioteca cliente.
+type rpcExecutor struct {
+	c *client.Client
+}
+
+func (e rpcExecutor) execute(ctx context.Context, in lincheck.ListInput) (lincheck.ListOutput, bool) {
+	var out lincheck.ListOutput
+	var err error
+	switch in.Op {
+	case "Append":
+		err = e.c.Append(ctx, in.List, in.Value)
+	case "Get":
+		out.Value, err = e.c.Get(ctx, in.List, in.Index)
+	case "Remove":
+		out.Value, err = e.c.Remove(ctx, in.List)
+	case "Size":
+		out.Value, err = e.c.Size(ctx, in.List)
+	case "Range":
+		out.Values, err = e.c.Range(ctx, in.List, in.Start, in.Stop)
+	case "Delete":
+		err = e.c.Delete(ctx, in.List)
+	}
+	return outcome(out, err)
+}
+
+func (e rpcExecutor) close() { e.c.Close() }
+
+// localExecutor executa as operações diretamente em um RemoteList do
+// processo, sem RPC nem log: verifica apenas o controle de concorrência.
+type localExecutor struct {
+	rl *structures.RemoteList
+}
+
+func (e localExecutor) execute(ctx context.Context, in lincheck.ListInput) (lincheck.ListOutput, bool) {
+	var out lincheck.ListOutput
+	var err error
+	switch in.Op {
+	case "Append":
+		err = e.rl.Append(structures.AppendArgs{ListID: in.List, Value: in.Value}, new(bool))
+	case "Get":
+		err = e.rl.Get(structures.GetArgs{ListID: in.List, Index: in.Index}, &out.Value)
+	case "Remove":
+		err = e.rl.Remove(structures.RemoveArgs{ListID: in.List}, &out.Value)
+	case "Size":
+		err = e.rl.Size(structures.SizeArgs{ListID: in.List}, &out.Value)
+	case "Range":
+		err = e.rl.Range(structures.RangeArgs{ListID: in.List, Start: in.Start, Stop: in.Stop}, &out.Values)
+		if len(out.Values) == 0 {
+			out.Values = nil
+		}
+	case "Delete":
+		err = e.rl.Delete(structures.DeleteArgs{ListID: in.List}, new(bool))
+	}
+	return outcome(out, err)
+}
+
+func (localExecutor) close() {}
+
+func saveHistory(path string, history []lincheck.Operation) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// --- Servidor em subprocesso ---
+
+// spawnedServer é um servidor iniciado por -spawn, em um diretório próprio.
+type spawnedServer struct {
+	binary string
+	dir    string
+	cmd    *exec.Cmd
+	exited chan error
+
+	stopOnce sync.Once
+}
+
+func spawnServer() (*spawnedServer, error) {
+	dir, err := os.MkdirTemp("", "lincheck-server-")
+	if err != nil {
+		return nil, err
+	}
+	binary := *serverFlag
+	if binary == "" {
+		binary = filepath.Join(dir, "server")
+		fmt.Println("Compilando server.go...")
+		if output, err := exec.Command("go", "build", "-o", binary, "server.go").CombinedOutput(); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("go build server.go: %v\n%s", err, output)
+		}
+	} else if binary, err = filepath.Abs(binary); err != nil {
+		return nil, err
+	}
+	s := &spawnedServer{binary: binary, dir: dir}
+	if serverAnswers() {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("já há um servidor respondendo em %s; encerre-o ou use -addr sem -spawn", *addrFlag)
+	}
+	if err := s.start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	fmt.Printf("Servidor iniciado em %s.\n", dir)
+	return s, nil
+}
+
+// serverAnswers indica se algum servidor responde ao Ping em -addr.
+func serverAnswers() bool {
+	opts := client.DefaultOptions(*addrFlag)
+	opts.Token = *tokenFlag
+	opts.MaxAttempts = 1
+	c, err := client.New(opts)
+	if err != nil {
+		return false
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err = c.Ping(ctx)
+	return err == nil
+}
+
+// start inicia o processo e espera o servidor responder ao Ping.
+func (s *spawnedServer) start() error {
+	s.cmd = exec.Command(s.binary, "-server-id", "lincheck", "-log-level", "warn")
+	s.cmd.Dir = s.dir
+	logFile, err := os.OpenFile(filepath.Join(s.dir, "server.out"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.cmd.Stdout, s.cmd.Stderr = logFile, logFile
+	if err := s.cmd.Start(); err != nil {
+		logFile.Close()
+		return err
+	}
+	s.exited = make(chan error, 1)
+	go func() {
+		s.exited <- s.cmd.Wait()
+		logFile.Close()
+	}()
+
+	opts := client.DefaultOptions(*addrFlag)
+	opts.Token = *tokenFlag
+	opts.MaxAttempts = 1
+	c, err := client.New(opts)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		select {
+		case err := <-s.exited:
+			return fmt.Errorf("o servidor terminou durante a inicialização (%v); veja %s", err, filepath.Join(s.dir, "server.out"))
+		default:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		reply, err := c.Ping(ctx)
+		cancel()
+		if err == nil && reply.Ready {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("o servidor não ficou pronto em 15s: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// restart derruba o processo sem aviso (kill -9) e o inicia de novo, no
+// mesmo diretório.
+func (s *spawnedServer) restart() error {
+	s.cmd.Process.Kill()
+	<-s.exited
+	return s.start()
+}
+
+func (s *spawnedServer) stop() {
+	s.stopOnce.Do(func() {
+		s.cmd.Process.Kill()
+		<-s.exited
+		os.RemoveAll(s.dir)
+	})
+}
@@ -0,0 +1,358 @@
+// Package lincheck verifica se um histórico de operações concorrentes é
+// linearizável: se existe uma ordem sequencial das operações, compatível com
+// os intervalos de tempo em que cada uma aconteceu, em que todas as respostas
+// são as de um modelo sequencial.
+//
+// A busca é a de Wing e Gong com a memorização de Lowe: as operações são
+// linearizadas uma a uma, sempre entre as que começaram antes do fim da
+// primeira ainda pendente, voltando atrás quando nenhuma serve; pares
+// (operações já linearizadas, estado) visitados não são repetidos. O
+// histórico é dividido em partes independentes (no modelo de listas, uma por
+// lista), verificadas em separado.
+package lincheck
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Pending é o instante de resposta de uma operação cujo resultado não se
+// sabe (erro de conexão, tempo esgotado): ela pode ter acontecido em qualquer
+// momento após a chamada, ou não ter acontecido.
+const Pending = math.MaxInt64
+
+// Operation é uma operação do histórico.
+type Operation struct {
+	Client int   `json:"client"` // Cliente que fez a chamada.
+	Call   int64 `json:"call"`   // Instante da chamada (ns desde o início do histórico).
+	Return int64 `json:"return"` // Instante da resposta, ou Pending.
+	Input  any   `json:"input"`
+	Output any   `json:"output"` // Ignorada pelo modelo se Return for Pending.
+}
+
+// Model é o modelo sequencial do objeto verificado.
+type Model struct {
+	// Partition divide o histórico em partes independentes; nil verifica o
+	// histórico inteiro de uma vez.
+	Partition func(history []Operation) [][]Operation
+	// Init retorna o estado inicial.
+	Init func() any
+	// Step aplica a operação ao estado e informa se a saída é a esperada.
+	// Para operações pendentes, output é nil e qualquer saída é aceita.
+	Step func(state, input, output any) (bool, any)
+	// Key identifica o estado, para a memorização.
+	Key func(state any) string
+	// Describe descreve a operação, para o contraexemplo.
+	Describe func(input, output any) string
+	// ReadOnly informa se a operação nunca altera o estado; essas operações
+	// podem ser retiradas do contraexemplo. nil trata todas como alterações.
+	ReadOnly func(input any) bool
+}
+
+// Outcome é o resultado de uma verificação.
+type Outcome int
+
+const (
+	Ok      Outcome = iota // O histórico é linearizável.
+	Illegal                // O histórico não é linearizável.
+	Unknown                // A verificação não terminou no tempo limite.
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Ok:
+		return "linearizável"
+	case Illegal:
+		return "NÃO linearizável"
+	}
+	return "inconclusivo (tempo esgotado)"
+}
+
+// Result é o resultado de Check.
+type Result struct {
+	Outcome Outcome
+	// Counterexample é um histórico reduzido, ainda não linearizável, de uma
+	// das partes: cada operação com resposta é necessária (sem ela, ou com a
+	// resposta desconhecida, o histórico passaria a ser linearizável); as
+	// pendentes são o contexto. Vazio se Outcome não for Illegal.
+	Counterexample []Operation
+	Partitions     int // Partes verificadas.
+	Operations     int // Operações verificadas.
+}
+
+// Check verifica o histórico. Se timeout for positivo e a verificação não
+// terminar nesse tempo, o resultado é Unknown.
+func Check(model Model, history []Operation, timeout time.Duration) Result {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	partitions := [][]Operation{history}
+	if model.Partition != nil {
+		partitions = model.Partition(history)
+	}
+
+	result := Result{Outcome: Ok, Partitions: len(partitions), Operations: len(history)}
+	for _, partition := range partitions {
+		switch checkPartition(model, partition, deadline) {
+		case Illegal:
+			result.Outcome = Illegal
+			result.Counterexample = shrink(model, partition, deadline)
+			return result
+		case Unknown:
+			result.Outcome = Unknown
+		}
+	}
+	return result
+}
+
+// entry é a chamada ou a resposta de uma operação, na lista duplamente
+// encadeada da busca.
+type entry struct {
+	id         int
+	call       bool
+	time       int64
+	op         *Operation
+	match      *entry // Na chamada, a resposta correspondente.
+	prev, next *entry
+}
+
+// buildEntries monta a lista de chamadas e respostas em ordem de tempo, com
+// as chamadas antes das respostas no mesmo instante (tratadas como
+// concorrentes). Retorna o nó sentinela do início.
+func buildEntries(history []Operation) *entry {
+	entries := make([]*entry, 0, 2*len(history))
+	for i := range history {
+		op := &history[i]
+		call := &entry{id: i, call: true, time: op.Call, op: op}
+		ret := &entry{id: i, time: op.Return, op: op}
+		call.match = ret
+		entries = append(entries, call, ret)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].time != entries[j].time {
+			return entries[i].time < entries[j].time
+		}
+		return entries[i].call && !entries[j].call
+	})
+
+	head := &entry{id: -1}
+	last := head
+	for _, e := range entries {
+		last.next, e.prev = e, last
+		last = e
+	}
+	return head
+}
+
+// lift retira a chamada e sua resposta da lista.
+func lift(call *entry) {
+	call.prev.next = call.next
+	if call.next != nil {
+		call.next.prev = call.prev
+	}
+	ret := call.match
+	ret.prev.next = ret.next
+	if ret.next != nil {
+		ret.next.prev = ret.prev
+	}
+}
+
+// unlift devolve à lista a chamada e a resposta retiradas por lift.
+func unlift(call *entry) {
+	ret := call.match
+	ret.prev.next = ret
+	if ret.next != nil {
+		ret.next.prev = ret
+	}
+	call.prev.next = call
+	if call.next != nil {
+		call.next.prev = call
+	}
+}
+
+// bitset marca as operações já linearizadas.
+type bitset []uint64
+
+func newBitset(n int) bitset { return make(bitset, (n+63)/64) }
+
+func (b bitset) set(i int)   { b[i/64] |= 1 << (uint(i) % 64) }
+func (b bitset) clear(i int) { b[i/64] &^= 1 << (uint(i) % 64) }
+
+func (b bitset) key() string {
+	buf := make([]byte, 8*len(b))
+	for i, word := range b {
+		for j := 0; j < 8; j++ {
+			buf[8*i+j] = byte(word >> (8 * j))
+		}
+	}
+	return string(buf)
+}
+
+// nextCandidate retorna a próxima operação que pode ser linearizada agora
+// (as chamadas antes da primeira resposta da lista) depois de after, ou a
+// primeira se after for nil; nil se não houver mais. As pendentes vêm por
+// último: como podem ser linearizadas em qualquer ponto até o fim, tentá-las
+// primeiro faz a busca percorrer todas as posições possíveis de cada uma.
+func nextCandidate(head, after *entry) *entry {
+	pending := false
+	start := head.next
+	if after != nil {
+		pending = after.op.Return == Pending
+		start = after.next
+	}
+	for {
+		for e := start; e != nil && e.call; e = e.next {
+			if (e.op.Return == Pending) == pending {
+				return e
+			}
+		}
+		if pending {
+			return nil
+		}
+		pending, start = true, head.next
+	}
+}
+
+// checkPartition verifica uma parte do histórico.
+func checkPartition(model Model, history []Operation, deadline time.Time) Outcome {
+	if len(history) == 0 {
+		return Ok
+	}
+	head := buildEntries(history)
+	linearized := newBitset(len(history))
+	visited := make(map[string]struct{})
+	state := model.Init()
+
+	type frame struct {
+		call  *entry
+		state any
+	}
+	var stack []frame
+
+	current := nextCandidate(head, nil)
+	for steps := 0; head.next != nil; steps++ {
+		if steps%4096 == 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			return Unknown
+		}
+		if current != nil {
+			output := current.op.Output
+			if current.op.Return == Pending {
+				output = nil
+			}
+			if ok, next := model.Step(state, current.op.Input, output); ok {
+				linearized.set(current.id)
+				key := linearized.key() + "\x00" + model.Key(next)
+				if _, seen := visited[key]; !seen {
+					visited[key] = struct{}{}
+					stack = append(stack, frame{current, state})
+					state = next
+					lift(current)
+					current = nextCandidate(head, nil)
+					continue
+				}
+				linearized.clear(current.id)
+			}
+			current = nextCandidate(head, current)
+			continue
+		}
+
+		// Nenhuma candidata serve: volta atrás.
+		if len(stack) == 0 {
+			return Illegal
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		state = top.state
+		linearized.clear(top.call.id)
+		unlift(top.call)
+		current = nextCandidate(head, top.call)
+	}
+	return Ok
+}
+
+// shrink reduz uma parte não linearizável a um contraexemplo. Só usa
+// reduções que preservam a violação (se o histórico reduzido não é
+// linearizável, o original também não é): cortar no menor prefixo (por
+// instante de chamada) ainda não linearizável, com as operações em andamento
+// no corte tratadas como pendentes; retirar leituras; tornar pendente uma
+// operação que altera o estado; e retirar pendentes chamadas depois da
+// resposta de todas as outras. Retirar uma operação que altera o estado não
+// é uma delas: sem um Append, por exemplo, qualquer leitura do valor
+// inserido vira uma violação. Se o tempo acabar, retorna o que já reduziu.
+func shrink(model Model, history []Operation, deadline time.Time) []Operation {
+	ops := append([]Operation(nil), history...)
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Call < ops[j].Call })
+	expired := func() bool { return !deadline.IsZero() && time.Now().After(deadline) }
+	illegal := func(candidate []Operation) bool { return checkPartition(model, candidate, deadline) == Illegal }
+	readOnly := func(op Operation) bool { return model.ReadOnly != nil && model.ReadOnly(op.Input) }
+
+	prefix := func(n int) []Operation {
+		cut := ops[n-1].Call
+		result := make([]Operation, n)
+		for i := range result {
+			result[i] = ops[i]
+			if result[i].Return > cut {
+				result[i].Return, result[i].Output = Pending, nil
+			}
+		}
+		return result
+	}
+	// Busca binária pelo menor prefixo não linearizável; o histórico
+	// inteiro (n = len(ops)) é o limite superior.
+	low, high := 1, len(ops)
+	best := ops
+	for low < high {
+		if expired() {
+			return best
+		}
+		mid := (low + high) / 2
+		if candidate := prefix(mid); illegal(candidate) {
+			best, high = candidate, mid
+		} else {
+			low = mid + 1
+		}
+	}
+	if high < len(ops) {
+		best = prefix(high)
+	}
+
+	// Uma passada basta: se uma redução deixou o histórico linearizável, as
+	// reduções seguintes, que só o enfraquecem, não mudam isso.
+	for i := 0; i < len(best) && !expired(); {
+		op := best[i]
+		switch {
+		case readOnly(op) && op.Return == Pending:
+			// Leitura pendente: não restringe nada.
+			best = append(best[:i:i], best[i+1:]...)
+			continue
+		case readOnly(op):
+			if candidate := append(best[:i:i], best[i+1:]...); illegal(candidate) {
+				best = candidate
+				continue
+			}
+		case op.Return != Pending:
+			candidate := append([]Operation(nil), best...)
+			candidate[i].Return, candidate[i].Output = Pending, nil
+			if illegal(candidate) {
+				best = candidate
+			}
+		}
+		i++
+	}
+
+	var lastReturn int64
+	for _, op := range best {
+		if op.Return != Pending && op.Return > lastReturn {
+			lastReturn = op.Return
+		}
+	}
+	result := best[:0:0]
+	for _, op := range best {
+		if op.Return != Pending || op.Call < lastReturn {
+			result = append(result, op)
+		}
+	}
+	return result
+}
@@ -0,0 +1,224 @@
+package lincheck
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// op monta uma operação do modelo de listas. Com ret == Pending, a saída é
+// descartada.
+func op(client int, call, ret int64, in ListInput, out ListOutput) Operation {
+	operation := Operation{Client: client, Call: call, Return: ret, Input: in, Output: out}
+	if ret == Pending {
+		operation.Output = nil
+	}
+	return operation
+}
+
+func appendOp(list string, value int) ListInput {
+	return ListInput{List: list, Op: "Append", Value: value}
+}
+func getOp(list string, index int) ListInput { return ListInput{List: list, Op: "Get", Index: index} }
+func removeOp(list string) ListInput         { return ListInput{List: list, Op: "Remove"} }
+func sizeOp(list string) ListInput           { return ListInput{List: list, Op: "Size"} }
+func rangeOp(list string) ListInput          { return ListInput{List: list, Op: "Range", Start: 0, Stop: -1} }
+
+var (
+	ok       = ListOutput{}
+	notFound = ListOutput{Err: "NOT_FOUND"}
+	empty    = ListOutput{Err: "EMPTY"}
+)
+
+func value(v int) ListOutput     { return ListOutput{Value: v} }
+func values(v ...int) ListOutput { return ListOutput{Values: v} }
+func check(h []Operation) Result { return Check(ListModel(), h, 0) }
+
+func TestLinearizableHistories(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []Operation
+	}{
+		{"vazio", nil},
+		{"sequencial", []Operation{
+			op(0, 0, 1, appendOp("a", 1), ok),
+			op(0, 2, 3, getOp("a", 0), value(1)),
+			op(0, 4, 5, removeOp("a"), value(1)),
+			op(0, 6, 7, removeOp("a"), empty),
+		}},
+		{"leitura concorrente vê o estado anterior", []Operation{
+			op(0, 0, 10, appendOp("a", 1), ok),
+			op(1, 1, 2, getOp("a", 0), notFound),
+			op(1, 3, 4, getOp("a", 0), value(1)),
+		}},
+		{"appends concorrentes em qualquer ordem", []Operation{
+			op(0, 0, 10, appendOp("a", 1), ok),
+			op(1, 0, 10, appendOp("a", 2), ok),
+			op(2, 11, 12, rangeOp("a"), values(2, 1)),
+		}},
+		{"remoções concorrentes recebem valores diferentes", []Operation{
+			op(0, 0, 1, appendOp("a", 1), ok),
+			op(0, 2, 3, appendOp("a", 2), ok),
+			op(1, 4, 8, removeOp("a"), value(1)),
+			op(2, 5, 7, removeOp("a"), value(2)),
+		}},
+		{"pendente observada depois", []Operation{
+			op(0, 0, Pending, appendOp("a", 1), ok),
+			op(1, 5, 6, getOp("a", 0), value(1)),
+		}},
+		{"pendente nunca observada", []Operation{
+			op(0, 0, Pending, appendOp("a", 1), ok),
+			op(1, 5, 6, sizeOp("a"), notFound),
+		}},
+		{"listas independentes", []Operation{
+			op(0, 0, 1, appendOp("a", 1), ok),
+			op(1, 0, 1, appendOp("b", 2), ok),
+			op(0, 2, 3, sizeOp("b"), value(1)),
+			op(1, 2, 3, getOp("a", 0), value(1)),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := check(tt.history)
+			if result.Outcome != Ok {
+				t.Fatalf("resultado %v, esperado linearizável; contraexemplo:\n%s", result.Outcome, Format(ListModel(), result.Counterexample))
+			}
+			if len(result.Counterexample) != 0 {
+				t.Errorf("contraexemplo em histórico linearizável: %v", result.Counterexample)
+			}
+		})
+	}
+}
+
+func TestNonLinearizableHistories(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []Operation
+	}{
+		{"leitura antiga após a resposta", []Operation{
+			op(0, 0, 1, appendOp("a", 1), ok),
+			op(1, 2, 3, getOp("a", 0), notFound),
+		}},
+		{"mesmo valor removido duas vezes", []Operation{
+			op(0, 0, 1, appendOp("a", 1), ok),
+			op(0, 2, 3, appendOp("a", 2), ok),
+			op(1, 4, 8, removeOp("a"), value(2)),
+			op(2, 5, 7, removeOp("a"), value(2)),
+		}},
+		{"ordem invertida de appends sequenciais", []Operation{
+			op(0, 0, 1, appendOp("a", 1), ok),
+			op(0, 2, 3, appendOp("a", 2), ok),
+			op(1, 4, 5, rangeOp("a"), values(2, 1)),
+		}},
+		{"valor nunca inserido", []Operation{
+			op(0, 0, 1, appendOp("a", 1), ok),
+			op(1, 0, 2, getOp("a", 0), value(7)),
+		}},
+		{"leitura volta no tempo", []Operation{
+			op(0, 0, 1, appendOp("a", 1), ok),
+			op(1, 2, 10, removeOp("a"), value(1)),
+			op(2, 3, 4, sizeOp("a"), value(0)),
+			op(3, 5, 6, sizeOp("a"), value(1)),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := check(tt.history)
+			if result.Outcome != Illegal {
+				t.Fatalf("resultado %v, esperado NÃO linearizável", result.Outcome)
+			}
+			if len(result.Counterexample) == 0 {
+				t.Fatal("sem contraexemplo")
+			}
+			// O contraexemplo também não pode ser linearizável.
+			if again := check(result.Counterexample); again.Outcome != Illegal {
+				t.Errorf("contraexemplo é %v:\n%s", again.Outcome, Format(ListModel(), result.Counterexample))
+			}
+		})
+	}
+}
+
+// TestCounterexample confere a redução: só a lista com a violação, sem as
+// leituras dispensáveis e com o resultado desconhecido nas operações cuja
+// resposta não importa. Size(a) -> 1 fica: sem ele, Append(a, 1), agora
+// pendente, poderia ter ocorrido depois de Append(a, 2).
+func TestCounterexample(t *testing.T) {
+	history := []Operation{
+		op(0, 0, 1, appendOp("a", 1), ok),
+		op(3, 0, 1, appendOp("b", 9), ok),
+		op(1, 2, 3, sizeOp("a"), value(1)),
+		op(0, 4, 5, appendOp("a", 2), ok),
+		op(3, 4, 5, getOp("b", 0), value(9)),
+		op(1, 6, 7, rangeOp("a"), values(2, 1)),
+		op(2, 8, 9, sizeOp("a"), value(2)),
+	}
+	result := check(history)
+	if result.Outcome != Illegal {
+		t.Fatalf("resultado %v, esperado NÃO linearizável", result.Outcome)
+	}
+	if result.Partitions != 2 || result.Operations != len(history) {
+		t.Errorf("Partitions = %d, Operations = %d; esperado 2 e %d", result.Partitions, result.Operations, len(history))
+	}
+
+	want := []Operation{
+		op(0, 0, Pending, appendOp("a", 1), ok),
+		op(1, 2, 3, sizeOp("a"), value(1)),
+		op(0, 4, Pending, appendOp("a", 2), ok),
+		op(1, 6, 7, rangeOp("a"), values(2, 1)),
+	}
+	if !reflect.DeepEqual(result.Counterexample, want) {
+		t.Fatalf("contraexemplo:\n%s\nesperado:\n%s", Format(ListModel(), result.Counterexample), Format(ListModel(), want))
+	}
+
+	formatted := Format(ListModel(), result.Counterexample)
+	for _, line := range []string{"Append(a, 1) -> ?", "Size(a) -> 1", "Append(a, 2) -> ?", "Range(a, 0, -1) -> [2 1]"} {
+		if !strings.Contains(formatted, line) {
+			t.Errorf("contraexemplo formatado não contém %q:\n%s", line, formatted)
+		}
+	}
+	if strings.Contains(formatted, "(b") || strings.Contains(formatted, "Size(a) -> 2") {
+		t.Errorf("contraexemplo deveria ter só as operações necessárias da lista a:\n%s", formatted)
+	}
+}
+
+func TestReadListHistory(t *testing.T) {
+	input := `[
+		{"client": 0, "call": 0, "return": 1, "input": {"list": "a", "op": "Append", "value": 1}, "output": {}},
+		{"client": 1, "call": 2, "return": 3, "input": {"list": "a", "op": "Get"}, "output": {"err": "NOT_FOUND"}}
+	]`
+	history, err := ReadListHistory(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Operation{
+		op(0, 0, 1, appendOp("a", 1), ok),
+		op(1, 2, 3, getOp("a", 0), notFound),
+	}
+	if !reflect.DeepEqual(history, want) {
+		t.Fatalf("lido %v, esperado %v", history, want)
+	}
+	if result := check(history); result.Outcome != Illegal {
+		t.Errorf("resultado %v, esperado NÃO linearizável", result.Outcome)
+	}
+	if _, err := ReadListHistory(strings.NewReader("{")); err == nil {
+		t.Error("JSON inválido deveria falhar")
+	}
+}
+
+func TestRecorderPending(t *testing.T) {
+	r := NewRecorder()
+	call := r.Now()
+	r.Record(0, call, appendOp("a", 1), ok, false)
+	r.Record(1, call, appendOp("a", 2), ok, true)
+
+	history := r.History()
+	if len(history) != 2 {
+		t.Fatalf("%d operações registradas, esperado 2", len(history))
+	}
+	if history[0].Return == Pending || history[0].Return < call {
+		t.Errorf("operação respondida com Return = %d", history[0].Return)
+	}
+	if history[1].Return != Pending || history[1].Output != nil {
+		t.Errorf("operação pendente com Return = %d e Output = %v", history[1].Return, history[1].Output)
+	}
+}
@@ -0,0 +1,72 @@
+package lincheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recorder registra as operações de vários clientes concorrentes. Os
+// instantes usam o relógio monotônico, a partir da criação do Recorder.
+type Recorder struct {
+	mu    sync.Mutex
+	start time.Time
+	ops   []Operation
+}
+
+// NewRecorder cria um Recorder vazio.
+func NewRecorder() *Recorder {
+	return &Recorder{start: time.Now()}
+}
+
+// Now retorna o instante atual do histórico, para marcar a chamada.
+func (r *Recorder) Now() int64 {
+	return int64(time.Since(r.start))
+}
+
+// Record registra uma operação chamada em call e respondida agora. Se
+// pending, o resultado não se sabe e a operação fica pendente.
+func (r *Recorder) Record(client int, call int64, input, output any, pending bool) {
+	op := Operation{Client: client, Call: call, Return: r.Now(), Input: input, Output: output}
+	if pending {
+		op.Return, op.Output = Pending, nil
+	}
+	r.mu.Lock()
+	r.ops = append(r.ops, op)
+	r.mu.Unlock()
+}
+
+// History retorna uma cópia das operações registradas.
+func (r *Recorder) History() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Operation(nil), r.ops...)
+}
+
+// Format descreve as operações em ordem de chamada, com o cliente e o
+// intervalo de cada uma em milissegundos desde a primeira chamada.
+func Format(model Model, ops []Operation) string {
+	sorted := append([]Operation(nil), ops...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Call < sorted[j].Call })
+
+	var origin int64
+	if len(sorted) > 0 {
+		origin = sorted[0].Call
+	}
+	ms := func(t int64) string { return fmt.Sprintf("%.3f", float64(t-origin)/1e6) }
+
+	var b strings.Builder
+	for _, op := range sorted {
+		end := "..."
+		output := op.Output
+		if op.Return == Pending {
+			output = nil
+		} else {
+			end = ms(op.Return)
+		}
+		fmt.Fprintf(&b, "  cliente %-3d [%10s, %10s] ms  %s\n", op.Client, ms(op.Call), end, model.Describe(op.Input, output))
+	}
+	return b.String()
+}
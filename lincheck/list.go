@@ -0,0 +1,228 @@
+package lincheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ListInput é uma operação sobre uma lista do RemoteList.
+type ListInput struct {
+	List  string `json:"list"`
+	Op    string `json:"op"` // Append, Get, Remove, Size, Range ou Delete.
+	Value int    `json:"value,omitempty"`
+	Index int    `json:"index,omitempty"`
+	Start int    `json:"start,omitempty"`
+	Stop  int    `json:"stop,omitempty"`
+}
+
+// ListOutput é a resposta de uma operação sobre uma lista.
+type ListOutput struct {
+	Value  int    `json:"value,omitempty"`  // Get, Remove e Size.
+	Values []int  `json:"values,omitempty"` // Range.
+	Err    string `json:"err,omitempty"`    // Código do erro (NOT_FOUND, EMPTY, OUT_OF_RANGE...), vazio se a operação teve sucesso.
+}
+
+// listState é o estado de uma lista no modelo. Não é alterado após criado:
+// Step sempre devolve um novo estado.
+type listState struct {
+	exists   bool
+	elements []int
+}
+
+// ListModel é o modelo sequencial do RemoteList, com as operações de cada
+// lista independentes das demais. As respostas esperadas, inclusive os
+// erros, são as de structures.RemoteList.
+func ListModel() Model {
+	return Model{
+		Partition: partitionByList,
+		Init:      func() any { return listState{} },
+		Step:      stepList,
+		Key: func(state any) string {
+			s := state.(listState)
+			if !s.exists {
+				return "-"
+			}
+			var b strings.Builder
+			for _, element := range s.elements {
+				b.WriteString(strconv.Itoa(element))
+				b.WriteByte(',')
+			}
+			return b.String()
+		},
+		Describe: describeList,
+		ReadOnly: func(input any) bool {
+			switch input.(ListInput).Op {
+			case "Get", "Size", "Range":
+				return true
+			}
+			return false
+		},
+	}
+}
+
+func partitionByList(history []Operation) [][]Operation {
+	index := make(map[string]int)
+	var partitions [][]Operation
+	for _, op := range history {
+		list := op.Input.(ListInput).List
+		i, ok := index[list]
+		if !ok {
+			i = len(partitions)
+			index[list] = i
+			partitions = append(partitions, nil)
+		}
+		partitions[i] = append(partitions[i], op)
+	}
+	return partitions
+}
+
+func stepList(state, input, output any) (bool, any) {
+	s := state.(listState)
+	in := input.(ListInput)
+	out, known := output.(ListOutput)
+	if output == nil {
+		known = false
+	}
+	size := len(s.elements)
+
+	// expect confere a saída conhecida com a esperada.
+	expect := func(want ListOutput) bool {
+		if !known {
+			return true
+		}
+		if out.Err != want.Err || out.Value != want.Value || len(out.Values) != len(want.Values) {
+			return false
+		}
+		for i := range want.Values {
+			if out.Values[i] != want.Values[i] {
+				return false
+			}
+		}
+		return true
+	}
+	notFound := ListOutput{Err: "NOT_FOUND"}
+
+	switch in.Op {
+	case "Append":
+		if known && out.Err != "" {
+			return false, state // Falhas definitivas não chegam ao histórico.
+		}
+		elements := append(append(make([]int, 0, size+1), s.elements...), in.Value)
+		return true, listState{exists: true, elements: elements}
+
+	case "Get":
+		switch {
+		case !s.exists:
+			return expect(notFound), state
+		case in.Index < 0 || in.Index >= size:
+			return expect(ListOutput{Err: "OUT_OF_RANGE"}), state
+		}
+		return expect(ListOutput{Value: s.elements[in.Index]}), state
+
+	case "Remove":
+		switch {
+		case !s.exists:
+			return expect(notFound), state
+		case size == 0:
+			return expect(ListOutput{Err: "EMPTY"}), state
+		}
+		if !expect(ListOutput{Value: s.elements[size-1]}) {
+			return false, state
+		}
+		return true, listState{exists: true, elements: s.elements[: size-1 : size-1]}
+
+	case "Size":
+		if !s.exists {
+			return expect(notFound), state
+		}
+		return expect(ListOutput{Value: size}), state
+
+	case "Range":
+		if !s.exists {
+			return expect(notFound), state
+		}
+		start, stop := in.Start, in.Stop
+		if start < 0 {
+			start += size
+		}
+		if stop < 0 {
+			stop += size
+		}
+		if start < 0 {
+			start = 0
+		}
+		if stop >= size {
+			stop = size - 1
+		}
+		var values []int
+		if start <= stop {
+			values = s.elements[start : stop+1]
+		}
+		return expect(ListOutput{Values: values}), state
+
+	case "Delete":
+		if !s.exists {
+			return expect(notFound), state
+		}
+		if !expect(ListOutput{}) {
+			return false, state
+		}
+		return true, listState{}
+	}
+	return false, state
+}
+
+func describeList(input, output any) string {
+	in := input.(ListInput)
+	var call string
+	switch in.Op {
+	case "Append":
+		call = fmt.Sprintf("Append(%s, %d)", in.List, in.Value)
+	case "Get":
+		call = fmt.Sprintf("Get(%s, %d)", in.List, in.Index)
+	case "Range":
+		call = fmt.Sprintf("Range(%s, %d, %d)", in.List, in.Start, in.Stop)
+	default:
+		call = fmt.Sprintf("%s(%s)", in.Op, in.List)
+	}
+
+	out, ok := output.(ListOutput)
+	switch {
+	case output == nil || !ok:
+		return call + " -> ?"
+	case out.Err != "":
+		return call + " -> " + out.Err
+	case in.Op == "Append" || in.Op == "Delete":
+		return call + " -> ok"
+	case in.Op == "Range":
+		return fmt.Sprintf("%s -> %v", call, out.Values)
+	}
+	return fmt.Sprintf("%s -> %d", call, out.Value)
+}
+
+// listOperationJSON é Operation com os tipos do modelo de listas, para a
+// leitura de históricos salvos.
+type listOperationJSON struct {
+	Client int        `json:"client"`
+	Call   int64      `json:"call"`
+	Return int64      `json:"return"`
+	Input  ListInput  `json:"input"`
+	Output ListOutput `json:"output"`
+}
+
+// ReadListHistory lê um histórico do modelo de listas salvo em JSON (uma
+// lista de Operation).
+func ReadListHistory(r io.Reader) ([]Operation, error) {
+	var decoded []listOperationJSON
+	if err := json.NewDecoder(r).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("histórico inválido: %w", err)
+	}
+	history := make([]Operation, len(decoded))
+	for i, op := range decoded {
+		history[i] = Operation{Client: op.Client, Call: op.Call, Return: op.Return, Input: op.Input, Output: op.Output}
+	}
+	return history, nil
+}
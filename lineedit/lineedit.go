@@ -0,0 +1,330 @@
+// Package lineedit lê linhas do terminal com edição, histórico persistente e
+// complementação com Tab, para o modo interativo do client.go. Sem terminal
+// (ou fora do Linux), lê linhas inteiras, sem edição; o histórico continua
+// sendo gravado.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInterrupted é retornado por ReadLine quando o usuário tecla Ctrl-C.
+var ErrInterrupted = errors.New("linha interrompida")
+
+// DefaultMaxHistory é a quantidade padrão de linhas mantidas no histórico.
+const DefaultMaxHistory = 1000
+
+// Completer retorna as opções de complementação para a palavra que termina no
+// cursor: line é o texto antes do cursor, e start, a posição (em bytes) onde
+// a palavra começa. Todas as opções devem começar com line[start:].
+type Completer func(line string) (start int, candidates []string)
+
+// Editor lê linhas de um terminal. Não é seguro para uso concorrente.
+type Editor struct {
+	in     *os.File
+	reader *bufio.Reader
+	out    io.Writer
+
+	// Complete, se definido, é chamado ao teclar Tab.
+	Complete Completer
+	// MaxHistory limita as linhas mantidas no histórico (e no arquivo).
+	MaxHistory int
+
+	history     []string
+	historyPath string // Arquivo do histórico; vazio se não for persistente.
+}
+
+// New cria um Editor que lê de in e escreve (o prompt e a linha em edição) em out.
+func New(in *os.File, out io.Writer) *Editor {
+	return &Editor{in: in, reader: bufio.NewReader(in), out: out, MaxHistory: DefaultMaxHistory}
+}
+
+// LoadHistory lê o histórico do arquivo e passa a acrescentar a ele as linhas
+// adicionadas com AddHistory. Um arquivo inexistente é criado na primeira
+// linha; um maior que MaxHistory é reescrito só com as últimas.
+func (e *Editor) LoadHistory(path string) error {
+	e.historyPath = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	e.history = e.history[:0]
+	for _, line := range lines {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > e.MaxHistory {
+		e.history = e.history[len(e.history)-e.MaxHistory:]
+		return os.WriteFile(path, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+	}
+	return nil
+}
+
+// AddHistory acrescenta a linha ao histórico (e ao arquivo, se houver).
+// Linhas vazias e repetições da anterior são ignoradas.
+func (e *Editor) AddHistory(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return nil
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > e.MaxHistory {
+		e.history = e.history[len(e.history)-e.MaxHistory:]
+	}
+	if e.historyPath == "" {
+		return nil
+	}
+	f, err := os.OpenFile(e.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadLine exibe o prompt e lê uma linha, sem o "\n". Retorna io.EOF com
+// Ctrl-D em uma linha vazia (ou no fim da entrada) e ErrInterrupted com Ctrl-C.
+//
+// Teclas: setas e Home/End para mover o cursor e navegar no histórico,
+// Backspace/Delete, Ctrl-A/Ctrl-E (início/fim), Ctrl-K/Ctrl-U (apaga até o
+// fim/início), Ctrl-W (apaga a palavra anterior), Ctrl-L (limpa a tela) e Tab
+// (complementa; duas vezes lista as opções).
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(int(e.in.Fd()))
+	if err != nil {
+		return e.readPlain(prompt)
+	}
+	defer restore()
+
+	s := &state{e: e, prompt: prompt, index: len(e.history)}
+	s.refresh()
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			fmt.Fprint(e.out, "\r\n")
+			if err == io.EOF && len(s.buf) > 0 {
+				return string(s.buf), nil
+			}
+			return "", err
+		}
+		tab := false
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(s.buf), nil
+		case ctrl('C'):
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case ctrl('D'):
+			if len(s.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			s.delete()
+		case 127, ctrl('H'):
+			if s.pos > 0 {
+				s.pos--
+				s.delete()
+			}
+		case ctrl('A'):
+			s.pos = 0
+		case ctrl('E'):
+			s.pos = len(s.buf)
+		case ctrl('B'):
+			s.move(-1)
+		case ctrl('F'):
+			s.move(1)
+		case ctrl('K'):
+			s.buf = s.buf[:s.pos]
+		case ctrl('U'):
+			s.buf = append([]rune(nil), s.buf[s.pos:]...)
+			s.pos = 0
+		case ctrl('W'):
+			s.deleteWord()
+		case ctrl('L'):
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case ctrl('P'):
+			s.historyMove(-1)
+		case ctrl('N'):
+			s.historyMove(1)
+		case '\t':
+			s.complete()
+			tab = true
+		case 27:
+			s.escape()
+		default:
+			if r >= ' ' && r != utf8.RuneError {
+				s.insert([]rune{r})
+			}
+		}
+		s.lastTab = tab
+		s.refresh()
+	}
+}
+
+// readPlain lê uma linha inteira, sem edição, quando o terminal não pode
+// entrar no modo raw.
+func (e *Editor) readPlain(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func ctrl(key rune) rune {
+	return key & 0x1f
+}
+
+// state é a linha em edição.
+type state struct {
+	e       *Editor
+	prompt  string
+	buf     []rune
+	pos     int    // Cursor, em runas.
+	index   int    // Posição no histórico; len(history) é a linha nova.
+	pending []rune // Linha nova, guardada ao navegar no histórico.
+	lastTab bool   // Se a tecla anterior foi Tab.
+}
+
+// refresh redesenha o prompt e a linha, com o cursor na posição.
+func (s *state) refresh() {
+	fmt.Fprintf(s.e.out, "\r%s%s\x1b[K", s.prompt, string(s.buf))
+	if back := len(s.buf) - s.pos; back > 0 {
+		fmt.Fprintf(s.e.out, "\x1b[%dD", back)
+	}
+}
+
+func (s *state) move(delta int) {
+	if pos := s.pos + delta; pos >= 0 && pos <= len(s.buf) {
+		s.pos = pos
+	}
+}
+
+func (s *state) insert(runes []rune) {
+	s.buf = append(s.buf[:s.pos], append(runes, s.buf[s.pos:]...)...)
+	s.pos += len(runes)
+}
+
+// delete apaga a runa sob o cursor.
+func (s *state) delete() {
+	if s.pos < len(s.buf) {
+		s.buf = append(s.buf[:s.pos], s.buf[s.pos+1:]...)
+	}
+}
+
+// deleteWord apaga a palavra antes do cursor (e os espaços depois dela).
+func (s *state) deleteWord() {
+	start := s.pos
+	for start > 0 && s.buf[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && s.buf[start-1] != ' ' {
+		start--
+	}
+	s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+	s.pos = start
+}
+
+// historyMove troca a linha pela anterior (delta -1) ou seguinte (+1) do histórico.
+func (s *state) historyMove(delta int) {
+	history := s.e.history
+	index := s.index + delta
+	if index < 0 || index > len(history) {
+		return
+	}
+	if s.index == len(history) {
+		s.pending = append([]rune(nil), s.buf...)
+	}
+	s.index = index
+	if index == len(history) {
+		s.buf = s.pending
+	} else {
+		s.buf = []rune(history[index])
+	}
+	s.pos = len(s.buf)
+}
+
+// escape trata as sequências das setas, Home, End e Delete.
+func (s *state) escape() {
+	r, _, err := s.e.reader.ReadRune()
+	if err != nil || r != '[' && r != 'O' {
+		return
+	}
+	code := ""
+	for {
+		r, _, err = s.e.reader.ReadRune()
+		if err != nil {
+			return
+		}
+		code += string(r)
+		if r < '0' || r > '9' && r != ';' {
+			break
+		}
+	}
+	switch code {
+	case "A":
+		s.historyMove(-1)
+	case "B":
+		s.historyMove(1)
+	case "C":
+		s.move(1)
+	case "D":
+		s.move(-1)
+	case "H", "1~", "7~":
+		s.pos = 0
+	case "F", "4~", "8~":
+		s.pos = len(s.buf)
+	case "3~":
+		s.delete()
+	}
+}
+
+// complete complementa a palavra no cursor com o prefixo comum das opções.
+// Sem avanço, um segundo Tab lista as opções.
+func (s *state) complete() {
+	if s.e.Complete == nil {
+		return
+	}
+	line := string(s.buf[:s.pos])
+	start, candidates := s.e.Complete(line)
+	if len(candidates) == 0 || start < 0 || start > len(line) {
+		fmt.Fprint(s.e.out, "\a")
+		return
+	}
+	word := line[start:]
+	common := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, common) {
+			_, size := utf8.DecodeLastRuneInString(common)
+			common = common[:len(common)-size]
+		}
+	}
+	if len(candidates) == 1 {
+		common += " "
+	}
+	if len(common) > len(word) && strings.HasPrefix(common, word) {
+		s.insert([]rune(common[len(word):]))
+		return
+	}
+	if !s.lastTab {
+		fmt.Fprint(s.e.out, "\a")
+		return
+	}
+	fmt.Fprintf(s.e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+}
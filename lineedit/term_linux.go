@@ -0,0 +1,32 @@
+package lineedit
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw desativa o eco, o modo canônico e os sinais do terminal fd, para
+// que as teclas cheguem uma a uma, e retorna a função que restaura o modo
+// anterior. A conversão de "\n" em "\r\n" na saída é mantida.
+func makeRaw(fd int) (restore func() error, err error) {
+	var old syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, &old); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return func() error { return ioctl(fd, syscall.TCSETS, &old) }, nil
+}
+
+func ioctl(fd int, request uintptr, termios *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(termios))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package lineedit
+
+import "errors"
+
+// makeRaw não é suportado fora do Linux; o Editor lê linhas inteiras, sem edição.
+func makeRaw(fd int) (restore func() error, err error) {
+	return nil, errors.New("modo raw do terminal não suportado neste sistema")
+}
@@ -0,0 +1,466 @@
+// loadgen gera carga contra o servidor RemoteList e mede a vazão e as
+// latências (média, p50, p99, p999) de cada operação. Os resultados podem ser
+// exportados em JSON e comparados com os de uma execução anterior.
+//
+// Uso: go run loadgen.go [-addr localhost:1234] [-clients 16] [-duration 30s] [-rate 0] [opções]
+//
+//	go run loadgen.go -duration 1m -out base.json                     # mede e salva os resultados
+//	go run loadgen.go -duration 1m -compare base.json                 # compara com a execução anterior
+//	go run loadgen.go -rate 2000 -dist zipf -keys 10000               # 2000 ops/s, poucas listas muito acessadas
+//	go run loadgen.go -mix append=80,remove=20 -value-size 12-18      # só escritas, com valores grandes
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"sd-miniprojeto-1/bench"
+	"sd-miniprojeto-1/client"
+)
+
+var (
+	addrFlag      = flag.String("addr", "localhost:1234", "Endereço do servidor.")
+	tokenFlag     = flag.String("token", os.Getenv("REMOTE_LIST_TOKEN"), "Token de acesso (padrão: $REMOTE_LIST_TOKEN).")
+	clientsFlag   = flag.Int("clients", 16, "Clientes concorrentes, cada um com a sua conexão.")
+	durationFlag  = flag.Duration("duration", 30*time.Second, "Duração da medição.")
+	warmupFlag    = flag.Duration("warmup", 2*time.Second, "Aquecimento antes da medição, com a mesma carga, não medido.")
+	rateFlag      = flag.Float64("rate", 0, "Vazão alvo, em operações por segundo no total; 0: o máximo possível (cada cliente faz uma chamada após a outra).")
+	mixFlag       = flag.String("mix", "append=30,get=35,size=10,range=15,remove=10", "Proporção das operações (append, get, remove, size, range e delete).")
+	keysFlag      = flag.Int("keys", 1000, "Quantidade de listas.")
+	distFlag      = flag.String("dist", "uniform", "Distribuição das listas acessadas: uniform ou zipf.")
+	zipfFlag      = flag.Float64("zipf-s", 1.1, "Expoente da distribuição zipf (> 1); maior, mais concentrada nas primeiras listas.")
+	valueSizeFlag = flag.String("value-size", "1-9", "Quantidade de dígitos dos valores inseridos, como mín-máx (até 18).")
+	rangeFlag     = flag.Int("range-size", 10, "Elementos lidos por Range.")
+	preloadFlag   = flag.Int("preload", 10, "Elementos inseridos em cada lista antes do aquecimento.")
+	prefixFlag    = flag.String("prefix", "loadgen", "Prefixo dos IDs das listas.")
+	cleanupFlag   = flag.Bool("cleanup", true, "Remove as listas no fim.")
+	seedFlag      = flag.Int64("seed", time.Now().UnixNano(), "Semente das escolhas aleatórias.")
+	timeoutFlag   = flag.Duration("timeout", 5*time.Second, "Tempo máximo de cada chamada.")
+	outFlag       = flag.String("out", "", "Salva os resultados (JSON) neste arquivo.")
+	compareFlag   = flag.String("compare", "", "Compara os resultados com os salvos (-out) de uma execução anterior.")
+)
+
+func main() {
+	flag.Parse()
+	mix, err := parseMix(*mixFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	minDigits, maxDigits, err := parseValueSize(*valueSizeFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *clientsFlag < 1 || *keysFlag < 1 || *durationFlag <= 0 {
+		fmt.Fprintln(os.Stderr, "-clients, -keys e -duration devem ser positivos")
+		os.Exit(2)
+	}
+	if *distFlag != "uniform" && (*distFlag != "zipf" || *zipfFlag <= 1) {
+		fmt.Fprintln(os.Stderr, "-dist deve ser uniform ou zipf (com -zipf-s maior que 1)")
+		os.Exit(2)
+	}
+	var base *bench.Report
+	if *compareFlag != "" {
+		r, err := bench.ReadReport(*compareFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao ler %s: %v\n", *compareFlag, err)
+			os.Exit(2)
+		}
+		base = &r
+	}
+
+	// Ctrl+C encerra a medição mais cedo, com os resultados até ali.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workers := make([]*worker, *clientsFlag)
+	for i := range workers {
+		opts := client.DefaultOptions(*addrFlag)
+		opts.Token = *tokenFlag
+		opts.PoolSize = 1
+		opts.MaxAttempts = 1 // Retentativas esconderiam falhas e distorceriam as latências.
+		c, err := client.New(opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao criar cliente: %v\n", err)
+			os.Exit(1)
+		}
+		defer c.Close()
+		workers[i] = newWorker(c, rand.New(rand.NewSource(*seedFlag+int64(i))), minDigits, maxDigits)
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, *timeoutFlag)
+	_, err = workers[0].client.Ping(pingCtx)
+	cancel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao conectar ao servidor: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *preloadFlag > 0 {
+		fmt.Printf("Inserindo %d elementos em cada uma das %d listas...\n", *preloadFlag, *keysFlag)
+		if err := preload(ctx, workers); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao preencher as listas: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	rateText := "máxima"
+	if *rateFlag > 0 {
+		rateText = fmt.Sprintf("%.0f ops/s", *rateFlag)
+	}
+	fmt.Printf("Carga: %d clientes, vazão %s, %d listas (%s), aquecimento de %s e medição de %s.\n",
+		*clientsFlag, rateText, *keysFlag, *distFlag, *warmupFlag, *durationFlag)
+
+	start := time.Now()
+	measureFrom := start.Add(*warmupFlag)
+	deadline := measureFrom.Add(*durationFlag)
+	var wg sync.WaitGroup
+	for i, w := range workers {
+		wg.Add(1)
+		go func(i int, w *worker) {
+			defer wg.Done()
+			w.run(ctx, mix, i, start, measureFrom, deadline)
+		}(i, w)
+	}
+	wg.Wait()
+	end := time.Now()
+	if end.After(deadline) {
+		end = deadline
+	}
+	interrupted := ctx.Err() != nil
+	stop()
+
+	report := buildReport(workers, measureFrom, end)
+	if interrupted {
+		fmt.Println("Interrompido; resultados até a interrupção.")
+	}
+	fmt.Printf("Medido em %s:\n", report.Duration.Round(time.Millisecond))
+	report.Print(os.Stdout)
+	if late := totalLate(workers); late > 0 {
+		fmt.Printf("Atenção: %d chamadas começaram atrasadas em relação à vazão alvo; as latências incluem a espera.\n", late)
+	}
+	if base != nil {
+		fmt.Printf("\nVariação em relação a %s:\n", *compareFlag)
+		report.Compare(os.Stdout, *base)
+	}
+	if *outFlag != "" {
+		if err := report.WriteFile(*outFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao salvar os resultados: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Resultados salvos em %s.\n", *outFlag)
+	}
+
+	if *cleanupFlag {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		for k := 0; k < *keysFlag; k++ {
+			if err := workers[k%len(workers)].client.Delete(cleanupCtx, listID(k)); err != nil && !errors.Is(err, client.ErrNotFound) {
+				fmt.Fprintf(os.Stderr, "Erro ao remover %s: %v\n", listID(k), err)
+				break
+			}
+		}
+	}
+}
+
+func listID(k int) string {
+	return *prefixFlag + "-" + strconv.Itoa(k)
+}
+
+// --- Clientes ---
+
+// worker é um cliente da carga, com as suas medições.
+type worker struct {
+	client    *client.Client
+	rng       *rand.Rand
+	zipf      *rand.Zipf
+	minDigits int
+	maxDigits int
+	ops       map[string]*opStats
+	late      uint64 // Chamadas iniciadas depois do instante previsto pela vazão alvo.
+}
+
+// opStats são as medições de uma operação em um cliente.
+type opStats struct {
+	latencies *bench.Histogram
+	errors    uint64
+	codes     map[string]uint64
+}
+
+func newWorker(c *client.Client, rng *rand.Rand, minDigits, maxDigits int) *worker {
+	w := &worker{client: c, rng: rng, minDigits: minDigits, maxDigits: maxDigits, ops: make(map[string]*opStats)}
+	if *distFlag == "zipf" {
+		w.zipf = rand.NewZipf(rng, *zipfFlag, 1, uint64(*keysFlag-1))
+	}
+	return w
+}
+
+// key sorteia a lista da próxima operação.
+func (w *worker) key() string {
+	if w.zipf != nil {
+		return listID(int(w.zipf.Uint64()))
+	}
+	return listID(w.rng.Intn(*keysFlag))
+}
+
+// value sorteia um valor com a quantidade de dígitos de -value-size.
+func (w *worker) value() int {
+	digits := w.minDigits + w.rng.Intn(w.maxDigits-w.minDigits+1)
+	low := int64(1)
+	for i := 1; i < digits; i++ {
+		low *= 10
+	}
+	if digits == 1 {
+		return w.rng.Intn(10)
+	}
+	return int(low + w.rng.Int63n(9*low))
+}
+
+// run executa operações até deadline. Com vazão alvo, cada cliente tem os
+// seus instantes previstos (a vazão dividida entre os clientes) e a latência
+// é medida a partir do previsto, não do início real: se o servidor atrasa,
+// a espera das chamadas seguintes também conta (sem omissão coordenada).
+func (w *worker) run(ctx context.Context, mix []weightedOp, index int, start, measureFrom, deadline time.Time) {
+	var interval time.Duration
+	next := start
+	if *rateFlag > 0 {
+		interval = time.Duration(float64(*clientsFlag) / *rateFlag * float64(time.Second))
+		// Os clientes começam defasados, para não chamarem todos juntos.
+		next = start.Add(interval * time.Duration(index) / time.Duration(*clientsFlag))
+	}
+	for ctx.Err() == nil {
+		now := time.Now()
+		intended := now
+		if interval > 0 {
+			if next.After(now) {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(next.Sub(now)):
+				}
+			} else if now.Sub(next) > time.Millisecond && !next.Before(measureFrom) {
+				w.late++
+			}
+			intended = next
+			next = next.Add(interval)
+		}
+		if !intended.Before(deadline) {
+			return
+		}
+
+		op := pick(mix, w.rng)
+		callCtx, cancel := context.WithTimeout(ctx, *timeoutFlag)
+		err := w.execute(callCtx, op)
+		cancel()
+		finished := time.Now()
+		if ctx.Err() != nil {
+			return // Chamada interrompida: não conta.
+		}
+		if intended.Before(measureFrom) {
+			continue
+		}
+		w.record(op, finished.Sub(intended), err)
+	}
+}
+
+// execute faz uma chamada da operação.
+func (w *worker) execute(ctx context.Context, op string) error {
+	key := w.key()
+	switch op {
+	case "Append":
+		return w.client.Append(ctx, key, w.value())
+	case "Get":
+		_, err := w.client.Get(ctx, key, w.rng.Intn(*preloadFlag+1))
+		return err
+	case "Remove":
+		_, err := w.client.Remove(ctx, key)
+		return err
+	case "Size":
+		_, err := w.client.Size(ctx, key)
+		return err
+	case "Range":
+		_, err := w.client.Range(ctx, key, 0, *rangeFlag-1)
+		return err
+	case "Delete":
+		return w.client.Delete(ctx, key)
+	}
+	return fmt.Errorf("operação desconhecida %s", op)
+}
+
+// record registra a latência da chamada. Respostas com erro tipado do
+// servidor (lista vazia, índice fora dos limites...) são respostas normais da
+// carga e entram nas latências; as demais falhas são contadas à parte.
+func (w *worker) record(op string, latency time.Duration, err error) {
+	stats := w.ops[op]
+	if stats == nil {
+		stats = &opStats{latencies: bench.NewHistogram(), codes: make(map[string]uint64)}
+		w.ops[op] = stats
+	}
+	code := client.Code(err)
+	switch {
+	case err == nil:
+	case code != "" && code != "INTERNAL" && code != "UNAVAILABLE" && code != "RESOURCE_EXHAUSTED":
+		stats.codes[string(code)]++
+	default:
+		stats.errors++
+		if code != "" {
+			stats.codes[string(code)]++
+		}
+		return
+	}
+	stats.latencies.Record(latency)
+}
+
+// preload insere -preload elementos em cada lista, com os clientes em paralelo.
+func preload(ctx context.Context, workers []*worker) error {
+	var next atomic.Int64
+	errs := make(chan error, len(workers))
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			for {
+				k := int(next.Add(1) - 1)
+				if k >= *keysFlag || ctx.Err() != nil {
+					return
+				}
+				for i := 0; i < *preloadFlag; i++ {
+					callCtx, cancel := context.WithTimeout(ctx, *timeoutFlag)
+					err := w.client.Append(callCtx, listID(k), w.value())
+					cancel()
+					if err != nil {
+						errs <- fmt.Errorf("%s: %w", listID(k), err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return <-errs
+}
+
+// --- Resultados ---
+
+// buildReport combina as medições dos clientes.
+func buildReport(workers []*worker, from, to time.Time) bench.Report {
+	period := to.Sub(from)
+	if period < 0 {
+		period = 0
+	}
+	report := bench.Report{Started: from, Duration: period, Config: make(map[string]string), Ops: make(map[string]bench.Stats)}
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name != "token" && f.Name != "out" && f.Name != "compare" {
+			report.Config[f.Name] = f.Value.String()
+		}
+	})
+
+	total := bench.NewHistogram()
+	var totalErrors uint64
+	totalCodes := make(map[string]uint64)
+	merged := make(map[string]*opStats)
+	for _, w := range workers {
+		for op, stats := range w.ops {
+			m := merged[op]
+			if m == nil {
+				m = &opStats{latencies: bench.NewHistogram(), codes: make(map[string]uint64)}
+				merged[op] = m
+			}
+			m.latencies.Merge(stats.latencies)
+			m.errors += stats.errors
+			for code, n := range stats.codes {
+				m.codes[code] += n
+			}
+		}
+	}
+	for op, m := range merged {
+		report.Ops[op] = bench.Summarize(m.latencies, m.errors, m.codes, period)
+		total.Merge(m.latencies)
+		totalErrors += m.errors
+		for code, n := range m.codes {
+			totalCodes[code] += n
+		}
+	}
+	report.Total = bench.Summarize(total, totalErrors, totalCodes, period)
+	return report
+}
+
+func totalLate(workers []*worker) uint64 {
+	var late uint64
+	for _, w := range workers {
+		late += w.late
+	}
+	return late
+}
+
+// --- Opções ---
+
+type weightedOp struct {
+	op     string
+	weight int
+}
+
+func parseMix(value string) ([]weightedOp, error) {
+	names := map[string]string{"append": "Append", "get": "Get", "remove": "Remove", "size": "Size", "range": "Range", "delete": "Delete"}
+	var mix []weightedOp
+	for _, part := range strings.Split(value, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		op, known := names[strings.ToLower(name)]
+		w, err := strconv.Atoi(weight)
+		if !ok || !known || err != nil || w < 0 {
+			return nil, fmt.Errorf("proporção inválida %q (use operação=peso, com append, get, remove, size, range e delete)", part)
+		}
+		if w > 0 {
+			mix = append(mix, weightedOp{op, w})
+		}
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("nenhuma operação com peso positivo em %q", value)
+	}
+	return mix, nil
+}
+
+func pick(mix []weightedOp, rng *rand.Rand) string {
+	total := 0
+	for _, w := range mix {
+		total += w.weight
+	}
+	n := rng.Intn(total)
+	for _, w := range mix {
+		if n < w.weight {
+			return w.op
+		}
+		n -= w.weight
+	}
+	return mix[len(mix)-1].op
+}
+
+// parseValueSize lê -value-size: "mín-máx" ou um único número de dígitos.
+func parseValueSize(value string) (int, int, error) {
+	low, high, found := strings.Cut(value, "-")
+	if !found {
+		high = low
+	}
+	min, err1 := strconv.Atoi(strings.TrimSpace(low))
+	max, err2 := strconv.Atoi(strings.TrimSpace(high))
+	if err1 != nil || err2 != nil || min < 1 || max > 18 || min > max {
+		return 0, 0, fmt.Errorf("-value-size inválido %q (use mín-máx, entre 1 e 18 dígitos)", value)
+	}
+	return min, max, nil
+}
@@ -0,0 +1,77 @@
+// Package logging acrescenta níveis (debug, info, warn, error) ao pacote log
+// da biblioteca padrão. O nível pode ser trocado com o servidor em execução.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level é o nível mínimo das mensagens exibidas.
+type Level int32
+
+// Níveis em ordem crescente de gravidade.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{LevelDebug: "debug", LevelInfo: "info", LevelWarn: "warn", LevelError: "error"}
+
+// current é o nível atual; o padrão é LevelInfo.
+var current atomic.Int32
+
+func init() {
+	current.Store(int32(LevelInfo))
+}
+
+// String retorna o nome do nível ("debug", "info", "warn" ou "error").
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+// ParseLevel converte o nome de um nível (sem diferenciar maiúsculas).
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("nível de log %q inválido (use debug, info, warn ou error)", name)
+}
+
+// SetLevel troca o nível mínimo das mensagens.
+func SetLevel(level Level) {
+	current.Store(int32(level))
+}
+
+// CurrentLevel retorna o nível atual.
+func CurrentLevel() Level {
+	return Level(current.Load())
+}
+
+// Debugf registra uma mensagem de depuração.
+func Debugf(format string, args ...interface{}) { output(LevelDebug, format, args...) }
+
+// Infof registra uma mensagem informativa.
+func Infof(format string, args ...interface{}) { output(LevelInfo, format, args...) }
+
+// Warnf registra um aviso.
+func Warnf(format string, args ...interface{}) { output(LevelWarn, format, args...) }
+
+// Errorf registra um erro.
+func Errorf(format string, args ...interface{}) { output(LevelError, format, args...) }
+
+func output(level Level, format string, args ...interface{}) {
+	if level < CurrentLevel() {
+		return
+	}
+	log.Print(strings.ToUpper(level.String()) + " " + fmt.Sprintf(format, args...))
+}
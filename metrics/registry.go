@@ -0,0 +1,323 @@
+// Package metrics implementa contadores, gauges e histogramas expostos no
+// formato texto do Prometheus, sem dependências externas.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets são os limites padrão (em segundos) dos histogramas de latência.
+var DefaultBuckets = []float64{.0001, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector é implementado por todas as métricas registráveis.
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry agrupa métricas e as exporta no formato texto.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry cria um registro vazio.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default é o registro usado pelas métricas do servidor.
+var Default = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.collectors {
+		if existing.name() == c.name() {
+			panic("metrics: métrica registrada duas vezes: " + c.name())
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteText escreve todas as métricas no formato de exposição do Prometheus.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler retorna um http.Handler que serve as métricas do registro.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// --- Séries com rótulos ---
+
+// labelSet guarda os nomes de rótulos de uma métrica e gera as chaves das séries.
+type labelSet []string
+
+// key junta os valores de rótulo em uma chave única para o mapa de séries.
+func (l labelSet) key(values []string) string {
+	if len(values) != len(l) {
+		panic(fmt.Sprintf("metrics: esperados %d rótulos, recebidos %d", len(l), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// format produz `{a="x",b="y"}` (com um rótulo extra opcional, usado por "le").
+func (l labelSet) format(key string, extraName, extraValue string) string {
+	var parts []string
+	if len(l) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			parts = append(parts, l[i]+`="`+escapeLabel(value)+`"`)
+		}
+	}
+	if extraName != "" {
+		parts = append(parts, extraName+`="`+extraValue+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sortedKeys retorna as chaves do mapa em ordem, para uma saída estável.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// --- Counter ---
+
+// CounterVec é um contador monotônico, opcionalmente particionado por rótulos.
+type CounterVec struct {
+	metricName, help string
+	labels           labelSet
+	mu               sync.Mutex
+	values           map[string]float64
+}
+
+// NewCounterVec cria e registra um contador no registro Default.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{metricName: name, help: help, labels: labels, values: make(map[string]float64)}
+	Default.register(c)
+	return c
+}
+
+// Add soma v (não negativo) à série identificada pelos valores de rótulo.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	key := c.labels.key(labelValues)
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+// Inc soma 1 à série identificada pelos valores de rótulo.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) name() string { return c.metricName }
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.metricName, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labels.format(key, "", ""), formatFloat(c.values[key]))
+	}
+}
+
+// --- Gauge ---
+
+// GaugeVec é um valor que pode subir e descer, opcionalmente particionado por rótulos.
+type GaugeVec struct {
+	metricName, help string
+	labels           labelSet
+	mu               sync.Mutex
+	values           map[string]float64
+}
+
+// NewGaugeVec cria e registra um gauge no registro Default.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{metricName: name, help: help, labels: labels, values: make(map[string]float64)}
+	Default.register(g)
+	return g
+}
+
+// Set define o valor da série.
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	key := g.labels.key(labelValues)
+	g.mu.Lock()
+	g.values[key] = v
+	g.mu.Unlock()
+}
+
+// Add soma v (que pode ser negativo) ao valor da série.
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	key := g.labels.key(labelValues)
+	g.mu.Lock()
+	g.values[key] += v
+	g.mu.Unlock()
+}
+
+func (g *GaugeVec) name() string { return g.metricName }
+
+func (g *GaugeVec) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeHeader(w, g.metricName, g.help, "gauge")
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.labels.format(key, "", ""), formatFloat(g.values[key]))
+	}
+}
+
+// GaugeFunc é um gauge cujo valor é calculado no momento da coleta.
+type GaugeFunc struct {
+	metricName, help string
+	mu               sync.Mutex
+	fn               func() float64
+}
+
+// NewGaugeFunc cria e registra um gauge calculado no registro Default.
+// A função pode ser definida depois, com SetFunc.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, fn: fn}
+	Default.register(g)
+	return g
+}
+
+// SetFunc troca a função usada para calcular o valor.
+func (g *GaugeFunc) SetFunc(fn func() float64) {
+	g.mu.Lock()
+	g.fn = fn
+	g.mu.Unlock()
+}
+
+func (g *GaugeFunc) name() string { return g.metricName }
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.mu.Lock()
+	fn := g.fn
+	g.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	writeHeader(w, g.metricName, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(fn()))
+}
+
+// --- Histogram ---
+
+// histogramValue acumula as contagens de uma série do histograma.
+type histogramValue struct {
+	counts []uint64 // Contagem por bucket (não cumulativa).
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec distribui observações em buckets, opcionalmente particionado por rótulos.
+type HistogramVec struct {
+	metricName, help string
+	labels           labelSet
+	buckets          []float64
+	mu               sync.Mutex
+	values           map[string]*histogramValue
+}
+
+// NewHistogramVec cria e registra um histograma no registro Default.
+// Se buckets for nil, usa DefaultBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{metricName: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogramValue)}
+	Default.register(h)
+	return h
+}
+
+// Observe registra uma observação na série identificada pelos valores de rótulo.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := h.labels.key(labelValues)
+	idx := sort.SearchFloat64s(h.buckets, v) // Primeiro bucket com limite >= v.
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	if idx < len(h.buckets) {
+		hv.counts[idx]++
+	}
+	hv.count++
+	hv.sum += v
+}
+
+func (h *HistogramVec) name() string { return h.metricName }
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.metricName, h.help, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += hv.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels.format(key, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels.format(key, "le", "+Inf"), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labels.format(key, "", ""), formatFloat(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labels.format(key, "", ""), hv.count)
+	}
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"net"
+	"sync"
+)
+
+// Métricas do servidor RemoteList, registradas no registro Default.
+var (
+	RPCRequests = NewCounterVec("remote_list_rpc_requests_total",
+		"Total de chamadas RPC por método.", "method")
+	RPCDuration = NewHistogramVec("remote_list_rpc_duration_seconds",
+		"Latência das chamadas RPC por método.", nil, "method")
+	RPCErrors = NewCounterVec("remote_list_rpc_errors_total",
+		"Total de erros em chamadas RPC por método e código de erro.", "method", "code")
+	RPCRejected = NewCounterVec("remote_list_rpc_rejected_total",
+		"Chamadas recusadas por limite de taxa ou de concorrência, por motivo.", "reason")
+	RPCInFlight = NewGaugeFunc("remote_list_rpc_in_flight",
+		"Chamadas RPC em andamento (sujeitas ao limite de concorrência).", nil)
+
+	Lists = NewGaugeFunc("remote_list_lists",
+		"Quantidade de listas existentes.", nil)
+	Elements = NewGaugeFunc("remote_list_elements",
+		"Quantidade total de elementos em todas as listas.", nil)
+
+	LogBytesWritten = NewCounterVec("remote_list_log_bytes_written_total",
+		"Bytes escritos no log de operações.")
+	LogFsyncDuration = NewHistogramVec("remote_list_log_fsync_duration_seconds",
+		"Latência do fsync do log de operações.", nil)
+
+	SnapshotDuration = NewHistogramVec("remote_list_snapshot_duration_seconds",
+		"Duração do salvamento de snapshots.", nil)
+	SnapshotSizeBytes = NewGaugeVec("remote_list_snapshot_size_bytes",
+		"Tamanho em bytes do último snapshot salvo.")
+	SnapshotAge = NewGaugeFunc("remote_list_snapshot_age_seconds",
+		"Segundos desde o último snapshot salvo com sucesso.", nil)
+
+	RecoveryDuration = NewGaugeVec("remote_list_recovery_duration_seconds",
+		"Duração da recuperação (snapshot + logs) na inicialização.")
+	RecoveryEntriesReplayed = NewGaugeVec("remote_list_recovery_entries_replayed",
+		"Entradas de log reaplicadas na inicialização.")
+
+	ActiveConnections = NewGaugeVec("remote_list_active_connections",
+		"Conexões abertas por listener.", "listener")
+)
+
+// CountConnections envolve o listener para manter ActiveConnections atualizado
+// com o rótulo informado (ex: "rpc", "resp").
+func CountConnections(l net.Listener, label string) net.Listener {
+	ActiveConnections.Set(0, label)
+	return &countingListener{Listener: l, label: label}
+}
+
+type countingListener struct {
+	net.Listener
+	label string
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	ActiveConnections.Add(1, l.label)
+	return &countingConn{Conn: conn, label: l.label}, nil
+}
+
+// countingConn decrementa o gauge uma única vez, no primeiro Close.
+type countingConn struct {
+	net.Conn
+	label string
+	once  sync.Once
+}
+
+func (c *countingConn) Close() error {
+	c.once.Do(func() { ActiveConnections.Add(-1, c.label) })
+	return c.Conn.Close()
+}
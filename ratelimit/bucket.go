@@ -0,0 +1,142 @@
+// Package ratelimit implementa limitação de taxa por token bucket.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket é um token bucket: acumula rate fichas por segundo, até burst, e
+// cada operação consome uma ficha.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64   // Fichas por segundo.
+	burst  float64   // Capacidade máxima.
+	tokens float64   // Fichas disponíveis.
+	last   time.Time // Último reabastecimento.
+}
+
+// NewBucket cria um Bucket cheio.
+func NewBucket(rate, burst float64) *Bucket {
+	return &Bucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Allow consome uma ficha, se houver.
+func (b *Bucket) Allow() bool {
+	ok, _ := b.Take()
+	return ok
+}
+
+// Take consome uma ficha, se houver. Caso contrário, retorna quanto tempo
+// falta para a próxima ficha ficar disponível.
+func (b *Bucket) Take() (ok bool, retryAfter time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// full informa se o bucket estará cheio em now.
+func (b *Bucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+// refill acumula as fichas desde o último reabastecimento.
+func (b *Bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// sweepInterval é o intervalo máximo entre varreduras de buckets ociosos.
+const sweepInterval = time.Minute
+
+// minSweepSize é o tamanho do mapa a partir do qual uma varredura é feita
+// antes de sweepInterval.
+const minSweepSize = 1024
+
+// Keyed mantém um Bucket por chave (ex: namespace), criado no primeiro uso
+// com os limites devolvidos por limits. Taxa zero significa sem limite, e
+// chaves sem limite não ocupam memória. Buckets cheios são descartados nas
+// varreduras: recriados cheios, dão o mesmo resultado, então o mapa só guarda
+// chaves que usaram fichas recentemente (ex: um host por cliente sem
+// autenticação).
+type Keyed struct {
+	mu        sync.Mutex
+	limits    func(key string) (rate, burst float64)
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+	sweepAt   int // Tamanho do mapa que antecipa a próxima varredura.
+}
+
+// NewKeyed cria um Keyed com a função de limites informada.
+func NewKeyed(limits func(key string) (rate, burst float64)) *Keyed {
+	return &Keyed{limits: limits, buckets: make(map[string]*Bucket), lastSweep: time.Now(), sweepAt: minSweepSize}
+}
+
+// Allow consome uma ficha do bucket da chave.
+func (k *Keyed) Allow(key string) bool {
+	ok, _ := k.Take(key)
+	return ok
+}
+
+// Take consome uma ficha do bucket da chave, como Bucket.Take.
+func (k *Keyed) Take(key string) (ok bool, retryAfter time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	if len(k.buckets) >= k.sweepAt || now.Sub(k.lastSweep) >= sweepInterval {
+		k.sweep(now)
+	}
+	bucket, ok := k.buckets[key]
+	if !ok {
+		rate, burst := k.limits(key)
+		if rate <= 0 {
+			return true, 0
+		}
+		bucket = NewBucket(rate, burst)
+		k.buckets[key] = bucket
+	}
+	// Com k.mu adquirido, para que a varredura não descarte um bucket entre
+	// a busca e o consumo da ficha.
+	return bucket.Take()
+}
+
+// Len retorna a quantidade de buckets mantidos.
+func (k *Keyed) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.buckets)
+}
+
+// SetLimits troca a função de limites e descarta os buckets existentes,
+// para que os novos limites valham já na próxima chamada.
+func (k *Keyed) SetLimits(limits func(key string) (rate, burst float64)) {
+	k.mu.Lock()
+	k.limits, k.buckets = limits, make(map[string]*Bucket)
+	k.mu.Unlock()
+}
+
+// sweep descarta os buckets cheios e agenda a próxima varredura para quando
+// o mapa dobrar de tamanho, mantendo o custo amortizado constante por Take.
+// Deve ser chamada com k.mu adquirido.
+func (k *Keyed) sweep(now time.Time) {
+	for key, bucket := range k.buckets {
+		if bucket.full(now) {
+			delete(k.buckets, key)
+		}
+	}
+	k.lastSweep = now
+	k.sweepAt = max(2*len(k.buckets), minSweepSize)
+}
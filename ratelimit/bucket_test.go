@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	b := NewBucket(10, 2)
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatalf("ficha %d da rajada recusada", i)
+		}
+	}
+	ok, wait := b.Take()
+	if ok {
+		t.Fatal("ficha além da rajada concedida")
+	}
+	if wait <= 0 || wait > 100*time.Millisecond {
+		t.Fatalf("espera sugerida de %v, esperado até 100ms", wait)
+	}
+}
+
+// TestKeyedUnlimitedKeys confere que chaves sem limite não ocupam memória.
+func TestKeyedUnlimitedKeys(t *testing.T) {
+	k := NewKeyed(func(string) (rate, burst float64) { return 0, 0 })
+	for i := 0; i < 10*minSweepSize; i++ {
+		if !k.Allow("host" + strconv.Itoa(i)) {
+			t.Fatal("chave sem limite recusada")
+		}
+	}
+	if n := k.Len(); n != 0 {
+		t.Fatalf("%d buckets mantidos para chaves sem limite", n)
+	}
+}
+
+// TestKeyedEvictsIdleBuckets confere que buckets que voltaram a encher são
+// descartados, e que chaves ativas continuam limitadas.
+func TestKeyedEvictsIdleBuckets(t *testing.T) {
+	k := NewKeyed(func(string) (rate, burst float64) { return 1000, 1 })
+	for i := 0; i < 10*minSweepSize; i++ {
+		k.Allow("host" + strconv.Itoa(i))
+		if i%minSweepSize == 0 {
+			time.Sleep(2 * time.Millisecond) // Tempo para os buckets anteriores encherem.
+		}
+	}
+	if n := k.Len(); n > 2*minSweepSize {
+		t.Fatalf("%d buckets mantidos, esperado até %d", n, 2*minSweepSize)
+	}
+
+	k = NewKeyed(func(string) (rate, burst float64) { return 0.001, 1 })
+	if !k.Allow("ativo") {
+		t.Fatal("primeira ficha recusada")
+	}
+	for i := 0; i < 10*minSweepSize; i++ {
+		k.Allow("host" + strconv.Itoa(i))
+	}
+	if k.Allow("ativo") {
+		t.Fatal("bucket vazio descartado na varredura: ficha concedida além do limite")
+	}
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Concurrency limita a quantidade de operações simultâneas. Quem não encontra
+// vaga espera na fila até o tempo limite; depois disso, é recusado. Com a
+// fila cheia, a recusa é imediata.
+type Concurrency struct {
+	mu       sync.Mutex
+	max      int           // Operações simultâneas permitidas; 0 = sem limite.
+	maxQueue int           // Operações esperando vaga; 0 = nenhuma espera.
+	active   int           // Operações em andamento.
+	waiting  int           // Operações na fila.
+	released chan struct{} // Fechado (e recriado) a cada vaga liberada, acordando a fila.
+}
+
+// NewConcurrency cria um limite de max operações simultâneas (0 = sem limite)
+// e até maxQueue esperando vaga.
+func NewConcurrency(max, maxQueue int) *Concurrency {
+	return &Concurrency{max: max, maxQueue: maxQueue, released: make(chan struct{})}
+}
+
+// SetMax troca os limites. Operações em andamento ou já na fila não são
+// interrompidas.
+func (c *Concurrency) SetMax(max, maxQueue int) {
+	c.mu.Lock()
+	c.max, c.maxQueue = max, maxQueue
+	c.wake()
+	c.mu.Unlock()
+}
+
+// Acquire ocupa uma vaga, esperando até timeout por uma. Retorna false se
+// não houver vaga nesse prazo ou se a fila estiver cheia; com sucesso,
+// Release deve ser chamado ao final.
+func (c *Concurrency) Acquire(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	queued := false
+	defer func() {
+		if queued {
+			c.mu.Lock()
+			c.waiting--
+			c.mu.Unlock()
+		}
+	}()
+	for {
+		c.mu.Lock()
+		if c.max <= 0 || c.active < c.max {
+			c.active++
+			c.mu.Unlock()
+			return true
+		}
+		if !queued {
+			if c.waiting >= c.maxQueue || timeout <= 0 {
+				c.mu.Unlock()
+				return false
+			}
+			c.waiting++
+			queued = true
+		}
+		released := c.released
+		c.mu.Unlock()
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-released:
+			timer.Stop()
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// Release libera a vaga ocupada por Acquire.
+func (c *Concurrency) Release() {
+	c.mu.Lock()
+	c.active--
+	c.wake()
+	c.mu.Unlock()
+}
+
+// Active retorna a quantidade de operações em andamento.
+func (c *Concurrency) Active() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+// Waiting retorna a quantidade de operações na fila.
+func (c *Concurrency) Waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.waiting
+}
+
+// wake acorda a fila. Deve ser chamada com c.mu adquirido.
+func (c *Concurrency) wake() {
+	close(c.released)
+	c.released = make(chan struct{})
+}
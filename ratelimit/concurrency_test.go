@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConcurrencyQueue(t *testing.T) {
+	c := NewConcurrency(1, 2)
+	if !c.Acquire(0) {
+		t.Fatal("vaga livre recusada")
+	}
+
+	// Duas chamadas cabem na fila; a terceira é recusada na hora.
+	acquired := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		go func() { acquired <- c.Acquire(time.Minute) }()
+	}
+	for deadline := time.Now().Add(5 * time.Second); c.Waiting() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d chamadas na fila, esperado 2", c.Waiting())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	start := time.Now()
+	if c.Acquire(time.Minute) {
+		t.Fatal("vaga concedida com a fila cheia")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("recusa com a fila cheia levou %v", elapsed)
+	}
+
+	// Cada vaga liberada atende uma chamada da fila.
+	for i := 0; i < 2; i++ {
+		c.Release()
+		if !<-acquired {
+			t.Fatal("chamada na fila recusada")
+		}
+	}
+	if c.Waiting() != 0 || c.Active() != 1 {
+		t.Fatalf("%d na fila e %d ativas, esperado 0 e 1", c.Waiting(), c.Active())
+	}
+}
+
+func TestConcurrencyTimeout(t *testing.T) {
+	c := NewConcurrency(1, 1)
+	c.Acquire(0)
+	if c.Acquire(10 * time.Millisecond) {
+		t.Fatal("vaga concedida sem nenhuma liberada")
+	}
+	if c.Waiting() != 0 {
+		t.Fatalf("%d chamadas na fila após o tempo limite", c.Waiting())
+	}
+}
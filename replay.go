@@ -0,0 +1,458 @@
+// replay reexecuta contra um servidor as chamadas gravadas com
+// `server.go -record` e confere se as respostas são as mesmas da gravação.
+// Serve para reproduzir um incidente e para verificar se uma mudança altera
+// o comportamento diante de uma carga real.
+//
+// Uso: go run replay.go [-addr localhost:1234] [-timing original|fast] [opções] gravacao.jsonl
+//
+//	go run replay.go -timing fast gravacao.jsonl                 # em sequência, o mais rápido possível
+//	go run replay.go -speed 2 gravacao.jsonl                     # no tempo original, duas vezes mais rápido
+//	go run replay.go -list-prefix replay- -timing fast gravacao.jsonl  # em listas separadas das existentes
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"sd-miniprojeto-1/bench"
+	"sd-miniprojeto-1/client"
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/workload"
+)
+
+var (
+	addrFlag     = flag.String("addr", "localhost:1234", "Endereço do servidor.")
+	tokenFlag    = flag.String("token", os.Getenv("REMOTE_LIST_TOKEN"), "Token de acesso usado em todas as chamadas (padrão: $REMOTE_LIST_TOKEN).")
+	timingFlag   = flag.String("timing", "original", "original: cada conexão gravada vira um cliente, com as chamadas nos instantes gravados; fast: uma chamada após a outra, na ordem em que o servidor as concluiu.")
+	speedFlag    = flag.Float64("speed", 1, "Com -timing original, multiplica a velocidade (2: na metade do tempo).")
+	prefixFlag   = flag.String("list-prefix", "", "Prefixo acrescentado aos IDs das listas, para não alterar as existentes.")
+	methodsFlag  = flag.String("methods", "", "Só reexecuta estes métodos (separados por vírgula); vazio: todos.")
+	noCheckFlag  = flag.Bool("no-check", false, "Não compara as respostas.")
+	maxDiffsFlag = flag.Int("max-diffs", 20, "Divergências exibidas.")
+	timeoutFlag  = flag.Duration("timeout", 5*time.Second, "Tempo máximo de cada chamada.")
+	latencyFlag  = flag.Bool("latency", true, "Compara as latências (p50 e p99) gravadas com as da reexecução.")
+)
+
+// outcome é o resultado de uma chamada reexecutada.
+type outcome struct {
+	call    workload.Call
+	reply   any
+	code    structures.ErrorCode
+	err     error // Erro sem código (conexão, tempo esgotado).
+	latency time.Duration
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Uso: go run replay.go [opções] gravacao.jsonl")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if *timingFlag != "original" && *timingFlag != "fast" {
+		fmt.Fprintln(os.Stderr, "-timing deve ser original ou fast")
+		os.Exit(2)
+	}
+	if *speedFlag <= 0 {
+		fmt.Fprintln(os.Stderr, "-speed deve ser positivo")
+		os.Exit(2)
+	}
+
+	calls, err := workload.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao ler a gravação: %v\n", err)
+		os.Exit(2)
+	}
+	calls = filterMethods(calls, *methodsFlag)
+	if len(calls) == 0 {
+		fmt.Println("Nenhuma chamada para reexecutar.")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	newClient := func() (*client.Client, error) {
+		opts := client.DefaultOptions(*addrFlag)
+		opts.Token = *tokenFlag
+		opts.PoolSize = 1
+		opts.MaxAttempts = 1 // Como na gravação: uma recusa é uma resposta a comparar.
+		return client.New(opts)
+	}
+
+	start := time.Now()
+	var outcomes []outcome
+	if *timingFlag == "fast" {
+		outcomes, err = replayFast(ctx, calls, newClient)
+	} else {
+		outcomes, err = replayTimed(ctx, calls, newClient)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
+		os.Exit(1)
+	}
+	elapsed := time.Since(start)
+	if ctx.Err() != nil {
+		fmt.Println("Interrompido; resultado das chamadas feitas até ali.")
+	}
+
+	os.Exit(report(outcomes, calls, elapsed))
+}
+
+// filterMethods mantém só as chamadas dos métodos informados.
+func filterMethods(calls []workload.Call, methods string) []workload.Call {
+	if methods == "" {
+		return calls
+	}
+	keep := make(map[string]bool)
+	for _, m := range strings.Split(methods, ",") {
+		keep[strings.ToLower(strings.TrimSpace(m))] = true
+	}
+	var filtered []workload.Call
+	for _, call := range calls {
+		if keep[strings.ToLower(call.Method)] {
+			filtered = append(filtered, call)
+		}
+	}
+	return filtered
+}
+
+// replayFast executa as chamadas uma após a outra, na ordem em que o servidor
+// as concluiu (seq). Sem concorrência, as respostas de uma gravação feita com
+// clientes concorrentes também devem se repetir, a menos que dependam do
+// horário (GetAt) ou de limites de taxa.
+func replayFast(ctx context.Context, calls []workload.Call, newClient func() (*client.Client, error)) ([]outcome, error) {
+	sorted := append([]workload.Call(nil), calls...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Seq < sorted[j].Seq })
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	outcomes := make([]outcome, 0, len(sorted))
+	for _, call := range sorted {
+		if ctx.Err() != nil {
+			break
+		}
+		outcomes = append(outcomes, execute(ctx, c, call))
+	}
+	return outcomes, nil
+}
+
+// replayTimed reexecuta cada conexão gravada em um cliente próprio, com as
+// chamadas no mesmo instante relativo ao início da gravação (dividido por
+// -speed). Chamadas de uma conexão continuam sequenciais: se uma atrasar, as
+// seguintes esperam por ela.
+func replayTimed(ctx context.Context, calls []workload.Call, newClient func() (*client.Client, error)) ([]outcome, error) {
+	sessions := make(map[uint64][]workload.Call)
+	first := calls[0].Start
+	for _, call := range calls {
+		sessions[call.Session] = append(sessions[call.Session], call)
+		if call.Start.Before(first) {
+			first = call.Start
+		}
+	}
+	fmt.Printf("Reexecutando %d chamadas de %d conexões no tempo original (velocidade %gx).\n", len(calls), len(sessions), *speedFlag)
+
+	var mu sync.Mutex
+	var outcomes []outcome
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, sessionCalls := range sessions {
+		c, err := newClient()
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		sort.SliceStable(sessionCalls, func(i, j int) bool { return sessionCalls[i].Start.Before(sessionCalls[j].Start) })
+
+		wg.Add(1)
+		go func(c *client.Client, sessionCalls []workload.Call) {
+			defer wg.Done()
+			for _, call := range sessionCalls {
+				at := start.Add(time.Duration(float64(call.Start.Sub(first)) / *speedFlag))
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Until(at)):
+				}
+				result := execute(ctx, c, call)
+				mu.Lock()
+				outcomes = append(outcomes, result)
+				mu.Unlock()
+			}
+		}(c, sessionCalls)
+	}
+	wg.Wait()
+	sort.Slice(outcomes, func(i, j int) bool { return outcomes[i].call.Seq < outcomes[j].call.Seq })
+	return outcomes, nil
+}
+
+// execute reexecuta a chamada, com o prefixo de -list-prefix nas listas.
+func execute(ctx context.Context, c *client.Client, call workload.Call) outcome {
+	result := outcome{call: call}
+	ctx, cancel := context.WithTimeout(ctx, *timeoutFlag)
+	defer cancel()
+	prefix := *prefixFlag
+
+	var err error
+	start := time.Now()
+	switch call.Method {
+	case "Append":
+		var args structures.AppendArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			err = c.Append(ctx, prefix+args.ListID, args.Value)
+			result.reply = true
+		}
+	case "Push":
+		var args structures.PushArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			result.reply, err = c.Push(ctx, prefix+args.ListID, args.Values...)
+		}
+	case "Get":
+		var args structures.GetArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			result.reply, err = c.Get(ctx, prefix+args.ListID, args.Index)
+		}
+	case "GetAt":
+		var args structures.GetAtArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			result.reply, err = c.GetAt(ctx, prefix+args.ListID, args.Index, args.AsOf)
+		}
+	case "Remove":
+		var args structures.RemoveArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			result.reply, err = c.Remove(ctx, prefix+args.ListID)
+		}
+	case "Size":
+		var args structures.SizeArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			result.reply, err = c.Size(ctx, prefix+args.ListID)
+		}
+	case "Range":
+		var args structures.RangeArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			result.reply, err = c.Range(ctx, prefix+args.ListID, args.Start, args.Stop)
+		}
+	case "Delete":
+		var args structures.DeleteArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			err = c.Delete(ctx, prefix+args.ListID)
+			result.reply = true
+		}
+	case "Keys":
+		var args structures.KeysArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			pattern := args.Pattern
+			if pattern == "" {
+				pattern = "*"
+			}
+			var keys []string
+			keys, err = c.Keys(ctx, prefix+pattern)
+			for i := range keys {
+				keys[i] = strings.TrimPrefix(keys[i], prefix)
+			}
+			result.reply = keys
+		}
+	case "Sum", "Min", "Max", "Avg":
+		var args structures.AggregateArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			span := spanOf(args.Bounded, args.Start, args.Stop)
+			switch call.Method {
+			case "Sum":
+				result.reply, err = c.Sum(ctx, prefix+args.ListID, span)
+			case "Min":
+				result.reply, err = c.Min(ctx, prefix+args.ListID, span)
+			case "Max":
+				result.reply, err = c.Max(ctx, prefix+args.ListID, span)
+			case "Avg":
+				result.reply, err = c.Avg(ctx, prefix+args.ListID, span)
+			}
+		}
+	case "Count":
+		var args structures.CountArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			result.reply, err = c.Count(ctx, prefix+args.ListID, spanOf(args.Bounded, args.Start, args.Stop), args.Op, args.Value)
+		}
+	case "Histogram":
+		var args structures.HistogramArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			result.reply, err = c.Histogram(ctx, prefix+args.ListID, spanOf(args.Bounded, args.Start, args.Stop), args.Bounds)
+		}
+	case "IndexOf", "LastIndexOf", "Contains", "FindAll":
+		var args structures.SearchArgs
+		if err = json.Unmarshal(call.Args, &args); err == nil {
+			switch call.Method {
+			case "IndexOf":
+				result.reply, err = c.IndexOf(ctx, prefix+args.ListID, args.Value)
+			case "LastIndexOf":
+				result.reply, err = c.LastIndexOf(ctx, prefix+args.ListID, args.Value)
+			case "Contains":
+				result.reply, err = c.Contains(ctx, prefix+args.ListID, args.Value)
+			case "FindAll":
+				result.reply, err = c.FindAll(ctx, prefix+args.ListID, args.Value)
+			}
+		}
+	case "Ping":
+		result.reply, err = c.Ping(ctx)
+	default:
+		err = fmt.Errorf("método desconhecido %q", call.Method)
+	}
+	result.latency = time.Since(start)
+	if err != nil {
+		result.reply = nil
+		if result.code = client.Code(err); result.code == "" {
+			result.err = err
+		}
+	}
+	return result
+}
+
+// spanOf converte o intervalo gravado de uma agregação.
+func spanOf(bounded bool, start, stop int) client.Span {
+	if bounded {
+		return client.Between(start, stop)
+	}
+	return client.WholeList()
+}
+
+// diff descreve a divergência entre a resposta gravada e a reexecutada; vazio
+// se forem iguais. Em Ping, só o erro é comparado (a resposta traz o uptime).
+func diff(o outcome) string {
+	call := o.call
+	switch {
+	case o.err != nil:
+		return fmt.Sprintf("falhou: %v", o.err)
+	case o.code != call.Code:
+		return fmt.Sprintf("gravado %s, reexecutado %s", describeResult(call.Code, call.Reply), describeResult(o.code, encode(o.reply)))
+	case o.code != "" || call.Method == "Ping":
+		return ""
+	}
+	var recorded, replayed any
+	json.Unmarshal(call.Reply, &recorded)
+	json.Unmarshal(encode(o.reply), &replayed)
+	if reflect.DeepEqual(emptyToNil(recorded), emptyToNil(replayed)) {
+		return ""
+	}
+	return fmt.Sprintf("gravado %s, reexecutado %s", call.Reply, encode(o.reply))
+}
+
+// emptyToNil trata a lista vazia como ausente: o net/rpc (gob) entrega uma
+// lista vazia como nil, que vira null no JSON.
+func emptyToNil(v any) any {
+	if list, ok := v.([]any); ok && len(list) == 0 {
+		return nil
+	}
+	return v
+}
+
+func encode(v any) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func describeResult(code structures.ErrorCode, reply json.RawMessage) string {
+	if code != "" {
+		return string(code)
+	}
+	return string(reply)
+}
+
+// report exibe o resumo, as divergências e as latências e retorna o código de
+// saída: 1 se houve divergência ou falha.
+func report(outcomes []outcome, calls []workload.Call, elapsed time.Duration) int {
+	var diffs, failures int
+	perMethod := make(map[string]int)
+	for _, o := range outcomes {
+		if o.err != nil {
+			failures++
+		}
+		if *noCheckFlag && o.err == nil {
+			continue
+		}
+		d := diff(o)
+		if d == "" {
+			continue
+		}
+		diffs++
+		perMethod[o.call.Method]++
+		if diffs <= *maxDiffsFlag {
+			fmt.Printf("  seq %d (%s, conexão %d): %s %s: %s\n", o.call.Seq, clientName(o.call), o.call.Session, o.call.Method, o.call.Args, d)
+		}
+	}
+	if diffs > *maxDiffsFlag {
+		fmt.Printf("  ... e mais %d divergências.\n", diffs-*maxDiffsFlag)
+	}
+
+	recordedSpan := calls[len(calls)-1].Start.Add(calls[len(calls)-1].Duration).Sub(calls[0].Start)
+	fmt.Printf("%d de %d chamadas reexecutadas em %s (gravação: %s); %d falhas.\n",
+		len(outcomes), len(calls), elapsed.Round(time.Millisecond), recordedSpan.Round(time.Millisecond), failures)
+	if !*noCheckFlag {
+		if diffs == 0 {
+			fmt.Println("Todas as respostas conferem com a gravação.")
+		} else {
+			var parts []string
+			for method, n := range perMethod {
+				parts = append(parts, fmt.Sprintf("%s=%d", method, n))
+			}
+			sort.Strings(parts)
+			fmt.Printf("%d respostas divergentes (%s).\n", diffs, strings.Join(parts, ", "))
+		}
+	}
+	if *latencyFlag {
+		printLatencies(outcomes)
+	}
+	if diffs > 0 || failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+func clientName(call workload.Call) string {
+	if call.Client == "" {
+		return call.RemoteAddr
+	}
+	return call.Client
+}
+
+// printLatencies compara, por método, as latências gravadas (medidas no
+// servidor) com as da reexecução (medidas no cliente, incluindo a rede).
+func printLatencies(outcomes []outcome) {
+	recorded := make(map[string]*bench.Histogram)
+	replayed := make(map[string]*bench.Histogram)
+	for _, o := range outcomes {
+		if recorded[o.call.Method] == nil {
+			recorded[o.call.Method], replayed[o.call.Method] = bench.NewHistogram(), bench.NewHistogram()
+		}
+		recorded[o.call.Method].Record(o.call.Duration)
+		replayed[o.call.Method].Record(o.latency)
+	}
+	methods := make([]string, 0, len(recorded))
+	for method := range recorded {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	fmt.Println("Latências (gravadas no servidor; reexecutadas no cliente, com a rede):")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "método\tchamadas\tp50 gravado\tp50 agora\tp99 gravado\tp99 agora\t")
+	for _, method := range methods {
+		r, p := recorded[method], replayed[method]
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t\n", method, r.Count(),
+			r.Quantile(0.5).Round(time.Microsecond), p.Quantile(0.5).Round(time.Microsecond),
+			r.Quantile(0.99).Round(time.Microsecond), p.Quantile(0.99).Round(time.Microsecond))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,146 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// limits limita o tamanho dos comandos lidos de uma conexão.
+type limits struct {
+	maxArray int // Quantidade máxima de argumentos por comando.
+	maxBulk  int // Tamanho máximo de uma bulk string.
+}
+
+var (
+	// authenticatedLimits valem para conexões autenticadas (ou sem
+	// autenticação configurada): os do Redis para bulk strings.
+	authenticatedLimits = limits{maxArray: 1024 * 1024, maxBulk: 512 * 1024 * 1024}
+	// unauthenticatedLimits valem antes do AUTH, como no Redis: o suficiente
+	// para AUTH, PING e QUIT, sem que conexões anônimas ocupem memória.
+	unauthenticatedLimits = limits{maxArray: 10, maxBulk: 16 * 1024}
+)
+
+const (
+	maxLineLength = 64 * 1024 // Tamanho máximo de uma linha (comando inline ou cabeçalho), como no Redis.
+	preallocArgs  = 1024      // Argumentos reservados de antemão, independentemente do declarado.
+)
+
+// readCommand lê um comando do cliente, seja como array RESP de bulk strings
+// (formato usado pelas bibliotecas e pelo redis-cli) ou como comando inline,
+// respeitando os limites informados. As bulk strings são lidas aos poucos: a
+// memória acompanha os bytes recebidos, não o tamanho declarado.
+func readCommand(r *bufio.Reader, lim limits) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+
+	if line[0] != '*' {
+		// Comando inline (ex: digitado via telnet).
+		args := strings.Fields(line)
+		if len(args) > lim.maxArray {
+			return nil, fmt.Errorf("comando com %d argumentos; o máximo é %d", len(args), lim.maxArray)
+		}
+		return args, nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count > lim.maxArray {
+		return nil, fmt.Errorf("tamanho de array inválido: %q", line)
+	}
+
+	args := make([]string, 0, min(max(count, 0), preallocArgs))
+	for i := 0; i < count; i++ {
+		header, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(header) == 0 || header[0] != '$' {
+			return nil, fmt.Errorf("esperado '$', recebido %q", header)
+		}
+
+		length, err := strconv.Atoi(header[1:])
+		if err != nil || length < 0 || length > lim.maxBulk {
+			return nil, fmt.Errorf("tamanho de bulk string inválido: %q", header)
+		}
+
+		var bulk strings.Builder
+		if _, err := io.CopyN(&bulk, r, int64(length)+2); err != nil { // Inclui o \r\n final.
+			return nil, err
+		}
+		value := bulk.String()
+		if !strings.HasSuffix(value, "\r\n") {
+			return nil, fmt.Errorf("bulk string sem terminador CRLF")
+		}
+		args = append(args, value[:length])
+	}
+	return args, nil
+}
+
+// readLine lê uma linha terminada em \r\n (ou \n) sem o terminador, com no
+// máximo maxLineLength bytes.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLength {
+			return "", fmt.Errorf("linha com mais de %d bytes", maxLineLength)
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// --- Escrita de respostas ---
+
+func writeSimpleString(w *bufio.Writer, s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func writeError(w *bufio.Writer, msg string) {
+	// Mensagens de erro não podem conter quebras de linha no RESP.
+	msg = strings.NewReplacer("\r", " ", "\n", " ").Replace(msg)
+	w.WriteString("-" + msg + "\r\n")
+}
+
+func writeInteger(w *bufio.Writer, n int) {
+	w.WriteString(":" + strconv.Itoa(n) + "\r\n")
+}
+
+func writeBulkString(w *bufio.Writer, s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func writeNull(w *bufio.Writer) {
+	w.WriteString("$-1\r\n")
+}
+
+func writeArrayHeader(w *bufio.Writer, n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+func writeIntArray(w *bufio.Writer, values []int) {
+	writeArrayHeader(w, len(values))
+	for _, v := range values {
+		writeBulkString(w, strconv.Itoa(v))
+	}
+}
+
+func writeStringArray(w *bufio.Writer, values []string) {
+	writeArrayHeader(w, len(values))
+	for _, v := range values {
+		writeBulkString(w, v)
+	}
+}
@@ -0,0 +1,63 @@
+package resp
+
+import (
+	"bufio"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadCommand(t *testing.T) {
+	long := strings.Repeat("x", unauthenticatedLimits.maxBulk+1)
+	tests := []struct {
+		name  string
+		input string
+		lim   limits
+		want  []string // nil com erro esperado.
+	}{
+		{"array", "*3\r\n$5\r\nRPUSH\r\n$1\r\na\r\n$2\r\n10\r\n", authenticatedLimits, []string{"RPUSH", "a", "10"}},
+		{"inline", "RPUSH a 10 20\r\n", authenticatedLimits, []string{"RPUSH", "a", "10", "20"}},
+		{"bulk com CRLF no meio", "*1\r\n$4\r\na\r\nb\r\n", authenticatedLimits, []string{"a\r\nb"}},
+		{"AUTH antes da autenticação", "*2\r\n$4\r\nAUTH\r\n$7\r\nsegredo\r\n", unauthenticatedLimits, []string{"AUTH", "segredo"}},
+		{"bulk sem CRLF", "*1\r\n$1\r\nab\r\n", authenticatedLimits, nil},
+		{"array grande antes da autenticação", "*11\r\n", unauthenticatedLimits, nil},
+		{"inline grande antes da autenticação", strings.Repeat("a ", 11) + "\r\n", unauthenticatedLimits, nil},
+		{"bulk grande antes da autenticação", "*1\r\n$" + strconv.Itoa(len(long)) + "\r\n" + long + "\r\n", unauthenticatedLimits, nil},
+		{"array além do limite", "*" + strconv.Itoa(authenticatedLimits.maxArray+1) + "\r\n", authenticatedLimits, nil},
+		{"bulk além do limite", "*1\r\n$" + strconv.Itoa(authenticatedLimits.maxBulk+1) + "\r\n", authenticatedLimits, nil},
+		{"linha longa", strings.Repeat("a", maxLineLength+1) + "\r\n", authenticatedLimits, nil},
+		{"cabeçalho longo", "*1\r\n$" + strings.Repeat("0", maxLineLength) + "1\r\n", authenticatedLimits, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readCommand(bufio.NewReader(strings.NewReader(tt.input)), tt.lim)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("lido %q, esperado erro", got)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("lido %q (erro %v), esperado %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestReadCommandDoesNotTrustDeclaredSizes confere que os tamanhos declarados
+// (e não recebidos) não são alocados de antemão.
+func TestReadCommandDoesNotTrustDeclaredSizes(t *testing.T) {
+	input := "*" + strconv.Itoa(authenticatedLimits.maxArray) + "\r\n$" + strconv.Itoa(authenticatedLimits.maxBulk) + "\r\nabc"
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	if _, err := readCommand(bufio.NewReader(strings.NewReader(input)), authenticatedLimits); err == nil {
+		t.Fatal("comando incompleto lido sem erro")
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1024*1024 {
+		t.Fatalf("%d bytes alocados para um comando incompleto de poucos bytes", allocated)
+	}
+}
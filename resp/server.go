@@ -0,0 +1,368 @@
+// Package resp implementa um listener compatível com o protocolo RESP do Redis,
+// traduzindo comandos de lista para as operações do RemoteList.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"sd-miniprojeto-1/logging"
+	"sd-miniprojeto-1/structures"
+)
+
+// Backend é o conjunto de operações usado pelo listener RESP. O servidor passa
+// o mesmo serviço registrado no net/rpc, garantindo que logs, persistência e
+// locks sejam compartilhados entre os dois protocolos.
+type Backend interface {
+	Push(args structures.PushArgs, reply *int) error
+	Remove(args structures.RemoveArgs, reply *int) error
+	Size(args structures.SizeArgs, reply *int) error
+	Range(args structures.RangeArgs, reply *[]int) error
+	Delete(args structures.DeleteArgs, reply *bool) error
+	Keys(args structures.KeysArgs, reply *[]string) error
+}
+
+// Sessions cria o Backend de cada conexão. O token vem do comando AUTH (vazio
+// ao conectar); se Open falhar, a conexão só aceita AUTH até se autenticar.
+// Se o Backend implementar io.Closer, Close é chamado quando a conexão termina
+// ou quando um novo AUTH o substitui.
+type Sessions interface {
+	Open(token, remoteAddr string) (Backend, error)
+}
+
+// Server atende conexões RESP e despacha os comandos para o Backend da sessão.
+type Server struct {
+	sessions Sessions
+}
+
+// NewServer cria um novo servidor RESP que abre sessões com o Sessions informado.
+func NewServer(sessions Sessions) *Server {
+	return &Server{sessions: sessions}
+}
+
+// connState guarda o estado de uma conexão RESP.
+type connState struct {
+	remoteAddr string
+	backend    Backend // nil enquanto não autenticada.
+}
+
+// ListenAndServe escuta no endereço informado e atende conexões até ocorrer um erro.
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("falha ao escutar RESP em %s: %w", addr, err)
+	}
+	return s.Serve(listener)
+}
+
+// Serve aceita conexões do listener e atende cada uma em sua própria goroutine.
+func (s *Server) Serve(listener net.Listener) error {
+	defer listener.Close()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// handleConn lê comandos da conexão até o cliente desconectar ou enviar QUIT.
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	state := &connState{remoteAddr: conn.RemoteAddr().String()}
+	if backend, err := s.sessions.Open("", state.remoteAddr); err == nil {
+		state.backend = backend
+	}
+	defer func() { closeBackend(state.backend) }()
+
+	for {
+		lim := authenticatedLimits
+		if state.backend == nil {
+			lim = unauthenticatedLimits
+		}
+		args, err := readCommand(reader, lim)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				writeError(writer, "ERR Protocol error: "+err.Error())
+				writer.Flush()
+				logging.Warnf("Erro de protocolo RESP de %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.dispatch(writer, state, args)
+		if err := writer.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// dispatch executa um comando e escreve a resposta. Retorna true se a conexão deve ser encerrada.
+func (s *Server) dispatch(w *bufio.Writer, state *connState, args []string) bool {
+	command := strings.ToUpper(args[0])
+	args = args[1:]
+
+	switch command {
+	case "PING", "QUIT", "COMMAND", "AUTH":
+	default:
+		if state.backend == nil {
+			writeError(w, "NOAUTH Authentication required.")
+			return false
+		}
+	}
+
+	b := state.backend
+	switch command {
+	case "AUTH":
+		// AUTH <senha> ou AUTH <usuário> <senha>: a senha é o token de acesso.
+		if len(args) < 1 || len(args) > 2 {
+			wrongArgs(w, "AUTH")
+			return false
+		}
+		backend, err := s.sessions.Open(args[len(args)-1], state.remoteAddr)
+		if err != nil {
+			writeError(w, "WRONGPASS "+err.Error())
+			return false
+		}
+		closeBackend(state.backend)
+		state.backend = backend
+		writeSimpleString(w, "OK")
+	case "PING":
+		if len(args) > 0 {
+			writeBulkString(w, args[0])
+		} else {
+			writeSimpleString(w, "PONG")
+		}
+	case "QUIT":
+		writeSimpleString(w, "OK")
+		return true
+	case "COMMAND":
+		// O redis-cli consulta COMMAND DOCS ao iniciar; uma lista vazia é suficiente.
+		writeArrayHeader(w, 0)
+	case "RPUSH":
+		rpush(w, b, args)
+	case "LINDEX":
+		lindex(w, b, args)
+	case "RPOP":
+		rpop(w, b, args)
+	case "LLEN":
+		llen(w, b, args)
+	case "LRANGE":
+		lrange(w, b, args)
+	case "DEL":
+		del(w, b, args)
+	case "KEYS":
+		keys(w, b, args)
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", truncateCommand(command)))
+	}
+	return false
+}
+
+// closeBackend encerra a sessão, se o Backend implementar io.Closer.
+func closeBackend(b Backend) {
+	if closer, ok := b.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// truncateCommand limita o tamanho do nome de comando ecoado em mensagens de erro.
+func truncateCommand(command string) string {
+	if len(command) > 64 {
+		return command[:64]
+	}
+	return command
+}
+
+// wrongArgs escreve o erro padrão do Redis para aridade incorreta.
+func wrongArgs(w *bufio.Writer, command string) {
+	writeError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command)))
+}
+
+// parseInt converte um argumento para inteiro, escrevendo o erro do Redis em caso de falha.
+func parseInt(w *bufio.Writer, s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		writeError(w, "ERR value is not an integer or out of range")
+		return 0, false
+	}
+	return n, true
+}
+
+// writeListError responde nil para os erros que o Redis trata como ausência
+// de valor e um erro RESP para os demais.
+func writeListError(w *bufio.Writer, err error, nilErrs ...error) {
+	for _, target := range nilErrs {
+		if errors.Is(err, target) {
+			writeNull(w)
+			return
+		}
+	}
+	writeError(w, "ERR "+err.Error())
+}
+
+// RPUSH key value [value ...] -> tamanho da lista após a inserção.
+func rpush(w *bufio.Writer, b Backend, args []string) {
+	if len(args) < 2 {
+		wrongArgs(w, "RPUSH")
+		return
+	}
+	if len(args)-1 > structures.MaxPushValues {
+		writeError(w, fmt.Sprintf("ERR RPUSH aceita no máximo %d valores por comando", structures.MaxPushValues))
+		return
+	}
+
+	listID := args[0]
+	values := make([]int, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		v, ok := parseInt(w, arg)
+		if !ok {
+			return
+		}
+		values = append(values, v)
+	}
+
+	// Push adiciona todos os valores de uma vez (ou nenhum) e retorna o
+	// tamanho logo após a inserção, como no Redis.
+	var size int
+	if err := b.Push(structures.PushArgs{ListID: listID, Values: values}, &size); err != nil {
+		writeError(w, "ERR "+err.Error())
+		return
+	}
+	writeInteger(w, size)
+}
+
+// LINDEX key index -> elemento ou nil. Aceita índices negativos.
+func lindex(w *bufio.Writer, b Backend, args []string) {
+	if len(args) != 2 {
+		wrongArgs(w, "LINDEX")
+		return
+	}
+	index, ok := parseInt(w, args[1])
+	if !ok {
+		return
+	}
+
+	// Um Range de um elemento resolve o índice negativo no servidor, na mesma
+	// chamada que lê o elemento: um RPOP ou RPUSH de outro cliente não se
+	// intercala entre o cálculo da posição e a leitura.
+	var values []int
+	if err := b.Range(structures.RangeArgs{ListID: args[0], Start: index, Stop: index}, &values); err != nil {
+		// Lista inexistente: o Redis responde nil.
+		writeListError(w, err, structures.ErrNotFound)
+		return
+	}
+	if len(values) == 0 {
+		// Índice fora dos limites.
+		writeNull(w)
+		return
+	}
+	writeBulkString(w, strconv.Itoa(values[0]))
+}
+
+// RPOP key -> último elemento ou nil.
+func rpop(w *bufio.Writer, b Backend, args []string) {
+	if len(args) != 1 {
+		wrongArgs(w, "RPOP")
+		return
+	}
+
+	var value int
+	if err := b.Remove(structures.RemoveArgs{ListID: args[0]}, &value); err != nil {
+		writeListError(w, err, structures.ErrNotFound, structures.ErrEmpty)
+		return
+	}
+	writeBulkString(w, strconv.Itoa(value))
+}
+
+// LLEN key -> tamanho da lista (0 se não existir).
+func llen(w *bufio.Writer, b Backend, args []string) {
+	if len(args) != 1 {
+		wrongArgs(w, "LLEN")
+		return
+	}
+
+	var size int
+	if err := b.Size(structures.SizeArgs{ListID: args[0]}, &size); err != nil && !errors.Is(err, structures.ErrNotFound) {
+		writeError(w, "ERR "+err.Error())
+		return
+	}
+	writeInteger(w, size)
+}
+
+// LRANGE key start stop -> elementos no intervalo (vazio se a lista não existir).
+func lrange(w *bufio.Writer, b Backend, args []string) {
+	if len(args) != 3 {
+		wrongArgs(w, "LRANGE")
+		return
+	}
+	start, ok := parseInt(w, args[1])
+	if !ok {
+		return
+	}
+	stop, ok := parseInt(w, args[2])
+	if !ok {
+		return
+	}
+
+	var values []int
+	if err := b.Range(structures.RangeArgs{ListID: args[0], Start: start, Stop: stop}, &values); err != nil {
+		if !errors.Is(err, structures.ErrNotFound) {
+			writeError(w, "ERR "+err.Error())
+			return
+		}
+		values = nil
+	}
+	writeIntArray(w, values)
+}
+
+// DEL key [key ...] -> quantidade de listas removidas.
+func del(w *bufio.Writer, b Backend, args []string) {
+	if len(args) < 1 {
+		wrongArgs(w, "DEL")
+		return
+	}
+
+	deleted := 0
+	for _, listID := range args {
+		var ok bool
+		err := b.Delete(structures.DeleteArgs{ListID: listID}, &ok)
+		if err != nil && !errors.Is(err, structures.ErrNotFound) {
+			writeError(w, "ERR "+err.Error())
+			return
+		}
+		if ok {
+			deleted++
+		}
+	}
+	writeInteger(w, deleted)
+}
+
+// KEYS pattern -> IDs das listas que casam com o padrão. O padrão segue
+// path.Match, e não o glob do Redis: "*" e "?" não casam com "/".
+func keys(w *bufio.Writer, b Backend, args []string) {
+	if len(args) != 1 {
+		wrongArgs(w, "KEYS")
+		return
+	}
+
+	var keys []string
+	if err := b.Keys(structures.KeysArgs{Pattern: args[0]}, &keys); err != nil {
+		writeError(w, "ERR "+err.Error())
+		return
+	}
+	writeStringArray(w, keys)
+}
@@ -0,0 +1,87 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// countingBackend é um Backend sobre um RemoteList em memória que conta as
+// chamadas.
+type countingBackend struct {
+	rl    *structures.RemoteList
+	calls int
+}
+
+func (b *countingBackend) Push(args structures.PushArgs, reply *int) error {
+	b.calls++
+	return b.rl.Push(args, reply)
+}
+
+func (b *countingBackend) Remove(args structures.RemoveArgs, reply *int) error {
+	b.calls++
+	return b.rl.Remove(args, reply)
+}
+
+func (b *countingBackend) Size(args structures.SizeArgs, reply *int) error {
+	b.calls++
+	return b.rl.Size(args, reply)
+}
+
+func (b *countingBackend) Range(args structures.RangeArgs, reply *[]int) error {
+	b.calls++
+	return b.rl.Range(args, reply)
+}
+
+func (b *countingBackend) Delete(args structures.DeleteArgs, reply *bool) error {
+	b.calls++
+	return b.rl.Delete(args, reply)
+}
+
+func (b *countingBackend) Keys(args structures.KeysArgs, reply *[]string) error {
+	b.calls++
+	return b.rl.Keys(args, reply)
+}
+
+// run executa o comando e retorna a resposta.
+func run(s *Server, state *connState, args ...string) string {
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	s.dispatch(w, state, args)
+	w.Flush()
+	return out.String()
+}
+
+func TestLindex(t *testing.T) {
+	b := &countingBackend{rl: structures.NewRemoteList()}
+	s := NewServer(nil)
+	state := &connState{backend: b}
+	if got := run(s, state, "RPUSH", "a", "10", "20", "30"); got != ":3\r\n" {
+		t.Fatalf("RPUSH: %q", got)
+	}
+
+	tests := []struct {
+		list, index, want string
+	}{
+		{"a", "0", "$2\r\n10\r\n"},
+		{"a", "2", "$2\r\n30\r\n"},
+		{"a", "3", "$-1\r\n"},
+		{"a", "-1", "$2\r\n30\r\n"},
+		{"a", "-3", "$2\r\n10\r\n"},
+		{"a", "-4", "$-1\r\n"},
+		{"inexistente", "-1", "$-1\r\n"},
+	}
+	for _, tt := range tests {
+		b.calls = 0
+		if got := run(s, state, "LINDEX", tt.list, tt.index); got != tt.want {
+			t.Errorf("LINDEX %s %s = %q, esperado %q", tt.list, tt.index, got, tt.want)
+		}
+		// Uma única chamada: o índice negativo é resolvido no servidor, sem
+		// um Size separado que outra alteração poderia invalidar.
+		if b.calls != 1 {
+			t.Errorf("LINDEX %s %s fez %d chamadas ao Backend, esperado 1", tt.list, tt.index, b.calls)
+		}
+	}
+}
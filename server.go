@@ -1,179 +1,1237 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"net/rpc"
 	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"sd-miniprojeto-1/auth"
+	"sd-miniprojeto-1/config"
+	"sd-miniprojeto-1/engine"
+	"sd-miniprojeto-1/health"
+	"sd-miniprojeto-1/logging"
+	"sd-miniprojeto-1/metrics"
+	"sd-miniprojeto-1/ratelimit"
+	"sd-miniprojeto-1/resp"
+	"sd-miniprojeto-1/storage"
 	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/tlsutil"
 	"sd-miniprojeto-1/utils"
+	"sd-miniprojeto-1/workload"
 )
 
 const (
-	snapshotInterval = 10 * time.Second // Intervalo entre salvamentos de snapshots.
-	serverPort       = ":1234"          // Porta do servidor RPC.
+	snapshotInterval   = 10 * time.Second       // Intervalo entre salvamentos de snapshots.
+	serverPort         = ":1234"                // Porta do servidor RPC.
+	overloadRetryAfter = 100 * time.Millisecond // Espera sugerida ao recusar chamadas por sobrecarga.
 )
 
 // RemoteListService atende aos pedidos dos clientes via RPC.
 type RemoteListService struct {
+	serverID                     string                 // Identidade do servidor, informada no Ping.
+	checker                      *health.Checker        // Estado de prontidão e uptime.
+	authorizer                   *auth.Authorizer       // Autenticação e regras de acesso.
+	auditor                      *auth.Auditor          // Registro de chamadas negadas.
+	opsLimiter                   *ratelimit.Keyed       // Limite de operações por segundo de cada namespace.
+	clientLimiter                *ratelimit.Keyed       // Limite de chamadas por segundo de cada cliente.
+	methodLimiter                *ratelimit.Keyed       // Limite de chamadas por segundo de cada cliente em cada método.
+	concurrency                  *ratelimit.Concurrency // Limite de chamadas simultâneas.
+	queueTimeout                 atomic.Int64           // Espera máxima (ns) por uma vaga de concorrência.
 	remoteList                   *structures.RemoteList // Gerencia os dados das listas.
-	lastLoggedOperationTimestamp time.Time              // Horário da última operação salva no log.
-	logTimestampMutex            sync.Mutex             // Protege o acesso ao timestamp do log.
+	engine                       *engine.Engine         // Registra as operações no log e as aplica às listas.
+	snapshots                    storage.Snapshotter    // Onde o estado das listas é salvo.
+	logs                         storage.LogStore       // Onde as operações são registradas.
+
+	sessionsMu  sync.Mutex                  // Protege sessions.
+	sessions    map[*clientSession]struct{} // Conexões de clientes abertas (RPC e RESP).
+	lastSession atomic.Uint64               // Número da última sessão aberta.
+
+	recorder *workload.Recorder // Gravação das chamadas dos clientes (-record); nil se desativada.
+
+	configPath   string       // Arquivo de configuração (-config); vazio se não houver.
+	reloadConfig func() error // Relê e aplica o arquivo de configuração (SIGHUP ou Admin).
 }
 
-// logAndTrackWithValue registra operações com valor/índice e atualiza o timestamp do log.
-func (s *RemoteListService) logAndTrackWithValue(logFunc func(string, int) error, listID string, val int) error {
-	operationTime := time.Now()
-	err := logFunc(listID, val)
+// observe registra contagem, latência e erros de uma chamada RPC e devolve o erro recebido.
+func (s *RemoteListService) observe(method string, start time.Time, err error) error {
+	elapsed := time.Since(start)
+	logging.Debugf("%s concluída em %v (erro: %v)", method, elapsed, err)
+	metrics.RPCRequests.Inc(method)
+	metrics.RPCDuration.Observe(elapsed.Seconds(), method)
 	if err != nil {
-		return err
+		code := "UNKNOWN"
+		var typed *structures.Error
+		if errors.As(err, &typed) {
+			code = string(typed.Code)
+		}
+		metrics.RPCErrors.Inc(method, code)
 	}
+	return err
+}
 
-	s.logTimestampMutex.Lock()
-	if operationTime.After(s.lastLoggedOperationTimestamp) {
-		s.lastLoggedOperationTimestamp = operationTime
+// admit aplica os limites de taxa do namespace, do cliente e do método, nessa ordem.
+// As recusas trazem a espera sugerida até a próxima chamada ser aceita.
+func (s *RemoteListService) admit(namespace, client, method string) error {
+	if ok, wait := s.opsLimiter.Take(namespace); !ok {
+		metrics.RPCRejected.Inc("namespace_rate")
+		return structures.NewError(structures.CodeResourceExhausted,
+			"namespace '%s' excedeu o limite de operações por segundo", namespace).WithRetryAfter(wait)
+	}
+	if ok, wait := s.clientLimiter.Take(client); !ok {
+		metrics.RPCRejected.Inc("client_rate")
+		return structures.NewError(structures.CodeResourceExhausted,
+			"cliente '%s' excedeu o limite de chamadas por segundo", client).WithRetryAfter(wait)
+	}
+	if ok, wait := s.methodLimiter.Take(client + " " + method); !ok {
+		metrics.RPCRejected.Inc("method_rate")
+		return structures.NewError(structures.CodeResourceExhausted,
+			"cliente '%s' excedeu o limite de chamadas por segundo em %s", client, method).WithRetryAfter(wait)
 	}
-	s.logTimestampMutex.Unlock()
 	return nil
 }
 
-// logAndTrackWithoutValue registra operações sem valor/índice e atualiza o timestamp do log.
-func (s *RemoteListService) logAndTrackWithoutValue(logFunc func(string) error, listID string) error {
-	operationTime := time.Now()
-	err := logFunc(listID)
-	if err != nil {
-		return err
+// acquire ocupa uma vaga de concorrência, esperando na fila até o tempo
+// configurado (ou recusando na hora, com a fila cheia). Com sucesso, concurrency.Release deve ser chamado ao final.
+func (s *RemoteListService) acquire() error {
+	if !s.concurrency.Acquire(time.Duration(s.queueTimeout.Load())) {
+		metrics.RPCRejected.Inc("concurrency")
+		return structures.NewError(structures.CodeResourceExhausted,
+			"servidor no limite de chamadas simultâneas").WithRetryAfter(overloadRetryAfter)
+	}
+	return nil
+}
+
+// applyLimits aplica os limites de taxa por cliente e de concorrência da configuração.
+func (s *RemoteListService) applyLimits(cfg config.LimitsConfig) {
+	toLimits := func(r config.RateConfig) (rate, burst float64) {
+		burst = float64(r.Burst)
+		if burst == 0 {
+			burst = math.Max(r.Rate, 1) // Rajada de até um segundo de chamadas.
+		}
+		return r.Rate, burst
+	}
+	s.clientLimiter.SetLimits(func(client string) (rate, burst float64) {
+		return toLimits(cfg.ClientRate(client))
+	})
+	s.methodLimiter.SetLimits(func(key string) (rate, burst float64) {
+		_, method, _ := strings.Cut(key, " ")
+		return toLimits(cfg.PerMethod[method])
+	})
+	maxQueue := cfg.MaxQueue
+	if maxQueue == 0 {
+		maxQueue = cfg.MaxConcurrent
 	}
+	s.concurrency.SetMax(cfg.MaxConcurrent, maxQueue)
+	s.queueTimeout.Store(int64(time.Duration(cfg.QueueTimeoutMs) * time.Millisecond))
+}
 
-	s.logTimestampMutex.Lock()
-	if operationTime.After(s.lastLoggedOperationTimestamp) {
-		s.lastLoggedOperationTimestamp = operationTime
+// applyNamespaceQuotas aplica as cotas de namespaces da configuração.
+func (s *RemoteListService) applyNamespaceQuotas(cfg config.NamespacesConfig) {
+	toQuota := func(q config.QuotaConfig) structures.Quota {
+		return structures.Quota{MaxLists: q.MaxLists, MaxElements: q.MaxElements}
 	}
-	s.logTimestampMutex.Unlock()
-	return nil
+	quotas := make(map[string]structures.Quota, len(cfg.Quotas))
+	for name, q := range cfg.Quotas {
+		quotas[name] = toQuota(q)
+	}
+	s.remoteList.SetQuotas(toQuota(cfg.DefaultQuota), quotas)
+
+	s.opsLimiter.SetLimits(func(namespace string) (rate, burst float64) {
+		rate = cfg.Quota(namespace).MaxOpsPerSecond
+		return rate, math.Max(rate, 1) // Rajada de até um segundo de operações.
+	})
+}
+
+// snapshot salva um snapshot cobrindo as operações registradas no log até agora.
+func (s *RemoteListService) snapshot() (structures.SnapshotReply, error) {
+	return s.engine.Snapshot()
 }
 
 // Append é o método RPC para adicionar um valor a uma lista.
 func (s *RemoteListService) Append(args structures.AppendArgs, reply *bool) error {
-	if err := s.logAndTrackWithValue(utils.AppendLog, args.ListID, args.Value); err != nil {
-		log.Printf("Erro ao logar APPEND para ListaID %s, Valor %d: %v", args.ListID, args.Value, err)
-	}
-	return s.remoteList.Append(args, reply)
+	return s.observe("Append", time.Now(), s.engine.Append(args, reply))
+}
+
+// Push é o método RPC para adicionar vários valores a uma lista de uma vez.
+func (s *RemoteListService) Push(args structures.PushArgs, reply *int) error {
+	return s.observe("Push", time.Now(), s.engine.Push(args, reply))
 }
 
 // Get é o método RPC para obter um valor de uma lista.
 func (s *RemoteListService) Get(args structures.GetArgs, reply *int) error {
-	if err := s.logAndTrackWithValue(utils.GetLog, args.ListID, args.Index); err != nil {
-		log.Printf("Erro ao logar GET para ListaID %s, Índice %d: %v", args.ListID, args.Index, err)
-	}
-	return s.remoteList.Get(args, reply)
+	return s.observe("Get", time.Now(), s.engine.Get(args, reply))
 }
 
 // Remove é o método RPC para remover o último elemento de uma lista.
 func (s *RemoteListService) Remove(args structures.RemoveArgs, reply *int) error {
-	if err := s.logAndTrackWithoutValue(utils.RemoveLog, args.ListID); err != nil {
-		log.Printf("Erro ao logar REMOVE para ListaID %s: %v", args.ListID, err)
-	}
-	return s.remoteList.Remove(args, reply)
+	return s.observe("Remove", time.Now(), s.engine.Remove(args, reply))
 }
 
 // Size é o método RPC para obter o tamanho de uma lista.
 func (s *RemoteListService) Size(args structures.SizeArgs, reply *int) error {
-	if err := s.logAndTrackWithValue(utils.GetLog, args.ListID, 0); err != nil {
-		log.Printf("Erro ao logar SIZE para ListaID %s: %v", args.ListID, err)
+	return s.observe("Size", time.Now(), s.engine.Size(args, reply))
+}
+
+// Range é o método RPC para obter um intervalo de elementos de uma lista.
+func (s *RemoteListService) Range(args structures.RangeArgs, reply *[]int) error {
+	return s.observe("Range", time.Now(), s.engine.Range(args, reply))
+}
+
+// GetAt é o método RPC para obter um valor de uma lista em um instante do passado.
+func (s *RemoteListService) GetAt(args structures.GetAtArgs, reply *int) error {
+	return s.observe("GetAt", time.Now(), s.engine.GetAt(args, reply))
+}
+
+// Delete é o método RPC para remover uma lista inteira.
+func (s *RemoteListService) Delete(args structures.DeleteArgs, reply *bool) error {
+	return s.observe("Delete", time.Now(), s.engine.Delete(args, reply))
+}
+
+// Sum é o método RPC para somar os elementos de uma lista.
+func (s *RemoteListService) Sum(args structures.AggregateArgs, reply *int) error {
+	return s.observe("Sum", time.Now(), s.engine.Sum(args, reply))
+}
+
+// Min é o método RPC para obter o menor elemento de uma lista.
+func (s *RemoteListService) Min(args structures.AggregateArgs, reply *int) error {
+	return s.observe("Min", time.Now(), s.engine.Min(args, reply))
+}
+
+// Max é o método RPC para obter o maior elemento de uma lista.
+func (s *RemoteListService) Max(args structures.AggregateArgs, reply *int) error {
+	return s.observe("Max", time.Now(), s.engine.Max(args, reply))
+}
+
+// Avg é o método RPC para obter a média dos elementos de uma lista.
+func (s *RemoteListService) Avg(args structures.AggregateArgs, reply *float64) error {
+	return s.observe("Avg", time.Now(), s.engine.Avg(args, reply))
+}
+
+// Count é o método RPC para contar os elementos de uma lista que satisfazem um predicado.
+func (s *RemoteListService) Count(args structures.CountArgs, reply *int) error {
+	return s.observe("Count", time.Now(), s.engine.Count(args, reply))
+}
+
+// Histogram é o método RPC para contar os elementos de uma lista por faixa de valores.
+func (s *RemoteListService) Histogram(args structures.HistogramArgs, reply *[]int) error {
+	return s.observe("Histogram", time.Now(), s.engine.Histogram(args, reply))
+}
+
+// IndexOf é o método RPC para obter a primeira posição de um valor em uma lista.
+func (s *RemoteListService) IndexOf(args structures.SearchArgs, reply *int) error {
+	return s.observe("IndexOf", time.Now(), s.engine.IndexOf(args, reply))
+}
+
+// LastIndexOf é o método RPC para obter a última posição de um valor em uma lista.
+func (s *RemoteListService) LastIndexOf(args structures.SearchArgs, reply *int) error {
+	return s.observe("LastIndexOf", time.Now(), s.engine.LastIndexOf(args, reply))
+}
+
+// Contains é o método RPC para verificar se um valor está em uma lista.
+func (s *RemoteListService) Contains(args structures.SearchArgs, reply *bool) error {
+	return s.observe("Contains", time.Now(), s.engine.Contains(args, reply))
+}
+
+// FindAll é o método RPC para obter todas as posições de um valor em uma lista.
+func (s *RemoteListService) FindAll(args structures.SearchArgs, reply *[]int) error {
+	return s.observe("FindAll", time.Now(), s.engine.FindAll(args, reply))
+}
+
+// Ping é o método RPC de verificação de vida. Não é logado.
+func (s *RemoteListService) Ping(args structures.PingArgs, reply *structures.PingReply) error {
+	start := time.Now()
+	*reply = structures.PingReply{
+		ServerID:  s.serverID,
+		StartedAt: s.checker.StartedAt(),
+		Uptime:    s.checker.Uptime(),
+		LastLSN:   s.logs.LastLSN(),
+		Ready:     s.checker.Ready(),
 	}
-	return s.remoteList.Size(args, reply)
+	return s.observe("Ping", start, nil)
 }
 
-func main() {
-	// 0. Prepara as pastas para logs e snapshots.
-	if err := os.MkdirAll("logs", 0755); err != nil {
-		log.Fatalf("Falha ao criar diretório 'logs': %v", err)
+// Keys é o método RPC para listar os IDs das listas existentes.
+// Não é logado, pois não se refere a uma lista específica.
+func (s *RemoteListService) Keys(args structures.KeysArgs, reply *[]string) error {
+	return s.observe("Keys", time.Now(), s.remoteList.Keys(args, reply))
+}
+
+// --- Sessões autenticadas ---
+
+// clientSession é a visão do RemoteListService para uma conexão. Cada chamada
+// é autorizada com o token da conexão e restrita ao namespace do cliente
+// antes de ser delegada ao serviço.
+type clientSession struct {
+	svc         *RemoteListService
+	id          uint64 // Número da sessão, para agrupar as chamadas gravadas por conexão.
+	token       string
+	remoteAddr  string
+	protocol    string        // "rpc" ou "resp".
+	connectedAt time.Time     // Horário da conexão.
+	calls       atomic.Uint64 // Chamadas feitas na conexão.
+}
+
+// openSession cria uma sessão e a registra na lista de conexões abertas.
+func (s *RemoteListService) openSession(token, remoteAddr, protocol string) *clientSession {
+	c := &clientSession{svc: s, id: s.lastSession.Add(1), token: token, remoteAddr: remoteAddr, protocol: protocol, connectedAt: time.Now()}
+	s.sessionsMu.Lock()
+	s.sessions[c] = struct{}{}
+	s.sessionsMu.Unlock()
+	return c
+}
+
+// Close remove a sessão da lista de conexões abertas. Implementa io.Closer,
+// chamado pelo listener RESP ao encerrar a conexão.
+func (c *clientSession) Close() error {
+	c.svc.sessionsMu.Lock()
+	delete(c.svc.sessions, c)
+	c.svc.sessionsMu.Unlock()
+	return nil
+}
+
+// begin autoriza a chamada sobre a lista e aplica os limites de taxa e de
+// concorrência, auditando as chamadas negadas por permissão e contabilizando
+// todas as recusas. Retorna o namespace do cliente e a função que encerra a chamada.
+func (c *clientSession) begin(method string, perm auth.Permission, listID string) (namespace string, end func(), err error) {
+	start := time.Now()
+	c.calls.Add(1)
+	identity, err := c.svc.authorizer.Authorize(c.token, perm, listID)
+	if err != nil {
+		client := ""
+		if identity != nil {
+			client = identity.Client
+		}
+		if auditErr := c.svc.auditor.Deny(client, c.remoteAddr, method, listID, err); auditErr != nil {
+			logging.Errorf("Erro ao auditar chamada negada: %v", auditErr)
+		}
+		return "", nil, c.svc.observe(method, start, err)
 	}
-	if err := os.MkdirAll("snapshots", 0755); err != nil {
-		log.Fatalf("Falha ao criar diretório 'snapshots': %v", err)
+
+	end, err = c.admit(method, identity)
+	if err != nil {
+		return "", nil, c.svc.observe(method, start, err)
 	}
+	return identity.Namespace, end, nil
+}
 
-	// 1. Carrega dados de snapshot e logs para recuperar o estado.
-	fmt.Println("Tentando carregar snapshot...")
-	remoteList, lastSnapshotLogTimestamp, err := utils.LoadSnapshot()
+// admit aplica à identidade já autorizada os limites de taxa e de concorrência.
+func (c *clientSession) admit(method string, identity *auth.Identity) (end func(), err error) {
+	if err := c.svc.admit(identity.Namespace, c.clientKey(identity), method); err != nil {
+		return nil, err
+	}
+	if err := c.svc.acquire(); err != nil {
+		return nil, err
+	}
+	return c.svc.concurrency.Release, nil
+}
+
+// clientKey identifica o cliente nos limites de taxa. Clientes anônimos
+// (autenticação desativada) são distinguidos pelo host de origem.
+func (c *clientSession) clientKey(identity *auth.Identity) string {
+	if c.svc.authorizer.Enabled() {
+		return identity.Client
+	}
+	host, _, err := net.SplitHostPort(c.remoteAddr)
 	if err != nil {
-		log.Fatalf("Erro ao carregar snapshot: %v", err)
+		host = c.remoteAddr
 	}
-	fmt.Println("Snapshot carregado ou nova lista criada.")
+	return identity.Client + "@" + host
+}
 
-	remoteListService := &RemoteListService{
-		remoteList:                   remoteList,
-		lastLoggedOperationTimestamp: lastSnapshotLogTimestamp,
+// record grava a chamada, se a gravação estiver ativa. É adiado no início de
+// cada método, para gravar também as chamadas recusadas; args é gravado como
+// o cliente o enviou (sem o namespace, que vem da sessão).
+func (c *clientSession) record(method string, start time.Time, args, reply any, err *error) {
+	recorder := c.svc.recorder
+	if recorder == nil {
+		return
+	}
+	call := workload.Call{
+		Start:      start,
+		Duration:   time.Since(start),
+		Session:    c.id,
+		RemoteAddr: c.remoteAddr,
+		Protocol:   c.protocol,
+		Method:     method,
+	}
+	if identity, authErr := c.svc.authorizer.Authenticate(c.token); authErr == nil {
+		call.Client = identity.Client
+	}
+	if recordErr := recorder.Record(call, args, reply, *err); recordErr != nil {
+		logging.Errorf("Erro ao gravar chamada %s: %v", method, recordErr)
 	}
+}
 
-	// Lógica de Recuperação de Dados.
-	recoveryStartTime := remoteListService.lastLoggedOperationTimestamp
-	if recoveryStartTime.IsZero() {
-		fmt.Println("Nenhum timestamp de snapshot válido. Aplicando todos os logs disponíveis.")
-	} else {
-		fmt.Printf("Aplicando logs a partir de %s...\n", recoveryStartTime.Format(time.RFC3339Nano))
+// Append exige a permissão "append".
+func (c *clientSession) Append(args structures.AppendArgs, reply *bool) (err error) {
+	defer c.record("Append", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Append", auth.PermAppend, args.ListID)
+	if err != nil {
+		return err
 	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Append(args, reply)
+}
 
-	logEntries, err := utils.ReadLogsFromTimestamp(recoveryStartTime)
+// Push exige a permissão "append".
+func (c *clientSession) Push(args structures.PushArgs, reply *int) (err error) {
+	defer c.record("Push", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Push", auth.PermAppend, args.ListID)
 	if err != nil {
-		log.Printf("Erro ao ler logs para recuperação: %v.", err)
-	} else {
-		for _, entry := range logEntries {
-			// Reaplica operações do log diretamente na lista (sem logar novamente).
-			switch entry.Operation {
-			case "Append":
-				remoteList.Append(structures.AppendArgs{ListID: entry.ListID, Value: entry.Value}, new(bool))
-			case "Remove":
-				remoteList.Remove(structures.RemoveArgs{ListID: entry.ListID}, new(int))
-			}
-			// Atualiza o timestamp da última operação reaplicada.
-			remoteListService.logTimestampMutex.Lock()
-			if entry.Timestamp.After(remoteListService.lastLoggedOperationTimestamp) {
-				remoteListService.lastLoggedOperationTimestamp = entry.Timestamp
-			}
-			remoteListService.logTimestampMutex.Unlock()
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Push(args, reply)
+}
+
+// Get exige a permissão "read".
+func (c *clientSession) Get(args structures.GetArgs, reply *int) (err error) {
+	defer c.record("Get", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Get", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Get(args, reply)
+}
+
+// Remove exige a permissão "remove".
+func (c *clientSession) Remove(args structures.RemoveArgs, reply *int) (err error) {
+	defer c.record("Remove", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Remove", auth.PermRemove, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Remove(args, reply)
+}
+
+// Size exige a permissão "read".
+func (c *clientSession) Size(args structures.SizeArgs, reply *int) (err error) {
+	defer c.record("Size", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Size", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Size(args, reply)
+}
+
+// Range exige a permissão "read".
+func (c *clientSession) Range(args structures.RangeArgs, reply *[]int) (err error) {
+	defer c.record("Range", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Range", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Range(args, reply)
+}
+
+// GetAt exige a permissão "read".
+func (c *clientSession) GetAt(args structures.GetAtArgs, reply *int) (err error) {
+	defer c.record("GetAt", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("GetAt", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.GetAt(args, reply)
+}
+
+// Delete exige a permissão "remove".
+func (c *clientSession) Delete(args structures.DeleteArgs, reply *bool) (err error) {
+	defer c.record("Delete", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Delete", auth.PermRemove, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Delete(args, reply)
+}
+
+// Sum exige a permissão "read".
+func (c *clientSession) Sum(args structures.AggregateArgs, reply *int) (err error) {
+	defer c.record("Sum", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Sum", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Sum(args, reply)
+}
+
+// Min exige a permissão "read".
+func (c *clientSession) Min(args structures.AggregateArgs, reply *int) (err error) {
+	defer c.record("Min", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Min", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Min(args, reply)
+}
+
+// Max exige a permissão "read".
+func (c *clientSession) Max(args structures.AggregateArgs, reply *int) (err error) {
+	defer c.record("Max", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Max", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Max(args, reply)
+}
+
+// Avg exige a permissão "read".
+func (c *clientSession) Avg(args structures.AggregateArgs, reply *float64) (err error) {
+	defer c.record("Avg", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Avg", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Avg(args, reply)
+}
+
+// Count exige a permissão "read".
+func (c *clientSession) Count(args structures.CountArgs, reply *int) (err error) {
+	defer c.record("Count", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Count", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Count(args, reply)
+}
+
+// Histogram exige a permissão "read".
+func (c *clientSession) Histogram(args structures.HistogramArgs, reply *[]int) (err error) {
+	defer c.record("Histogram", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Histogram", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Histogram(args, reply)
+}
+
+// IndexOf exige a permissão "read".
+func (c *clientSession) IndexOf(args structures.SearchArgs, reply *int) (err error) {
+	defer c.record("IndexOf", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("IndexOf", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.IndexOf(args, reply)
+}
+
+// LastIndexOf exige a permissão "read".
+func (c *clientSession) LastIndexOf(args structures.SearchArgs, reply *int) (err error) {
+	defer c.record("LastIndexOf", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("LastIndexOf", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.LastIndexOf(args, reply)
+}
+
+// Contains exige a permissão "read".
+func (c *clientSession) Contains(args structures.SearchArgs, reply *bool) (err error) {
+	defer c.record("Contains", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("Contains", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.Contains(args, reply)
+}
+
+// FindAll exige a permissão "read".
+func (c *clientSession) FindAll(args structures.SearchArgs, reply *[]int) (err error) {
+	defer c.record("FindAll", time.Now(), args, reply, &err)
+	namespace, end, err := c.begin("FindAll", auth.PermRead, args.ListID)
+	if err != nil {
+		return err
+	}
+	defer end()
+	args.Namespace = namespace
+	return c.svc.FindAll(args, reply)
+}
+
+// Keys retorna apenas as listas do namespace que a sessão pode ler.
+func (c *clientSession) Keys(args structures.KeysArgs, reply *[]string) (err error) {
+	start := time.Now()
+	defer c.record("Keys", start, args, reply, &err)
+	identity, err := c.svc.authorizer.Authenticate(c.token)
+	if err != nil {
+		_, _, err = c.begin("Keys", auth.PermRead, "")
+		return err
+	}
+	c.calls.Add(1)
+	end, err := c.admit("Keys", identity)
+	if err != nil {
+		return c.svc.observe("Keys", start, err)
+	}
+	defer end()
+
+	var all []string
+	args.Namespace = identity.Namespace
+	if err := c.svc.Keys(args, &all); err != nil {
+		return err
+	}
+	visible := make([]string, 0, len(all))
+	for _, listID := range all {
+		if c.svc.authorizer.Allowed(identity, auth.PermRead, listID) {
+			visible = append(visible, listID)
+		}
+	}
+	*reply = visible
+	return nil
+}
+
+// Batch executa as operações do lote em ordem, cada uma pelo método
+// correspondente da sessão: com a própria autorização, limites, métricas e
+// gravação, como se tivessem sido chamadas uma a uma. Só a ida e volta é
+// economizada; o lote não é atômico.
+func (c *clientSession) Batch(args structures.BatchArgs, reply *structures.BatchReply) error {
+	start := time.Now()
+	if len(args.Ops) > structures.MaxBatchOps {
+		return c.svc.observe("Batch", start, structures.NewError(structures.CodeInvalidArgument,
+			"lote com %d operações; o máximo é %d", len(args.Ops), structures.MaxBatchOps))
+	}
+	results := make([]structures.BatchResult, 0, len(args.Ops))
+	for _, op := range args.Ops {
+		result := c.batchOp(op)
+		results = append(results, result)
+		if result.Error != nil && args.StopOnError {
+			break
+		}
+	}
+	reply.Results = results
+	return c.svc.observe("Batch", start, nil)
+}
+
+// batchOp executa uma operação do lote.
+func (c *clientSession) batchOp(op structures.BatchOp) (result structures.BatchResult) {
+	var ok bool
+	var err error
+	aggregate := structures.AggregateArgs{ListID: op.ListID, Bounded: op.Bounded, Start: op.Start, Stop: op.Stop}
+	search := structures.SearchArgs{ListID: op.ListID, Value: op.Value}
+	switch op.Method {
+	case "Append":
+		err = c.Append(structures.AppendArgs{ListID: op.ListID, Value: op.Value}, &ok)
+	case "Get":
+		err = c.Get(structures.GetArgs{ListID: op.ListID, Index: op.Index}, &result.Value)
+	case "GetAt":
+		err = c.GetAt(structures.GetAtArgs{ListID: op.ListID, Index: op.Index, AsOf: op.AsOf}, &result.Value)
+	case "Remove":
+		err = c.Remove(structures.RemoveArgs{ListID: op.ListID}, &result.Value)
+	case "Size":
+		err = c.Size(structures.SizeArgs{ListID: op.ListID}, &result.Value)
+	case "Range":
+		err = c.Range(structures.RangeArgs{ListID: op.ListID, Start: op.Start, Stop: op.Stop}, &result.Values)
+	case "Delete":
+		err = c.Delete(structures.DeleteArgs{ListID: op.ListID}, &ok)
+	case "Keys":
+		err = c.Keys(structures.KeysArgs{Pattern: op.Pattern}, &result.Keys)
+	case "Sum":
+		err = c.Sum(aggregate, &result.Value)
+	case "Min":
+		err = c.Min(aggregate, &result.Value)
+	case "Max":
+		err = c.Max(aggregate, &result.Value)
+	case "Avg":
+		err = c.Avg(aggregate, &result.Mean)
+	case "Count":
+		err = c.Count(structures.CountArgs{ListID: op.ListID, Bounded: op.Bounded, Start: op.Start, Stop: op.Stop, Op: op.Op, Value: op.Value}, &result.Value)
+	case "Histogram":
+		err = c.Histogram(structures.HistogramArgs{ListID: op.ListID, Bounded: op.Bounded, Start: op.Start, Stop: op.Stop, Bounds: op.Bounds}, &result.Values)
+	case "IndexOf":
+		err = c.IndexOf(search, &result.Value)
+	case "LastIndexOf":
+		err = c.LastIndexOf(search, &result.Value)
+	case "Contains":
+		err = c.Contains(search, &result.Found)
+	case "FindAll":
+		err = c.FindAll(search, &result.Values)
+	default:
+		err = structures.NewError(structures.CodeInvalidArgument, "método '%s' não é permitido em lotes", op.Method)
+	}
+	if err != nil {
+		var typed *structures.Error
+		if !errors.As(err, &typed) {
+			typed = structures.NewError(structures.CodeInternal, "%v", err)
+		}
+		result.Error = typed
+	}
+	return result
+}
+
+// Ping exige apenas um token válido.
+func (c *clientSession) Ping(args structures.PingArgs, reply *structures.PingReply) (err error) {
+	defer c.record("Ping", time.Now(), args, reply, &err)
+	if _, err := c.svc.authorizer.Authenticate(c.token); err != nil {
+		return err
+	}
+	return c.svc.Ping(args, reply)
+}
+
+// Open implementa resp.Sessions: abre uma sessão para uma conexão RESP.
+func (s *RemoteListService) Open(token, remoteAddr string) (resp.Backend, error) {
+	if _, err := s.authorizer.Authenticate(token); err != nil {
+		// A abertura implícita (sem token) ao conectar não é uma tentativa de AUTH.
+		if token != "" {
+			s.auditor.Deny("", remoteAddr, "AUTH", "", err)
+		}
+		return nil, err
+	}
+	return s.openSession(token, remoteAddr, "resp"), nil
+}
+
+// --- Serviço Admin ---
+
+// adminSession expõe o serviço "Admin" a uma conexão RPC. Todas as chamadas
+// exigem a permissão "admin" sobre todas as listas ("*").
+type adminSession struct {
+	svc        *RemoteListService
+	token      string
+	remoteAddr string
+}
+
+// authorize verifica a permissão de administrador, auditando as recusas.
+func (a *adminSession) authorize(method string) error {
+	start := time.Now()
+	identity, err := a.svc.authorizer.Authorize(a.token, auth.PermAdmin, "*")
+	if err != nil {
+		client := ""
+		if identity != nil {
+			client = identity.Client
+		}
+		if auditErr := a.svc.auditor.Deny(client, a.remoteAddr, "Admin."+method, "*", err); auditErr != nil {
+			logging.Errorf("Erro ao auditar chamada negada: %v", auditErr)
+		}
+		return a.svc.observe("Admin."+method, start, err)
+	}
+	logging.Infof("Admin.%s chamado por %s (%s).", method, identity.Client, a.remoteAddr)
+	return nil
+}
+
+// ForceSnapshot salva um snapshot imediatamente.
+func (a *adminSession) ForceSnapshot(args structures.AdminArgs, reply *structures.SnapshotReply) error {
+	if err := a.authorize("ForceSnapshot"); err != nil {
+		return err
+	}
+	start := time.Now()
+	snapshot, err := a.svc.snapshot()
+	if err != nil {
+		return a.svc.observe("Admin.ForceSnapshot", start, structures.NewError(structures.CodeInternal, "erro ao salvar snapshot: %v", err))
+	}
+	*reply = snapshot
+	return a.svc.observe("Admin.ForceSnapshot", start, nil)
+}
+
+// CompactLog salva um snapshot e remove do log as entradas cobertas por ele.
+func (a *adminSession) CompactLog(args structures.AdminArgs, reply *structures.CompactLogReply) error {
+	if err := a.authorize("CompactLog"); err != nil {
+		return err
+	}
+	start := time.Now()
+	result, err := a.svc.engine.CompactLog()
+	if err != nil {
+		return a.svc.observe("Admin.CompactLog", start, structures.NewError(structures.CodeInternal, "%v", err))
+	}
+	*reply = result
+	return a.svc.observe("Admin.CompactLog", start, nil)
+}
+
+// Stats retorna contagens de listas, uso de memória, posição do log e idade do snapshot.
+func (a *adminSession) Stats(args structures.AdminArgs, reply *structures.StatsReply) error {
+	if err := a.authorize("Stats"); err != nil {
+		return err
+	}
+	start := time.Now()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	lists, elements := a.svc.remoteList.Stats()
+	*reply = structures.StatsReply{
+		ServerID:     a.svc.serverID,
+		Uptime:       a.svc.checker.Uptime(),
+		Lists:        lists,
+		Elements:     elements,
+		Namespaces:   a.svc.remoteList.Usage(),
+		HeapBytes:    mem.HeapAlloc,
+		SysBytes:     mem.Sys,
+		Goroutines:   runtime.NumGoroutine(),
+		LastLSN:      a.svc.logs.LastLSN(),
+		LogBytes:     a.svc.logs.Size(),
+		LastSnapshot: a.svc.snapshots.LastSaved(),
+		Snapshots:    a.svc.snapshots.Generations(),
+		LogLevel:     logging.CurrentLevel().String(),
+	}
+	if !reply.LastSnapshot.IsZero() {
+		reply.SnapshotAge = time.Since(reply.LastSnapshot)
+	}
+	return a.svc.observe("Admin.Stats", start, nil)
+}
+
+// ListClients retorna as conexões de clientes abertas, das mais antigas para as mais novas.
+func (a *adminSession) ListClients(args structures.AdminArgs, reply *[]structures.ClientInfo) error {
+	if err := a.authorize("ListClients"); err != nil {
+		return err
+	}
+	start := time.Now()
+	a.svc.sessionsMu.Lock()
+	sessions := make([]*clientSession, 0, len(a.svc.sessions))
+	for c := range a.svc.sessions {
+		sessions = append(sessions, c)
+	}
+	a.svc.sessionsMu.Unlock()
+
+	clients := make([]structures.ClientInfo, 0, len(sessions))
+	for _, c := range sessions {
+		info := structures.ClientInfo{
+			RemoteAddr:  c.remoteAddr,
+			Protocol:    c.protocol,
+			ConnectedAt: c.connectedAt,
+			Calls:       c.calls.Load(),
+		}
+		// O token é resolvido agora: um token revogado aparece sem cliente.
+		if identity, err := a.svc.authorizer.Authenticate(c.token); err == nil {
+			info.Client, info.Namespace = identity.Client, identity.Namespace
+		}
+		clients = append(clients, info)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ConnectedAt.Before(clients[j].ConnectedAt) })
+
+	*reply = clients
+	return a.svc.observe("Admin.ListClients", start, nil)
+}
+
+// SetLogLevel troca o nível de log do servidor e retorna o nível anterior.
+func (a *adminSession) SetLogLevel(args structures.SetLogLevelArgs, reply *string) error {
+	if err := a.authorize("SetLogLevel"); err != nil {
+		return err
+	}
+	start := time.Now()
+	level, err := logging.ParseLevel(args.Level)
+	if err != nil {
+		return a.svc.observe("Admin.SetLogLevel", start, structures.NewError(structures.CodeInvalidArgument, "%v", err))
+	}
+	*reply = logging.CurrentLevel().String()
+	logging.SetLevel(level)
+	return a.svc.observe("Admin.SetLogLevel", start, nil)
+}
+
+// ReloadConfig relê e aplica o arquivo de configuração, como o SIGHUP.
+func (a *adminSession) ReloadConfig(args structures.AdminArgs, reply *structures.ReloadConfigReply) error {
+	if err := a.authorize("ReloadConfig"); err != nil {
+		return err
+	}
+	start := time.Now()
+	if a.svc.reloadConfig == nil {
+		return a.svc.observe("Admin.ReloadConfig", start, structures.NewError(structures.CodeConflict, "servidor iniciado sem arquivo de configuração (-config)"))
+	}
+	if err := a.svc.reloadConfig(); err != nil {
+		return a.svc.observe("Admin.ReloadConfig", start, structures.NewError(structures.CodeInvalidArgument, "%v", err))
+	}
+	*reply = structures.ReloadConfigReply{Path: a.svc.configPath}
+	return a.svc.observe("Admin.ReloadConfig", start, nil)
+}
+
+// sessionHandler atende o CONNECT do net/rpc sobre HTTP. O token vem no
+// cabeçalho "Authorization: Bearer <token>"; cada conexão recebe um
+// rpc.Server próprio, com os serviços "RemoteList" e "Admin" vinculados à sessão.
+type sessionHandler struct {
+	svc *RemoteListService
+}
+
+func (h sessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+
+	token, err := bearerToken(r.Header.Get("Authorization"))
+	if err == nil {
+		_, err = h.svc.authorizer.Authenticate(token)
+	}
+	if err != nil {
+		h.svc.auditor.Deny("", r.RemoteAddr, "CONNECT", "", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		logging.Errorf("Erro ao assumir conexão RPC de %s: %v", r.RemoteAddr, err)
+		return
+	}
+	io.WriteString(conn, "HTTP/1.0 200 Connected to Go RPC\n\n")
+
+	session := h.svc.openSession(token, r.RemoteAddr, "rpc")
+	defer session.Close()
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("RemoteList", session); err != nil {
+		logging.Errorf("Erro ao registrar sessão RPC: %v", err)
+		conn.Close()
+		return
+	}
+	if err := server.RegisterName("Admin", &adminSession{svc: h.svc, token: token, remoteAddr: r.RemoteAddr}); err != nil {
+		logging.Errorf("Erro ao registrar serviço Admin: %v", err)
+		conn.Close()
+		return
+	}
+	server.ServeConn(conn)
+}
+
+// bearerToken extrai o token do cabeçalho Authorization. Sem o cabeçalho, o
+// token é vazio (aceito só com a autenticação desativada); com outro esquema
+// que não Bearer, ou sem o token, o cabeçalho é recusado, em vez de o texto
+// ser tratado como token.
+func bearerToken(header string) (string, error) {
+	if header == "" {
+		return "", nil
+	}
+	scheme, token, found := strings.Cut(header, " ")
+	token = strings.TrimSpace(token)
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", structures.NewError(structures.CodeUnauthorized, "cabeçalho Authorization deve ter o formato 'Bearer <token>'")
+	}
+	return token, nil
+}
+
+// snapshotRetention converte a retenção de snapshots da configuração.
+func snapshotRetention(cfg config.SnapshotsConfig) utils.SnapshotRetention {
+	return utils.SnapshotRetention{KeepLast: cfg.KeepLast, KeepHourly: cfg.KeepHourly, KeepDaily: cfg.KeepDaily}
+}
+
+// restoreToTarget recupera o estado no ponto informado por -restore-to e o
+// torna o estado persistido, preservando o log e o snapshot anteriores. Em
+// seguida, a recuperação normal carrega o snapshot recuperado.
+func restoreToTarget(value, snapshotPath string) {
+	target, err := utils.ParseRecoveryTarget(value)
+	if err != nil {
+		log.Fatalf("Erro na flag -restore-to: %v", err)
+	}
+
+	fmt.Printf("Recuperando o estado em %s...\n", target)
+	restored, info, err := utils.RestoreAt(snapshotPath, utils.LogPath(), target)
+	if err != nil {
+		log.Fatalf("Erro na recuperação até %s: %v", target, err)
+	}
+	if info.Snapshot != "" {
+		fmt.Printf("Partindo do snapshot %s.\n", info.Snapshot)
+	} else if info.SnapshotTooNew {
+		fmt.Println("Os snapshots são posteriores ao alvo; aplicando o log desde o início.")
+	}
+	if err := utils.CommitRestore(restored, info.LogLastLSN); err != nil {
+		log.Fatalf("Erro ao salvar o estado recuperado: %v", err)
+	}
+	lists, elements := restored.Stats()
+	fmt.Printf("Estado recuperado até %s: %d alterações do log aplicadas, %d listas, %d elementos (log e snapshot anteriores preservados com o sufixo .pre-restore-*).\n",
+		target, info.Applied, lists, elements)
+}
+
+func main() {
+	respAddr := flag.String("resp-addr", "", "Endereço do listener compatível com Redis/RESP (ex: :6379). Vazio desativa.")
+	serverID := flag.String("server-id", defaultServerID(), "Identificador do servidor informado no Ping.")
+	healthcheck := flag.Bool("healthcheck", false, "Consulta o /readyz do servidor local e sai com código 0 (pronto) ou 1.")
+	configPath := flag.String("config", "", "Arquivo de configuração JSON (TLS etc.). Recarregado ao receber SIGHUP.")
+	logLevel := flag.String("log-level", "info", "Nível de log: debug, info, warn ou error. Pode ser trocado com Admin.SetLogLevel.")
+	restoreTo := flag.String("restore-to", "", "Recupera o estado em um ponto do passado (horário RFC3339 ou lsn:<número>) antes de iniciar.")
+	restoreSnapshot := flag.String("restore-snapshot", "", "Snapshot de partida para -restore-to (padrão: a geração mais nova anterior ao alvo).")
+	recordPath := flag.String("record", "", "Grava as chamadas dos clientes (método, argumentos, resposta, cliente e tempos) neste arquivo, em JSON lines, para replay.go.")
+	flag.Parse()
+
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		log.Fatalf("Erro na flag -log-level: %v", err)
+	}
+	logging.SetLevel(level)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Erro ao carregar configuração: %v", err)
+	}
+
+	var tlsReloader *tlsutil.Reloader
+	if cfg.TLS.Enabled() {
+		tlsReloader, err = tlsutil.NewReloader(cfg.TLS)
+		if err != nil {
+			log.Fatalf("Erro ao configurar TLS: %v", err)
+		}
+	}
+
+	// Modo healthcheck: usado pelo Docker, já que a imagem não tem shell nem curl.
+	if *healthcheck {
+		var probeTLS *tls.Config
+		if tlsReloader != nil {
+			// Consulta local: o certificado do servidor também é apresentado como
+			// certificado de cliente, caso o mTLS seja exigido.
+			probeTLS = &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{tlsReloader.Certificate()}}
 		}
-		fmt.Printf("%d logs relevantes aplicados.\n", len(logEntries))
+		if err := health.Probe("localhost"+serverPort, probeTLS); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	// 3. Prepara e registra o serviço RPC.
-	err = rpc.RegisterName("RemoteList", remoteListService)
+	// 0. Prepara as pastas para logs e snapshots e abre o armazenamento.
+	if cfg.Storage.LogBackend() == config.StorageFile {
+		if err := os.MkdirAll("logs", 0755); err != nil {
+			log.Fatalf("Falha ao criar diretório 'logs': %v", err)
+		}
+	}
+	if cfg.Storage.SnapshotsBackend() == config.StorageFile {
+		if err := os.MkdirAll("snapshots", 0755); err != nil {
+			log.Fatalf("Falha ao criar diretório 'snapshots': %v", err)
+		}
+	}
+	if *restoreTo != "" && (cfg.Storage.SnapshotsBackend() != config.StorageFile || cfg.Storage.LogBackend() != config.StorageFile) {
+		log.Fatalf("A flag -restore-to exige os backends de arquivo para snapshots e log.")
+	}
+	snapshots, logs, err := storage.Open(cfg.Storage)
 	if err != nil {
-		log.Fatalf("Falha ao registrar serviço RPC: %v", err)
+		log.Fatalf("Erro ao abrir o armazenamento: %v", err)
+	}
+	fmt.Printf("Armazenamento: snapshots em %q, log em %q.\n", cfg.Storage.SnapshotsBackend(), cfg.Storage.LogBackend())
+
+	// 1. Começa a escutar por conexões. Os endpoints de saúde respondem desde já,
+	// mas o RPC fica bloqueado (503) até a recuperação terminar.
+	checker := health.NewChecker()
+	remoteListService := &RemoteListService{
+		serverID:      *serverID,
+		checker:       checker,
+		authorizer:    auth.NewAuthorizer(cfg.Auth),
+		auditor:       auth.NewAuditor(cfg.Auth.AuditFile),
+		opsLimiter:    ratelimit.NewKeyed(noLimit),
+		clientLimiter: ratelimit.NewKeyed(noLimit),
+		methodLimiter: ratelimit.NewKeyed(noLimit),
+		concurrency:   ratelimit.NewConcurrency(0, 0),
+		snapshots:     snapshots,
+		logs:          logs,
+		sessions:      make(map[*clientSession]struct{}),
+		configPath:    *configPath,
+	}
+	remoteListService.applyLimits(cfg.Limits)
+	if *recordPath != "" {
+		recorder, err := workload.NewRecorder(*recordPath)
+		if err != nil {
+			log.Fatalf("Erro ao iniciar a gravação: %v", err)
+		}
+		remoteListService.recorder = recorder
+		fmt.Printf("Gravando as chamadas em %s.\n", *recordPath)
 	}
-	rpc.HandleHTTP() // Configura o RPC sobre HTTP.
 
-	// 4. Começa a escutar por conexões.
+	checker.Register(http.DefaultServeMux)
+	http.Handle("/metrics", metrics.Default.Handler())
+	http.Handle(rpc.DefaultRPCPath, checker.Gate(sessionHandler{svc: remoteListService}))
+
 	listener, err := net.Listen("tcp", serverPort)
 	if err != nil {
 		log.Fatalf("Falha ao escutar na porta %s: %v", serverPort, err)
 	}
+	listener = metrics.CountConnections(listener, "rpc")
+	if tlsReloader != nil {
+		listener = tls.NewListener(listener, tlsReloader.ServerConfig())
+		fmt.Println("TLS habilitado no listener RPC.")
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- http.Serve(listener, nil)
+	}()
+	fmt.Printf("Servidor escutando na porta %s (aguardando recuperação)...\n", serverPort)
+
+	// 2. Carrega dados de snapshot e logs para recuperar o estado.
+	recoveryStart := time.Now()
+	utils.SetSnapshotRetention(snapshotRetention(cfg.Snapshots))
+	utils.SetFullSnapshotInterval(cfg.Snapshots.FullEvery)
+	if *restoreTo != "" {
+		restoreToTarget(*restoreTo, *restoreSnapshot)
+	}
+	recovery, err := storage.Recover(snapshots, logs)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	metrics.RecoveryEntriesReplayed.Set(float64(recovery.Replayed))
+
+	// O serviço só é acessado após SetReady, portanto pode ser preenchido aqui.
+	remoteList := recovery.State
+	remoteListService.remoteList = remoteList
+	remoteListService.engine = engine.New(recovery, snapshots, logs)
+	metrics.RecoveryDuration.Set(time.Since(recoveryStart).Seconds())
+
+	// As cotas só valem após a recuperação: operações já aceitas não são recusadas ao reaplicar o log.
+	// (Append confere as cotas antes de registrar no log.)
+	remoteListService.applyNamespaceQuotas(cfg.Namespaces)
+	// O histórico de GetAt também começa após a recuperação.
+	remoteList.SetHistoryRetention(cfg.History.Retention())
+	// As agregações incrementais e os índices de valores são montados uma vez, sobre o estado recuperado.
+	remoteList.SetIncrementalAggregates(cfg.Aggregates.Incremental)
+	remoteList.SetIndexedLists(cfg.Search.Indexed)
+
+	metrics.Lists.SetFunc(func() float64 {
+		lists, _ := remoteList.Stats()
+		return float64(lists)
+	})
+	metrics.Elements.SetFunc(func() float64 {
+		_, elements := remoteList.Stats()
+		return float64(elements)
+	})
+	metrics.RPCInFlight.SetFunc(func() float64 {
+		return float64(remoteListService.concurrency.Active())
+	})
+	metrics.SnapshotAge.SetFunc(func() float64 {
+		if last := snapshots.LastSaved(); !last.IsZero() {
+			return time.Since(last).Seconds()
+		}
+		return 0
+	})
+
+	// 3. Libera o atendimento. Cada conexão registra sua própria sessão RPC.
+	if remoteListService.authorizer.Enabled() {
+		fmt.Println("Autenticação por token habilitada.")
+	}
+	checker.SetReady(true)
 	fmt.Printf("Servidor online na porta %s...\n", serverPort)
 
-	// 5. Inicia salvamento periódico de snapshots em segundo plano.
+	// 4. Inicia salvamento periódico de snapshots em segundo plano.
 	ticker := time.NewTicker(snapshotInterval)
 	defer ticker.Stop()
 
 	go func() {
 		for range ticker.C {
 			fmt.Println("Tentando salvar snapshot...")
-			remoteListService.logTimestampMutex.Lock()
-			currentLastLogTimestamp := remoteListService.lastLoggedOperationTimestamp
-			remoteListService.logTimestampMutex.Unlock()
+			if _, err := remoteListService.snapshot(); err != nil {
+				logging.Errorf("Erro ao salvar snapshot: %v", err)
+			}
+			remoteList.PruneHistory()
+		}
+	}()
+
+	// 5. Inicia o listener RESP opcional, compartilhando o mesmo serviço (logs, locks e snapshots).
+	if *respAddr != "" {
+		respListener, err := net.Listen("tcp", *respAddr)
+		if err != nil {
+			log.Fatalf("Falha ao escutar RESP em %s: %v", *respAddr, err)
+		}
+		respListener = metrics.CountConnections(respListener, "resp")
+		if tlsReloader != nil {
+			respListener = tls.NewListener(respListener, tlsReloader.ServerConfig())
+		}
+		respServer := resp.NewServer(remoteListService)
+		go func() {
+			log.Fatal(respServer.Serve(respListener))
+		}()
+		fmt.Printf("Listener RESP online em %s...\n", *respAddr)
+	}
 
-			err := utils.SaveSnapshot(remoteList, currentLastLogTimestamp)
+	// 6. Recarrega a configuração ao receber SIGHUP ou pelo Admin.ReloadConfig.
+	applyConfig := func(newCfg *config.Config) {
+		remoteListService.authorizer.Update(newCfg.Auth)
+		remoteListService.auditor.SetPath(newCfg.Auth.AuditFile)
+		remoteListService.applyNamespaceQuotas(newCfg.Namespaces)
+		remoteListService.applyLimits(newCfg.Limits)
+		remoteListService.remoteList.SetHistoryRetention(newCfg.History.Retention())
+		remoteListService.remoteList.SetIncrementalAggregates(newCfg.Aggregates.Incremental)
+		remoteListService.remoteList.SetIndexedLists(newCfg.Search.Indexed)
+		utils.SetSnapshotRetention(snapshotRetention(newCfg.Snapshots))
+		utils.SetFullSnapshotInterval(newCfg.Snapshots.FullEvery)
+		if newCfg.Storage != cfg.Storage {
+			logging.Warnf("Trocar o armazenamento exige reiniciar o servidor; mudança de storage ignorada.")
+		}
+
+		switch {
+		case (tlsReloader != nil) != newCfg.TLS.Enabled():
+			logging.Warnf("Ativar ou desativar o TLS exige reiniciar o servidor; mudança de TLS ignorada.")
+		case tlsReloader == nil:
+			// TLS desativado antes e depois: nada a fazer.
+		default:
+			if err := tlsReloader.Update(newCfg.TLS); err != nil {
+				logging.Errorf("Erro ao aplicar nova configuração TLS (mantendo a anterior): %v", err)
+			}
+		}
+	}
+	if *configPath != "" {
+		remoteListService.reloadConfig = func() error {
+			newCfg, err := config.Load(*configPath)
 			if err != nil {
-				log.Printf("Erro ao salvar snapshot: %v", err)
+				return err
+			}
+			applyConfig(newCfg)
+			logging.Infof("Configuração recarregada de %s.", *configPath)
+			return nil
+		}
+		watchConfigReload(remoteListService.reloadConfig)
+	}
+
+	// 7. Servidor atende às requisições.
+	log.Fatal(<-serveErr)
+}
+
+// noLimit é a função de limites de um ratelimit.Keyed sem limite algum.
+func noLimit(string) (rate, burst float64) { return 0, 0 }
+
+// watchConfigReload chama reload a cada SIGHUP. Configurações inválidas são
+// ignoradas, mantendo a anterior.
+func watchConfigReload(reload func() error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			if err := reload(); err != nil {
+				logging.Errorf("Erro ao recarregar configuração (mantendo a anterior): %v", err)
 			}
 		}
 	}()
+}
 
-	// 6. Servidor atende às requisições.
-	log.Fatal(http.Serve(listener, nil))
+// defaultServerID usa o hostname como identidade padrão do servidor.
+func defaultServerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "remote-list-server"
+	}
+	return hostname
 }
\ No newline at end of file
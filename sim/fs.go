@@ -0,0 +1,385 @@
+package sim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"sd-miniprojeto-1/utils"
+)
+
+// ErrCrashed é retornado pelo FS entre uma queda e Restart.
+var ErrCrashed = errors.New("sim: nó caído")
+
+// DiskFaults são as probabilidades de falha de cada operação do FS.
+type DiskFaults struct {
+	Crash    float64 // Queda do nó no meio da operação (escritas: parciais).
+	WriteErr float64 // Escrita parcial com ENOSPC.
+	SyncErr  float64 // fsync com EIO; o que não foi sincronizado se perde.
+	TruncErr float64 // Truncate com EIO, sem efeito.
+}
+
+// FS é um utils.FS em memória, com o conteúdo sincronizado de cada arquivo
+// acompanhado à parte. Numa queda (Crash), cada arquivo volta ao conteúdo do
+// último fsync, mais um prefixo aleatório do que foi acrescentado depois
+// (páginas que o sistema já tinha gravado). Criações, renomeações e remoções
+// são tratadas como duráveis assim que feitas. As falhas de DiskFaults são
+// sorteadas com o gerador da simulação.
+type FS struct {
+	sim     *Simulator
+	files   map[string]*memFile
+	dirs    map[string]bool
+	temps   int
+	faults  DiskFaults
+	down    bool
+	onCrash func()
+}
+
+type memFile struct {
+	data    []byte
+	synced  []byte
+	modTime time.Time
+}
+
+// NewFS cria um FS vazio com os diretórios informados.
+func NewFS(s *Simulator, dirs ...string) *FS {
+	f := &FS{sim: s, files: make(map[string]*memFile), dirs: map[string]bool{".": true}}
+	for _, dir := range dirs {
+		f.dirs[filepath.Clean(dir)] = true
+	}
+	return f
+}
+
+// SetFaults define as probabilidades de falha.
+func (f *FS) SetFaults(faults DiskFaults) {
+	f.faults = faults
+}
+
+// OnCrash registra a função chamada quando uma operação sorteia a queda do
+// nó. Ela deve chamar Crash e derrubar as tarefas do nó (Simulator.Kill),
+// incluindo a atual.
+func (f *FS) OnCrash(fn func()) {
+	f.onCrash = fn
+}
+
+// Crash simula a queda do nó: o que não foi sincronizado se perde, com
+// exceção de um prefixo aleatório, e as operações falham até Restart.
+func (f *FS) Crash() {
+	if f.down {
+		return
+	}
+	f.down = true
+	for _, name := range f.names() {
+		file := f.files[name]
+		if bytes.Equal(file.data, file.synced) {
+			continue
+		}
+		kept := file.synced
+		if len(file.data) > len(file.synced) && bytes.HasPrefix(file.data, file.synced) {
+			extra := f.sim.rng.Intn(len(file.data) - len(file.synced) + 1)
+			kept = file.data[:len(file.synced)+extra]
+		}
+		file.data = append([]byte(nil), kept...)
+		file.synced = append([]byte(nil), kept...)
+		f.sim.Tracef("fs: %s volta a %d bytes", name, len(kept))
+	}
+}
+
+// Restart volta a aceitar operações, após uma queda.
+func (f *FS) Restart() {
+	f.down = false
+}
+
+// names retorna os arquivos em ordem, para que as escolhas aleatórias não
+// dependam da ordem do mapa.
+func (f *FS) names() []string {
+	names := make([]string, 0, len(f.files))
+	for name := range f.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fault sorteia a falha p da operação op sobre name.
+func (f *FS) fault(p float64, what, op, name string) bool {
+	if p <= 0 || f.sim.rng.Float64() >= p {
+		return false
+	}
+	f.sim.Tracef("fs: %s em %s %s", what, op, name)
+	return true
+}
+
+// crashPoint sorteia a queda do nó antes (ou no meio) da operação.
+func (f *FS) crashPoint(op, name string) bool {
+	if f.onCrash == nil || !f.fault(f.faults.Crash, "queda", op, name) {
+		return false
+	}
+	return true
+}
+
+func (f *FS) crash() {
+	f.onCrash()
+	// onCrash derruba a tarefa atual; se não o fizer, a operação falha.
+	panic("sim: OnCrash retornou sem derrubar a tarefa atual")
+}
+
+func pathError(op, name string, err error) error {
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (f *FS) OpenFile(name string, flag int, perm os.FileMode) (utils.File, error) {
+	if f.down {
+		return nil, pathError("open", name, ErrCrashed)
+	}
+	name = filepath.Clean(name)
+	if !f.dirs[filepath.Dir(name)] {
+		return nil, pathError("open", name, fs.ErrNotExist)
+	}
+	file, ok := f.files[name]
+	switch {
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, pathError("open", name, fs.ErrNotExist)
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, pathError("open", name, fs.ErrExist)
+	case !ok:
+		file = &memFile{modTime: f.sim.now}
+		f.files[name] = file
+	}
+	if flag&os.O_TRUNC != 0 {
+		file.data = nil
+		file.modTime = f.sim.now
+	}
+	return &memHandle{fs: f, name: name, file: file, flag: flag}, nil
+}
+
+func (f *FS) CreateTemp(dir, pattern string) (utils.File, error) {
+	f.temps++
+	random := fmt.Sprintf("%09d", f.temps)
+	var base string
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		base = pattern[:i] + random + pattern[i+1:]
+	} else {
+		base = pattern + random
+	}
+	return f.OpenFile(filepath.Join(dir, base), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+}
+
+func (f *FS) Rename(oldpath, newpath string) error {
+	if f.down {
+		return pathError("rename", oldpath, ErrCrashed)
+	}
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	if f.crashPoint("rename", newpath) {
+		f.crash()
+	}
+	file, ok := f.files[oldpath]
+	if !ok {
+		return pathError("rename", oldpath, fs.ErrNotExist)
+	}
+	delete(f.files, oldpath)
+	f.files[newpath] = file
+	return nil
+}
+
+func (f *FS) Remove(name string) error {
+	if f.down {
+		return pathError("remove", name, ErrCrashed)
+	}
+	name = filepath.Clean(name)
+	if f.crashPoint("remove", name) {
+		f.crash()
+	}
+	if _, ok := f.files[name]; !ok {
+		return pathError("remove", name, fs.ErrNotExist)
+	}
+	delete(f.files, name)
+	return nil
+}
+
+func (f *FS) Stat(name string) (os.FileInfo, error) {
+	if f.down {
+		return nil, pathError("stat", name, ErrCrashed)
+	}
+	name = filepath.Clean(name)
+	if file, ok := f.files[name]; ok {
+		return fileInfo{name: filepath.Base(name), size: int64(len(file.data)), modTime: file.modTime}, nil
+	}
+	if f.dirs[name] {
+		return fileInfo{name: filepath.Base(name), dir: true, modTime: Epoch}, nil
+	}
+	return nil, pathError("stat", name, fs.ErrNotExist)
+}
+
+func (f *FS) ReadDir(name string) ([]os.DirEntry, error) {
+	if f.down {
+		return nil, pathError("readdir", name, ErrCrashed)
+	}
+	name = filepath.Clean(name)
+	if !f.dirs[name] {
+		return nil, pathError("readdir", name, fs.ErrNotExist)
+	}
+	var entries []os.DirEntry
+	for _, path := range f.names() {
+		if filepath.Dir(path) == name {
+			file := f.files[path]
+			entries = append(entries, fs.FileInfoToDirEntry(fileInfo{name: filepath.Base(path), size: int64(len(file.data)), modTime: file.modTime}))
+		}
+	}
+	return entries, nil
+}
+
+func (f *FS) SyncDir(name string) error {
+	if f.down {
+		return pathError("sync", name, ErrCrashed)
+	}
+	if f.crashPoint("syncdir", name) {
+		f.crash()
+	}
+	return nil
+}
+
+// memHandle é um arquivo aberto no FS.
+type memHandle struct {
+	fs     *FS
+	name   string
+	file   *memFile
+	flag   int
+	offset int64
+}
+
+func (h *memHandle) Name() string { return h.name }
+func (h *memHandle) Close() error { return nil }
+
+func (h *memHandle) Stat() (os.FileInfo, error) {
+	if h.fs.down {
+		return nil, pathError("stat", h.name, ErrCrashed)
+	}
+	return fileInfo{name: filepath.Base(h.name), size: int64(len(h.file.data)), modTime: h.file.modTime}, nil
+}
+
+func (h *memHandle) Read(p []byte) (int, error) {
+	n, err := h.ReadAt(p, h.offset)
+	h.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (h *memHandle) ReadAt(p []byte, off int64) (int, error) {
+	if h.fs.down {
+		return 0, pathError("read", h.name, ErrCrashed)
+	}
+	if off >= int64(len(h.file.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, h.file.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (h *memHandle) Write(p []byte) (int, error) {
+	f := h.fs
+	if f.down {
+		return 0, pathError("write", h.name, ErrCrashed)
+	}
+	if h.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, pathError("write", h.name, fs.ErrPermission)
+	}
+	var injected error
+	switch {
+	case f.crashPoint("write", h.name):
+		// Escrita interrompida pela queda: só um prefixo chega ao arquivo.
+		h.write(p[:f.sim.rng.Intn(len(p)+1)])
+		f.crash()
+	case f.fault(f.faults.WriteErr, "ENOSPC", "write", h.name):
+		p = p[:f.sim.rng.Intn(len(p)+1)]
+		injected = pathError("write", h.name, syscall.ENOSPC)
+	}
+	h.write(p)
+	return len(p), injected
+}
+
+func (h *memHandle) write(p []byte) {
+	file := h.file
+	if h.flag&os.O_APPEND != 0 {
+		h.offset = int64(len(file.data))
+	}
+	if end := h.offset + int64(len(p)); end > int64(len(file.data)) {
+		file.data = append(file.data, make([]byte, end-int64(len(file.data)))...)
+	}
+	copy(file.data[h.offset:], p)
+	h.offset += int64(len(p))
+	file.modTime = h.fs.sim.now
+}
+
+func (h *memHandle) Sync() error {
+	f := h.fs
+	if f.down {
+		return pathError("sync", h.name, ErrCrashed)
+	}
+	if f.crashPoint("sync", h.name) {
+		f.crash()
+	}
+	if f.fault(f.faults.SyncErr, "EIO", "sync", h.name) {
+		// Como no Linux após um fsync com erro: as páginas não gravadas são
+		// descartadas, e o conteúdo volta ao último fsync bem-sucedido.
+		h.file.data = append([]byte(nil), h.file.synced...)
+		return pathError("sync", h.name, syscall.EIO)
+	}
+	h.file.synced = append([]byte(nil), h.file.data...)
+	return nil
+}
+
+func (h *memHandle) Truncate(size int64) error {
+	f := h.fs
+	if f.down {
+		return pathError("truncate", h.name, ErrCrashed)
+	}
+	if f.crashPoint("truncate", h.name) {
+		f.crash()
+	}
+	if f.fault(f.faults.TruncErr, "EIO", "truncate", h.name) {
+		return pathError("truncate", h.name, syscall.EIO)
+	}
+	file := h.file
+	if size < int64(len(file.data)) {
+		file.data = file.data[:size:size]
+	} else {
+		file.data = append(file.data, make([]byte, size-int64(len(file.data)))...)
+	}
+	file.modTime = f.sim.now
+	return nil
+}
+
+// fileInfo implementa os.FileInfo para o FS.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+func (i fileInfo) IsDir() bool        { return i.dir }
+func (i fileInfo) Sys() any           { return nil }
+
+func (i fileInfo) Mode() os.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
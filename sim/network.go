@@ -0,0 +1,79 @@
+package sim
+
+import "time"
+
+// Network entrega mensagens entre nós com latência aleatória, perdas e
+// partições, sorteadas com o gerador da simulação.
+type Network struct {
+	sim        *Simulator
+	minLatency time.Duration
+	maxLatency time.Duration
+	dropRate   float64
+	cut        map[[2]string]bool
+}
+
+// NewNetwork cria uma rede com latência uniforme entre min e max e a taxa de
+// perda de mensagens informada.
+func NewNetwork(s *Simulator, min, max time.Duration, dropRate float64) *Network {
+	return &Network{sim: s, minLatency: min, maxLatency: max, dropRate: dropRate, cut: make(map[[2]string]bool)}
+}
+
+func link(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// Partition corta a ligação entre a e b, nos dois sentidos. Mensagens em
+// trânsito também se perdem.
+func (n *Network) Partition(a, b string) {
+	n.cut[link(a, b)] = true
+	n.sim.Tracef("rede: partição %s | %s", a, b)
+}
+
+// Heal restaura a ligação entre a e b.
+func (n *Network) Heal(a, b string) {
+	if n.cut[link(a, b)] {
+		delete(n.cut, link(a, b))
+		n.sim.Tracef("rede: %s e %s religados", a, b)
+	}
+}
+
+// HealAll restaura todas as ligações.
+func (n *Network) HealAll() {
+	for l := range n.cut {
+		delete(n.cut, l)
+	}
+	n.sim.Tracef("rede: todas as ligações restauradas")
+}
+
+// SetDropRate muda a taxa de perda de mensagens.
+func (n *Network) SetDropRate(rate float64) {
+	n.dropRate = rate
+}
+
+// Send envia uma mensagem de from para to: deliver é chamada (fora de
+// tarefas, como em Simulator.After) na chegada, a menos que a mensagem se
+// perca ou a ligação esteja cortada no envio ou na chegada.
+func (n *Network) Send(from, to, what string, deliver func()) {
+	if n.cut[link(from, to)] {
+		n.sim.Tracef("rede: %s -> %s %s perdida (partição)", from, to, what)
+		return
+	}
+	if n.dropRate > 0 && n.sim.rng.Float64() < n.dropRate {
+		n.sim.Tracef("rede: %s -> %s %s perdida", from, to, what)
+		return
+	}
+	latency := n.minLatency
+	if n.maxLatency > n.minLatency {
+		latency += time.Duration(n.sim.rng.Int63n(int64(n.maxLatency - n.minLatency)))
+	}
+	n.sim.After(latency, func() {
+		if n.cut[link(from, to)] {
+			n.sim.Tracef("rede: %s -> %s %s perdida (partição)", from, to, what)
+			return
+		}
+		deliver()
+	})
+}
@@ -0,0 +1,430 @@
+// Package sim executa código do servidor em uma simulação determinística: o
+// relógio, a rede, o disco e o escalonamento são controlados por um gerador
+// aleatório com semente, e a mesma semente reproduz exatamente a mesma
+// execução.
+//
+// O código simulado roda em tarefas (Task), goroutines das quais só uma
+// executa de cada vez. Uma tarefa roda até ceder a vez (Yield, Sleep ou
+// Event.Wait); então o Simulator escolhe, pela semente, a próxima entre as
+// prontas. Os pontos em que as tarefas cedem a vez são os pontos de
+// escalonamento: no servidor, os de engine.SetYield. Uma tarefa não pode
+// bloquear de outra forma (em um mutex disputado, por exemplo), pois nenhuma
+// outra rodaria para liberá-lo.
+//
+// O tempo é virtual: avança um pouco a cada passo e salta para o próximo
+// timer quando nenhuma tarefa está pronta. O disco (FS) e a rede (Network)
+// simulados usam o mesmo gerador para latências e falhas.
+package sim
+
+import (
+	"container/heap"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"runtime/debug"
+	"time"
+)
+
+// Epoch é o horário em que toda simulação começa.
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// killed é o valor do panic que encerra uma tarefa derrubada por Kill.
+type killed struct{}
+
+// Simulator executa as tarefas de uma simulação.
+type Simulator struct {
+	rng      *rand.Rand
+	now      time.Time
+	runnable []*Task
+	dying    []*Task // Derrubadas por Kill, ainda não encerradas.
+	timers   timerHeap
+	seq      uint64
+	current  *Task
+	control  chan struct{} // A tarefa em execução devolve o controle por aqui.
+	tasks    []*Task       // Em ordem de criação; as encerradas são retiradas em compact.
+	failure  error
+	steps    int
+	digest   hash.Hash64
+	trace    io.Writer
+}
+
+// New cria um Simulator com a semente informada.
+func New(seed int64) *Simulator {
+	return &Simulator{
+		rng:     rand.New(rand.NewSource(seed)),
+		now:     Epoch,
+		control: make(chan struct{}),
+		digest:  fnv.New64a(),
+	}
+}
+
+// SetTrace faz os eventos da simulação serem escritos em w, um por linha.
+func (s *Simulator) SetTrace(w io.Writer) {
+	s.trace = w
+}
+
+// Tracef registra um evento. Todos os eventos entram no resumo (Digest);
+// duas execuções com a mesma semente devem ter o mesmo resumo.
+func (s *Simulator) Tracef(format string, args ...any) {
+	line := fmt.Sprintf("%12s %s", s.Elapsed(), fmt.Sprintf(format, args...))
+	s.digest.Write([]byte(line))
+	s.digest.Write([]byte{'\n'})
+	if s.trace != nil {
+		fmt.Fprintln(s.trace, line)
+	}
+}
+
+// Digest retorna o resumo dos eventos registrados até agora.
+func (s *Simulator) Digest() uint64 {
+	return s.digest.Sum64()
+}
+
+// Steps retorna quantos passos (execuções de tarefas) a simulação já fez.
+func (s *Simulator) Steps() int {
+	return s.steps
+}
+
+// Rand retorna o gerador da simulação. Só deve ser usado pelas tarefas e
+// pelas funções de After, nunca por outras goroutines.
+func (s *Simulator) Rand() *rand.Rand {
+	return s.rng
+}
+
+// Now retorna o horário simulado. Cada leitura avança o relógio em 1ns: como
+// um relógio real de alta resolução, duas leituras nunca são iguais.
+func (s *Simulator) Now() time.Time {
+	s.now = s.now.Add(time.Nanosecond)
+	return s.now
+}
+
+// Elapsed retorna o tempo simulado desde o início.
+func (s *Simulator) Elapsed() time.Duration {
+	return s.now.Sub(Epoch)
+}
+
+// Task é uma tarefa da simulação.
+type Task struct {
+	sim    *Simulator
+	name   string
+	group  string
+	resume chan struct{}
+	killed bool
+	done   bool
+	timer  *timer // Timer que acordará a tarefa, se estiver dormindo.
+}
+
+// Name retorna o nome da tarefa.
+func (t *Task) Name() string { return t.name }
+
+// Go cria uma tarefa no grupo informado, pronta para executar fn. Os grupos
+// permitem derrubar várias tarefas de uma vez (Kill), como os processos de um
+// nó que cai.
+func (s *Simulator) Go(group, name string, fn func()) *Task {
+	t := &Task{sim: s, name: name, group: group, resume: make(chan struct{})}
+	s.compact()
+	s.tasks = append(s.tasks, t)
+	go func() {
+		<-t.resume
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(killed); !ok && s.failure == nil {
+					s.failure = fmt.Errorf("panic na tarefa %s: %v\n%s", t.name, r, debug.Stack())
+				}
+			}
+			t.done = true
+			s.control <- struct{}{}
+		}()
+		if t.killed {
+			panic(killed{})
+		}
+		fn()
+	}()
+	s.runnable = append(s.runnable, t)
+	return t
+}
+
+// Current retorna a tarefa em execução (nil fora de tarefas).
+func (s *Simulator) Current() *Task {
+	return s.current
+}
+
+// switchOut devolve o controle ao Simulator e espera a tarefa ser retomada.
+func (s *Simulator) switchOut() {
+	t := s.current
+	s.control <- struct{}{}
+	<-t.resume
+	if t.killed {
+		panic(killed{})
+	}
+}
+
+// Yield cede a vez: a tarefa atual continua pronta, e o Simulator escolhe a
+// próxima a executar.
+func (s *Simulator) Yield() {
+	t := s.mustCurrent("Yield")
+	s.runnable = append(s.runnable, t)
+	s.switchOut()
+}
+
+// Sleep suspende a tarefa atual por d de tempo simulado.
+func (s *Simulator) Sleep(d time.Duration) {
+	t := s.mustCurrent("Sleep")
+	t.timer = s.schedule(d, t, nil)
+	s.switchOut()
+}
+
+func (s *Simulator) mustCurrent(op string) *Task {
+	if s.current == nil {
+		panic("sim: " + op + " chamado fora de uma tarefa")
+	}
+	return s.current
+}
+
+// After executa fn após d de tempo simulado, fora de qualquer tarefa: fn
+// não pode ceder a vez, mas pode criar tarefas e disparar eventos.
+func (s *Simulator) After(d time.Duration, fn func()) {
+	s.schedule(d, nil, fn)
+}
+
+// Kill derruba as tarefas do grupo. Se a tarefa atual for do grupo, Kill não
+// retorna: ela é encerrada na hora.
+func (s *Simulator) Kill(group string) {
+	var self bool
+	for _, t := range s.tasks {
+		if t.done || t.group != group || t.killed {
+			continue
+		}
+		t.killed = true
+		if t == s.current {
+			self = true
+			continue
+		}
+		if t.timer != nil {
+			t.timer.cancelled = true
+			t.timer = nil
+		}
+		s.dying = append(s.dying, t)
+	}
+	// Retira as derrubadas da fila de prontas, mantendo a ordem das demais.
+	kept := s.runnable[:0]
+	for _, t := range s.runnable {
+		if !t.killed {
+			kept = append(kept, t)
+		}
+	}
+	s.runnable = kept
+	s.Tracef("kill %s", group)
+	if self {
+		panic(killed{})
+	}
+}
+
+// Run executa a simulação até nenhuma tarefa estar pronta nem haver timers,
+// ou até o tempo simulado passar de limit (se positivo). Retorna erro se uma
+// tarefa entrar em panic (exceto ao ser derrubada).
+func (s *Simulator) Run(limit time.Duration) error {
+	for s.failure == nil {
+		s.reap()
+		for len(s.timers) > 0 && !s.timers[0].at.After(s.now) {
+			s.fire(heap.Pop(&s.timers).(*timer))
+		}
+		if len(s.runnable) == 0 {
+			for len(s.timers) > 0 && s.timers[0].cancelled {
+				heap.Pop(&s.timers)
+			}
+			if len(s.timers) == 0 {
+				break
+			}
+			s.now = s.timers[0].at
+			continue
+		}
+		if limit > 0 && s.Elapsed() > limit {
+			return fmt.Errorf("tempo simulado esgotado (%s) com %d tarefas prontas", limit, len(s.runnable))
+		}
+
+		i := s.rng.Intn(len(s.runnable))
+		t := s.runnable[i]
+		s.runnable = append(s.runnable[:i], s.runnable[i+1:]...)
+		// Cada passo consome um pouco de tempo, como o processamento real.
+		s.now = s.now.Add(time.Duration(1+s.rng.Intn(20)) * time.Microsecond)
+		s.steps++
+		s.run(t)
+	}
+	s.reap()
+	return s.failure
+}
+
+// run executa a tarefa até ela devolver o controle.
+func (s *Simulator) run(t *Task) {
+	s.current = t
+	t.resume <- struct{}{}
+	<-s.control
+	s.current = nil
+}
+
+// reap encerra as tarefas derrubadas, retomando-as para que desfaçam a pilha.
+func (s *Simulator) reap() {
+	for len(s.dying) > 0 {
+		t := s.dying[0]
+		s.dying = s.dying[1:]
+		if !t.done {
+			s.run(t)
+		}
+	}
+}
+
+// Blocked retorna os nomes das tarefas não encerradas (suspensas à espera
+// de um evento que não aconteceu, quando Run termina).
+func (s *Simulator) Blocked() []string {
+	var names []string
+	for _, t := range s.tasks {
+		if !t.done {
+			names = append(names, t.name)
+		}
+	}
+	return names
+}
+
+// compact retira as tarefas encerradas da lista de tarefas.
+func (s *Simulator) compact() {
+	live := s.tasks[:0]
+	for _, t := range s.tasks {
+		if !t.done {
+			live = append(live, t)
+		}
+	}
+	s.tasks = live
+}
+
+// Close encerra todas as tarefas restantes, liberando suas goroutines.
+func (s *Simulator) Close() {
+	for _, t := range s.tasks {
+		if !t.done && !t.killed {
+			t.killed = true
+			if t.timer != nil {
+				t.timer.cancelled = true
+			}
+			s.dying = append(s.dying, t)
+		}
+	}
+	s.runnable = nil
+	s.reap()
+}
+
+// --- Timers ---
+
+type timer struct {
+	at        time.Time
+	seq       uint64
+	task      *Task  // Tarefa a acordar...
+	fn        func() // ...ou função a executar.
+	cancelled bool
+	index     int
+}
+
+func (s *Simulator) schedule(d time.Duration, t *Task, fn func()) *timer {
+	s.seq++
+	tm := &timer{at: s.now.Add(d), seq: s.seq, task: t, fn: fn}
+	heap.Push(&s.timers, tm)
+	return tm
+}
+
+func (s *Simulator) fire(tm *timer) {
+	if tm.cancelled {
+		return
+	}
+	if tm.task != nil {
+		tm.task.timer = nil
+		s.runnable = append(s.runnable, tm.task)
+		return
+	}
+	tm.fn()
+}
+
+type timerHeap []*timer
+
+func (h timerHeap) Len() int { return len(h) }
+func (h timerHeap) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return h[i].seq < h[j].seq
+}
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *timerHeap) Push(x any) {
+	tm := x.(*timer)
+	tm.index = len(*h)
+	*h = append(*h, tm)
+}
+func (h *timerHeap) Pop() any {
+	old := *h
+	tm := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return tm
+}
+
+// --- Eventos ---
+
+// Event é um acontecimento único (uma resposta que chega, por exemplo) pelo
+// qual tarefas podem esperar.
+type Event struct {
+	sim     *Simulator
+	fired   bool
+	value   any
+	waiters []*Task
+}
+
+// NewEvent cria um evento ainda não disparado.
+func (s *Simulator) NewEvent() *Event {
+	return &Event{sim: s}
+}
+
+// Fire dispara o evento com o valor informado, acordando quem espera por
+// ele. Disparos seguintes são ignorados.
+func (e *Event) Fire(value any) {
+	if e.fired {
+		return
+	}
+	e.fired, e.value = true, value
+	for _, t := range e.waiters {
+		if t.killed || t.done {
+			continue
+		}
+		if t.timer != nil {
+			t.timer.cancelled = true
+			t.timer = nil
+		}
+		e.sim.runnable = append(e.sim.runnable, t)
+	}
+	e.waiters = nil
+}
+
+// Wait suspende a tarefa atual até o evento ser disparado ou, se timeout for
+// positivo, até o tempo acabar. Retorna o valor e se o evento foi disparado.
+func (e *Event) Wait(timeout time.Duration) (any, bool) {
+	s := e.sim
+	t := s.mustCurrent("Wait")
+	if e.fired {
+		return e.value, true
+	}
+	e.waiters = append(e.waiters, t)
+	if timeout > 0 {
+		t.timer = s.schedule(timeout, t, nil)
+	}
+	s.switchOut()
+	if !e.fired {
+		for i, w := range e.waiters {
+			if w == t {
+				e.waiters = append(e.waiters[:i], e.waiters[i+1:]...)
+				break
+			}
+		}
+		return nil, false
+	}
+	return e.value, true
+}
@@ -0,0 +1,529 @@
+package sim_test
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"sd-miniprojeto-1/engine"
+	"sd-miniprojeto-1/lincheck"
+	"sd-miniprojeto-1/sim"
+	"sd-miniprojeto-1/storage"
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/utils"
+)
+
+// Os cenários executam o servidor em simulações determinísticas: o relógio,
+// a rede, o disco e o escalonamento das chamadas concorrentes são sorteados a
+// partir de uma semente, e a mesma semente reproduz exatamente a mesma
+// execução. Cada cenário tem clientes concorrentes, quedas do servidor
+// (kill -9 e no meio de operações de disco) seguidas de recuperação,
+// partições e perdas na rede, falhas de disco e snapshots e compactações
+// periódicos; o histórico das respostas é verificado com o modelo de listas
+// do pacote lincheck.
+var (
+	simSeeds       = flag.Int("sim.seeds", 0, "Cenários de TestScenarios (0: 1000, ou 100 com -short); o cenário i usa a semente seed+i.")
+	simSeed        = flag.Int64("sim.seed", 1, "Semente do primeiro cenário. Com -sim.seeds 1, reproduz um cenário.")
+	simClients     = flag.Int("sim.clients", 3, "Clientes concorrentes.")
+	simOps         = flag.Int("sim.ops", 30, "Operações por cliente.")
+	simLists       = flag.Int("sim.lists", 2, "Listas usadas.")
+	simChaos       = flag.String("sim.chaos", "crash,disk,partition,drop", "Falhas injetadas: crash (quedas), disk (erros e quedas em operações de disco), partition e drop (rede).")
+	simDeterminism = flag.Bool("sim.determinism", false, "Executa cada cenário duas vezes e confere se os eventos são idênticos.")
+	simTrace       = flag.Bool("sim.trace", false, "Exibe os eventos de cada cenário.")
+)
+
+// Tempos simulados do cenário.
+const (
+	callTimeout  = 50 * time.Millisecond  // Espera do cliente por uma resposta.
+	timeLimit    = 60 * time.Second       // Limite de tempo simulado de um cenário.
+	checkTimeout = 10 * time.Second       // Limite da verificação de linearizabilidade.
+	verifyEvery  = 500 * time.Microsecond // Intervalo da espera pela recuperação no fim.
+)
+
+// chaos são as falhas habilitadas em -sim.chaos.
+type chaos struct {
+	crash, disk, partition, drop bool
+}
+
+func parseChaos(value string) (chaos, error) {
+	var c chaos
+	for _, name := range strings.Split(value, ",") {
+		switch strings.TrimSpace(name) {
+		case "crash":
+			c.crash = true
+		case "disk":
+			c.disk = true
+		case "partition":
+			c.partition = true
+		case "drop":
+			c.drop = true
+		case "", "none":
+		default:
+			return c, fmt.Errorf("falha desconhecida %q (use crash, disk, partition, drop ou none)", name)
+		}
+	}
+	return c, nil
+}
+
+// scenario são os parâmetros comuns a todos os cenários.
+type scenario struct {
+	clients, ops, lists int
+	chaos               chaos
+}
+
+func scenarioFromFlags(t *testing.T) scenario {
+	t.Helper()
+	enabled, err := parseChaos(*simChaos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scenario{clients: *simClients, ops: *simOps, lists: *simLists, chaos: enabled}
+}
+
+// setup silencia os prints de utils e storage (recuperação, snapshots) e os
+// logs, que não interessam aqui, e configura os snapshots como nos cenários.
+// Retorna a saída original, para os eventos de -sim.trace.
+func setup(t *testing.T) io.Writer {
+	t.Helper()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+		log.SetOutput(os.Stderr)
+	})
+	utils.SetSnapshotRetention(utils.SnapshotRetention{KeepLast: 2})
+	utils.SetFullSnapshotInterval(3)
+	return stdout
+}
+
+func TestScenarios(t *testing.T) {
+	sc := scenarioFromFlags(t)
+	stdout := setup(t)
+	seeds := *simSeeds
+	if seeds == 0 {
+		seeds = 1000
+		if testing.Short() {
+			seeds = 100
+		}
+	}
+
+	var totals result
+	for i := 0; i < seeds; i++ {
+		seed := *simSeed + int64(i)
+		var trace io.Writer
+		if *simTrace {
+			trace = stdout
+			fmt.Fprintf(stdout, "--- cenário %d\n", seed)
+		}
+		r := sc.run(seed, trace)
+		totals.add(r)
+
+		if r.err == nil && *simDeterminism {
+			if again := sc.run(seed, nil); again.digest != r.digest {
+				r.err = fmt.Errorf("execução não determinística: resumo %016x e depois %016x", r.digest, again.digest)
+			}
+		}
+		if r.err == nil {
+			continue
+		}
+
+		reproduced := fmt.Sprintf("reproduzida (resumo dos eventos %016x)", r.digest)
+		if again := sc.run(seed, nil); again.digest != r.digest {
+			reproduced = fmt.Sprintf("ATENÇÃO: a segunda execução divergiu (resumo %016x e %016x)", r.digest, again.digest)
+		}
+		t.Fatalf("seed=%d (%s): %v\n%s\nreproduza com: go test ./sim -run TestScenarios -sim.seeds 1 -sim.seed %d -sim.chaos %s -sim.clients %d -sim.ops %d -sim.lists %d -sim.trace -v",
+			seed, r.summary(), r.err, reproduced, seed, *simChaos, sc.clients, sc.ops, sc.lists)
+	}
+	t.Logf("%d cenários: %s", seeds, totals.summary())
+}
+
+// TestDeterminism confere que a mesma semente gera os mesmos eventos, com
+// todas as falhas habilitadas.
+func TestDeterminism(t *testing.T) {
+	sc := scenario{clients: 3, ops: 30, lists: 2, chaos: chaos{crash: true, disk: true, partition: true, drop: true}}
+	setup(t)
+	for seed := int64(1); seed <= 10; seed++ {
+		first, second := sc.run(seed, nil), sc.run(seed, nil)
+		if first.digest != second.digest || first.steps != second.steps {
+			t.Fatalf("seed=%d: resumo %016x (%d passos) e depois %016x (%d passos)", seed, first.digest, first.steps, second.digest, second.steps)
+		}
+	}
+}
+
+// result é o resultado de um cenário.
+type result struct {
+	err        error
+	digest     uint64
+	operations int // Operações no histórico verificado.
+	crashes    int
+	partitions int
+	steps      int
+	elapsed    time.Duration // Tempo simulado.
+}
+
+func (r result) summary() string {
+	return fmt.Sprintf("%d operações, %d quedas, %d partições, %d passos, %s simulados",
+		r.operations, r.crashes, r.partitions, r.steps, r.elapsed.Round(time.Millisecond))
+}
+
+func (r *result) add(o result) {
+	r.operations += o.operations
+	r.crashes += o.crashes
+	r.partitions += o.partitions
+	r.steps += o.steps
+	r.elapsed += o.elapsed
+}
+
+// --- Servidor simulado ---
+
+const serverName = "servidor"
+
+// node é o servidor simulado: a recuperação, as chamadas e os snapshots
+// periódicos rodam como tarefas do grupo do servidor, derrubadas juntas numa
+// queda.
+type node struct {
+	s           *sim.Simulator
+	fs          *sim.FS
+	net         *sim.Network
+	engine      *engine.Engine // nil com o servidor caído ou em recuperação.
+	down        bool           // Caído, à espera do reinício.
+	stopped     bool           // Fim do cenário: não reinicia nem salva snapshots.
+	incarnation int
+	crashes     int
+}
+
+// start inicia o servidor: recupera o estado como em server.go e depois salva
+// snapshots (e, às vezes, compacta o log) periodicamente.
+func (n *node) start() {
+	if n.stopped {
+		return
+	}
+	n.down = false
+	n.incarnation++
+	n.s.Go(serverName, fmt.Sprintf("servidor-%d", n.incarnation), func() {
+		n.fs.Restart()
+		utils.Reset()
+		recovery, err := storage.Recover(storage.FileSnapshotter{}, storage.FileLogStore{})
+		if err != nil {
+			// O servidor real termina com erro (log.Fatalf) e é reiniciado.
+			n.s.Tracef("servidor: recuperação falhou: %v", err)
+			n.crash("recuperação falhou")
+		}
+		e := engine.New(recovery, storage.FileSnapshotter{}, storage.FileLogStore{})
+		e.SetYield(func(string) { n.s.Yield() })
+		n.engine = e
+		n.s.Tracef("servidor: online (%d entradas do log reaplicadas)", recovery.Replayed)
+
+		rng := n.s.Rand()
+		for !n.stopped {
+			n.s.Sleep(time.Duration(2+rng.Intn(20)) * time.Millisecond)
+			if n.stopped {
+				return
+			}
+			if rng.Intn(4) == 0 {
+				reply, err := e.CompactLog()
+				n.s.Tracef("servidor: compactação (%d entradas descartadas, erro: %v)", reply.EntriesFreed, err)
+			} else {
+				reply, err := e.Snapshot()
+				n.s.Tracef("servidor: snapshot LSN %d (incremental: %v, dispensado: %v, erro: %v)", reply.LastLSN, reply.Delta, reply.Skipped, err)
+			}
+		}
+	})
+}
+
+// crash derruba o servidor: o que não foi sincronizado no disco se perde e
+// todas as tarefas do servidor param. O reinício é agendado. Se chamado por
+// uma tarefa do servidor, não retorna.
+func (n *node) crash(reason string) {
+	if n.down {
+		return
+	}
+	n.s.Tracef("servidor: QUEDA (%s)", reason)
+	n.engine, n.down = nil, true
+	n.crashes++
+	n.fs.Crash()
+	n.s.After(time.Duration(1+n.s.Rand().Intn(10))*time.Millisecond, n.start)
+	n.s.Kill(serverName)
+}
+
+// response é a resposta de uma chamada.
+type response struct {
+	refused bool // Conexão recusada: a chamada não foi executada.
+	out     lincheck.ListOutput
+	err     error
+}
+
+// rpcCall acompanha uma chamada do lado do servidor. A simulação vê os dois
+// lados: uma chamada sem resposta para o cliente só tem resultado
+// desconhecido se foi interrompida por uma queda; se a requisição se perdeu,
+// ela não aconteceu, e se a resposta se perdeu, o servidor sabe qual foi.
+type rpcCall struct {
+	in      lincheck.ListInput
+	reply   *sim.Event
+	arrived bool     // A requisição chegou a um servidor no ar.
+	done    bool     // O servidor terminou de executá-la.
+	doneAt  int64    // Instante do fim da execução.
+	resp    response // Resposta do servidor, se done.
+}
+
+// handle atende a uma chamada que chegou ao servidor.
+func (n *node) handle(client string, c *rpcCall) {
+	e := n.engine
+	if e == nil {
+		// Caído ou em recuperação: a conexão é recusada.
+		n.net.Send(serverName, client, "recusa", func() { c.reply.Fire(response{refused: true}) })
+		return
+	}
+	c.arrived = true
+	n.s.Go(serverName, "rpc "+client, func() {
+		out, err := execute(e, c.in)
+		c.done, c.doneAt, c.resp = true, int64(n.s.Elapsed()), response{out: out, err: err}
+		n.net.Send(serverName, client, "resposta", func() { c.reply.Fire(c.resp) })
+	})
+}
+
+// execute executa a operação no engine.
+func execute(e *engine.Engine, in lincheck.ListInput) (lincheck.ListOutput, error) {
+	var out lincheck.ListOutput
+	var err error
+	switch in.Op {
+	case "Append":
+		err = e.Append(structures.AppendArgs{ListID: in.List, Value: in.Value}, new(bool))
+	case "Get":
+		err = e.Get(structures.GetArgs{ListID: in.List, Index: in.Index}, &out.Value)
+	case "Remove":
+		err = e.Remove(structures.RemoveArgs{ListID: in.List}, &out.Value)
+	case "Size":
+		err = e.Size(structures.SizeArgs{ListID: in.List}, &out.Value)
+	case "Range":
+		err = e.Range(structures.RangeArgs{ListID: in.List, Start: in.Start, Stop: in.Stop}, &out.Values)
+		if len(out.Values) == 0 {
+			out.Values = nil
+		}
+	case "Delete":
+		err = e.Delete(structures.DeleteArgs{ListID: in.List}, new(bool))
+	}
+	return out, err
+}
+
+// --- Cenário ---
+
+// run executa o cenário da semente e verifica o histórico.
+func (sc scenario) run(seed int64, trace io.Writer) result {
+	enabled := sc.chaos
+	s := sim.New(seed)
+	if trace != nil {
+		s.SetTrace(trace)
+	}
+	defer s.Close()
+	rng := s.Rand()
+
+	fsys := sim.NewFS(s, "logs", "snapshots")
+	utils.SetFS(fsys)
+	utils.SetClock(s.Now)
+	defer utils.SetFS(utils.OSFS{})
+	defer utils.SetClock(time.Now)
+
+	// A intensidade das falhas também varia com a semente.
+	dropRate := 0.0
+	if enabled.drop {
+		dropRate = 0.05 * rng.Float64()
+	}
+	network := sim.NewNetwork(s, 50*time.Microsecond, time.Duration(100+rng.Intn(2000))*time.Microsecond, dropRate)
+	if enabled.disk {
+		fsys.SetFaults(sim.DiskFaults{
+			Crash:    0.01 * rng.Float64(),
+			WriteErr: 0.02 * rng.Float64(),
+			SyncErr:  0.02 * rng.Float64(),
+			TruncErr: 0.1 * rng.Float64(),
+		})
+	}
+
+	n := &node{s: s, fs: fsys, net: network}
+	fsys.OnCrash(func() { n.crash("falha no disco") })
+	n.start()
+
+	var r result
+	var history []lincheck.Operation
+	record := func(client int, call, ret int64, in lincheck.ListInput, out lincheck.ListOutput, pending bool) {
+		op := lincheck.Operation{Client: client, Call: call, Return: ret, Input: in, Output: out}
+		if pending {
+			op.Return, op.Output = lincheck.Pending, nil
+		}
+		history = append(history, op)
+	}
+
+	running := sc.clients
+	for c := 0; c < sc.clients; c++ {
+		clientID := c
+		name := fmt.Sprintf("cliente-%d", clientID)
+		s.Go(name, name, func() {
+			defer func() { running-- }()
+			for seq := 0; seq < sc.ops; seq++ {
+				in := lincheck.ListInput{List: fmt.Sprintf("l%d", rng.Intn(sc.lists))}
+				switch p := rng.Intn(100); {
+				case p < 40:
+					in.Op, in.Value = "Append", clientID*1_000_000+seq // Valores únicos.
+				case p < 60:
+					in.Op = "Remove"
+				case p < 75:
+					in.Op, in.Index = "Get", rng.Intn(4)-1
+				case p < 85:
+					in.Op = "Size"
+				case p < 95:
+					in.Op, in.Start, in.Stop = "Range", rng.Intn(3), rng.Intn(5)-1
+				default:
+					in.Op = "Delete"
+				}
+
+				c := &rpcCall{in: in, reply: s.NewEvent()}
+				call := int64(s.Elapsed())
+				network.Send(name, serverName, in.Op, func() { n.handle(name, c) })
+				value, ok := c.reply.Wait(callTimeout)
+				description := describe(in)
+				switch resp, _ := value.(response); {
+				case ok && resp.refused:
+					s.Tracef("%s: %s -> conexão recusada", name, description)
+				case ok:
+					out, known, applied := classify(resp)
+					s.Tracef("%s: %s -> %+v (erro: %v)", name, description, out, resp.err)
+					if applied {
+						record(clientID, call, int64(s.Elapsed()), in, out, !known)
+					}
+				case !c.arrived:
+					// A requisição se perdeu (a latência é bem menor que o
+					// tempo de espera) ou foi recusada: não foi executada.
+					s.Tracef("%s: %s -> sem resposta (requisição perdida)", name, description)
+				case c.done:
+					// A resposta se perdeu: vale a do servidor, no instante em
+					// que ele terminou.
+					out, known, applied := classify(c.resp)
+					s.Tracef("%s: %s -> sem resposta (resposta perdida: %+v, erro: %v)", name, description, out, c.resp.err)
+					if applied {
+						record(clientID, call, c.doneAt, in, out, !known)
+					}
+				default:
+					s.Tracef("%s: %s -> sem resposta", name, description)
+					record(clientID, call, 0, in, lincheck.ListOutput{}, true)
+				}
+				s.Sleep(time.Duration(rng.Intn(3000)) * time.Microsecond)
+			}
+		})
+	}
+
+	// Caos: quedas e partições enquanto houver clientes.
+	s.Go("caos", "caos", func() {
+		for running > 0 {
+			s.Sleep(time.Duration(1+rng.Intn(30)) * time.Millisecond)
+			switch p := rng.Intn(100); {
+			case p < 20 && enabled.crash:
+				n.crash("kill -9")
+			case p < 45 && enabled.partition:
+				client := fmt.Sprintf("cliente-%d", rng.Intn(sc.clients))
+				network.Partition(client, serverName)
+				r.partitions++
+				s.After(time.Duration(1+rng.Intn(40))*time.Millisecond, func() { network.Heal(client, serverName) })
+			}
+		}
+	})
+
+	// Verificação final: sem falhas, espera o servidor voltar e lê todas as
+	// listas, para que o histórico termine com o estado durável.
+	s.Go("verificação", "verificação", func() {
+		for running > 0 {
+			s.Sleep(verifyEvery)
+		}
+		network.HealAll()
+		network.SetDropRate(0)
+		fsys.SetFaults(sim.DiskFaults{})
+		for n.engine == nil {
+			s.Sleep(verifyEvery)
+		}
+		e := n.engine
+		for l := 0; l < sc.lists; l++ {
+			in := lincheck.ListInput{List: fmt.Sprintf("l%d", l), Op: "Range", Start: 0, Stop: -1}
+			call := int64(s.Elapsed())
+			out, err := execute(e, in)
+			out, known, applied := classify(response{out: out, err: err})
+			s.Tracef("verificação: %s -> %+v (erro: %v)", describe(in), out, err)
+			if applied {
+				record(sc.clients, call, int64(s.Elapsed()), in, out, !known)
+			}
+		}
+		n.stopped = true
+		n.engine = nil
+	})
+
+	r.err = s.Run(timeLimit)
+	r.digest = s.Digest()
+	r.crashes, r.steps, r.elapsed = n.crashes, s.Steps(), s.Elapsed()
+	r.operations = len(history)
+	if r.err != nil {
+		return r
+	}
+	if blocked := s.Blocked(); len(blocked) > 0 {
+		r.err = fmt.Errorf("tarefas bloqueadas no fim: %s", strings.Join(blocked, ", "))
+		return r
+	}
+
+	check := lincheck.Check(lincheck.ListModel(), history, checkTimeout)
+	switch check.Outcome {
+	case lincheck.Illegal:
+		r.err = fmt.Errorf("histórico não linearizável; contraexemplo:\n%s", lincheck.Format(lincheck.ListModel(), check.Counterexample))
+	case lincheck.Unknown:
+		r.err = errors.New("verificação de linearizabilidade inconclusiva (tempo esgotado)")
+	}
+	return r
+}
+
+// definiteCodes são os erros que fazem parte do modelo de listas.
+var definiteCodes = map[structures.ErrorCode]bool{
+	structures.CodeNotFound:   true,
+	structures.CodeEmpty:      true,
+	structures.CodeOutOfRange: true,
+}
+
+// classify interpreta a resposta: a saída para o modelo, se ela é conhecida
+// e se a operação pode ter tido efeito (UNAVAILABLE garante que não teve, e
+// a operação fica fora do histórico).
+func classify(resp response) (out lincheck.ListOutput, known, applied bool) {
+	if resp.err == nil {
+		return resp.out, true, true
+	}
+	var typed *structures.Error
+	if !errors.As(resp.err, &typed) {
+		return lincheck.ListOutput{}, false, true
+	}
+	switch {
+	case definiteCodes[typed.Code]:
+		return lincheck.ListOutput{Err: string(typed.Code)}, true, true
+	case typed.Code == structures.CodeUnavailable:
+		return lincheck.ListOutput{}, false, false
+	}
+	return lincheck.ListOutput{}, false, true
+}
+
+// describe descreve a chamada, para o registro dos eventos.
+func describe(in lincheck.ListInput) string {
+	switch in.Op {
+	case "Append":
+		return fmt.Sprintf("Append(%s, %d)", in.List, in.Value)
+	case "Get":
+		return fmt.Sprintf("Get(%s, %d)", in.List, in.Index)
+	case "Range":
+		return fmt.Sprintf("Range(%s, %d, %d)", in.List, in.Start, in.Stop)
+	}
+	return fmt.Sprintf("%s(%s)", in.Op, in.List)
+}
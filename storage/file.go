@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"time"
+
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/utils"
+)
+
+// FileSnapshotter guarda as gerações de snapshot no diretório snapshots/,
+// com as funções de utils.
+type FileSnapshotter struct{}
+
+// Save salva uma nova geração (completa ou incremental).
+func (FileSnapshotter) Save(rl *structures.RemoteList, coveredUntil time.Time, lastLSN uint64) (utils.SavedSnapshot, error) {
+	return utils.SaveSnapshot(rl, coveredUntil, lastLSN)
+}
+
+// Load carrega a geração válida mais nova.
+func (FileSnapshotter) Load() (*structures.RemoteList, Checkpoint, error) {
+	rl, info, err := utils.LoadSnapshot()
+	if err != nil {
+		return nil, Checkpoint{}, err
+	}
+	return rl, Checkpoint{CoveredUntil: info.LastLogTimestamp, LSN: info.LastLSN}, nil
+}
+
+// CompactionPoint retorna o timestamp coberto pela geração válida mais antiga.
+func (FileSnapshotter) CompactionPoint() (time.Time, error) {
+	return utils.CompactionPoint()
+}
+
+// LastSaved retorna o horário do último snapshot salvo ou carregado.
+func (FileSnapshotter) LastSaved() time.Time {
+	return utils.LastSnapshotTime()
+}
+
+// Generations retorna a quantidade de gerações no diretório.
+func (FileSnapshotter) Generations() int {
+	files, err := utils.Snapshots()
+	if err != nil {
+		return 0
+	}
+	return len(files)
+}
+
+// FileLogStore guarda o log em logs/operations.log, com as funções de utils.
+type FileLogStore struct{}
+
+// Write acrescenta a entrada ao arquivo de log, com fsync.
+func (FileLogStore) Write(entry utils.LogEntry) error {
+	return utils.WriteLog(entry)
+}
+
+// Recover descarta uma linha incompleta no fim do arquivo e lê as entradas
+// posteriores a since.
+func (FileLogStore) Recover(since time.Time, snapshotLSN uint64) ([]utils.LogEntry, error) {
+	if _, err := utils.RepairLogTail(); err != nil {
+		return nil, err
+	}
+	utils.AdvanceLSN(snapshotLSN)
+	return utils.ReadLogsFromTimestamp(since)
+}
+
+// LastLSN retorna o LSN da última entrada escrita ou recuperada.
+func (FileLogStore) LastLSN() uint64 {
+	return utils.LastLSN()
+}
+
+// Compact reescreve o arquivo de log sem as entradas cobertas.
+func (FileLogStore) Compact(coveredUntil time.Time) (utils.CompactionResult, error) {
+	return utils.CompactLog(coveredUntil)
+}
+
+// Size retorna o tamanho do arquivo de log.
+func (FileLogStore) Size() int64 {
+	return utils.LogSize()
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"sd-miniprojeto-1/metrics"
+	"sd-miniprojeto-1/storage/kv"
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/utils"
+)
+
+// Chaves do armazenamento chave-valor: o checkpoint fica em kvMetaKey e cada
+// lista em kvListPrefix + namespace + "\x00" + ID, com a quantidade de
+// elementos (uvarint) seguida das diferenças entre elementos consecutivos
+// (varints), como nos snapshots binários.
+const (
+	kvMetaKey    = "meta"
+	kvListPrefix = "list/"
+)
+
+// KVSnapshotter guarda o estado em um armazenamento chave-valor embutido, uma
+// chave por lista. Cada Save grava, em um único lote atômico, só as listas
+// alteradas ou removidas desde o anterior, junto com o novo checkpoint; não
+// há gerações nem cadeia a consolidar.
+type KVSnapshotter struct {
+	mu         sync.Mutex
+	store      *kv.Store
+	version    uint64 // Versão do RemoteList no último Save ou Load.
+	checkpoint Checkpoint
+	saved      bool // Se há checkpoint gravado.
+	savedAt    time.Time
+}
+
+// OpenKVSnapshotter abre (ou cria) o armazenamento em path.
+func OpenKVSnapshotter(path string) (*KVSnapshotter, error) {
+	store, err := kv.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	k := &KVSnapshotter{store: store}
+	value, ok, err := store.Get(kvMetaKey)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if ok {
+		if k.checkpoint, err = decodeKVMeta(value); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("checkpoint inválido em %s: %w", path, err)
+		}
+		k.saved = true
+	}
+	return k, nil
+}
+
+// Save grava as listas alteradas desde o último Save ou Load.
+func (k *KVSnapshotter) Save(rl *structures.RemoteList, coveredUntil time.Time, lastLSN uint64) (utils.SavedSnapshot, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	version := rl.Version()
+	if k.saved && version == k.version {
+		k.savedAt = time.Now()
+		return utils.SavedSnapshot{Path: k.store.Path(), CoveredUntil: k.checkpoint.CoveredUntil, LSN: k.checkpoint.LSN, Delta: true, Skipped: true}, nil
+	}
+
+	start := time.Now()
+	batch := &kv.Batch{}
+	err := rl.EachChangedList(k.version, func(namespace, listID string, elements []int, exists bool) error {
+		if exists {
+			batch.Put(kvListKey(namespace, listID), encodeKVList(elements))
+		} else {
+			batch.Delete(kvListKey(namespace, listID))
+		}
+		return nil
+	})
+	if err != nil {
+		return utils.SavedSnapshot{}, err
+	}
+	checkpoint := Checkpoint{CoveredUntil: coveredUntil, LSN: lastLSN}
+	meta, err := encodeKVMeta(checkpoint)
+	if err != nil {
+		return utils.SavedSnapshot{}, err
+	}
+	lists := batch.Len()
+	batch.Put(kvMetaKey, meta)
+	if _, err := k.store.Write(batch); err != nil {
+		return utils.SavedSnapshot{}, err
+	}
+	metrics.SnapshotSizeBytes.Set(float64(k.store.Size()))
+	metrics.SnapshotDuration.Observe(time.Since(start).Seconds())
+
+	k.version, k.checkpoint, k.saved, k.savedAt = version, checkpoint, true, time.Now()
+	fmt.Printf("Snapshot salvo em %s (%d listas gravadas; Cobre logs até: %s).\n", k.store.Path(), lists, coveredUntil.Format(time.RFC3339))
+	return utils.SavedSnapshot{Path: k.store.Path(), CoveredUntil: coveredUntil, LSN: lastLSN, Delta: true}, nil
+}
+
+// Load reconstrói o RemoteList a partir das listas gravadas.
+func (k *KVSnapshotter) Load() (*structures.RemoteList, Checkpoint, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	rl := structures.NewRemoteList()
+	err := k.store.Scan(kvListPrefix, func(key string, value []byte) error {
+		namespace, listID, ok := strings.Cut(strings.TrimPrefix(key, kvListPrefix), "\x00")
+		if !ok {
+			return fmt.Errorf("chave de lista inválida %q", key)
+		}
+		elements, err := decodeKVList(value)
+		if err != nil {
+			return fmt.Errorf("lista %s/%s inválida: %w", namespace, listID, err)
+		}
+		rl.Restore(namespace, listID, elements)
+		return nil
+	})
+	if err != nil {
+		return nil, Checkpoint{}, fmt.Errorf("erro ao carregar %s: %w", k.store.Path(), err)
+	}
+
+	k.version = rl.Version()
+	if k.saved {
+		k.savedAt = time.Now()
+		fmt.Printf("Estado carregado de %s (Cobre logs até: %s).\n", k.store.Path(), k.checkpoint.CoveredUntil.Format(time.RFC3339))
+	}
+	return rl, k.checkpoint, nil
+}
+
+// CompactionPoint retorna o timestamp coberto pelo último Save: o
+// armazenamento só guarda o estado mais recente.
+func (k *KVSnapshotter) CompactionPoint() (time.Time, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.checkpoint.CoveredUntil, nil
+}
+
+// LastSaved retorna o horário do último Save ou Load.
+func (k *KVSnapshotter) LastSaved() time.Time {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.savedAt
+}
+
+// Generations retorna 1 se há checkpoint gravado.
+func (k *KVSnapshotter) Generations() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.saved {
+		return 0
+	}
+	return 1
+}
+
+// Close fecha o armazenamento.
+func (k *KVSnapshotter) Close() error {
+	return k.store.Close()
+}
+
+func kvListKey(namespace, listID string) string {
+	return kvListPrefix + structures.NamespaceName(namespace) + "\x00" + listID
+}
+
+func encodeKVList(elements []int) []byte {
+	buf := binary.AppendUvarint(make([]byte, 0, 1+2*len(elements)), uint64(len(elements)))
+	previous := 0
+	for _, element := range elements {
+		buf = binary.AppendVarint(buf, int64(element-previous))
+		previous = element
+	}
+	return buf
+}
+
+func decodeKVList(value []byte) ([]int, error) {
+	count, n := binary.Uvarint(value)
+	if n <= 0 || count > uint64(len(value)) {
+		return nil, errors.New("quantidade de elementos inválida")
+	}
+	value = value[n:]
+	elements := make([]int, 0, count)
+	previous := 0
+	for i := uint64(0); i < count; i++ {
+		delta, n := binary.Varint(value)
+		if n <= 0 {
+			return nil, errors.New("elementos truncados")
+		}
+		value = value[n:]
+		previous += int(delta)
+		elements = append(elements, previous)
+	}
+	if len(value) != 0 {
+		return nil, errors.New("bytes extras após os elementos")
+	}
+	return elements, nil
+}
+
+func encodeKVMeta(checkpoint Checkpoint) ([]byte, error) {
+	timestamp, err := checkpoint.CoveredUntil.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(binary.AppendUvarint(nil, checkpoint.LSN), timestamp...), nil
+}
+
+func decodeKVMeta(value []byte) (Checkpoint, error) {
+	lsn, n := binary.Uvarint(value)
+	if n <= 0 {
+		return Checkpoint{}, errors.New("LSN inválido")
+	}
+	var checkpoint Checkpoint
+	if err := checkpoint.CoveredUntil.UnmarshalBinary(value[n:]); err != nil {
+		return Checkpoint{}, err
+	}
+	checkpoint.LSN = lsn
+	return checkpoint, nil
+}
@@ -0,0 +1,436 @@
+// Package kv implementa um armazenamento chave-valor embutido, em um único
+// arquivo só de acréscimos com índice em memória.
+//
+// As escritas são feitas em lotes atômicos: um lote só passa a valer quando
+// seu registro de confirmação está no disco, e um lote incompleto no fim do
+// arquivo (escrita interrompida) é descartado na abertura; um registro
+// inválido seguido de lotes confirmados faz a abertura falhar. Quando a maior
+// parte do arquivo são valores substituídos ou removidos, ele é reescrito
+// só com os valores vivos.
+package kv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Formato de cada registro:
+//
+//	<crc32> <tipo> <tamanho da chave> <tamanho do valor> <chave> <valor>
+//
+// O crc32 (Castagnoli, little-endian) cobre do tipo ao fim do valor; os
+// tamanhos são uvarints.
+const (
+	recordPut    = 1 // Grava o valor da chave.
+	recordDelete = 2 // Remove a chave.
+	recordCommit = 3 // Confirma os registros anteriores do lote.
+
+	maxKeyLen   = 1 << 16
+	maxValueLen = 1 << 30
+
+	// compactMinBytes é o tamanho mínimo do arquivo para a reescrita.
+	compactMinBytes = 1 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// location é a posição de um valor vivo no arquivo.
+type location struct {
+	offset int64 // Início do valor.
+	size   int   // Tamanho do valor.
+	record int64 // Tamanho do registro inteiro.
+}
+
+// Store é um armazenamento aberto. Seus métodos podem ser chamados de
+// várias goroutines.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	f     *os.File
+	index map[string]location
+	end   int64 // Fim do último lote confirmado.
+	live  int64 // Bytes dos registros com valores vivos.
+}
+
+// Open abre (ou cria) o armazenamento em path, descartando um lote
+// incompleto no fim do arquivo. Um registro inválido seguido de lotes
+// confirmados não é descartado: Open retorna um erro com ErrCorrupt.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("erro ao criar diretório de %s: %w", path, err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir %s: %w", path, err)
+	}
+	s := &Store{path: path, f: f}
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reconstrói o índice a partir do arquivo e o trunca no fim do último
+// lote confirmado.
+func (s *Store) load() error {
+	s.index = make(map[string]location)
+	s.end, s.live = 0, 0
+
+	type pending struct {
+		kind int
+		key  string
+		loc  location
+	}
+	var batch []pending
+	r := bufio.NewReader(io.NewSectionReader(s.f, 0, 1<<62))
+	offset := int64(0)
+	for {
+		kind, key, valueOffset, valueSize, n, err := readRecord(r, offset)
+		if err != nil {
+			// Fim do arquivo ou registro incompleto ou corrompido. Só é um
+			// lote incompleto, que pode ser descartado, se nenhum lote foi
+			// confirmado depois dele.
+			if err := s.checkTail(offset); err != nil {
+				return err
+			}
+			break
+		}
+		offset += n
+		switch kind {
+		case recordPut, recordDelete:
+			batch = append(batch, pending{kind, key, location{valueOffset, valueSize, n}})
+		case recordCommit:
+			for _, p := range batch {
+				s.apply(p.kind, p.key, p.loc)
+			}
+			batch = batch[:0]
+			s.end = offset
+		}
+	}
+
+	info, err := s.f.Stat()
+	if err != nil {
+		return fmt.Errorf("erro ao consultar %s: %w", s.path, err)
+	}
+	if info.Size() > s.end {
+		if err := s.f.Truncate(s.end); err != nil {
+			return fmt.Errorf("erro ao descartar lote incompleto de %s: %w", s.path, err)
+		}
+	}
+	return nil
+}
+
+// ErrCorrupt indica um registro inválido seguido de lotes confirmados:
+// descartá-lo, como um lote incompleto, apagaria os lotes seguintes.
+var ErrCorrupt = errors.New("arquivo corrompido")
+
+// commitRecord é o registro de confirmação, sempre com os mesmos bytes.
+var commitRecord = appendRecord(nil, recordCommit, "", nil)
+
+// checkTail verifica se o arquivo a partir de offset, onde a leitura parou,
+// é só o resto de uma escrita interrompida: se não há, depois dele, nenhum
+// registro de confirmação. Um lote gravado depois de um registro inválido
+// indica corrupção no meio do arquivo, e não uma escrita interrompida.
+func (s *Store) checkTail(offset int64) error {
+	r := bufio.NewReader(io.NewSectionReader(s.f, offset, 1<<62))
+	window := make([]byte, 0, len(commitRecord))
+	position := offset
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao ler %s: %w", s.path, err)
+		}
+		position++
+		if len(window) == cap(window) {
+			window = append(window[:0], window[1:]...)
+		}
+		window = append(window, b)
+		if string(window) == string(commitRecord) {
+			return fmt.Errorf("%w: %s tem um registro inválido no byte %d e um lote confirmado no byte %d",
+				ErrCorrupt, s.path, offset, position-int64(len(commitRecord)))
+		}
+	}
+}
+
+// apply atualiza o índice com um registro confirmado.
+func (s *Store) apply(kind int, key string, loc location) {
+	if old, ok := s.index[key]; ok {
+		s.live -= old.record
+		delete(s.index, key)
+	}
+	if kind == recordPut {
+		s.index[key] = loc
+		s.live += loc.record
+	}
+}
+
+// readRecord lê e confere um registro que começa em offset. Retorna a
+// posição e o tamanho do valor e o tamanho do registro.
+func readRecord(r *bufio.Reader, offset int64) (kind int, key string, valueOffset int64, valueSize int, n int64, err error) {
+	var header [5]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return
+	}
+	crc := crc32.Update(0, crcTable, header[4:])
+	kind = int(header[4])
+
+	counter := &countingReader{r: r}
+	keyLen, err := binary.ReadUvarint(counter)
+	if err != nil {
+		return
+	}
+	valueLen, err := binary.ReadUvarint(counter)
+	if err != nil {
+		return
+	}
+	if keyLen > maxKeyLen || valueLen > maxValueLen {
+		err = errors.New("tamanho de registro inválido")
+		return
+	}
+	crc = crc32.Update(crc, crcTable, counter.read)
+
+	data := make([]byte, keyLen+valueLen)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return
+	}
+	crc = crc32.Update(crc, crcTable, data)
+	if crc != binary.LittleEndian.Uint32(header[:4]) {
+		err = errors.New("checksum inválido")
+		return
+	}
+
+	key = string(data[:keyLen])
+	headerLen := int64(len(header) + len(counter.read))
+	valueOffset = offset + headerLen + int64(keyLen)
+	valueSize = int(valueLen)
+	n = headerLen + int64(len(data))
+	return
+}
+
+// countingReader guarda os bytes lidos pelos uvarints, para o checksum.
+type countingReader struct {
+	r    io.ByteReader
+	read []byte
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.read = append(c.read, b)
+	}
+	return b, err
+}
+
+// appendRecord codifica um registro no fim de buf.
+func appendRecord(buf []byte, kind int, key string, value []byte) []byte {
+	start := len(buf)
+	buf = append(buf, 0, 0, 0, 0, byte(kind))
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	buf = append(buf, key...)
+	buf = append(buf, value...)
+	binary.LittleEndian.PutUint32(buf[start:], crc32.Checksum(buf[start+4:], crcTable))
+	return buf
+}
+
+// Get retorna o valor da chave e se ela existe.
+func (s *Store) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loc, ok := s.index[key]
+	if !ok {
+		return nil, false, nil
+	}
+	value, err := s.read(loc)
+	return value, err == nil, err
+}
+
+func (s *Store) read(loc location) ([]byte, error) {
+	value := make([]byte, loc.size)
+	if _, err := s.f.ReadAt(value, loc.offset); err != nil {
+		return nil, fmt.Errorf("erro ao ler %s: %w", s.path, err)
+	}
+	return value, nil
+}
+
+// Scan chama fn, em ordem de chave, para cada chave com o prefixo. O
+// armazenamento fica bloqueado durante a varredura; se fn retornar erro, ela
+// para e o erro é devolvido.
+func (s *Store) Scan(prefix string, fn func(key string, value []byte) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := s.read(s.index[key])
+		if err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Batch acumula alterações para gravação atômica com Write.
+type Batch struct {
+	ops []batchOp
+}
+
+type batchOp struct {
+	kind  int
+	key   string
+	value []byte
+}
+
+// Put grava o valor da chave.
+func (b *Batch) Put(key string, value []byte) {
+	b.ops = append(b.ops, batchOp{recordPut, key, value})
+}
+
+// Delete remove a chave.
+func (b *Batch) Delete(key string) {
+	b.ops = append(b.ops, batchOp{recordDelete, key, nil})
+}
+
+// Len retorna a quantidade de alterações no lote.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Write grava o lote e o confirma com fsync; ou todas as alterações passam a
+// valer, ou nenhuma. Retorna os bytes gravados.
+func (s *Store) Write(b *Batch) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, op := range b.ops {
+		if len(op.key) > maxKeyLen || len(op.value) > maxValueLen {
+			return 0, fmt.Errorf("chave ou valor grande demais para %q", op.key)
+		}
+	}
+
+	var buf []byte
+	locs := make([]location, len(b.ops))
+	for i, op := range b.ops {
+		start := len(buf)
+		buf = appendRecord(buf, op.kind, op.key, op.value)
+		n := int64(len(buf) - start)
+		locs[i] = location{offset: s.end + int64(start) + n - int64(len(op.value)), size: len(op.value), record: n}
+	}
+	buf = appendRecord(buf, recordCommit, "", nil)
+
+	if _, err := s.f.WriteAt(buf, s.end); err != nil {
+		s.f.Truncate(s.end) // Melhor esforço; um lote incompleto é descartado na abertura.
+		return 0, fmt.Errorf("erro ao gravar em %s: %w", s.path, err)
+	}
+	if err := s.f.Sync(); err != nil {
+		s.f.Truncate(s.end)
+		return 0, fmt.Errorf("erro ao sincronizar %s: %w", s.path, err)
+	}
+
+	for i, op := range b.ops {
+		s.apply(op.kind, op.key, locs[i])
+	}
+	s.end += int64(len(buf))
+
+	if s.end > compactMinBytes && s.live*2 < s.end {
+		if err := s.compact(); err != nil {
+			return int64(len(buf)), fmt.Errorf("lote gravado, mas a reescrita de %s falhou: %w", s.path, err)
+		}
+	}
+	return int64(len(buf)), nil
+}
+
+// compact reescreve o arquivo só com os valores vivos, em um único lote.
+// Deve ser chamada com s.mu adquirido.
+func (s *Store) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Sem efeito após o rename.
+
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w := bufio.NewWriter(tmp)
+	for _, key := range keys {
+		value, err := s.read(s.index[key])
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if _, err := w.Write(appendRecord(nil, recordPut, key, value)); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if _, err := w.Write(appendRecord(nil, recordCommit, "", nil)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		tmp.Close()
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(s.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	s.f.Close()
+	s.f = tmp
+	return s.load()
+}
+
+// Size retorna o tamanho do arquivo em bytes.
+func (s *Store) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.end
+}
+
+// Path retorna o caminho do arquivo.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Close fecha o arquivo.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
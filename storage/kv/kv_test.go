@@ -0,0 +1,191 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// batches grava os lotes em um armazenamento novo e retorna o caminho e o
+// tamanho do arquivo após cada lote.
+func batches(t *testing.T, ops ...map[string]string) (path string, ends []int64) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "dados.kv")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, op := range ops {
+		var b Batch
+		for key, value := range op {
+			if value == "" {
+				b.Delete(key)
+			} else {
+				b.Put(key, []byte(value))
+			}
+		}
+		if _, err := s.Write(&b); err != nil {
+			t.Fatal(err)
+		}
+		ends = append(ends, s.Size())
+	}
+	return path, ends
+}
+
+// contents abre o armazenamento e retorna todas as chaves e valores.
+func contents(path string) (map[string]string, error) {
+	s, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	all := make(map[string]string)
+	err = s.Scan("", func(key string, value []byte) error {
+		all[key] = string(value)
+		return nil
+	})
+	return all, err
+}
+
+func TestRoundTrip(t *testing.T) {
+	path, _ := batches(t,
+		map[string]string{"a": "1", "b": "2"},
+		map[string]string{"a": "3", "c": "4"},
+		map[string]string{"b": ""}, // Remove b.
+	)
+	got, err := contents(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"a": "3", "c": "4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("lido %v, esperado %v", got, want)
+	}
+}
+
+func TestCompactionKeepsLiveValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dados.kv")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := make([]byte, 64*1024)
+	for i := 0; i < 3*compactMinBytes/len(value); i++ {
+		var b Batch
+		b.Put(fmt.Sprintf("k%d", i%4), append(value[:len(value):len(value)], byte(i)))
+		if _, err := s.Write(&b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if size := s.Size(); size > compactMinBytes {
+		t.Errorf("arquivo com %d bytes após a reescrita, esperado até %d", size, compactMinBytes)
+	}
+	s.Close()
+
+	got, err := contents(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("%d chaves após a reescrita, esperado 4", len(got))
+	}
+}
+
+// TestTornTail confere que um lote cortado em qualquer ponto (escrita
+// interrompida) é descartado, e os anteriores continuam.
+func TestTornTail(t *testing.T) {
+	path, ends := batches(t,
+		map[string]string{"a": "1"},
+		map[string]string{"a": "2", "b": "3"},
+	)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cut := ends[0]; cut <= ends[1]; cut++ {
+		torn := filepath.Join(t.TempDir(), "dados.kv")
+		if err := os.WriteFile(torn, data[:cut], 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := contents(torn)
+		if err != nil {
+			t.Fatalf("corte no byte %d: %v", cut, err)
+		}
+		want := map[string]string{"a": "1"}
+		if cut == ends[1] {
+			want = map[string]string{"a": "2", "b": "3"}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("corte no byte %d: lido %v, esperado %v", cut, got, want)
+		}
+		if info, _ := os.Stat(torn); cut < ends[1] && info.Size() != ends[0] {
+			t.Errorf("corte no byte %d: arquivo com %d bytes após a abertura, esperado %d", cut, info.Size(), ends[0])
+		}
+	}
+}
+
+// TestCorruption confere que bytes alterados em um registro só são
+// descartados quando estão no lote final, ainda não confirmado; antes de um
+// lote confirmado, Open falha sem alterar o arquivo.
+func TestCorruption(t *testing.T) {
+	path, ends := batches(t,
+		map[string]string{"a": "1"},
+		map[string]string{"b": "2"},
+		map[string]string{"c": "3"},
+	)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		want    map[string]string // nil se Open deve falhar.
+		wantLen int64
+	}{
+		{"valor do primeiro lote", flip(data, ends[0]-int64(len(commitRecord))-1), nil, 0},
+		{"checksum do lote do meio", flip(data, ends[0]), nil, 0},
+		{"confirmação do lote do meio", flip(data, ends[1]-1), nil, 0},
+		{"valor do último lote", flip(data, ends[2]-int64(len(commitRecord))-1), nil, 0},
+		{"confirmação do último lote", flip(data, ends[2]-1), map[string]string{"a": "1", "b": "2"}, ends[1]},
+		{"lixo após o último lote", append(append([]byte(nil), data...), 0xff, 0x00, 0x13), map[string]string{"a": "1", "b": "2", "c": "3"}, ends[2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupted := filepath.Join(t.TempDir(), "dados.kv")
+			if err := os.WriteFile(corrupted, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := contents(corrupted)
+			info, _ := os.Stat(corrupted)
+			if tt.want == nil {
+				if !errors.Is(err, ErrCorrupt) {
+					t.Fatalf("lido %v (erro %v), esperado ErrCorrupt", got, err)
+				}
+				if info.Size() != int64(len(tt.data)) {
+					t.Errorf("arquivo corrompido alterado: %d bytes, antes %d", info.Size(), len(tt.data))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("lido %v, esperado %v", got, tt.want)
+			}
+			if info.Size() != tt.wantLen {
+				t.Errorf("arquivo com %d bytes após a abertura, esperado %d", info.Size(), tt.wantLen)
+			}
+		})
+	}
+}
+
+// flip retorna uma cópia de data com o byte em offset alterado.
+func flip(data []byte, offset int64) []byte {
+	corrupted := append([]byte(nil), data...)
+	corrupted[offset] ^= 0xff
+	return corrupted
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/utils"
+)
+
+// memoryPath identifica o estado em memória em SavedSnapshot.Path.
+const memoryPath = "(memória)"
+
+// MemorySnapshotter guarda o último estado salvo em memória. Serve para
+// testes: Load devolve uma cópia do que foi salvo, como se o processo
+// tivesse reiniciado, mas nada sobrevive ao fim do processo.
+type MemorySnapshotter struct {
+	mu         sync.Mutex
+	state      *structures.RemoteList // Nil enquanto nada foi salvo.
+	checkpoint Checkpoint
+	savedAt    time.Time
+}
+
+// NewMemorySnapshotter cria um MemorySnapshotter vazio.
+func NewMemorySnapshotter() *MemorySnapshotter {
+	return &MemorySnapshotter{}
+}
+
+// Save guarda uma cópia de rl, se ele mudou desde o último salvamento.
+func (m *MemorySnapshotter) Save(rl *structures.RemoteList, coveredUntil time.Time, lastLSN uint64) (utils.SavedSnapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state != nil && m.state.Version() == rl.Version() {
+		m.savedAt = time.Now()
+		return utils.SavedSnapshot{Path: memoryPath, CoveredUntil: m.checkpoint.CoveredUntil, LSN: m.checkpoint.LSN, Skipped: true}, nil
+	}
+	m.state = rl.Clone()
+	m.checkpoint = Checkpoint{CoveredUntil: coveredUntil, LSN: lastLSN}
+	m.savedAt = time.Now()
+	return utils.SavedSnapshot{Path: memoryPath, CoveredUntil: coveredUntil, LSN: lastLSN}, nil
+}
+
+// Load devolve uma cópia do último estado salvo.
+func (m *MemorySnapshotter) Load() (*structures.RemoteList, Checkpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state == nil {
+		return structures.NewRemoteList(), Checkpoint{}, nil
+	}
+	return m.state.Clone(), m.checkpoint, nil
+}
+
+// CompactionPoint retorna o timestamp coberto pelo estado salvo.
+func (m *MemorySnapshotter) CompactionPoint() (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.checkpoint.CoveredUntil, nil
+}
+
+// LastSaved retorna o horário do último salvamento.
+func (m *MemorySnapshotter) LastSaved() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.savedAt
+}
+
+// Generations retorna 1 se há estado salvo.
+func (m *MemorySnapshotter) Generations() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state == nil {
+		return 0
+	}
+	return 1
+}
+
+// MemoryLogStore guarda as entradas do log em memória, para testes.
+type MemoryLogStore struct {
+	mu      sync.Mutex
+	entries []utils.LogEntry
+	lastLSN uint64
+	bytes   int64 // Tamanho das entradas no formato do arquivo de log.
+}
+
+// NewMemoryLogStore cria um MemoryLogStore vazio.
+func NewMemoryLogStore() *MemoryLogStore {
+	return &MemoryLogStore{}
+}
+
+// Write acrescenta a entrada com o próximo LSN.
+func (m *MemoryLogStore) Write(entry utils.LogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastLSN++
+	entry.LSN = m.lastLSN
+	m.entries = append(m.entries, entry)
+	m.bytes += int64(len(entry.String()) + 1)
+	return nil
+}
+
+// Recover retorna as entradas posteriores a since.
+func (m *MemoryLogStore) Recover(since time.Time, snapshotLSN uint64) ([]utils.LogEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if snapshotLSN > m.lastLSN {
+		m.lastLSN = snapshotLSN
+	}
+	var entries []utils.LogEntry
+	for _, entry := range m.entries {
+		if entry.Timestamp.After(since) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// LastLSN retorna o LSN da última entrada.
+func (m *MemoryLogStore) LastLSN() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastLSN
+}
+
+// Compact descarta as entradas até coveredUntil e as leituras.
+func (m *MemoryLogStore) Compact(coveredUntil time.Time) (utils.CompactionResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := utils.CompactionResult{BytesBefore: m.bytes}
+	kept := m.entries[:0]
+	m.bytes = 0
+	for _, entry := range m.entries {
+		if !entry.Timestamp.After(coveredUntil) || entry.Operation == "Get/Size" {
+			result.Freed++
+			continue
+		}
+		kept = append(kept, entry)
+		m.bytes += int64(len(entry.String()) + 1)
+	}
+	m.entries = kept
+	result.Kept, result.BytesAfter = len(kept), m.bytes
+	return result, nil
+}
+
+// Size retorna o tamanho das entradas no formato do arquivo de log.
+func (m *MemoryLogStore) Size() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bytes
+}
+
+// Entries retorna uma cópia das entradas registradas.
+func (m *MemoryLogStore) Entries() []utils.LogEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]utils.LogEntry(nil), m.entries...)
+}
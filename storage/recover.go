@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/utils"
+)
+
+// Recovery é o estado reconstruído por Recover.
+type Recovery struct {
+	State         *structures.RemoteList
+	Checkpoint    Checkpoint // Posição do log coberta pelo estado salvo.
+	Replayed      int        // Entradas do log reaplicadas sobre o estado salvo.
+	LastTimestamp time.Time  // Timestamp da última operação reaplicada (ou do checkpoint).
+}
+
+// Recover reconstrói o estado na inicialização: carrega o estado salvo mais
+// recente e reaplica as entradas do log posteriores a ele. Se o estado salvo
+// ou o log não puderem ser lidos, retorna erro: iniciar só com o estado salvo
+// perderia as operações já confirmadas que estão apenas no log.
+func Recover(snapshots Snapshotter, logs LogStore) (Recovery, error) {
+	fmt.Println("Tentando carregar snapshot...")
+	remoteList, checkpoint, err := snapshots.Load()
+	if err != nil {
+		return Recovery{}, fmt.Errorf("erro ao carregar snapshot: %w", err)
+	}
+	fmt.Println("Snapshot carregado ou nova lista criada.")
+	recovery := Recovery{State: remoteList, Checkpoint: checkpoint, LastTimestamp: checkpoint.CoveredUntil}
+
+	if checkpoint.CoveredUntil.IsZero() {
+		fmt.Println("Nenhum timestamp de snapshot válido. Aplicando todos os logs disponíveis.")
+	} else {
+		fmt.Printf("Aplicando logs a partir de %s...\n", checkpoint.CoveredUntil.Format(time.RFC3339Nano))
+	}
+	entries, err := logs.Recover(checkpoint.CoveredUntil, checkpoint.LSN)
+	if err != nil {
+		return Recovery{}, fmt.Errorf("erro ao ler logs para recuperação: %w", err)
+	}
+	for _, entry := range entries {
+		// Reaplica operações do log diretamente na lista (sem logar novamente).
+		utils.ApplyLogEntry(remoteList, entry)
+		if entry.Timestamp.After(recovery.LastTimestamp) {
+			recovery.LastTimestamp = entry.Timestamp
+		}
+	}
+	recovery.Replayed = len(entries)
+	fmt.Printf("%d logs relevantes aplicados.\n", len(entries))
+	return recovery, nil
+}
@@ -0,0 +1,393 @@
+package storage
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/utils"
+	"sd-miniprojeto-1/utils/faultfs"
+)
+
+// Os cenários de queda verificam que as operações confirmadas sobrevivem a
+// falhas de disco e quedas do processo. Cada rodada executa, em um diretório
+// temporário, uma sequência aleatória de operações, snapshots e compactações
+// com o log e os snapshots em arquivo (os mesmos caminhos do servidor), sobre
+// um sistema de arquivos que injeta uma falha (utils/faultfs) e simula a
+// queda do processo no fim; depois, faz a recuperação do servidor (Recover) e
+// compara o estado recuperado com o esperado.
+var (
+	crashRuns       = flag.Int("crash.runs", 0, "Rodadas de TestCrashRecovery (0: 100, ou 20 com -short).")
+	crashSeed       = flag.Int64("crash.seed", 1, "Semente da primeira rodada; a rodada i usa seed+i.")
+	crashOps        = flag.Int("crash.ops", 150, "Operações por rodada.")
+	crashFaults     = flag.String("crash.faults", "crash,torn,fsync,enospc", "Falhas injetadas, alternadas entre as rodadas.")
+	crashAt         = flag.Int("crash.at", 0, "Ponto de escrita da falha (0: aleatório). Com -crash.runs 1, reproduz uma rodada.")
+	crashExhaustive = flag.Bool("crash.exhaustive", false, "Para cada semente e falha, testa todos os pontos de escrita.")
+)
+
+const markerListID = "__marker__"
+
+// crashOp é uma operação da rodada.
+type crashOp struct {
+	Op    string // Append, Remove ou Delete.
+	List  string
+	Value int
+	acked bool // Confirmada; se falso, pode ou não ter sido aplicada.
+}
+
+func (op crashOp) String() string {
+	if op.Op == "Append" {
+		return fmt.Sprintf("%s(%s, %d)", op.Op, op.List, op.Value)
+	}
+	return fmt.Sprintf("%s(%s)", op.Op, op.List)
+}
+
+// listState é o conteúdo das listas, por "namespace/lista".
+type listState map[string][]int
+
+func TestCrashRecovery(t *testing.T) {
+	var faults []faultfs.Fault
+	for _, name := range strings.Split(*crashFaults, ",") {
+		fault, err := faultfs.ParseFault(strings.TrimSpace(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		faults = append(faults, fault)
+	}
+	runs := *crashRuns
+	if runs == 0 {
+		runs = 100
+		if testing.Short() {
+			runs = 20
+		}
+	}
+
+	for i := 0; i < runs; i++ {
+		seed := *crashSeed + int64(i)
+		fault := faults[i%len(faults)]
+		ops, boundaries := crashRound(t, seed, faultfs.None, 0)
+		if err := checkRecovered(ops, recoverState(t)); err != nil {
+			t.Fatalf("seed=%d sem falhas: %v", seed, err)
+		}
+
+		var points []int
+		switch {
+		case *crashExhaustive:
+			for at := 1; at <= boundaries; at++ {
+				points = append(points, at)
+			}
+		case *crashAt > 0:
+			points = []int{*crashAt}
+		default:
+			points = []int{1 + rand.New(rand.NewSource(seed)).Intn(boundaries)}
+		}
+		for _, at := range points {
+			if err := crashCheck(t, seed, fault, at); err != nil {
+				t.Fatalf("seed=%d fault=%s at=%d: %v\nreproduza com: go test ./storage -run TestCrashRecovery -crash.runs 1 -crash.seed %d -crash.faults %s -crash.at %d -crash.ops %d",
+					seed, fault, at, err, seed, fault, at, *crashOps)
+			}
+		}
+	}
+}
+
+// crashCheck executa uma rodada com a falha e verifica a recuperação.
+func crashCheck(t *testing.T, seed int64, fault faultfs.Fault, at int) error {
+	ops, _ := crashRound(t, seed, fault, at)
+	recovered := recoverState(t)
+	if err := checkRecovered(ops, recovered); err != nil {
+		return err
+	}
+
+	// Uma segunda recuperação, após uma operação registrada sobre o log
+	// recuperado, deve enxergá-la: o log continua íntegro após a queda.
+	if err := (FileLogStore{}).Write(utils.AppendEntry("", markerListID, 1)); err != nil {
+		return fmt.Errorf("operação após a recuperação: %v", err)
+	}
+	again := recoverState(t)
+	key := structures.DefaultNamespace + "/" + markerListID
+	recovered[key] = append(recovered[key], 1)
+	if !reflect.DeepEqual(again, recovered) {
+		return fmt.Errorf("a operação registrada após a recuperação não sobreviveu à recuperação seguinte:\n  esperado %v\n  obtido   %v", recovered, again)
+	}
+	return nil
+}
+
+// enterTempDir muda para um diretório temporário novo, preparado como o do
+// servidor, e reinicia o estado de utils, como em um processo novo.
+func enterTempDir(t *testing.T) {
+	t.Helper()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+	for _, dir := range []string{"logs", "snapshots"} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	utils.Reset()
+}
+
+// quiet descarta as mensagens que utils e storage escrevem na saída padrão
+// durante fn.
+func quiet(t *testing.T, fn func()) {
+	t.Helper()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	defer func() {
+		os.Stdout = stdout
+		devNull.Close()
+	}()
+	fn()
+}
+
+// crashRound executa, em um diretório temporário novo, as operações da
+// semente sobre o log e os snapshots em arquivo, como o servidor, com a falha
+// injetada, e termina com a queda simulada. Uma operação só é confirmada se o
+// log retornou com sucesso. Retorna as operações e quantos pontos de escrita
+// foram executados. O diretório continua como o atual, para a recuperação.
+func crashRound(t *testing.T, seed int64, fault faultfs.Fault, at int) (ops []crashOp, boundaries int) {
+	t.Helper()
+	enterTempDir(t)
+	fsys := faultfs.New(fault, at, seed)
+	previousFS := utils.SetFS(fsys)
+	defer utils.SetFS(previousFS)
+	utils.SetSnapshotRetention(utils.SnapshotRetention{KeepLast: 2})
+	utils.SetFullSnapshotInterval(3)
+	defer utils.SetSnapshotRetention(utils.SnapshotRetention{})
+	defer utils.SetFullSnapshotInterval(0)
+
+	quiet(t, func() {
+		snapshots, logs := FileSnapshotter{}, FileLogStore{}
+		recovery, err := Recover(snapshots, logs)
+		if err != nil {
+			t.Fatalf("seed=%d: recuperação do diretório vazio: %v", seed, err)
+		}
+		remoteList := recovery.State
+		coveredUntil := recovery.LastTimestamp
+
+		rng := rand.New(rand.NewSource(seed))
+		lists := []string{"a", "b", "c"}
+		for seq := 0; seq < *crashOps && !fsys.Crashed(); seq++ {
+			op := crashOp{List: lists[rng.Intn(len(lists))]}
+			var entry utils.LogEntry
+			switch r := rng.Intn(100); {
+			case r < 55:
+				op.Op, op.Value = "Append", rng.Intn(1_000_000_000_000)
+				entry = utils.AppendEntry("", op.List, op.Value)
+			case r < 80:
+				op.Op = "Remove"
+				entry = utils.RemoveEntry("", op.List)
+			case r < 85:
+				op.Op = "Delete"
+				entry = utils.DeleteEntry("", op.List)
+			case r < 95:
+				crashSnapshot(snapshots, logs, remoteList, coveredUntil)
+				continue
+			default:
+				crashSnapshot(snapshots, logs, remoteList, coveredUntil)
+				if point, err := snapshots.CompactionPoint(); err == nil {
+					logs.Compact(point)
+				}
+				continue
+			}
+
+			ops = append(ops, op)
+			if err := logs.Write(entry); err != nil {
+				continue
+			}
+			coveredUntil = time.Now()
+			utils.ApplyLogEntry(remoteList, entry)
+			ops[len(ops)-1].acked = true
+		}
+	})
+
+	// A rodada termina com uma queda: o que não foi sincronizado se perde.
+	boundaries = fsys.Ops()
+	fsys.Crash()
+	return ops, boundaries
+}
+
+// crashSnapshot salva o estado como o servidor.
+func crashSnapshot(snapshots Snapshotter, logs LogStore, rl *structures.RemoteList, coveredUntil time.Time) {
+	state := rl.Clone()
+	saved, err := snapshots.Save(state, coveredUntil, logs.LastLSN())
+	if err == nil && !saved.Skipped {
+		rl.ForgetDeleted(state.Version())
+	}
+}
+
+// recoverState faz a recuperação do servidor, como em um processo novo, no
+// diretório atual, e retorna o estado.
+func recoverState(t *testing.T) listState {
+	t.Helper()
+	utils.Reset()
+	var recovery Recovery
+	var err error
+	quiet(t, func() { recovery, err = Recover(FileSnapshotter{}, FileLogStore{}) })
+	if err != nil {
+		t.Fatalf("recuperação falhou: %v", err)
+	}
+	recovered := make(listState)
+	recovery.State.EachList(func(namespace, listID string, elements []int) error {
+		recovered[namespace+"/"+listID] = append([]int(nil), elements...)
+		return nil
+	})
+	return recovered
+}
+
+// checkRecovered verifica se recovered é o resultado de aplicar, em ordem,
+// todas as operações confirmadas e algum subconjunto das não confirmadas
+// (em andamento na queda ou recusadas por erro de disco).
+func checkRecovered(ops []crashOp, recovered listState) error {
+	var uncertain []int
+	for i, op := range ops {
+		if !op.acked {
+			uncertain = append(uncertain, i)
+		}
+	}
+	if len(uncertain) > 16 {
+		return fmt.Errorf("%d operações não confirmadas; combinações demais para verificar", len(uncertain))
+	}
+
+	var expected listState
+	for mask := 0; mask < 1<<len(uncertain); mask++ {
+		skip := make(map[int]bool)
+		for bit, i := range uncertain {
+			if mask&(1<<bit) == 0 {
+				skip[i] = true
+			}
+		}
+		model := make(listState)
+		for i, op := range ops {
+			if !skip[i] {
+				model.apply(op)
+			}
+		}
+		if reflect.DeepEqual(model.normalized(), recovered.normalized()) {
+			return nil
+		}
+		if mask == 1<<len(uncertain)-1 {
+			expected = model
+		}
+	}
+	return fmt.Errorf("estado recuperado não corresponde às operações confirmadas (%d operações, %d não confirmadas):\n  esperado %v\n  obtido   %v",
+		len(ops), len(uncertain), expected.normalized(), recovered.normalized())
+}
+
+func (s listState) apply(op crashOp) {
+	key := structures.DefaultNamespace + "/" + op.List
+	switch op.Op {
+	case "Append":
+		s[key] = append(s[key], op.Value)
+	case "Remove":
+		if elements := s[key]; len(elements) > 0 {
+			s[key] = elements[:len(elements)-1]
+		}
+	case "Delete":
+		delete(s, key)
+	}
+}
+
+// normalized remove as listas vazias, que podem ou não existir após remoções.
+func (s listState) normalized() listState {
+	result := make(listState)
+	for key, elements := range s {
+		if len(elements) > 0 {
+			result[key] = elements
+		}
+	}
+	return result
+}
+
+// TestCheckRecoveredDetectsLostOperation garante que a verificação acusa a
+// perda de uma operação confirmada, e não só aceita qualquer estado.
+func TestCheckRecoveredDetectsLostOperation(t *testing.T) {
+	ops := []crashOp{
+		{Op: "Append", List: "a", Value: 1, acked: true},
+		{Op: "Append", List: "a", Value: 2, acked: true},
+		{Op: "Append", List: "a", Value: 3},
+	}
+	key := structures.DefaultNamespace + "/a"
+	for _, recovered := range []listState{{key: {1, 2}}, {key: {1, 2, 3}}} {
+		if err := checkRecovered(ops, recovered); err != nil {
+			t.Errorf("%v deveria ser aceito: %v", recovered, err)
+		}
+	}
+	for _, recovered := range []listState{{key: {1}}, {key: {1, 3}}, {}} {
+		if err := checkRecovered(ops, recovered); err == nil {
+			t.Errorf("%v perde uma operação confirmada e deveria ser recusado", recovered)
+		}
+	}
+}
+
+// TestRecoverReplaysPush confere que um Push registrado no log é reaplicado
+// por inteiro na recuperação.
+func TestRecoverReplaysPush(t *testing.T) {
+	enterTempDir(t)
+	logs := FileLogStore{}
+	for _, entry := range []utils.LogEntry{
+		utils.AppendEntry("", "a", 1),
+		utils.PushEntry("", "a", []int{2, 3, 4}),
+		utils.RemoveEntry("", "a"),
+	} {
+		if err := logs.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recovered := recoverState(t)
+	want := listState{structures.DefaultNamespace + "/a": {1, 2, 3}}
+	if !reflect.DeepEqual(recovered, want) {
+		t.Fatalf("recuperado %v, esperado %v", recovered, want)
+	}
+}
+
+// TestRecoverReplaysLargePush confere que um Push com o máximo de valores,
+// gravado em uma única linha do log bem maior que o limite padrão de um
+// bufio.Scanner, é recuperado, assim como as entradas seguintes.
+func TestRecoverReplaysLargePush(t *testing.T) {
+	enterTempDir(t)
+	values := make([]int, structures.MaxPushValues)
+	for i := range values {
+		values[i] = math.MinInt64 + i // 20 dígitos e o sinal por valor.
+	}
+	push := structures.PushArgs{ListID: "a", Values: values}
+	if err := structures.NewRemoteList().CheckPush(push); err != nil {
+		t.Fatalf("Push com %d valores recusado: %v", len(values), err)
+	}
+	push.Values = append(push.Values, 0)
+	if err := structures.NewRemoteList().CheckPush(push); !errors.Is(err, structures.ErrInvalidArgument) {
+		t.Fatalf("Push com %d valores: erro %v, esperado INVALID_ARGUMENT", len(push.Values), err)
+	}
+
+	logs := FileLogStore{}
+	for _, entry := range []utils.LogEntry{
+		utils.PushEntry("", "a", values),
+		utils.AppendEntry("", "a", 1),
+	} {
+		if err := logs.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recovered := recoverState(t)
+	want := listState{structures.DefaultNamespace + "/a": append(append([]int(nil), values...), 1)}
+	if !reflect.DeepEqual(recovered, want) {
+		t.Fatalf("recuperados %d elementos, esperado %d", len(recovered[structures.DefaultNamespace+"/a"]), len(want[structures.DefaultNamespace+"/a"]))
+	}
+}
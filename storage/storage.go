@@ -0,0 +1,86 @@
+// Package storage define as interfaces de persistência das quais o servidor
+// depende, Snapshotter e LogStore, e suas implementações: arquivos (o
+// formato de utils), memória (para testes) e um armazenamento chave-valor
+// embutido que salva cada lista em separado.
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"sd-miniprojeto-1/config"
+	"sd-miniprojeto-1/structures"
+	"sd-miniprojeto-1/utils"
+)
+
+// Checkpoint é a posição do log coberta por um estado salvo: as entradas até
+// CoveredUntil (e LSN) já estão nele.
+type Checkpoint struct {
+	CoveredUntil time.Time
+	LSN          uint64
+}
+
+// Snapshotter salva e carrega o estado das listas.
+type Snapshotter interface {
+	// Save salva o estado de rl, que cobre o log até coveredUntil e lastLSN.
+	// rl deve ser uma cópia feita com Clone, que mantém o registro de
+	// alterações usado pelas implementações incrementais.
+	Save(rl *structures.RemoteList, coveredUntil time.Time, lastLSN uint64) (utils.SavedSnapshot, error)
+	// Load carrega o estado salvo mais recente; sem estado salvo, retorna um
+	// RemoteList vazio e um Checkpoint zerado.
+	Load() (*structures.RemoteList, Checkpoint, error)
+	// CompactionPoint retorna até onde o log pode ser compactado sem
+	// comprometer a recuperação a partir dos estados retidos.
+	CompactionPoint() (time.Time, error)
+	// LastSaved retorna o horário do último estado salvo ou carregado.
+	LastSaved() time.Time
+	// Generations retorna quantos estados salvos estão retidos.
+	Generations() int
+}
+
+// LogStore registra as operações e as devolve na recuperação.
+type LogStore interface {
+	// Write registra a entrada com o próximo LSN e só retorna após ela estar
+	// persistida. Em caso de erro, a entrada não foi registrada, a menos que
+	// o erro contenha utils.ErrLogUncertain.
+	Write(entry utils.LogEntry) error
+	// Recover retorna as entradas posteriores a since e garante que os
+	// próximos LSNs sejam maiores que snapshotLSN e que os do log.
+	Recover(since time.Time, snapshotLSN uint64) ([]utils.LogEntry, error)
+	// LastLSN retorna o LSN da última entrada registrada ou recuperada.
+	LastLSN() uint64
+	// Compact descarta as entradas até coveredUntil e as leituras.
+	Compact(coveredUntil time.Time) (utils.CompactionResult, error)
+	// Size retorna o tamanho do log em bytes.
+	Size() int64
+}
+
+// Open cria os backends escolhidos na configuração.
+func Open(cfg config.StorageConfig) (Snapshotter, LogStore, error) {
+	var snapshots Snapshotter
+	switch backend := cfg.SnapshotsBackend(); backend {
+	case config.StorageFile:
+		snapshots = FileSnapshotter{}
+	case config.StorageMemory:
+		snapshots = NewMemorySnapshotter()
+	case config.StorageKV:
+		kvSnapshots, err := OpenKVSnapshotter(cfg.KVPath())
+		if err != nil {
+			return nil, nil, err
+		}
+		snapshots = kvSnapshots
+	default:
+		return nil, nil, fmt.Errorf("backend de snapshots %q desconhecido", backend)
+	}
+
+	var logs LogStore
+	switch backend := cfg.LogBackend(); backend {
+	case config.StorageFile:
+		logs = FileLogStore{}
+	case config.StorageMemory:
+		logs = NewMemoryLogStore()
+	default:
+		return nil, nil, fmt.Errorf("backend de log %q desconhecido", backend)
+	}
+	return snapshots, logs, nil
+}
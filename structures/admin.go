@@ -0,0 +1,71 @@
+package structures
+
+import "time"
+
+// --- Tipos do serviço Admin ---
+
+// AdminArgs é o argumento dos métodos do Admin que não recebem parâmetros.
+type AdminArgs struct{}
+
+// SnapshotReply descreve um snapshot salvo por ForceSnapshot.
+type SnapshotReply struct {
+	Path         string        // Arquivo do snapshot.
+	CoveredUntil time.Time     // Timestamp da última operação do log coberta pelo snapshot.
+	LastLSN      uint64        // LSN do log no momento do snapshot.
+	Duration     time.Duration // Tempo gasto para salvar.
+	Delta        bool          // Snapshot incremental, com as listas alteradas desde o anterior.
+	Skipped      bool          // O estado não mudou desde o snapshot anterior, que foi mantido.
+}
+
+// CompactLogReply descreve o resultado de CompactLog.
+type CompactLogReply struct {
+	Snapshot     SnapshotReply // Snapshot salvo antes da compactação.
+	EntriesKept  int           // Entradas mantidas no log.
+	EntriesFreed int           // Entradas descartadas (cobertas pelo snapshot ou somente leitura).
+	BytesBefore  int64         // Tamanho do log antes.
+	BytesAfter   int64         // Tamanho do log depois.
+}
+
+// NamespaceUsage é o uso de um namespace.
+type NamespaceUsage struct {
+	Lists    int // Quantidade de listas.
+	Elements int // Total de elementos.
+}
+
+// StatsReply traz o estado geral do servidor.
+type StatsReply struct {
+	ServerID     string                    // Identificador do servidor.
+	Uptime       time.Duration             // Tempo desde o início do processo.
+	Lists        int                       // Total de listas.
+	Elements     int                       // Total de elementos.
+	Namespaces   map[string]NamespaceUsage // Uso por namespace.
+	HeapBytes    uint64                    // Memória em uso no heap.
+	SysBytes     uint64                    // Memória obtida do sistema operacional.
+	Goroutines   int                       // Goroutines em execução.
+	LastLSN      uint64                    // LSN da última entrada do log.
+	LogBytes     int64                     // Tamanho do arquivo de log.
+	LastSnapshot time.Time                 // Horário do último snapshot (zero se nenhum).
+	Snapshots    int                       // Gerações de snapshot retidas.
+	SnapshotAge  time.Duration             // Tempo desde o último snapshot.
+	LogLevel     string                    // Nível de log atual.
+}
+
+// ClientInfo descreve uma conexão de cliente aberta.
+type ClientInfo struct {
+	Client      string    // Nome do cliente (conforme o token no momento da consulta).
+	Namespace   string    // Namespace do cliente.
+	RemoteAddr  string    // Endereço de origem.
+	Protocol    string    // "rpc" ou "resp".
+	ConnectedAt time.Time // Horário da conexão.
+	Calls       uint64    // Chamadas feitas na conexão.
+}
+
+// SetLogLevelArgs para o método SetLogLevel.
+type SetLogLevelArgs struct {
+	Level string // "debug", "info", "warn" ou "error".
+}
+
+// ReloadConfigReply descreve a configuração recarregada.
+type ReloadConfigReply struct {
+	Path string // Arquivo relido.
+}
@@ -0,0 +1,357 @@
+package structures
+
+import (
+	"math"
+	"math/bits"
+	"path"
+	"sort"
+)
+
+// MaxHistogramBounds é a quantidade máxima de limites de um Histogram.
+const MaxHistogramBounds = 1000
+
+// AggregateArgs para os métodos Sum, Min, Max e Avg. Sem Bounded, a lista
+// inteira é considerada; com Bounded, só os elementos entre Start e Stop
+// (inclusivos, com índices negativos e ajustes como em RangeArgs).
+type AggregateArgs struct {
+	Namespace string
+	ListID    string
+	Bounded   bool
+	Start     int
+	Stop      int
+}
+
+// CountArgs para o método Count: conta os elementos v (da lista ou do
+// intervalo, como em AggregateArgs) para os quais "v Op Value" é verdadeiro.
+// Op é "<", "<=", ">", ">=", "==" ou "!="; vazio conta todos.
+type CountArgs struct {
+	Namespace string
+	ListID    string
+	Bounded   bool
+	Start     int
+	Stop      int
+	Op        string
+	Value     int
+}
+
+// HistogramArgs para o método Histogram. Bounds, em ordem estritamente
+// crescente, divide os valores em len(Bounds)+1 faixas: a faixa i conta os
+// elementos v com Bounds[i-1] <= v < Bounds[i] (a primeira, v < Bounds[0]; a
+// última, v >= Bounds[len(Bounds)-1]).
+type HistogramArgs struct {
+	Namespace string
+	ListID    string
+	Bounded   bool
+	Start     int
+	Stop      int
+	Bounds    []int
+}
+
+// --- Agregações incrementais ---
+
+// wideInt é um inteiro de 128 bits com sinal, para somar elementos sem estouro.
+type wideInt struct {
+	hi int64
+	lo uint64
+}
+
+func (w wideInt) add(v int) wideInt {
+	lo, carry := bits.Add64(w.lo, uint64(v), 0)
+	return wideInt{hi: w.hi + int64(v)>>63 + int64(carry), lo: lo}
+}
+
+func (w wideInt) sub(o wideInt) wideInt {
+	lo, borrow := bits.Sub64(w.lo, o.lo, 0)
+	return wideInt{hi: w.hi - o.hi - int64(borrow), lo: lo}
+}
+
+// fitsInt informa se o valor cabe em um int de 64 bits.
+func (w wideInt) fitsInt() bool {
+	return w.hi == int64(w.lo)>>63
+}
+
+func (w wideInt) float() float64 {
+	return math.Ldexp(float64(w.hi), 64) + float64(w.lo)
+}
+
+// prefixAggregates guarda, para cada posição i de uma lista, a soma, o
+// mínimo e o máximo dos elementos 0..i. Como as listas só mudam no fim,
+// Append e Remove os atualizam em O(1); com eles, Sum e Avg de qualquer
+// intervalo, e Min e Max dos que começam no índice 0 (inclusive a lista
+// inteira), são respondidos sem percorrer os elementos. Ocupam cerca de
+// quatro vezes a memória dos elementos.
+type prefixAggregates struct {
+	sums []wideInt
+	mins []int
+	maxs []int
+}
+
+func newPrefixAggregates(elements []int) *prefixAggregates {
+	p := &prefixAggregates{
+		sums: make([]wideInt, 0, len(elements)),
+		mins: make([]int, 0, len(elements)),
+		maxs: make([]int, 0, len(elements)),
+	}
+	for _, v := range elements {
+		p.push(v)
+	}
+	return p
+}
+
+func (p *prefixAggregates) push(v int) {
+	sum, lowest, highest := wideInt{}.add(v), v, v
+	if n := len(p.sums); n > 0 {
+		sum = p.sums[n-1].add(v)
+		lowest, highest = min(p.mins[n-1], v), max(p.maxs[n-1], v)
+	}
+	p.sums = append(p.sums, sum)
+	p.mins = append(p.mins, lowest)
+	p.maxs = append(p.maxs, highest)
+}
+
+func (p *prefixAggregates) pop() {
+	n := len(p.sums) - 1
+	p.sums, p.mins, p.maxs = p.sums[:n], p.mins[:n], p.maxs[:n]
+}
+
+// SetIncrementalAggregates define as listas (padrões de path.Match sobre o
+// ID, em qualquer namespace) cujas somas e extremos são mantidos a cada
+// alteração, para responder Sum, Avg, Min e Max sem percorrer os elementos.
+// As listas que passam a casar são indexadas na hora; as que deixam de
+// casar, descartadas.
+func (rl *RemoteList) SetIncrementalAggregates(patterns []string) {
+	rl.Mu.Lock()
+	defer rl.Mu.Unlock()
+
+	rl.incremental = append([]string(nil), patterns...)
+	for _, ns := range rl.Namespaces {
+		for listID, specificList := range ns.Lists {
+			specificList.mu.Lock()
+			rl.trackAggregates(listID, specificList)
+			specificList.mu.Unlock()
+		}
+	}
+}
+
+// trackAggregates cria ou descarta as agregações incrementais da lista,
+// conforme rl.incremental. Deve ser chamada com rl.Mu adquirido e com o
+// mutex da lista (ou antes de ela ser publicada).
+func (rl *RemoteList) trackAggregates(listID string, specificList *SpecificList) {
+	tracked := matchesAny(rl.incremental, listID)
+	switch {
+	case tracked && specificList.aggregates == nil:
+		specificList.aggregates = newPrefixAggregates(specificList.Elements)
+	case !tracked:
+		specificList.aggregates = nil
+	}
+}
+
+// matchesAny informa se o ID casa com algum dos padrões de path.Match.
+func matchesAny(patterns []string, listID string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, listID); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// track cria ou descarta as agregações incrementais e o índice de valores da
+// lista, conforme a configuração, nas mesmas condições de trackAggregates.
+func (rl *RemoteList) track(listID string, specificList *SpecificList) {
+	rl.trackAggregates(listID, specificList)
+	rl.trackIndex(listID, specificList)
+}
+
+// --- Consultas ---
+
+// span converte Start e Stop inclusivos (com índices negativos contados a
+// partir do fim) no intervalo [from, to) dos elementos, ajustado aos limites
+// como no LRANGE do Redis. from >= to indica intervalo vazio.
+func span(start, stop, size int) (from, to int) {
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+// aggregate executa fn com o mutex da lista adquirido, sobre o intervalo
+// [from, to) dos elementos escolhido por bounded, start e stop. Os elementos
+// são lidos no lugar, sem cópia.
+func (rl *RemoteList) aggregate(namespace, listID string, bounded bool, start, stop int, fn func(list *SpecificList, from, to int) error) error {
+	rl.Mu.RLock()
+	_, specificList := rl.lookup(namespace, listID)
+	rl.Mu.RUnlock()
+
+	if specificList == nil {
+		return NewError(CodeNotFound, "lista com ID '%s' não encontrada", listID)
+	}
+
+	specificList.mu.Lock()
+	defer specificList.mu.Unlock()
+
+	from, to := 0, len(specificList.Elements)
+	if bounded {
+		from, to = span(start, stop, to)
+	}
+	return fn(specificList, from, to)
+}
+
+// emptyError é o erro de Min, Max e Avg sem elementos.
+func emptyError(args AggregateArgs) error {
+	if args.Bounded {
+		return NewError(CodeEmpty, "intervalo %d a %d da lista ID '%s' não tem elementos", args.Start, args.Stop, args.ListID)
+	}
+	return NewError(CodeEmpty, "lista com ID '%s' está vazia", args.ListID)
+}
+
+// sum soma os elementos em [from, to).
+func (sl *SpecificList) sum(from, to int) wideInt {
+	if sl.aggregates != nil {
+		if from >= to {
+			return wideInt{}
+		}
+		total := sl.aggregates.sums[to-1]
+		if from > 0 {
+			total = total.sub(sl.aggregates.sums[from-1])
+		}
+		return total
+	}
+	var total wideInt
+	for _, v := range sl.Elements[from:to] {
+		total = total.add(v)
+	}
+	return total
+}
+
+// Sum retorna a soma dos elementos. Retorna OUT_OF_RANGE se a soma não couber
+// em um inteiro de 64 bits (Avg continua disponível).
+func (rl *RemoteList) Sum(args AggregateArgs, reply *int) error {
+	return rl.aggregate(args.Namespace, args.ListID, args.Bounded, args.Start, args.Stop, func(list *SpecificList, from, to int) error {
+		total := list.sum(from, to)
+		if !total.fitsInt() {
+			return NewError(CodeOutOfRange, "a soma dos elementos da lista ID '%s' não cabe em 64 bits", args.ListID)
+		}
+		*reply = int(total.lo)
+		return nil
+	})
+}
+
+// Avg retorna a média dos elementos. Retorna EMPTY se não houver elementos.
+func (rl *RemoteList) Avg(args AggregateArgs, reply *float64) error {
+	return rl.aggregate(args.Namespace, args.ListID, args.Bounded, args.Start, args.Stop, func(list *SpecificList, from, to int) error {
+		if from >= to {
+			return emptyError(args)
+		}
+		*reply = list.sum(from, to).float() / float64(to-from)
+		return nil
+	})
+}
+
+// Min retorna o menor elemento. Retorna EMPTY se não houver elementos.
+func (rl *RemoteList) Min(args AggregateArgs, reply *int) error {
+	return rl.aggregate(args.Namespace, args.ListID, args.Bounded, args.Start, args.Stop, func(list *SpecificList, from, to int) error {
+		if from >= to {
+			return emptyError(args)
+		}
+		if list.aggregates != nil && from == 0 {
+			*reply = list.aggregates.mins[to-1]
+			return nil
+		}
+		lowest := list.Elements[from]
+		for _, v := range list.Elements[from+1 : to] {
+			lowest = min(lowest, v)
+		}
+		*reply = lowest
+		return nil
+	})
+}
+
+// Max retorna o maior elemento. Retorna EMPTY se não houver elementos.
+func (rl *RemoteList) Max(args AggregateArgs, reply *int) error {
+	return rl.aggregate(args.Namespace, args.ListID, args.Bounded, args.Start, args.Stop, func(list *SpecificList, from, to int) error {
+		if from >= to {
+			return emptyError(args)
+		}
+		if list.aggregates != nil && from == 0 {
+			*reply = list.aggregates.maxs[to-1]
+			return nil
+		}
+		highest := list.Elements[from]
+		for _, v := range list.Elements[from+1 : to] {
+			highest = max(highest, v)
+		}
+		*reply = highest
+		return nil
+	})
+}
+
+// predicates são os operadores aceitos por Count.
+var predicates = map[string]func(v, value int) bool{
+	"<":  func(v, value int) bool { return v < value },
+	"<=": func(v, value int) bool { return v <= value },
+	">":  func(v, value int) bool { return v > value },
+	">=": func(v, value int) bool { return v >= value },
+	"==": func(v, value int) bool { return v == value },
+	"!=": func(v, value int) bool { return v != value },
+}
+
+// Count retorna a quantidade de elementos que satisfazem o predicado.
+func (rl *RemoteList) Count(args CountArgs, reply *int) error {
+	matches, ok := predicates[args.Op]
+	if !ok && args.Op != "" {
+		return NewError(CodeInvalidArgument, "operador '%s' inválido (use <, <=, >, >=, == ou !=)", args.Op)
+	}
+	return rl.aggregate(args.Namespace, args.ListID, args.Bounded, args.Start, args.Stop, func(list *SpecificList, from, to int) error {
+		if from >= to {
+			*reply = 0
+			return nil
+		}
+		if matches == nil {
+			*reply = to - from
+			return nil
+		}
+		count := 0
+		for _, v := range list.Elements[from:to] {
+			if matches(v, args.Value) {
+				count++
+			}
+		}
+		*reply = count
+		return nil
+	})
+}
+
+// Histogram retorna a quantidade de elementos em cada faixa definida por Bounds.
+func (rl *RemoteList) Histogram(args HistogramArgs, reply *[]int) error {
+	if len(args.Bounds) > MaxHistogramBounds {
+		return NewError(CodeInvalidArgument, "histograma com %d limites; o máximo é %d", len(args.Bounds), MaxHistogramBounds)
+	}
+	for i := 1; i < len(args.Bounds); i++ {
+		if args.Bounds[i] <= args.Bounds[i-1] {
+			return NewError(CodeInvalidArgument, "limites do histograma devem estar em ordem estritamente crescente")
+		}
+	}
+	return rl.aggregate(args.Namespace, args.ListID, args.Bounded, args.Start, args.Stop, func(list *SpecificList, from, to int) error {
+		counts := make([]int, len(args.Bounds)+1)
+		if from < to {
+			for _, v := range list.Elements[from:to] {
+				counts[sort.Search(len(args.Bounds), func(i int) bool { return args.Bounds[i] > v })]++
+			}
+		}
+		*reply = counts
+		return nil
+	})
+}
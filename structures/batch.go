@@ -0,0 +1,61 @@
+package structures
+
+import "time"
+
+// MaxBatchOps é a quantidade máxima de operações em um Batch.
+const MaxBatchOps = 1000
+
+// BatchOp é uma operação de um Batch. Method é o nome do método RPC (Append,
+// Get, GetAt, Remove, Size, Range, Delete, Keys, Sum, Min, Max, Avg, Count,
+// Histogram, IndexOf, LastIndexOf, Contains ou FindAll); os demais campos são
+// os argumentos dele, com os mesmos nomes de AppendArgs, GetArgs, CountArgs etc.
+type BatchOp struct {
+	Method  string
+	ListID  string
+	Value   int
+	Index   int
+	Bounded bool
+	Start   int
+	Stop    int
+	AsOf    time.Time
+	Pattern string
+	Op      string
+	Bounds  []int
+}
+
+// BatchArgs para o método Batch. As operações são executadas em ordem, cada
+// uma como uma chamada separada (o lote não é atômico); com StopOnError, as
+// seguintes à primeira com erro não são executadas.
+type BatchArgs struct {
+	Ops         []BatchOp
+	StopOnError bool
+}
+
+// BatchResult é o resultado de uma operação do lote. Só o campo
+// correspondente ao método é preenchido: Value (Get, GetAt, Remove, Size,
+// Sum, Min, Max, Count, IndexOf, LastIndexOf), Values (Range, Histogram,
+// FindAll), Mean (Avg), Found (Contains) ou Keys (Keys).
+type BatchResult struct {
+	Value  int
+	Values []int
+	Mean   float64
+	Found  bool
+	Keys   []string
+	Error  *Error // Erro da operação; nil em caso de sucesso.
+}
+
+// BatchReply traz um resultado por operação executada, na ordem do lote.
+// Com StopOnError, pode ter menos resultados que operações.
+type BatchReply struct {
+	Results []BatchResult
+}
+
+// ReadOnly informa se o método só lê as listas (pode ser repetido sem efeitos).
+func (op BatchOp) ReadOnly() bool {
+	switch op.Method {
+	case "Get", "GetAt", "Size", "Range", "Keys", "Sum", "Min", "Max", "Avg", "Count", "Histogram",
+		"IndexOf", "LastIndexOf", "Contains", "FindAll":
+		return true
+	}
+	return false
+}
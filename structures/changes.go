@@ -0,0 +1,71 @@
+package structures
+
+import "sort"
+
+// Rastreamento das listas alteradas, para snapshots incrementais: cada lista
+// guarda a versão do RemoteList em sua última alteração, e as removidas ficam
+// em rl.deleted com a versão da remoção. Comparar essas versões com a versão
+// do último snapshot indica o que mudou desde ele.
+
+// EachChangedList chama fn para cada lista alterada depois da versão since,
+// ordenadas por namespace e ID, com o mutex da lista adquirido. Listas
+// removidas desde então, e não recriadas, são passadas com exists falso e
+// elements nil. elements não deve ser guardado após o retorno de fn.
+func (rl *RemoteList) EachChangedList(since uint64, fn func(namespace, listID string, elements []int, exists bool) error) error {
+	rl.Mu.RLock()
+	defer rl.Mu.RUnlock()
+
+	var keys []listKey
+	for name, ns := range rl.Namespaces {
+		for listID, specificList := range ns.Lists {
+			specificList.mu.Lock()
+			changed := specificList.changed
+			specificList.mu.Unlock()
+			if changed > since {
+				keys = append(keys, listKey{name, listID})
+			}
+		}
+	}
+	for key, version := range rl.deleted {
+		if _, specificList := rl.lookup(key.namespace, key.listID); version > since && specificList == nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		return keys[i].listID < keys[j].listID
+	})
+
+	for _, key := range keys {
+		_, specificList := rl.lookup(key.namespace, key.listID)
+		if specificList == nil {
+			if err := fn(key.namespace, key.listID, nil, false); err != nil {
+				return err
+			}
+			continue
+		}
+		specificList.mu.Lock()
+		err := fn(key.namespace, key.listID, specificList.Elements, true)
+		specificList.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForgetDeleted descarta os registros de listas removidas até a versão upTo.
+// Após um snapshot completo nessa versão, os incrementais seguintes não
+// precisam deles.
+func (rl *RemoteList) ForgetDeleted(upTo uint64) {
+	rl.Mu.Lock()
+	defer rl.Mu.Unlock()
+
+	for key, version := range rl.deleted {
+		if version <= upTo {
+			delete(rl.deleted, key)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package structures
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrorCode identifica o tipo de um erro de forma estável e legível por máquina.
+type ErrorCode string
+
+// Códigos de erro retornados pelo RemoteList e pelos serviços do servidor.
+const (
+	CodeNotFound          ErrorCode = "NOT_FOUND"          // Lista inexistente.
+	CodeEmpty             ErrorCode = "EMPTY"              // Lista sem elementos.
+	CodeOutOfRange        ErrorCode = "OUT_OF_RANGE"       // Índice fora dos limites.
+	CodeInvalidArgument   ErrorCode = "INVALID_ARGUMENT"   // Argumento malformado.
+	CodeConflict          ErrorCode = "CONFLICT"           // Estado incompatível com a operação.
+	CodeUnauthorized      ErrorCode = "UNAUTHORIZED"       // Credencial ausente ou inválida.
+	CodePermissionDenied  ErrorCode = "PERMISSION_DENIED"  // Credencial válida sem permissão.
+	CodeResourceExhausted ErrorCode = "RESOURCE_EXHAUSTED" // Cota ou limite de taxa excedido.
+	CodeUnavailable       ErrorCode = "UNAVAILABLE"        // Servidor indisponível no momento.
+	CodeInternal          ErrorCode = "INTERNAL"           // Falha inesperada no servidor.
+)
+
+// Error é o erro tipado do sistema. O net/rpc transporta erros apenas como
+// texto, por isso Error() usa o formato "CODIGO: mensagem", que ParseError
+// reconstrói do lado do cliente. As tags JSON permitem serializá-lo diretamente.
+type Error struct {
+	Code       ErrorCode     `json:"code"`                  // Código do erro.
+	Message    string        `json:"message"`               // Mensagem legível (em português).
+	RetryAfter time.Duration `json:"retry_after,omitempty"` // Espera sugerida antes de tentar de novo (limites e sobrecarga).
+}
+
+// Erros sentinela para comparação com errors.Is. Só o código é comparado.
+var (
+	ErrNotFound          = &Error{Code: CodeNotFound}
+	ErrEmpty             = &Error{Code: CodeEmpty}
+	ErrOutOfRange        = &Error{Code: CodeOutOfRange}
+	ErrInvalidArgument   = &Error{Code: CodeInvalidArgument}
+	ErrConflict          = &Error{Code: CodeConflict}
+	ErrUnauthorized      = &Error{Code: CodeUnauthorized}
+	ErrPermissionDenied  = &Error{Code: CodePermissionDenied}
+	ErrResourceExhausted = &Error{Code: CodeResourceExhausted}
+	ErrUnavailable       = &Error{Code: CodeUnavailable}
+	ErrInternal          = &Error{Code: CodeInternal}
+)
+
+// knownCodes é usado por ParseError para validar o prefixo da mensagem.
+var knownCodes = map[ErrorCode]bool{
+	CodeNotFound: true, CodeEmpty: true, CodeOutOfRange: true, CodeInvalidArgument: true,
+	CodeConflict: true, CodeUnauthorized: true, CodePermissionDenied: true,
+	CodeResourceExhausted: true, CodeUnavailable: true, CodeInternal: true,
+}
+
+// NewError cria um erro tipado com mensagem formatada.
+func NewError(code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// retryAfterPrefix marca, no texto do erro, a espera sugerida: "CODIGO: mensagem (retry-after: 250ms)".
+const retryAfterPrefix = " (retry-after: "
+
+// WithRetryAfter define a espera sugerida antes de uma nova tentativa,
+// arredondada para cima em milissegundos, e retorna o próprio erro.
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+	if rounded := d.Truncate(time.Millisecond); rounded < d {
+		d = rounded + time.Millisecond
+	}
+	e.RetryAfter = d
+	return e
+}
+
+// Error implementa a interface error no formato de transporte "CODIGO: mensagem",
+// ou só "CODIGO" sem mensagem. A espera sugerida vai no fim, nos dois casos.
+func (e *Error) Error() string {
+	s := string(e.Code)
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	if e.RetryAfter > 0 {
+		s += retryAfterPrefix + e.RetryAfter.String() + ")"
+	}
+	return s
+}
+
+// Is permite errors.Is(err, structures.ErrNotFound) comparando apenas o código.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// ParseError reconstrói um *Error a partir do texto produzido por Error().
+// Retorna nil se o texto não começar com um código conhecido.
+func ParseError(s string) *Error {
+	// A espera sai primeiro: sem mensagem, ela vem logo após o código.
+	var retryAfter time.Duration
+	if i := strings.LastIndex(s, retryAfterPrefix); i >= 0 && strings.HasSuffix(s, ")") {
+		if d, err := time.ParseDuration(s[i+len(retryAfterPrefix) : len(s)-1]); err == nil {
+			s, retryAfter = s[:i], d
+		}
+	}
+	code, message, _ := strings.Cut(s, ": ")
+	if !knownCodes[ErrorCode(code)] {
+		return nil
+	}
+	return &Error{Code: ErrorCode(code), Message: message, RetryAfter: retryAfter}
+}
@@ -0,0 +1,34 @@
+package structures
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestParseErrorRoundTrip confere que ParseError reconstrói o que Error()
+// produz, inclusive a espera sugerida de erros sem mensagem.
+func TestParseErrorRoundTrip(t *testing.T) {
+	tests := []*Error{
+		NewError(CodeNotFound, "lista '%s' não encontrada", "a"),
+		NewError(CodeNotFound, ""),
+		NewError(CodeInvalidArgument, "valor: %d", 3),
+		NewError(CodeResourceExhausted, "limite excedido").WithRetryAfter(120 * time.Millisecond),
+		NewError(CodeResourceExhausted, "").WithRetryAfter(5 * time.Millisecond),
+		NewError(CodeUnavailable, "").WithRetryAfter(1500 * time.Microsecond),
+	}
+	for _, want := range tests {
+		got := ParseError(want.Error())
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseError(%q) = %#v, esperado %#v", want.Error(), got, want)
+		}
+	}
+}
+
+func TestParseErrorUnknown(t *testing.T) {
+	for _, s := range []string{"", "falha qualquer", "OUTRO: mensagem", "OUTRO (retry-after: 5ms)"} {
+		if got := ParseError(s); got != nil {
+			t.Errorf("ParseError(%q) = %#v, esperado nil", s, got)
+		}
+	}
+}
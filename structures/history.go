@@ -0,0 +1,172 @@
+package structures
+
+import (
+	"sync"
+	"time"
+)
+
+// changeKind identifica o tipo de alteração guardada no histórico.
+type changeKind int
+
+const (
+	changeCreate changeKind = iota // Lista criada (pelo primeiro Append).
+	changeAppend                   // Valor adicionado ao final.
+	changeRemove                   // Último valor removido.
+	changeDelete                   // Lista inteira removida.
+)
+
+// change é uma alteração de uma lista, com o necessário para desfazê-la.
+type change struct {
+	at       time.Time
+	kind     changeKind
+	value    int   // Valor removido (changeRemove).
+	elements []int // Elementos da lista removida (changeDelete).
+}
+
+// listKey identifica uma lista no histórico.
+type listKey struct {
+	namespace string
+	listID    string
+}
+
+// history guarda, pelo tempo de retenção, as alterações de cada lista. GetAt
+// parte do estado atual e desfaz as alterações posteriores ao instante pedido.
+type history struct {
+	mu        sync.Mutex
+	retention time.Duration // Zero desativa o histórico.
+	since     time.Time     // Início do registro (quando o histórico foi ativado).
+	lists     map[listKey][]change
+}
+
+// SetHistoryRetention define por quanto tempo as alterações das listas ficam
+// disponíveis para GetAt. Zero desativa e descarta o histórico; ao ativar, o
+// histórico começa vazio, a partir do momento da chamada.
+func (rl *RemoteList) SetHistoryRetention(retention time.Duration) {
+	h := &rl.history
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if retention <= 0 {
+		h.retention, h.lists = 0, nil
+		return
+	}
+	if h.retention == 0 {
+		h.since = time.Now()
+		h.lists = make(map[listKey][]change)
+	}
+	h.retention = retention
+}
+
+// record guarda uma alteração da lista. Deve ser chamada na mesma seção
+// crítica da alteração: com o mutex da lista (Append e Remove) ou com rl.Mu
+// adquirido para escrita (criação e Delete).
+func (rl *RemoteList) record(namespace, listID string, c change) {
+	h := &rl.history
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.retention == 0 {
+		return
+	}
+	c.at = time.Now()
+	key := listKey{NamespaceName(namespace), listID}
+	h.lists[key] = append(expire(h.lists[key], c.at.Add(-h.retention)), c)
+}
+
+// PruneHistory descarta as alterações mais antigas que o tempo de retenção.
+func (rl *RemoteList) PruneHistory() {
+	h := &rl.history
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cutoff := time.Now().Add(-h.retention)
+	for key, changes := range h.lists {
+		if changes = expire(changes, cutoff); len(changes) == 0 {
+			delete(h.lists, key)
+		} else {
+			h.lists[key] = changes
+		}
+	}
+}
+
+// expire remove do início as alterações anteriores a cutoff.
+func expire(changes []change, cutoff time.Time) []change {
+	i := 0
+	for i < len(changes) && changes[i].at.Before(cutoff) {
+		i++
+	}
+	return changes[i:]
+}
+
+// GetAtArgs para o método GetAt.
+type GetAtArgs struct {
+	Namespace string
+	ListID    string
+	Index     int
+	AsOf      time.Time // Instante da leitura.
+}
+
+// GetAt retorna o valor que estava na posição da lista no instante AsOf.
+// Retorna UNAVAILABLE se o histórico estiver desativado e OUT_OF_RANGE se o
+// instante for anterior ao histórico retido.
+func (rl *RemoteList) GetAt(args GetAtArgs, reply *int) error {
+	// rl.Mu para leitura impede Deletes e criações durante a consulta, e o
+	// mutex da lista, Appends e Removes.
+	rl.Mu.RLock()
+	defer rl.Mu.RUnlock()
+
+	_, specificList := rl.lookup(args.Namespace, args.ListID)
+	exists := specificList != nil
+	var elements []int
+	if exists {
+		specificList.mu.Lock()
+		defer specificList.mu.Unlock()
+		elements = append(elements, specificList.Elements...)
+	}
+
+	h := &rl.history
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.retention == 0 {
+		return NewError(CodeUnavailable, "histórico de versões desativado no servidor")
+	}
+	if from := h.availableFrom(time.Now()); args.AsOf.Before(from) {
+		return NewError(CodeOutOfRange, "instante %s anterior ao histórico retido (disponível a partir de %s)",
+			args.AsOf.Format(time.RFC3339Nano), from.Format(time.RFC3339Nano))
+	}
+
+	// Desfaz, da mais recente para a mais antiga, as alterações posteriores a AsOf.
+	changes := h.lists[listKey{NamespaceName(args.Namespace), args.ListID}]
+	for i := len(changes) - 1; i >= 0 && changes[i].at.After(args.AsOf); i-- {
+		switch c := changes[i]; c.kind {
+		case changeCreate:
+			exists, elements = false, nil
+		case changeAppend:
+			elements = elements[:len(elements)-1]
+		case changeRemove:
+			elements = append(elements, c.value)
+		case changeDelete:
+			exists, elements = true, append([]int(nil), c.elements...)
+		}
+	}
+
+	if !exists {
+		return NewError(CodeNotFound, "lista com ID '%s' não encontrada em %s", args.ListID, args.AsOf.Format(time.RFC3339Nano))
+	}
+	if args.Index < 0 || args.Index >= len(elements) {
+		return NewError(CodeOutOfRange, "índice %d fora dos limites para a lista ID '%s' em %s (tamanho %d)",
+			args.Index, args.ListID, args.AsOf.Format(time.RFC3339Nano), len(elements))
+	}
+	*reply = elements[args.Index]
+	return nil
+}
+
+// availableFrom retorna o instante mais antigo que o histórico cobre por
+// completo. Deve ser chamada com h.mu adquirido.
+func (h *history) availableFrom(now time.Time) time.Time {
+	if cutoff := now.Add(-h.retention); cutoff.After(h.since) {
+		return cutoff
+	}
+	return h.since
+}
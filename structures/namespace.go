@@ -0,0 +1,147 @@
+package structures
+
+import (
+	"sync/atomic"
+)
+
+// DefaultNamespace é o namespace de clientes sem namespace próprio
+// (inclusive todos os clientes com a autenticação desativada).
+const DefaultNamespace = "default"
+
+// Namespace agrupa as listas de um inquilino (tenant). IDs de listas são
+// únicos apenas dentro do namespace.
+type Namespace struct {
+	Lists    map[string]*SpecificList // Mapa de IDs de listas para listas específicas.
+	elements atomic.Int64             // Total de elementos nas listas do namespace.
+}
+
+// Quota limita o uso de um namespace. Zero significa sem limite.
+type Quota struct {
+	MaxLists    int // Quantidade máxima de listas.
+	MaxElements int // Total máximo de elementos somando todas as listas.
+}
+
+// NamespaceName normaliza o nome do namespace: vazio equivale a DefaultNamespace.
+func NamespaceName(name string) string {
+	if name == "" {
+		return DefaultNamespace
+	}
+	return name
+}
+
+// newNamespace cria um namespace vazio.
+func newNamespace() *Namespace {
+	return &Namespace{Lists: make(map[string]*SpecificList)}
+}
+
+// SetQuotas define a cota padrão e as cotas específicas por namespace.
+// As cotas valem a partir da próxima operação e não removem dados existentes.
+func (rl *RemoteList) SetQuotas(defaultQuota Quota, quotas map[string]Quota) {
+	rl.Mu.Lock()
+	defer rl.Mu.Unlock()
+	rl.defaultQuota, rl.quotas = defaultQuota, quotas
+}
+
+// quota retorna a cota do namespace. Deve ser chamada com rl.Mu adquirido.
+func (rl *RemoteList) quota(name string) Quota {
+	if q, ok := rl.quotas[name]; ok {
+		return q
+	}
+	return rl.defaultQuota
+}
+
+// CheckAppend verifica, sem alterar nada, se um Append caberia nas cotas do
+// namespace. Permite recusar a operação antes de registrá-la no log.
+func (rl *RemoteList) CheckAppend(args AppendArgs) error {
+	return rl.checkAppend(args.Namespace, args.ListID, 1)
+}
+
+// CheckPush verifica, como CheckAppend, se todos os valores do Push caberiam
+// nas cotas do namespace.
+func (rl *RemoteList) CheckPush(args PushArgs) error {
+	if err := validatePush(args); err != nil {
+		return err
+	}
+	return rl.checkAppend(args.Namespace, args.ListID, len(args.Values))
+}
+
+// checkAppend verifica se a lista comportaria mais added elementos.
+func (rl *RemoteList) checkAppend(namespace, listID string, added int) error {
+	rl.Mu.RLock()
+	defer rl.Mu.RUnlock()
+
+	name := NamespaceName(namespace)
+	q := rl.quota(name)
+	ns, specificList := rl.lookup(name, listID)
+	lists, elements := 0, int64(0)
+	if ns != nil {
+		lists, elements = len(ns.Lists), ns.elements.Load()
+	}
+	if specificList == nil && q.MaxLists > 0 && lists >= q.MaxLists {
+		return NewError(CodeResourceExhausted, "namespace '%s' atingiu o limite de %d listas", name, q.MaxLists)
+	}
+	if q.MaxElements > 0 && elements+int64(added) > int64(q.MaxElements) {
+		return NewError(CodeResourceExhausted, "namespace '%s' atingiu o limite de %d elementos", name, q.MaxElements)
+	}
+	return nil
+}
+
+// Restore insere uma lista com os elementos informados, sem aplicar cotas.
+// Usado ao carregar snapshots.
+func (rl *RemoteList) Restore(namespace, listID string, elements []int) {
+	rl.Mu.Lock()
+	defer rl.Mu.Unlock()
+
+	ns := rl.namespace(namespace, true)
+	if old, ok := ns.Lists[listID]; ok {
+		ns.elements.Add(-int64(len(old.Elements)))
+	}
+	restored := NewSpecificList(elements)
+	restored.changed = rl.version.Add(1)
+	rl.track(listID, restored)
+	ns.Lists[listID] = restored
+	ns.elements.Add(int64(len(elements)))
+}
+
+// RestoreDeleted remove a lista, se existir, sem registrá-la no histórico.
+// Usado ao aplicar snapshots incrementais.
+func (rl *RemoteList) RestoreDeleted(namespace, listID string) {
+	rl.Mu.Lock()
+	defer rl.Mu.Unlock()
+
+	name := NamespaceName(namespace)
+	ns, specificList := rl.lookup(name, listID)
+	if specificList == nil {
+		return
+	}
+	delete(ns.Lists, listID)
+	ns.elements.Add(-int64(len(specificList.Elements)))
+	rl.deleted[listKey{name, listID}] = rl.version.Add(1)
+	if len(ns.Lists) == 0 {
+		delete(rl.Namespaces, name)
+	}
+}
+
+// NamespaceStats retorna a quantidade de listas e o total de elementos de um namespace.
+func (rl *RemoteList) NamespaceStats(namespace string) (lists int, elements int) {
+	rl.Mu.RLock()
+	defer rl.Mu.RUnlock()
+
+	ns := rl.namespace(namespace, false)
+	if ns == nil {
+		return 0, 0
+	}
+	return len(ns.Lists), int(ns.elements.Load())
+}
+
+// Usage retorna o uso de cada namespace existente.
+func (rl *RemoteList) Usage() map[string]NamespaceUsage {
+	rl.Mu.RLock()
+	defer rl.Mu.RUnlock()
+
+	usage := make(map[string]NamespaceUsage, len(rl.Namespaces))
+	for name, ns := range rl.Namespaces {
+		usage[name] = NamespaceUsage{Lists: len(ns.Lists), Elements: int(ns.elements.Load())}
+	}
+	return usage
+}
@@ -1,27 +1,44 @@
 package structures
 
 import (
-	"fmt"
+	"path"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
 )
 
-// RemoteList gerencia coleções de listas de inteiros por um ID.
+// RemoteList gerencia coleções de listas de inteiros, agrupadas por namespace e identificadas por um ID.
 type RemoteList struct {
-	Lists map[string]*SpecificList // Mapa de IDs de listas para listas específicas.
-	Mu    sync.RWMutex             `json:"-"` // Mutex para o mapa 'Lists'. Ignorado no JSON.
+	Namespaces map[string]*Namespace // Mapa de nomes de namespaces para suas listas.
+	Mu         sync.RWMutex          `json:"-"` // Mutex para o mapa 'Namespaces' e os mapas de listas. Ignorado no JSON.
+
+	defaultQuota Quota              // Cota de namespaces sem cota própria.
+	quotas       map[string]Quota   // Cotas por namespace.
+	history      history            // Alterações recentes, para GetAt.
+	version      atomic.Uint64      // Incrementado a cada alteração do estado.
+	deleted      map[listKey]uint64 // Versão em que cada lista foi removida, para snapshots incrementais.
+	incremental  []string           // Padrões das listas com agregações incrementais.
+	indexed      []string           // Padrões das listas com índice de valores.
 }
 
 // SpecificList representa uma única lista de valores inteiros.
 type SpecificList struct {
 	Elements []int      // Elementos da lista.
 	mu       sync.Mutex // Mutex para a lista específica.
+	changed  uint64     // Versão do RemoteList na última alteração da lista.
+
+	aggregates *prefixAggregates // Somas e extremos dos prefixos; nil se não forem mantidos.
+	index      valueIndex        // Posições de cada valor; nil se não for mantido.
 }
 
 // NewRemoteList cria uma nova instância de RemoteList.
 func NewRemoteList() *RemoteList {
 	return &RemoteList{
-		Lists: make(map[string]*SpecificList),
-		Mu:    sync.RWMutex{},
+		Namespaces: make(map[string]*Namespace),
+		Mu:         sync.RWMutex{},
+		deleted:    make(map[listKey]uint64),
 	}
 }
 
@@ -33,70 +50,337 @@ func NewSpecificList(elements []int) *SpecificList {
     }
 }
 
-// ensureListExists garante que uma lista exista no dicionário.
-// Retorna a lista (existente ou nova).
-func (rl *RemoteList) ensureListExists(listID string) *SpecificList {
-	if _, ok := rl.Lists[listID]; !ok {
-		rl.Lists[listID] = NewSpecificList(make([]int, 0))
+// namespace retorna o namespace pelo nome, criando-o se create for verdadeiro
+// (o que exige rl.Mu adquirido para escrita). Retorna nil se não existir.
+func (rl *RemoteList) namespace(name string, create bool) *Namespace {
+	name = NamespaceName(name)
+	ns, ok := rl.Namespaces[name]
+	if !ok && create {
+		ns = newNamespace()
+		rl.Namespaces[name] = ns
+	}
+	return ns
+}
+
+// lookup busca uma lista no namespace. Deve ser chamada com rl.Mu adquirido.
+func (rl *RemoteList) lookup(namespace, listID string) (*Namespace, *SpecificList) {
+	ns := rl.namespace(namespace, false)
+	if ns == nil {
+		return nil, nil
+	}
+	return ns, ns.Lists[listID]
+}
+
+// ensureListExists garante que uma lista exista no namespace, respeitando a
+// cota de listas. Deve ser chamada com rl.Mu adquirido para escrita.
+func (rl *RemoteList) ensureListExists(namespace, listID string) error {
+	if err := ValidateListID(listID); err != nil {
+		return err
+	}
+	ns := rl.namespace(namespace, true)
+	if _, ok := ns.Lists[listID]; ok {
+		return nil
+	}
+	name := NamespaceName(namespace)
+	if q := rl.quota(name); q.MaxLists > 0 && len(ns.Lists) >= q.MaxLists {
+		return NewError(CodeResourceExhausted, "namespace '%s' atingiu o limite de %d listas", name, q.MaxLists)
+	}
+	created := NewSpecificList(make([]int, 0))
+	created.changed = rl.version.Add(1)
+	rl.track(listID, created)
+	ns.Lists[listID] = created
+	rl.record(namespace, listID, change{kind: changeCreate})
+	return nil
+}
+
+// ValidateListID recusa IDs de lista vazios ou com espaços ou caracteres de
+// controle. O log separa os campos por espaços e termina com um campo
+// opcional "ns=<namespace>": um ID como "a ns=outro" seria reaplicado na
+// recuperação como a lista "a" de outro namespace.
+func ValidateListID(listID string) error {
+	if listID == "" {
+		return NewError(CodeInvalidArgument, "ID de lista vazio")
+	}
+	for _, r := range listID {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return NewError(CodeInvalidArgument, "ID de lista %q inválido: não pode conter espaços nem caracteres de controle", listID)
+		}
+	}
+	return nil
+}
+
+// Stats retorna a quantidade de listas e o total de elementos em todos os namespaces.
+func (rl *RemoteList) Stats() (lists int, elements int) {
+	rl.Mu.RLock()
+	defer rl.Mu.RUnlock()
+
+	for _, ns := range rl.Namespaces {
+		lists += len(ns.Lists)
+		elements += int(ns.elements.Load())
+	}
+	return lists, elements
+}
+
+// Clone retorna uma cópia das listas, com a mesma versão e o registro de
+// alterações usado pelos snapshots incrementais. Cotas e histórico não são
+// copiados.
+func (rl *RemoteList) Clone() *RemoteList {
+	rl.Mu.RLock()
+	defer rl.Mu.RUnlock()
+
+	clone := NewRemoteList()
+	for name, ns := range rl.Namespaces {
+		copied := newNamespace()
+		for listID, specificList := range ns.Lists {
+			specificList.mu.Lock()
+			copiedList := NewSpecificList(append(make([]int, 0, len(specificList.Elements)), specificList.Elements...))
+			copiedList.changed = specificList.changed
+			specificList.mu.Unlock()
+			copied.Lists[listID] = copiedList
+		}
+		copied.elements.Store(ns.elements.Load())
+		clone.Namespaces[name] = copied
+	}
+	for key, version := range rl.deleted {
+		clone.deleted[key] = version
+	}
+	clone.version.Store(rl.version.Load())
+	return clone
+}
+
+// EachList chama fn para cada lista, ordenadas por namespace e ID, com o
+// mutex da lista adquirido. elements não deve ser guardado após o retorno de
+// fn. Para no primeiro erro retornado por fn.
+func (rl *RemoteList) EachList(fn func(namespace, listID string, elements []int) error) error {
+	rl.Mu.RLock()
+	defer rl.Mu.RUnlock()
+
+	names := make([]string, 0, len(rl.Namespaces))
+	for name := range rl.Namespaces {
+		names = append(names, name)
 	}
-	return rl.Lists[listID]
+	sort.Strings(names)
+	for _, name := range names {
+		ns := rl.Namespaces[name]
+		ids := make([]string, 0, len(ns.Lists))
+		for listID := range ns.Lists {
+			ids = append(ids, listID)
+		}
+		sort.Strings(ids)
+		for _, listID := range ids {
+			specificList := ns.Lists[listID]
+			specificList.mu.Lock()
+			err := fn(name, listID, specificList.Elements)
+			specificList.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Version retorna um contador que muda a cada alteração das listas. Versões
+// iguais indicam que o estado não mudou entre as duas leituras.
+func (rl *RemoteList) Version() uint64 {
+	return rl.version.Load()
 }
 
 // --- Tipos de Argumentos RPC ---
+//
+// O campo Namespace é preenchido pelo servidor com o namespace do cliente
+// autenticado; o valor enviado pelo cliente é ignorado. Vazio equivale a
+// DefaultNamespace.
 
 // AppendArgs para o método Append.
 type AppendArgs struct {
-	ListID string
-	Value  int
+	Namespace string
+	ListID    string
+	Value     int
+}
+
+// MaxPushValues limita os valores de um Push (e de um RPUSH). O Push vira uma
+// única linha do log, que precisa caber na leitura da recuperação.
+const MaxPushValues = 10000
+
+// PushArgs para o método Push: Values são adicionados, na ordem, de uma vez.
+type PushArgs struct {
+	Namespace string
+	ListID    string
+	Values    []int
 }
 
 // GetArgs para o método Get.
 type GetArgs struct {
-	ListID string
-	Index  int
+	Namespace string
+	ListID    string
+	Index     int
 }
 
 // RemoveArgs para o método Remove.
 type RemoveArgs struct {
-	ListID string
+	Namespace string
+	ListID    string
 }
 
 // SizeArgs para o método Size.
 type SizeArgs struct {
-	ListID string
+	Namespace string
+	ListID    string
+}
+
+// RangeArgs para o método Range. Start e Stop são inclusivos e aceitam
+// índices negativos contados a partir do fim da lista (-1 é o último).
+type RangeArgs struct {
+	Namespace string
+	ListID    string
+	Start     int
+	Stop      int
+}
+
+// DeleteArgs para o método Delete.
+type DeleteArgs struct {
+	Namespace string
+	ListID    string
+}
+
+// KeysArgs para o método Keys. Pattern segue a sintaxe de path.Match;
+// vazio equivale a "*".
+type KeysArgs struct {
+	Namespace string
+	Pattern   string
+}
+
+// PingArgs para o método Ping.
+type PingArgs struct{}
+
+// PingReply traz a identidade e o estado do servidor.
+type PingReply struct {
+	ServerID  string        // Identificador do servidor.
+	StartedAt time.Time     // Horário de início do processo.
+	Uptime    time.Duration // Tempo desde o início do processo.
+	LastLSN   uint64        // LSN da última entrada do log de operações.
+	Ready     bool          // Se a recuperação de snapshot e logs terminou.
 }
 
 // --- Métodos RPC ---
 
-// Append adiciona um valor ao final da lista.
+// Append adiciona um valor ao final da lista, criando-a se necessário.
+// Retorna RESOURCE_EXHAUSTED se o namespace estiver no limite de listas ou elementos.
 func (rl *RemoteList) Append(args AppendArgs, reply *bool) error {
-	rl.Mu.Lock()
-	specificList := rl.ensureListExists(args.ListID)
-	rl.Mu.Unlock()
+	for {
+		// A lista é alterada com rl.Mu adquirido para leitura, para que um
+		// Delete concorrente (que exige escrita) não a descarte no meio da operação.
+		rl.Mu.RLock()
+		ns, specificList := rl.lookup(args.Namespace, args.ListID)
+		if specificList != nil {
+			_, err := rl.appendLocked(args.Namespace, args.ListID, ns, specificList, []int{args.Value})
+			rl.Mu.RUnlock()
+			if err != nil {
+				return err
+			}
+			*reply = true
+			return nil
+		}
+		rl.Mu.RUnlock()
+
+		rl.Mu.Lock()
+		err := rl.ensureListExists(args.Namespace, args.ListID)
+		rl.Mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// appendLocked adiciona os valores, todos ou nenhum, respeitando a cota de
+// elementos do namespace, e retorna o tamanho da lista em seguida. Deve ser
+// chamada com rl.Mu adquirido.
+func (rl *RemoteList) appendLocked(namespace, listID string, ns *Namespace, specificList *SpecificList, values []int) (int, error) {
+	name := NamespaceName(namespace)
+	added := int64(len(values))
+	if q := rl.quota(name); q.MaxElements > 0 {
+		if total := ns.elements.Add(added); total > int64(q.MaxElements) {
+			ns.elements.Add(-added)
+			return 0, NewError(CodeResourceExhausted, "namespace '%s' atingiu o limite de %d elementos", name, q.MaxElements)
+		}
+	} else {
+		ns.elements.Add(added)
+	}
 
 	specificList.mu.Lock()
-	specificList.Elements = append(specificList.Elements, args.Value)
-	specificList.mu.Unlock()
+	defer specificList.mu.Unlock()
+	for _, value := range values {
+		specificList.Elements = append(specificList.Elements, value)
+		if specificList.aggregates != nil {
+			specificList.aggregates.push(value)
+		}
+		if specificList.index != nil {
+			specificList.index.push(value, len(specificList.Elements)-1)
+		}
+		specificList.changed = rl.version.Add(1)
+		rl.record(namespace, listID, change{kind: changeAppend})
+	}
+	return len(specificList.Elements), nil
+}
 
-	*reply = true
+// validatePush recusa um Push sem valores ou com mais de MaxPushValues.
+func validatePush(args PushArgs) error {
+	switch {
+	case len(args.Values) == 0:
+		return NewError(CodeInvalidArgument, "Push sem valores para a lista ID '%s'", args.ListID)
+	case len(args.Values) > MaxPushValues:
+		return NewError(CodeInvalidArgument, "Push com %d valores para a lista ID '%s' (máximo %d)", len(args.Values), args.ListID, MaxPushValues)
+	}
 	return nil
 }
 
+// Push adiciona os valores ao final da lista, criando-a se necessário, e
+// retorna o tamanho dela em seguida. Os valores são adicionados de uma vez:
+// nenhuma outra alteração da lista se intercala entre eles, e, se a cota não
+// comportar todos, nenhum é adicionado.
+func (rl *RemoteList) Push(args PushArgs, reply *int) error {
+	if err := validatePush(args); err != nil {
+		return err
+	}
+	for {
+		rl.Mu.RLock()
+		ns, specificList := rl.lookup(args.Namespace, args.ListID)
+		if specificList != nil {
+			size, err := rl.appendLocked(args.Namespace, args.ListID, ns, specificList, args.Values)
+			rl.Mu.RUnlock()
+			if err != nil {
+				return err
+			}
+			*reply = size
+			return nil
+		}
+		rl.Mu.RUnlock()
+
+		rl.Mu.Lock()
+		err := rl.ensureListExists(args.Namespace, args.ListID)
+		rl.Mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
 // Get retorna um valor em uma posição específica da lista.
 func (rl *RemoteList) Get(args GetArgs, reply *int) error {
 	rl.Mu.RLock()
-	specificList, ok := rl.Lists[args.ListID]
+	_, specificList := rl.lookup(args.Namespace, args.ListID)
 	rl.Mu.RUnlock()
 
-	if !ok {
-		return fmt.Errorf("lista com ID '%s' não encontrada", args.ListID)
+	if specificList == nil {
+		return NewError(CodeNotFound, "lista com ID '%s' não encontrada", args.ListID)
 	}
 
 	specificList.mu.Lock()
 	defer specificList.mu.Unlock()
 
 	if args.Index < 0 || args.Index >= len(specificList.Elements) {
-		return fmt.Errorf("índice %d fora dos limites para a lista ID '%s' (tamanho %d)", args.Index, args.ListID, len(specificList.Elements))
+		return NewError(CodeOutOfRange, "índice %d fora dos limites para a lista ID '%s' (tamanho %d)", args.Index, args.ListID, len(specificList.Elements))
 	}
 
 	*reply = specificList.Elements[args.Index]
@@ -106,34 +390,43 @@ func (rl *RemoteList) Get(args GetArgs, reply *int) error {
 // Remove remove e retorna o último elemento da lista.
 func (rl *RemoteList) Remove(args RemoveArgs, reply *int) error {
 	rl.Mu.RLock()
-	specificList, ok := rl.Lists[args.ListID]
-	rl.Mu.RUnlock()
+	defer rl.Mu.RUnlock()
 
-	if !ok {
-		return fmt.Errorf("lista com ID '%s' não encontrada", args.ListID)
+	ns, specificList := rl.lookup(args.Namespace, args.ListID)
+	if specificList == nil {
+		return NewError(CodeNotFound, "lista com ID '%s' não encontrada", args.ListID)
 	}
 
 	specificList.mu.Lock()
 	defer specificList.mu.Unlock()
 
 	if len(specificList.Elements) == 0 {
-		return fmt.Errorf("lista com ID '%s' está vazia", args.ListID)
+		return NewError(CodeEmpty, "lista com ID '%s' está vazia", args.ListID)
 	}
 
 	lastIndex := len(specificList.Elements) - 1
 	*reply = specificList.Elements[lastIndex]
 	specificList.Elements = specificList.Elements[:lastIndex]
+	if specificList.aggregates != nil {
+		specificList.aggregates.pop()
+	}
+	if specificList.index != nil {
+		specificList.index.pop(*reply)
+	}
+	ns.elements.Add(-1)
+	specificList.changed = rl.version.Add(1)
+	rl.record(args.Namespace, args.ListID, change{kind: changeRemove, value: *reply})
 	return nil
 }
 
 // Size obtém a quantidade de elementos na lista.
 func (rl *RemoteList) Size(args SizeArgs, reply *int) error {
 	rl.Mu.RLock()
-	specificList, ok := rl.Lists[args.ListID]
+	_, specificList := rl.lookup(args.Namespace, args.ListID)
 	rl.Mu.RUnlock()
 
-	if !ok {
-		return fmt.Errorf("lista com ID '%s' não encontrada", args.ListID)
+	if specificList == nil {
+		return NewError(CodeNotFound, "lista com ID '%s' não encontrada", args.ListID)
 	}
 
 	specificList.mu.Lock()
@@ -141,4 +434,76 @@ func (rl *RemoteList) Size(args SizeArgs, reply *int) error {
 
 	*reply = len(specificList.Elements)
 	return nil
-}
\ No newline at end of file
+}
+
+// Range retorna uma cópia dos elementos entre Start e Stop (inclusivos).
+// Índices fora dos limites são ajustados, como no LRANGE do Redis.
+func (rl *RemoteList) Range(args RangeArgs, reply *[]int) error {
+	rl.Mu.RLock()
+	_, specificList := rl.lookup(args.Namespace, args.ListID)
+	rl.Mu.RUnlock()
+
+	if specificList == nil {
+		return NewError(CodeNotFound, "lista com ID '%s' não encontrada", args.ListID)
+	}
+
+	specificList.mu.Lock()
+	defer specificList.mu.Unlock()
+
+	from, to := span(args.Start, args.Stop, len(specificList.Elements))
+	if from >= to {
+		*reply = []int{}
+		return nil
+	}
+
+	*reply = append([]int(nil), specificList.Elements[from:to]...)
+	return nil
+}
+
+// Delete remove a lista inteira do namespace. Namespaces sem listas são descartados.
+func (rl *RemoteList) Delete(args DeleteArgs, reply *bool) error {
+	rl.Mu.Lock()
+	defer rl.Mu.Unlock()
+
+	ns, specificList := rl.lookup(args.Namespace, args.ListID)
+	if specificList == nil {
+		return NewError(CodeNotFound, "lista com ID '%s' não encontrada", args.ListID)
+	}
+
+	delete(ns.Lists, args.ListID)
+	ns.elements.Add(-int64(len(specificList.Elements)))
+	rl.deleted[listKey{NamespaceName(args.Namespace), args.ListID}] = rl.version.Add(1)
+	rl.record(args.Namespace, args.ListID, change{kind: changeDelete, elements: specificList.Elements})
+	if len(ns.Lists) == 0 {
+		delete(rl.Namespaces, NamespaceName(args.Namespace))
+	}
+	*reply = true
+	return nil
+}
+
+// Keys retorna, em ordem alfabética, os IDs das listas do namespace que casam com o padrão.
+func (rl *RemoteList) Keys(args KeysArgs, reply *[]string) error {
+	pattern := args.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return NewError(CodeInvalidArgument, "padrão '%s' inválido: %v", pattern, err)
+	}
+
+	rl.Mu.RLock()
+	defer rl.Mu.RUnlock()
+
+	keys := []string{}
+	if ns := rl.namespace(args.Namespace, false); ns != nil {
+		for listID := range ns.Lists {
+			if matched, _ := path.Match(pattern, listID); matched {
+				keys = append(keys, listID)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	*reply = keys
+	return nil
+}
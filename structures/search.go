@@ -0,0 +1,161 @@
+package structures
+
+// SearchArgs para os métodos IndexOf, LastIndexOf, Contains e FindAll, que
+// procuram Value nos elementos da lista.
+type SearchArgs struct {
+	Namespace string
+	ListID    string
+	Value     int
+}
+
+// --- Índice de valores ---
+
+// valueIndex guarda, para cada valor de uma lista, as posições em que ele
+// aparece, em ordem crescente. Como as listas só mudam no fim, Append e Push
+// acrescentam a posição nova ao fim das posições do valor e Remove retira a
+// última, ambos em O(1); com ele, as buscas não percorrem os elementos. Cada
+// elemento ocupa, além de si, uma posição e a sua parte da entrada do mapa.
+//
+// O índice só acompanha alterações no fim da lista: a API não tem inserção
+// nem remoção em outras posições, e elas ficam fora do seu escopo. Uma
+// operação assim teria de deslocar as posições de todos os elementos
+// seguintes (O(n), como a própria alteração dos elementos) ou descartar o
+// índice e reconstruí-lo com newValueIndex.
+type valueIndex map[int][]int
+
+func newValueIndex(elements []int) valueIndex {
+	index := make(valueIndex)
+	for position, v := range elements {
+		index.push(v, position)
+	}
+	return index
+}
+
+func (index valueIndex) push(v, position int) {
+	index[v] = append(index[v], position)
+}
+
+func (index valueIndex) pop(v int) {
+	positions := index[v]
+	if len(positions) <= 1 {
+		delete(index, v)
+		return
+	}
+	index[v] = positions[:len(positions)-1]
+}
+
+// SetIndexedLists define as listas (padrões de path.Match sobre o ID, em
+// qualquer namespace) com índice de valores, para responder IndexOf,
+// LastIndexOf, Contains e FindAll sem percorrer os elementos. As listas que
+// passam a casar são indexadas na hora; as que deixam de casar, descartadas.
+func (rl *RemoteList) SetIndexedLists(patterns []string) {
+	rl.Mu.Lock()
+	defer rl.Mu.Unlock()
+
+	rl.indexed = append([]string(nil), patterns...)
+	for _, ns := range rl.Namespaces {
+		for listID, specificList := range ns.Lists {
+			specificList.mu.Lock()
+			rl.trackIndex(listID, specificList)
+			specificList.mu.Unlock()
+		}
+	}
+}
+
+// trackIndex cria ou descarta o índice de valores da lista, conforme
+// rl.indexed. Deve ser chamada com rl.Mu adquirido e com o mutex da lista
+// (ou antes de ela ser publicada).
+func (rl *RemoteList) trackIndex(listID string, specificList *SpecificList) {
+	tracked := matchesAny(rl.indexed, listID)
+	switch {
+	case tracked && specificList.index == nil:
+		specificList.index = newValueIndex(specificList.Elements)
+	case !tracked:
+		specificList.index = nil
+	}
+}
+
+// --- Consultas ---
+
+// search executa fn com o mutex da lista adquirido.
+func (rl *RemoteList) search(args SearchArgs, fn func(list *SpecificList) error) error {
+	rl.Mu.RLock()
+	_, specificList := rl.lookup(args.Namespace, args.ListID)
+	rl.Mu.RUnlock()
+
+	if specificList == nil {
+		return NewError(CodeNotFound, "lista com ID '%s' não encontrada", args.ListID)
+	}
+
+	specificList.mu.Lock()
+	defer specificList.mu.Unlock()
+	return fn(specificList)
+}
+
+// positions retorna as posições do valor, em ordem crescente. Sem índice,
+// percorre os elementos; limit > 0 para a busca na limit-ésima posição
+// encontrada (a partir do fim, se reverse for verdadeiro).
+func (sl *SpecificList) positions(v, limit int, reverse bool) []int {
+	if sl.index != nil {
+		return sl.index[v]
+	}
+	var found []int
+	for i := range sl.Elements {
+		position := i
+		if reverse {
+			position = len(sl.Elements) - 1 - i
+		}
+		if sl.Elements[position] != v {
+			continue
+		}
+		found = append(found, position)
+		if len(found) == limit {
+			break
+		}
+	}
+	if reverse {
+		for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+			found[i], found[j] = found[j], found[i]
+		}
+	}
+	return found
+}
+
+// IndexOf retorna a primeira posição do valor na lista, ou -1 se ele não estiver nela.
+func (rl *RemoteList) IndexOf(args SearchArgs, reply *int) error {
+	return rl.search(args, func(list *SpecificList) error {
+		*reply = -1
+		if found := list.positions(args.Value, 1, false); len(found) > 0 {
+			*reply = found[0]
+		}
+		return nil
+	})
+}
+
+// LastIndexOf retorna a última posição do valor na lista, ou -1 se ele não estiver nela.
+func (rl *RemoteList) LastIndexOf(args SearchArgs, reply *int) error {
+	return rl.search(args, func(list *SpecificList) error {
+		*reply = -1
+		if found := list.positions(args.Value, 1, true); len(found) > 0 {
+			*reply = found[len(found)-1]
+		}
+		return nil
+	})
+}
+
+// Contains informa se o valor está na lista.
+func (rl *RemoteList) Contains(args SearchArgs, reply *bool) error {
+	return rl.search(args, func(list *SpecificList) error {
+		*reply = len(list.positions(args.Value, 1, false)) > 0
+		return nil
+	})
+}
+
+// FindAll retorna todas as posições do valor na lista, em ordem crescente
+// (vazio se ele não estiver nela).
+func (rl *RemoteList) FindAll(args SearchArgs, reply *[]int) error {
+	return rl.search(args, func(list *SpecificList) error {
+		*reply = append(make([]int, 0), list.positions(args.Value, 0, false)...)
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package structures
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// TestValueIndexMatchesScan confere que as buscas com índice respondem o
+// mesmo que a busca nos elementos, após Append, Push, Remove e Delete (as
+// únicas alterações das listas, todas no fim) e após o índice ser descartado
+// e reconstruído.
+func TestValueIndexMatchesScan(t *testing.T) {
+	indexed, scanned := NewRemoteList(), NewRemoteList()
+	indexed.SetIndexedLists([]string{"*"})
+	rng := rand.New(rand.NewSource(1))
+
+	for step := 0; step < 2000; step++ {
+		listID := []string{"a", "b"}[rng.Intn(2)]
+		for _, rl := range []*RemoteList{indexed, scanned} {
+			// A mesma semente por passo produz as mesmas operações nas duas.
+			op := rand.New(rand.NewSource(int64(step)))
+			switch p := op.Intn(100); {
+			case p < 45:
+				rl.Append(AppendArgs{ListID: listID, Value: op.Intn(5)}, new(bool))
+			case p < 55:
+				rl.Push(PushArgs{ListID: listID, Values: []int{op.Intn(5), op.Intn(5), op.Intn(5)}}, new(int))
+			case p < 95:
+				rl.Remove(RemoveArgs{ListID: listID}, new(int))
+			case p < 97:
+				rl.Delete(DeleteArgs{ListID: listID}, new(bool))
+			case rl == indexed:
+				indexed.SetIndexedLists(nil)
+				indexed.SetIndexedLists([]string{"*"})
+			}
+		}
+
+		for value := 0; value < 5; value++ {
+			args := SearchArgs{ListID: listID, Value: value}
+			for name, search := range map[string]func(rl *RemoteList) (any, error){
+				"IndexOf":     func(rl *RemoteList) (any, error) { var r int; err := rl.IndexOf(args, &r); return r, err },
+				"LastIndexOf": func(rl *RemoteList) (any, error) { var r int; err := rl.LastIndexOf(args, &r); return r, err },
+				"Contains":    func(rl *RemoteList) (any, error) { var r bool; err := rl.Contains(args, &r); return r, err },
+				"FindAll":     func(rl *RemoteList) (any, error) { var r []int; err := rl.FindAll(args, &r); return r, err },
+			} {
+				got, gotErr := search(indexed)
+				want, wantErr := search(scanned)
+				if !reflect.DeepEqual(got, want) || (gotErr == nil) != (wantErr == nil) {
+					t.Fatalf("passo %d: %s(%s, %d) com índice = %v (%v), sem índice = %v (%v)",
+						step, name, listID, value, got, gotErr, want, wantErr)
+				}
+			}
+		}
+	}
+}
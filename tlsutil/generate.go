@@ -0,0 +1,129 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// GeneratedFiles lista os arquivos criados por GenerateCertificates.
+type GeneratedFiles struct {
+	CACert, ServerCert, ServerKey, ClientCert, ClientKey string
+}
+
+// GenerateCertificates cria em dir uma CA local e certificados de servidor
+// (válido para os hosts informados) e de cliente assinados por ela. Serve para
+// desenvolvimento e testes; não use em produção.
+func GenerateCertificates(dir string, hosts []string, clientName string, validFor time.Duration) (GeneratedFiles, error) {
+	files := GeneratedFiles{
+		CACert:     filepath.Join(dir, "ca.pem"),
+		ServerCert: filepath.Join(dir, "server.pem"),
+		ServerKey:  filepath.Join(dir, "server-key.pem"),
+		ClientCert: filepath.Join(dir, "client.pem"),
+		ClientKey:  filepath.Join(dir, "client-key.pem"),
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return files, fmt.Errorf("erro ao criar diretório %s: %w", dir, err)
+	}
+
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return files, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          newSerial(),
+		Subject:               pkix.Name{CommonName: "remote-list-ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return files, fmt.Errorf("erro ao criar CA: %w", err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return files, err
+	}
+	if err := writePEM(files.CACert, "CERTIFICATE", caDER, 0644); err != nil {
+		return files, err
+	}
+
+	serverTemplate := &x509.Certificate{
+		SerialNumber: newSerial(),
+		Subject:      pkix.Name{CommonName: "remote-list-server"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		// O certificado do servidor também serve de cliente, para o healthcheck com mTLS.
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, h)
+		}
+	}
+	if err := issue(serverTemplate, caCert, caKey, files.ServerCert, files.ServerKey); err != nil {
+		return files, err
+	}
+
+	clientTemplate := &x509.Certificate{
+		SerialNumber: newSerial(),
+		Subject:      pkix.Name{CommonName: clientName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if err := issue(clientTemplate, caCert, caKey, files.ClientCert, files.ClientKey); err != nil {
+		return files, err
+	}
+	return files, nil
+}
+
+// issue gera uma chave, assina o template com a CA e grava certificado e chave.
+func issue(template, caCert *x509.Certificate, caKey *ecdsa.PrivateKey, certPath, keyPath string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("erro ao emitir certificado %s: %w", template.Subject.CommonName, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := writePEM(certPath, "CERTIFICATE", der, 0644); err != nil {
+		return err
+	}
+	return writePEM(keyPath, "EC PRIVATE KEY", keyDER, 0600)
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("erro ao gravar %s: %w", path, err)
+	}
+	return nil
+}
+
+func newSerial() *big.Int {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 120))
+	return serial
+}
@@ -0,0 +1,178 @@
+// Package tlsutil monta configurações TLS para o servidor e para os clientes,
+// com recarga automática de certificados.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"sd-miniprojeto-1/config"
+	"sd-miniprojeto-1/logging"
+)
+
+// Reloader mantém o certificado do servidor e as CAs de cliente em memória e
+// os recarrega quando os arquivos mudam (verificado a cada handshake) ou quando
+// Update/Reload é chamado, sem reiniciar o servidor.
+type Reloader struct {
+	mu        sync.RWMutex
+	cfg       config.TLSConfig
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time // Última modificação vista de cada arquivo.
+}
+
+// NewReloader carrega os arquivos da configuração informada.
+func NewReloader(cfg config.TLSConfig) (*Reloader, error) {
+	r := &Reloader{}
+	if err := r.Update(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Update troca a configuração (ex: após recarregar o arquivo de configuração)
+// e carrega os novos arquivos. Em caso de erro, mantém o estado anterior.
+func (r *Reloader) Update(cfg config.TLSConfig) error {
+	cert, clientCAs, modTimes, err := load(cfg)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cfg, r.cert, r.clientCAs, r.modTimes = cfg, cert, clientCAs, modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+// Reload recarrega os arquivos da configuração atual.
+func (r *Reloader) Reload() error {
+	r.mu.RLock()
+	cfg := r.cfg
+	r.mu.RUnlock()
+	return r.Update(cfg)
+}
+
+// reloadIfChanged recarrega os arquivos se algum deles foi modificado.
+func (r *Reloader) reloadIfChanged() {
+	r.mu.RLock()
+	changed := false
+	for path, seen := range r.modTimes {
+		if info, err := os.Stat(path); err == nil && !info.ModTime().Equal(seen) {
+			changed = true
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	if changed {
+		if err := r.Reload(); err != nil {
+			logging.Errorf("Erro ao recarregar certificados TLS (mantendo os anteriores): %v", err)
+		} else {
+			logging.Infof("Certificados TLS recarregados.")
+		}
+	}
+}
+
+// ServerConfig retorna a configuração TLS do servidor. Cada handshake usa o
+// certificado e as CAs de cliente mais recentes.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.reloadIfChanged()
+
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+			}
+			if r.clientCAs != nil {
+				cfg.ClientCAs = r.clientCAs
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				if r.cfg.ClientAuth == "optional" {
+					cfg.ClientAuth = tls.VerifyClientCertIfGiven
+				}
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// Certificate retorna o certificado atual do servidor.
+func (r *Reloader) Certificate() tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return *r.cert
+}
+
+// load lê o par certificado/chave e, se configurado, o arquivo de CAs de cliente.
+func load(cfg config.TLSConfig) (*tls.Certificate, *x509.CertPool, map[string]time.Time, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("erro ao carregar certificado %s/%s: %w", cfg.CertFile, cfg.KeyFile, err)
+	}
+
+	var clientCAs *x509.CertPool
+	if cfg.ClientCAFile != "" {
+		clientCAs, err = LoadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	modTimes := make(map[string]time.Time)
+	for _, path := range []string{cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	return &cert, clientCAs, modTimes, nil
+}
+
+// LoadCertPool lê um arquivo PEM com um ou mais certificados de CA.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler CAs %s: %w", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("nenhum certificado PEM válido em %s", path)
+	}
+	return pool, nil
+}
+
+// ClientConfig monta a configuração TLS de um cliente. caFile verifica o
+// servidor (vazio usa as CAs do sistema); certFile/keyFile habilitam mTLS.
+func ClientConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("certificado e chave do cliente devem ser informados juntos")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao carregar certificado do cliente: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
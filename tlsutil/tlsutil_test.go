@@ -0,0 +1,189 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"sd-miniprojeto-1/config"
+)
+
+// generate cria CA e certificados locais em um diretório temporário.
+func generate(t *testing.T) GeneratedFiles {
+	t.Helper()
+	files, err := GenerateCertificates(t.TempDir(), []string{"127.0.0.1", "localhost"}, "cliente-teste", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateCertificates: %v", err)
+	}
+	return files
+}
+
+// serve atende conexões TLS com a configuração do Reloader: após o handshake,
+// o servidor escreve "ok" e fecha a conexão. Retorna o endereço.
+func serve(t *testing.T, r *Reloader) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", r.ServerConfig())
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				if err := conn.(*tls.Conn).Handshake(); err == nil {
+					conn.Write([]byte("ok"))
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// dial conecta com a configuração do cliente e lê a resposta do servidor. No
+// TLS 1.3, a recusa do certificado do cliente só aparece na primeira leitura.
+func dial(addr string, cfg *tls.Config) (*x509.Certificate, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		return nil, err
+	}
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func clientConfig(t *testing.T, caFile, certFile, keyFile string) *tls.Config {
+	t.Helper()
+	cfg, err := ClientConfig(caFile, certFile, keyFile, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+	return cfg
+}
+
+func TestHandshake(t *testing.T) {
+	files := generate(t)
+	r, err := NewReloader(config.TLSConfig{CertFile: files.ServerCert, KeyFile: files.ServerKey})
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	addr := serve(t, r)
+
+	if _, err := dial(addr, clientConfig(t, files.CACert, "", "")); err != nil {
+		t.Fatalf("handshake com a CA local falhou: %v", err)
+	}
+	if _, err := dial(addr, clientConfig(t, generate(t).CACert, "", "")); err == nil {
+		t.Fatal("handshake confiando em outra CA deveria falhar")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	files := generate(t)
+	r, err := NewReloader(config.TLSConfig{CertFile: files.ServerCert, KeyFile: files.ServerKey, ClientCAFile: files.CACert})
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	addr := serve(t, r)
+
+	if _, err := dial(addr, clientConfig(t, files.CACert, "", "")); err == nil {
+		t.Error("cliente sem certificado deveria ser recusado")
+	}
+	other := generate(t)
+	if _, err := dial(addr, clientConfig(t, files.CACert, other.ClientCert, other.ClientKey)); err == nil {
+		t.Error("cliente com certificado de outra CA deveria ser recusado")
+	}
+	if _, err := dial(addr, clientConfig(t, files.CACert, files.ClientCert, files.ClientKey)); err != nil {
+		t.Errorf("cliente com certificado válido foi recusado: %v", err)
+	}
+}
+
+func TestMutualTLSOptional(t *testing.T) {
+	files := generate(t)
+	r, err := NewReloader(config.TLSConfig{CertFile: files.ServerCert, KeyFile: files.ServerKey, ClientCAFile: files.CACert, ClientAuth: "optional"})
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	addr := serve(t, r)
+
+	if _, err := dial(addr, clientConfig(t, files.CACert, "", "")); err != nil {
+		t.Errorf("com client_auth optional, cliente sem certificado foi recusado: %v", err)
+	}
+	other := generate(t)
+	if _, err := dial(addr, clientConfig(t, files.CACert, other.ClientCert, other.ClientKey)); err == nil {
+		t.Error("com client_auth optional, certificado de outra CA deveria ser recusado")
+	}
+}
+
+func TestReloaderPicksUpRotatedFiles(t *testing.T) {
+	files := generate(t)
+	r, err := NewReloader(config.TLSConfig{CertFile: files.ServerCert, KeyFile: files.ServerKey})
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	addr := serve(t, r)
+
+	before, err := dial(addr, clientConfig(t, files.CACert, "", ""))
+	if err != nil {
+		t.Fatalf("handshake antes da rotação: %v", err)
+	}
+
+	// Troca certificado e chave por outros, de outra CA, nos mesmos caminhos.
+	rotated := generate(t)
+	future := time.Now().Add(time.Minute)
+	for src, dst := range map[string]string{rotated.ServerCert: files.ServerCert, rotated.ServerKey: files.ServerKey} {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dst, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		// Garante uma data de modificação diferente mesmo em sistemas de
+		// arquivos com resolução baixa.
+		if err := os.Chtimes(dst, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	after, err := dial(addr, clientConfig(t, rotated.CACert, "", ""))
+	if err != nil {
+		t.Fatalf("handshake com o certificado novo: %v", err)
+	}
+	if after.SerialNumber.Cmp(before.SerialNumber) == 0 {
+		t.Fatal("o servidor continuou com o certificado anterior")
+	}
+	if _, err := dial(addr, clientConfig(t, files.CACert, "", "")); err == nil {
+		t.Error("após a rotação, a CA anterior não deveria validar o servidor")
+	}
+}
+
+func TestReloaderKeepsCertificateOnInvalidFiles(t *testing.T) {
+	files := generate(t)
+	r, err := NewReloader(config.TLSConfig{CertFile: files.ServerCert, KeyFile: files.ServerKey})
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	addr := serve(t, r)
+
+	future := time.Now().Add(time.Minute)
+	if err := os.WriteFile(files.ServerCert, []byte("inválido"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(files.ServerCert, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dial(addr, clientConfig(t, files.CACert, "", "")); err != nil {
+		t.Fatalf("com arquivos inválidos, o certificado anterior deveria continuar em uso: %v", err)
+	}
+}
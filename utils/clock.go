@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	clockMu sync.RWMutex
+	clock   = time.Now
+)
+
+// SetClock troca o relógio usado por utils (timestamps das entradas do log e
+// horários dos snapshots) e retorna o anterior. O padrão é time.Now; a
+// simulação determinística (pacote sim) usa o relógio simulado.
+func SetClock(now func() time.Time) func() time.Time {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	previous := clock
+	clock = now
+	return previous
+}
+
+// Now retorna o horário atual do relógio de utils.
+func Now() time.Time {
+	clockMu.RLock()
+	now := clock
+	clockMu.RUnlock()
+	return now()
+}
+
+// Reset descarta o estado mantido em memória por utils sobre o log e os
+// snapshots (último LSN, falha do log, último snapshot salvo), como em um
+// processo novo. A configuração (retenção, intervalo de completos, FS e
+// relógio) é mantida. Usado por simulações que reiniciam o servidor no mesmo
+// processo; deve ser chamada antes da recuperação.
+func Reset() {
+	logMu.Lock()
+	lastLSN, logBroken = 0, nil
+	logMu.Unlock()
+
+	saveMu.Lock()
+	lastSaved.SavedSnapshot, lastSaved.version, lastSaved.chain, lastSaved.valid = SavedSnapshot{}, 0, 0, false
+	saveMu.Unlock()
+
+	setLastSnapshotTime(time.Time{})
+}
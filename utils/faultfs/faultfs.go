@@ -0,0 +1,416 @@
+// Package faultfs implementa um utils.FS que injeta falhas de disco, para
+// testar a recuperação: escritas parciais (torn writes), fsync com erro,
+// disco cheio (ENOSPC) e queda do processo em qualquer ponto de escrita.
+//
+// Os arquivos são os reais, no sistema operacional; o FS acompanha, por
+// arquivo, quanto do conteúdo já foi sincronizado. Cada operação que altera o
+// disco (Write, Sync, Truncate, Rename, Remove e SyncDir) é um ponto de
+// escrita, numerado a partir de 1. A falha configurada acontece no ponto At
+// (as de escrita, no primeiro Write a partir dele; a de fsync, no primeiro
+// Sync). Numa queda, o que não foi sincronizado se perde, com exceção de um
+// prefixo aleatório (páginas que o sistema já tinha gravado), e todas as
+// operações seguintes falham com ErrCrashed. Renomeações e remoções são
+// tratadas como duráveis assim que feitas.
+package faultfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"sd-miniprojeto-1/utils"
+)
+
+// Fault é o tipo de falha injetada.
+type Fault int
+
+const (
+	None      Fault = iota // Nenhuma falha.
+	Crash                  // Queda antes da operação do ponto At.
+	TornWrite              // Escrita parcial seguida de queda.
+	SyncError              // fsync com erro (EIO); o que não foi sincronizado se perde.
+	NoSpace                // Escrita parcial com ENOSPC; o processo continua.
+)
+
+// Faults são os tipos de falha, na ordem das constantes.
+var Faults = []Fault{None, Crash, TornWrite, SyncError, NoSpace}
+
+func (f Fault) String() string {
+	switch f {
+	case None:
+		return "none"
+	case Crash:
+		return "crash"
+	case TornWrite:
+		return "torn"
+	case SyncError:
+		return "fsync"
+	case NoSpace:
+		return "enospc"
+	}
+	return fmt.Sprintf("Fault(%d)", int(f))
+}
+
+// ParseFault converte o nome de uma falha (como em String).
+func ParseFault(name string) (Fault, error) {
+	for _, f := range Faults {
+		if f.String() == name {
+			return f, nil
+		}
+	}
+	return None, fmt.Errorf("falha desconhecida %q (use none, crash, torn, fsync ou enospc)", name)
+}
+
+// ErrCrashed é retornado por todas as operações após a queda simulada.
+var ErrCrashed = errors.New("faultfs: processo caiu (queda simulada)")
+
+// FS é um utils.FS com injeção de falhas.
+type FS struct {
+	mu      sync.Mutex
+	fault   Fault
+	at      int
+	rng     *rand.Rand
+	ops     int                   // Pontos de escrita já executados.
+	fired   bool                  // Se a falha já aconteceu.
+	crashed bool                  // Se a queda já aconteceu.
+	files   map[string]*fileState // Por caminho limpo.
+	onCrash func()
+}
+
+// fileState é o tamanho de um arquivo e quanto dele já foi sincronizado.
+type fileState struct {
+	size   int64
+	synced int64
+}
+
+// New cria um FS que injeta fault no ponto de escrita at, com as escolhas
+// aleatórias (tamanho das escritas parciais e do que sobrevive à queda)
+// feitas a partir de seed.
+func New(fault Fault, at int, seed int64) *FS {
+	return &FS{fault: fault, at: at, rng: rand.New(rand.NewSource(seed)), files: make(map[string]*fileState)}
+}
+
+// OnCrash registra uma função chamada uma vez, logo após a queda simulada
+// (por exemplo, para encerrar o processo).
+func (f *FS) OnCrash(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onCrash = fn
+}
+
+// Ops retorna quantos pontos de escrita foram executados.
+func (f *FS) Ops() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ops
+}
+
+// Fired indica se a falha configurada já aconteceu.
+func (f *FS) Fired() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fired
+}
+
+// Crashed indica se a queda simulada já aconteceu.
+func (f *FS) Crashed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.crashed
+}
+
+// Crash simula a queda do processo agora: o que não foi sincronizado se
+// perde (com exceção de um prefixo aleatório) e as operações seguintes
+// falham.
+func (f *FS) Crash() {
+	f.mu.Lock()
+	f.crashLocked()
+	fn := f.onCrash
+	f.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+func (f *FS) crashLocked() {
+	if f.crashed {
+		return
+	}
+	f.crashed = true
+	for path, state := range f.files {
+		if state.size <= state.synced {
+			continue
+		}
+		kept := state.synced + f.rng.Int63n(state.size-state.synced+1)
+		os.Truncate(path, kept)
+	}
+}
+
+// boundary registra um ponto de escrita da operação op ("write", "sync" etc.)
+// e retorna a falha que deve ser injetada nele (None se nenhuma).
+func (f *FS) boundary(op string) (Fault, error) {
+	if f.crashed {
+		return None, ErrCrashed
+	}
+	f.ops++
+	if f.fired || f.fault == None || f.ops < f.at {
+		return None, nil
+	}
+	switch f.fault {
+	case Crash:
+	case TornWrite, NoSpace:
+		if op != "write" {
+			return None, nil
+		}
+	case SyncError:
+		if op != "sync" {
+			return None, nil
+		}
+	}
+	f.fired = true
+	return f.fault, nil
+}
+
+// crashNow simula a queda a partir de um método com f.mu adquirido.
+func (f *FS) crashNow() error {
+	f.crashLocked()
+	if fn := f.onCrash; fn != nil {
+		f.mu.Unlock()
+		fn()
+		f.mu.Lock()
+	}
+	return ErrCrashed
+}
+
+// state retorna o estado do arquivo em path, começando pelo conteúdo atual
+// do disco, tratado como já sincronizado.
+func (f *FS) state(path string) *fileState {
+	path = filepath.Clean(path)
+	state, ok := f.files[path]
+	if !ok {
+		state = &fileState{}
+		if info, err := os.Stat(path); err == nil {
+			state.size, state.synced = info.Size(), info.Size()
+		}
+		f.files[path] = state
+	}
+	return state
+}
+
+func (f *FS) OpenFile(name string, flag int, perm os.FileMode) (utils.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.crashed {
+		return nil, ErrCrashed
+	}
+	file, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	state := f.state(name)
+	if flag&os.O_TRUNC != 0 {
+		state.size = 0
+	}
+	return &faultFile{file: file, fs: f, state: state}, nil
+}
+
+func (f *FS) CreateTemp(dir, pattern string) (utils.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.crashed {
+		return nil, ErrCrashed
+	}
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	state := &fileState{}
+	f.files[filepath.Clean(file.Name())] = state
+	return &faultFile{file: file, fs: f, state: state}, nil
+}
+
+func (f *FS) Rename(oldpath, newpath string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fault, err := f.boundary("rename")
+	if err != nil {
+		return err
+	}
+	if fault == Crash {
+		return f.crashNow()
+	}
+	if err := os.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	if state, ok := f.files[oldpath]; ok {
+		f.files[newpath] = state
+		delete(f.files, oldpath)
+	} else {
+		delete(f.files, newpath)
+	}
+	return nil
+}
+
+func (f *FS) Remove(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fault, err := f.boundary("remove")
+	if err != nil {
+		return err
+	}
+	if fault == Crash {
+		return f.crashNow()
+	}
+	delete(f.files, filepath.Clean(name))
+	return os.Remove(name)
+}
+
+func (f *FS) Stat(name string) (os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.crashed {
+		return nil, ErrCrashed
+	}
+	return os.Stat(name)
+}
+
+func (f *FS) ReadDir(name string) ([]os.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.crashed {
+		return nil, ErrCrashed
+	}
+	return os.ReadDir(name)
+}
+
+func (f *FS) SyncDir(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fault, err := f.boundary("syncdir")
+	if err != nil {
+		return err
+	}
+	if fault == Crash {
+		return f.crashNow()
+	}
+	return utils.OSFS{}.SyncDir(name)
+}
+
+// faultFile é um arquivo aberto pelo FS. O *os.File não é embutido: métodos
+// como WriteString e ReadFrom escapariam da injeção de falhas.
+type faultFile struct {
+	file  *os.File
+	fs    *FS
+	state *fileState
+}
+
+func (file *faultFile) Name() string               { return file.file.Name() }
+func (file *faultFile) Stat() (os.FileInfo, error) { return file.file.Stat() }
+func (file *faultFile) Close() error               { return file.file.Close() }
+
+func (file *faultFile) Read(p []byte) (int, error) {
+	if file.fs.Crashed() {
+		return 0, ErrCrashed
+	}
+	return file.file.Read(p)
+}
+
+func (file *faultFile) ReadAt(p []byte, off int64) (int, error) {
+	if file.fs.Crashed() {
+		return 0, ErrCrashed
+	}
+	return file.file.ReadAt(p, off)
+}
+
+func (file *faultFile) Write(p []byte) (int, error) {
+	f := file.fs
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fault, err := f.boundary("write")
+	if err != nil {
+		return 0, err
+	}
+
+	var injected error
+	switch fault {
+	case Crash:
+		return 0, f.crashNow()
+	case TornWrite, NoSpace:
+		p = p[:f.rng.Intn(len(p)+1)]
+		injected = &fs.PathError{Op: "write", Path: file.Name(), Err: syscall.ENOSPC}
+	}
+	n, err := file.file.Write(p)
+	file.updateSize()
+	if err != nil {
+		return n, err
+	}
+	if fault == TornWrite {
+		// O que foi escrito parcialmente não foi sincronizado e pode se
+		// perder na queda, como qualquer escrita não sincronizada.
+		return n, f.crashNow()
+	}
+	return n, injected
+}
+
+func (file *faultFile) Sync() error {
+	f := file.fs
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fault, err := f.boundary("sync")
+	if err != nil {
+		return err
+	}
+	switch fault {
+	case Crash:
+		return f.crashNow()
+	case SyncError:
+		// Como no Linux após um fsync com erro: as páginas não gravadas são
+		// descartadas, e o conteúdo volta ao último fsync bem-sucedido.
+		os.Truncate(file.Name(), file.state.synced)
+		file.state.size = file.state.synced
+		return &fs.PathError{Op: "sync", Path: file.Name(), Err: syscall.EIO}
+	}
+	if err := file.file.Sync(); err != nil {
+		return err
+	}
+	file.state.synced = file.state.size
+	return nil
+}
+
+func (file *faultFile) Truncate(size int64) error {
+	f := file.fs
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fault, err := f.boundary("truncate")
+	if err != nil {
+		return err
+	}
+	if fault == Crash {
+		return f.crashNow()
+	}
+	if err := file.file.Truncate(size); err != nil {
+		return err
+	}
+	file.state.size = size
+	if size < file.state.synced {
+		// Uma truncagem não sincronizada pode se perder, mas os bytes
+		// removidos também não voltam com outro conteúdo: tratá-la como
+		// durável é o caso relevante para quem a usa para desfazer escritas.
+		file.state.synced = size
+	}
+	return nil
+}
+
+// updateSize atualiza o tamanho conhecido após uma escrita. Deve ser chamada
+// com fs.mu adquirido.
+func (file *faultFile) updateSize() {
+	if info, err := file.file.Stat(); err == nil {
+		file.state.size = info.Size()
+	}
+}
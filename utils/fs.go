@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// File é um arquivo aberto por FS.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Closer
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// FS é o sistema de arquivos usado por utils para logs e snapshots. O padrão
+// é o do sistema operacional; testes podem trocá-lo com SetFS por uma
+// implementação que injeta falhas (veja utils/faultfs).
+type FS interface {
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	CreateTemp(dir, pattern string) (File, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.DirEntry, error)
+	// SyncDir sincroniza o diretório, tornando duráveis as criações e
+	// renomeações feitas nele.
+	SyncDir(name string) error
+}
+
+// OSFS é o FS do sistema operacional.
+type OSFS struct{}
+
+func (OSFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err // Evita um File não nulo com *os.File nulo.
+	}
+	return f, nil
+}
+
+func (OSFS) CreateTemp(dir, pattern string) (File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (OSFS) Rename(oldpath, newpath string) error       { return os.Rename(oldpath, newpath) }
+func (OSFS) Remove(name string) error                   { return os.Remove(name) }
+func (OSFS) Stat(name string) (os.FileInfo, error)      { return os.Stat(name) }
+func (OSFS) ReadDir(name string) ([]os.DirEntry, error) { return os.ReadDir(name) }
+
+func (OSFS) SyncDir(name string) error {
+	dir, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+var (
+	fsMu       sync.RWMutex
+	fileSystem FS = OSFS{}
+)
+
+// SetFS troca o sistema de arquivos usado por utils e retorna o anterior.
+func SetFS(fsys FS) FS {
+	fsMu.Lock()
+	defer fsMu.Unlock()
+	previous := fileSystem
+	fileSystem = fsys
+	return previous
+}
+
+// currentFS retorna o sistema de arquivos em uso.
+func currentFS() FS {
+	fsMu.RLock()
+	defer fsMu.RUnlock()
+	return fileSystem
+}
+
+// openFile abre name só para leitura.
+func openFile(name string) (File, error) {
+	return currentFS().OpenFile(name, os.O_RDONLY, 0)
+}
+
+// readFile lê o arquivo inteiro.
+func readFile(name string) ([]byte, error) {
+	f, err := openFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogIssue é um problema encontrado por CheckLog.
+type LogIssue struct {
+	Line    int    // Linha do arquivo (0 para problemas do arquivo como um todo).
+	Message string // Descrição do problema.
+	Warning bool   // Verdadeiro se não compromete a recuperação.
+}
+
+// LogReport resume a verificação de um arquivo de log.
+type LogReport struct {
+	Lines      int            // Linhas não vazias.
+	Entries    int            // Entradas válidas.
+	Legacy     int            // Entradas sem LSN explícito (formato antigo).
+	Operations map[string]int // Entradas válidas por operação.
+	FirstLSN   uint64         // LSN da primeira entrada válida.
+	LastLSN    uint64         // LSN da última entrada válida.
+	First      time.Time      // Timestamp da primeira entrada válida.
+	Last       time.Time      // Timestamp da última entrada válida.
+	Issues     []LogIssue     // Problemas encontrados, na ordem do arquivo.
+}
+
+// Valid indica se o log não tem problemas além de avisos.
+func (r LogReport) Valid() bool {
+	for _, issue := range r.Issues {
+		if !issue.Warning {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckLog verifica a integridade do arquivo de log em path: linhas que não
+// podem ser interpretadas, operações desconhecidas, LSNs que não crescem e
+// uma última linha sem quebra de linha (escrita interrompida). Saltos de LSN
+// são esperados após a compactação e não são reportados; timestamps fora de
+// ordem geram apenas avisos, pois o horário é lido antes de adquirir o log.
+func CheckLog(path string) (LogReport, error) {
+	report := LogReport{Operations: make(map[string]int)}
+	var prevLSN uint64
+	var prevTimestamp time.Time
+	err := ScanLog(path, func(record LogRecord) error {
+		report.Lines++
+		if record.Err != nil {
+			report.Issues = append(report.Issues, LogIssue{Line: record.Line, Message: record.Err.Error()})
+			return nil
+		}
+		entry := record.Entry
+		if !knownOperation(entry.Operation) {
+			report.Issues = append(report.Issues, LogIssue{Line: record.Line, Message: fmt.Sprintf("operação desconhecida %q", entry.Operation)})
+			return nil
+		}
+
+		if prevLSN != 0 && entry.LSN <= prevLSN {
+			report.Issues = append(report.Issues, LogIssue{Line: record.Line, Message: fmt.Sprintf("LSN %d não é maior que o anterior (%d)", entry.LSN, prevLSN)})
+		}
+		if !prevTimestamp.IsZero() && entry.Timestamp.Before(prevTimestamp) {
+			report.Issues = append(report.Issues, LogIssue{Line: record.Line, Warning: true,
+				Message: fmt.Sprintf("timestamp %s anterior ao da entrada precedente (%s)", entry.Timestamp.Format(time.RFC3339Nano), prevTimestamp.Format(time.RFC3339Nano))})
+		}
+		if _, err := strconv.ParseUint(strings.Fields(record.Raw)[0], 10, 64); err != nil {
+			report.Legacy++
+		}
+
+		if report.Entries == 0 {
+			report.FirstLSN, report.First = entry.LSN, entry.Timestamp
+		}
+		report.Entries++
+		report.Operations[entry.Operation]++
+		report.LastLSN, report.Last = entry.LSN, entry.Timestamp
+		prevLSN, prevTimestamp = entry.LSN, entry.Timestamp
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if torn, err := missingFinalNewline(path); err != nil {
+		return report, err
+	} else if torn {
+		report.Issues = append(report.Issues, LogIssue{Message: "a última linha não termina com quebra de linha (escrita interrompida?)"})
+	}
+	return report, nil
+}
+
+// knownOperation indica se a operação é uma das registradas pelo servidor.
+func knownOperation(operation string) bool {
+	switch operation {
+	case "Append", "Push", "Remove", "Delete", "Get/Size":
+		return true
+	}
+	return false
+}
+
+// missingFinalNewline indica se o arquivo não vazio em path não termina com '\n'.
+func missingFinalNewline(path string) (bool, error) {
+	f, err := openFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("erro ao abrir arquivo de log: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil && err != io.EOF {
+		return false, fmt.Errorf("erro ao ler arquivo de log: %w", err)
+	}
+	return last[0] != '\n', nil
+}
@@ -2,149 +2,538 @@ package utils
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"sd-miniprojeto-1/logging"
+	"sd-miniprojeto-1/metrics"
+	"sd-miniprojeto-1/structures"
 )
 
 // LogEntry representa uma entrada no log de operações.
 type LogEntry struct {
+	LSN       uint64    // Número de sequência da entrada no log (Log Sequence Number).
 	Timestamp time.Time // Horário da operação.
 	Operation string    // Tipo de operação (e.g., "Append").
+	Namespace string    // Namespace da lista (vazio equivale ao namespace padrão).
 	ListID    string    // ID da lista.
 	Value     int       // Valor envolvido (para Append).
+	Values    []int     // Valores envolvidos (para Push).
 	Index     int       // Índice envolvido (para Get).
 }
 
 const (
 	logsDir     = "logs"          // Diretório de logs.
 	logFileName = "operations.log" // Nome do arquivo de log.
+
+	namespacePrefix = "ns=" // Prefixo do campo opcional de namespace nas linhas do log.
 )
 
-// getLogFilePath retorna o caminho completo do arquivo de log.
-func getLogFilePath() string {
+var (
+	logMu   sync.Mutex // Serializa as escritas no log e a atribuição de LSNs.
+	lastLSN uint64     // Último LSN atribuído; protegido por logMu.
+)
+
+// LastLSN retorna o LSN da última entrada escrita (ou recuperada) do log.
+func LastLSN() uint64 {
+	logMu.Lock()
+	defer logMu.Unlock()
+	return lastLSN
+}
+
+// LogPath retorna o caminho completo do arquivo de log.
+func LogPath() string {
 	return filepath.Join(logsDir, logFileName)
 }
 
-// AppendLog escreve uma entrada de log para operações de adição.
-func AppendLog(listID string, value int) error {
-	return writeLog(LogEntry{
-		Timestamp: time.Now(),
+// AppendEntry cria a entrada de log de uma adição.
+func AppendEntry(namespace, listID string, value int) LogEntry {
+	return LogEntry{
+		Timestamp: Now(),
 		Operation: "Append",
+		Namespace: namespace,
 		ListID:    listID,
 		Value:     value,
-	})
+	}
+}
+
+// PushEntry cria a entrada de log da adição de vários valores de uma vez.
+func PushEntry(namespace, listID string, values []int) LogEntry {
+	return LogEntry{
+		Timestamp: Now(),
+		Operation: "Push",
+		Namespace: namespace,
+		ListID:    listID,
+		Values:    append([]int(nil), values...),
+	}
 }
 
-// RemoveLog escreve uma entrada de log para operações de remoção.
-func RemoveLog(listID string) error {
-	return writeLog(LogEntry{
-		Timestamp: time.Now(),
+// RemoveEntry cria a entrada de log de uma remoção.
+func RemoveEntry(namespace, listID string) LogEntry {
+	return LogEntry{
+		Timestamp: Now(),
 		Operation: "Remove",
+		Namespace: namespace,
 		ListID:    listID,
-	})
+	}
+}
+
+// DeleteEntry cria a entrada de log da remoção de uma lista inteira.
+func DeleteEntry(namespace, listID string) LogEntry {
+	return LogEntry{
+		Timestamp: Now(),
+		Operation: "Delete",
+		Namespace: namespace,
+		ListID:    listID,
+	}
 }
 
-// GetLog escreve uma entrada de log para operações de leitura.
-func GetLog(listID string, valueOrIndex int) error {
-	return writeLog(LogEntry{
-		Timestamp: time.Now(),
+// GetEntry cria a entrada de log de uma leitura.
+func GetEntry(namespace, listID string, valueOrIndex int) LogEntry {
+	return LogEntry{
+		Timestamp: Now(),
 		Operation: "Get/Size",
+		Namespace: namespace,
 		ListID:    listID,
 		Index:     valueOrIndex,
-	})
+	}
 }
 
-// writeLog escreve uma entrada no arquivo de log.
-func writeLog(entry LogEntry) error {
-	fileName := getLogFilePath()
-	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// WriteLog escreve uma entrada no arquivo de log, atribuindo o próximo LSN, e
+// só retorna após o fsync. Se a escrita ou o fsync falhar, a linha é desfeita
+// (o arquivo volta ao tamanho anterior) e a entrada não recebe LSN; se nem
+// isso for possível, o conteúdo do log fica incerto e as escritas seguintes
+// são recusadas até o servidor reiniciar e recuperar o log.
+func WriteLog(entry LogEntry) error {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	fileName := LogPath()
+	if logBroken != nil {
+		return fmt.Errorf("log %s indisponível após falha anterior (reinicie o servidor): %w", fileName, logBroken)
+	}
+	f, err := currentFS().OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("erro ao abrir arquivo de log %s: %w", fileName, err)
 	}
 	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("erro ao consultar arquivo de log %s: %w", fileName, err)
+	}
+
+	entry.LSN = lastLSN + 1
+	line := formatLogEntry(entry) + "\n"
+
+	if _, err := io.WriteString(f, line); err != nil {
+		return rollbackLog(f, info.Size(), fmt.Errorf("erro ao escrever no arquivo de log %s: %w", fileName, err))
+	}
+	metrics.LogBytesWritten.Add(float64(len(line)))
 
-	fileLogger := log.New(f, "", 0)
+	// Garante que a entrada está no disco antes de confirmar a operação.
+	syncStart := time.Now()
+	if err := f.Sync(); err != nil {
+		return rollbackLog(f, info.Size(), fmt.Errorf("erro ao sincronizar arquivo de log %s: %w", fileName, err))
+	}
+	metrics.LogFsyncDuration.Observe(time.Since(syncStart).Seconds())
+
+	lastLSN = entry.LSN
+	return nil
+}
+
+// logBroken guarda a falha que deixou o log em estado incerto; protegido por logMu.
+var logBroken error
+
+// ErrLogUncertain acompanha o erro de WriteLog quando a escrita falhou e não
+// pôde ser desfeita: a entrada pode ou não ter ficado no log. Nos demais
+// erros, a entrada não foi registrada.
+var ErrLogUncertain = errors.New("a entrada pode ter ficado no log")
+
+// rollbackLog desfaz uma escrita que falhou, truncando o log em size, e
+// retorna cause. Uma linha parcial deixada no arquivo se juntaria à próxima,
+// corrompendo uma entrada confirmada; e, após um fsync com erro, não se sabe
+// o que chegou ao disco. Se a truncagem não puder ser sincronizada, o log é
+// marcado como incerto. Deve ser chamada com logMu adquirido.
+func rollbackLog(f File, size int64, cause error) error {
+	if err := f.Truncate(size); err != nil {
+		logBroken = cause
+		return fmt.Errorf("%w (e erro ao desfazer a escrita: %v; %w)", cause, err, ErrLogUncertain)
+	}
+	if err := f.Sync(); err != nil {
+		logBroken = cause
+		return fmt.Errorf("%w (e erro ao sincronizar o log desfeito: %v; %w)", cause, err, ErrLogUncertain)
+	}
+	return cause
+}
 
-	logString := fmt.Sprintf("%s %s %s", entry.Timestamp.Format(time.RFC3339Nano), entry.Operation, entry.ListID)
+// formatLogEntry formata a entrada como uma linha do log (sem a quebra de linha).
+// Formato: "<lsn> <timestamp> <operação> <lista> [valor/índice] [ns=<namespace>]";
+// no Push, os valores vão separados por vírgula no lugar do valor.
+// O namespace só é escrito quando não é o padrão.
+func formatLogEntry(entry LogEntry) string {
+	logString := fmt.Sprintf("%d %s %s %s", entry.LSN, entry.Timestamp.Format(time.RFC3339Nano), entry.Operation, entry.ListID)
 	switch entry.Operation {
 	case "Append":
 		logString += fmt.Sprintf(" %d", entry.Value)
+	case "Push":
+		values := make([]string, len(entry.Values))
+		for i, v := range entry.Values {
+			values[i] = strconv.Itoa(v)
+		}
+		logString += " " + strings.Join(values, ",")
 	case "Get/Size":
 		logString += fmt.Sprintf(" %d", entry.Index)
 	}
+	if ns := structures.NamespaceName(entry.Namespace); ns != structures.DefaultNamespace {
+		logString += " " + namespacePrefix + ns
+	}
+	return logString
+}
 
-	fileLogger.Println(logString)
-	return nil
+// String formata a entrada como uma linha do log.
+func (e LogEntry) String() string {
+	return formatLogEntry(e)
+}
+
+// parseLogLine interpreta uma linha do log. Linhas antigas não têm LSN e
+// começam direto pelo timestamp; recebem o LSN seguinte a prevLSN. Se o
+// timestamp for válido, a entrada devolvida tem LSN e Timestamp preenchidos
+// mesmo quando o restante da linha é inválido (e um erro é retornado).
+func parseLogLine(line string, prevLSN uint64) (LogEntry, error) {
+	parts := strings.Fields(line)
+
+	lsn := prevLSN + 1
+	if len(parts) > 0 {
+		if parsed, err := strconv.ParseUint(parts[0], 10, 64); err == nil {
+			lsn = parsed
+			parts = parts[1:]
+		}
+	}
+
+	if len(parts) < 3 {
+		return LogEntry{}, fmt.Errorf("linha malformada: %s", line)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return LogEntry{}, fmt.Errorf("parse timestamp: %w", err)
+	}
+
+	entry := LogEntry{LSN: lsn, Timestamp: timestamp, Operation: parts[1], ListID: parts[2]}
+	// O namespace, quando presente, é o último campo além dos da operação.
+	if last := parts[len(parts)-1]; len(parts) > operationFields(entry.Operation) && strings.HasPrefix(last, namespacePrefix) {
+		entry.Namespace = strings.TrimPrefix(last, namespacePrefix)
+		parts = parts[:len(parts)-1]
+	}
+	switch entry.Operation {
+	case "Append":
+		if len(parts) <= 3 {
+			return entry, fmt.Errorf("Append malformado: valor ausente")
+		}
+		val, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return entry, fmt.Errorf("parse valor: %w", err)
+		}
+		entry.Value = val
+	case "Push":
+		if len(parts) <= 3 {
+			return entry, fmt.Errorf("Push malformado: valores ausentes")
+		}
+		for _, field := range strings.Split(parts[3], ",") {
+			val, err := strconv.Atoi(field)
+			if err != nil {
+				return entry, fmt.Errorf("parse valor: %w", err)
+			}
+			entry.Values = append(entry.Values, val)
+		}
+	case "Get/Size":
+		if len(parts) > 3 {
+			idx, err := strconv.Atoi(parts[3])
+			if err != nil {
+				return entry, fmt.Errorf("parse índice: %w", err)
+			}
+			entry.Index = idx
+		}
+	}
+	return entry, nil
 }
 
 // ReadLogsFromTimestamp lê entradas de log a partir de um timestamp específico.
+// O log inteiro é percorrido, e o contador de LSN é avançado até a última
+// entrada encontrada, para que novas escritas continuem a sequência.
 func ReadLogsFromTimestamp(since time.Time) ([]LogEntry, error) {
-	fileName := getLogFilePath()
-	f, err := os.Open(fileName)
+	entries := []LogEntry{}
+	var currentLSN uint64
+	err := ScanLog(LogPath(), func(record LogRecord) error {
+		if record.Entry.LSN != 0 {
+			currentLSN = record.Entry.LSN
+		}
+		if record.Err != nil {
+			logging.Warnf("Pulando linha de log %d: %v", record.Line, record.Err)
+			return nil
+		}
+
+		if record.Entry.Timestamp.After(since) { // Inclui apenas logs APÓS o timestamp fornecido.
+			entries = append(entries, record.Entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	AdvanceLSN(currentLSN)
+	return entries, nil
+}
+
+// RepairLogTail descarta do fim do log uma linha sem quebra de linha, deixada
+// por uma escrita interrompida (queda durante o WriteLog). A linha nunca foi
+// confirmada e, parcial, poderia ser lida com outro valor (um número cortado
+// ao meio) ou se juntar à próxima entrada escrita. Retorna os bytes
+// descartados. Deve ser chamada antes de ReadLogsFromTimestamp na recuperação.
+func RepairLogTail() (int64, error) {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	fileName := LogPath()
+	f, err := currentFS().OpenFile(fileName, os.O_RDWR, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("erro ao abrir arquivo de log %s: %w", fileName, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao consultar arquivo de log %s: %w", fileName, err)
+	}
+
+	// Procura a última quebra de linha, lendo o arquivo de trás para a frente.
+	size := info.Size()
+	end := size
+	buf := make([]byte, 4096)
+	for end > 0 {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, fmt.Errorf("erro ao ler arquivo de log %s: %w", fileName, err)
+		}
+		if i := strings.LastIndexByte(string(chunk), '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+	if end == size {
+		return 0, nil
+	}
+	if err := f.Truncate(end); err != nil {
+		return 0, fmt.Errorf("erro ao descartar linha incompleta de %s: %w", fileName, err)
+	}
+	if err := f.Sync(); err != nil {
+		return 0, fmt.Errorf("erro ao sincronizar arquivo de log %s: %w", fileName, err)
+	}
+	logging.Warnf("Descartados %d bytes de uma linha incompleta no fim de %s (escrita interrompida).", size-end, fileName)
+	return size - end, nil
+}
+
+// LogRecord é uma linha do arquivo de log lida por ScanLog.
+type LogRecord struct {
+	Line  int      // Número da linha, a partir de 1.
+	Raw   string   // Conteúdo original da linha.
+	Entry LogEntry // Entrada interpretada; se Err != nil, pode estar incompleta.
+	Err   error    // Erro de interpretação da linha.
+}
+
+// ScanLog percorre o arquivo de log em path e chama fn para cada linha não
+// vazia, inclusive as inválidas. Um arquivo inexistente equivale a um log
+// vazio. Se fn retornar erro, a leitura para e o erro é devolvido.
+func ScanLog(path string, fn func(LogRecord) error) error {
+	f, err := openFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return []LogEntry{}, nil
+			return nil
 		}
-		return nil, fmt.Errorf("erro ao abrir arquivo de log: %w", err)
+		return fmt.Errorf("erro ao abrir arquivo de log: %w", err)
 	}
 	defer f.Close()
 
-	var entries []LogEntry
-	scanner := bufio.NewScanner(f)
+	// Um bufio.Reader, e não um bufio.Scanner, para que uma linha longa (um
+	// Push com muitos valores) não interrompa a leitura por exceder o limite
+	// de tamanho do Scanner.
+	var currentLSN uint64
+	reader := bufio.NewReader(f)
 	lineNum := 0
-	for scanner.Scan() {
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("erro ao ler arquivo de log: %w", readErr)
+		}
+		if line == "" && readErr == io.EOF {
+			return nil
+		}
 		lineNum++
-		line := scanner.Text()
-		parts := strings.Fields(line)
-		if len(parts) < 3 {
-			log.Printf("Pulando linha de log malformada %d: %s", lineNum, line)
-			continue
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if strings.TrimSpace(line) != "" {
+			entry, err := parseLogLine(line, currentLSN)
+			if entry.LSN != 0 {
+				currentLSN = entry.LSN
+			}
+			if err := fn(LogRecord{Line: lineNum, Raw: line, Entry: entry, Err: err}); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
 		}
+	}
+}
 
-		timestamp, err := time.Parse(time.RFC3339Nano, parts[0])
-		if err != nil {
-			log.Printf("Pulando linha de log %d (parse timestamp): %v", lineNum, err)
-			continue
+// ApplyLogEntry reaplica uma entrada do log no RemoteList, sem registrá-la
+// novamente. Leituras (Get/Size) não alteram o estado e são ignoradas.
+func ApplyLogEntry(rl *structures.RemoteList, entry LogEntry) {
+	switch entry.Operation {
+	case "Append":
+		rl.Append(structures.AppendArgs{Namespace: entry.Namespace, ListID: entry.ListID, Value: entry.Value}, new(bool))
+	case "Push":
+		rl.Push(structures.PushArgs{Namespace: entry.Namespace, ListID: entry.ListID, Values: entry.Values}, new(int))
+	case "Remove":
+		rl.Remove(structures.RemoveArgs{Namespace: entry.Namespace, ListID: entry.ListID}, new(int))
+	case "Delete":
+		rl.Delete(structures.DeleteArgs{Namespace: entry.Namespace, ListID: entry.ListID}, new(bool))
+	}
+}
+
+// AdvanceLSN garante que o próximo LSN atribuído seja maior que lsn.
+func AdvanceLSN(lsn uint64) {
+	logMu.Lock()
+	if lsn > lastLSN {
+		lastLSN = lsn
+	}
+	logMu.Unlock()
+}
+
+// operationFields retorna quantos campos (após o LSN) a linha de cada operação
+// tem, sem contar o namespace.
+func operationFields(operation string) int {
+	switch operation {
+	case "Append", "Push", "Get/Size":
+		return 4
+	default:
+		return 3
+	}
+}
+
+// LogSize retorna o tamanho atual do arquivo de log em bytes (zero se não existir).
+func LogSize() int64 {
+	info, err := currentFS().Stat(LogPath())
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// CompactionResult descreve o resultado de CompactLog.
+type CompactionResult struct {
+	Kept        int   // Entradas mantidas.
+	Freed       int   // Entradas descartadas.
+	BytesBefore int64 // Tamanho do log antes.
+	BytesAfter  int64 // Tamanho do log depois.
+}
+
+// CompactLog reescreve o log sem as entradas já cobertas por um snapshot
+// (timestamp até coveredUntil) e sem as leituras (Get/Size), que não alteram
+// o estado. As demais linhas são mantidas com seus LSNs; linhas inválidas são
+// preservadas como estão. O novo arquivo é gravado à parte e renomeado, e as
+// escritas no log ficam bloqueadas durante a compactação. Com o log incerto
+// (veja WriteLog), a compactação é recusada: a linha parcial no fim do
+// arquivo seria regravada como uma entrada completa.
+func CompactLog(coveredUntil time.Time) (CompactionResult, error) {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	var result CompactionResult
+	fileName := LogPath()
+	if logBroken != nil {
+		return result, fmt.Errorf("log %s indisponível após falha anterior (reinicie o servidor): %w", fileName, logBroken)
+	}
+	data, err := readFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
 		}
+		return result, fmt.Errorf("erro ao ler arquivo de log %s: %w", fileName, err)
+	}
+	result.BytesBefore = int64(len(data))
 
-		if timestamp.After(since) { // Inclui apenas logs APÓS o timestamp fornecido.
-			entry := LogEntry{Timestamp: timestamp, Operation: parts[1], ListID: parts[2]}
-			switch entry.Operation {
-			case "Append":
-				if len(parts) > 3 {
-					val, err := strconv.Atoi(parts[3])
-					if err != nil {
-						log.Printf("Pulando linha de log %d (parse valor): %v", lineNum, err)
-						continue
-					}
-					entry.Value = val
-				} else {
-					log.Printf("Pulando linha de log Append malformada %d: valor ausente", lineNum)
-					continue
-				}
-			case "Get/Size":
-				if len(parts) > 3 {
-					idx, err := strconv.Atoi(parts[3])
-					if err != nil {
-						log.Printf("Pulando linha de log %d (parse índice): %v", lineNum, err)
-						continue
-					}
-					entry.Index = idx
-				}
-			}
-			entries = append(entries, entry)
+	var compacted strings.Builder
+	var currentLSN uint64
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		entry, err := parseLogLine(line, currentLSN)
+		if entry.LSN != 0 {
+			currentLSN = entry.LSN
 		}
+		switch {
+		case err != nil:
+			compacted.WriteString(line + "\n")
+			result.Kept++
+		case !entry.Timestamp.After(coveredUntil), entry.Operation == "Get/Size":
+			result.Freed++
+		default:
+			// Reescrita no formato atual: linhas antigas passam a ter o LSN explícito.
+			compacted.WriteString(formatLogEntry(entry) + "\n")
+			result.Kept++
+		}
+	}
+	if currentLSN > lastLSN {
+		lastLSN = currentLSN
 	}
 
-	if err := scanner.Err(); err != nil && err != io.EOF {
-		return nil, fmt.Errorf("erro ao ler arquivo de log: %w", err)
+	if err := writeFileAtomic(fileName, []byte(compacted.String())); err != nil {
+		return result, err
 	}
+	result.BytesAfter = int64(compacted.Len())
+	return result, nil
+}
 
-	return entries, nil
-}
\ No newline at end of file
+// writeFileAtomic grava o arquivo em um temporário no mesmo diretório,
+// sincroniza e o renomeia sobre o destino.
+func writeFileAtomic(fileName string, data []byte) error {
+	fsys := currentFS()
+	tmp, err := fsys.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("erro ao criar arquivo temporário para %s: %w", fileName, err)
+	}
+	defer fsys.Remove(tmp.Name()) // Sem efeito após o rename.
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("erro ao escrever %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("erro ao sincronizar %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("erro ao fechar %s: %w", tmp.Name(), err)
+	}
+	if err := fsys.Rename(tmp.Name(), fileName); err != nil {
+		return fmt.Errorf("erro ao substituir %s: %w", fileName, err)
+	}
+	fsys.SyncDir(filepath.Dir(fileName)) // Melhor esforço: nem todo sistema sincroniza diretórios.
+	return nil
+}
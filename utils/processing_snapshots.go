@@ -1,88 +1,368 @@
 package utils
 
 import (
+	"bufio"
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
-	"os"
+	"io"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"sd-miniprojeto-1/logging"
+	"sd-miniprojeto-1/metrics"
 	"sd-miniprojeto-1/structures"
 )
 
 const (
 	snapshotsDir     = "snapshots"          // Diretório de snapshots.
-	snapshotFileName = "remote_list_snapshot.json.gz" // Nome do arquivo de snapshot.
+	snapshotFileName = "remote_list_snapshot.json.gz" // Nome do snapshot único usado antes das gerações.
 )
 
-// SnapshotContent armazena o estado do RemoteList e o timestamp do último log coberto.
-type SnapshotContent struct {
-	RemoteList       *structures.RemoteList // Estado das listas.
-	LastLogTimestamp time.Time            // Timestamp do último log coberto.
+// storedList e storedSnapshot descrevem os snapshots em JSON comprimido, formato
+// anterior ao binário, que continuam sendo lidos. Além das listas agrupadas
+// por namespace, aceitam o formato anterior aos namespaces, em que as listas
+// ficavam direto em RemoteList.Lists e pertencem ao namespace padrão.
+type storedList struct {
+	Elements []int
 }
 
-// SaveSnapshot salva o RemoteList e o timestamp do último log em um arquivo comprimido.
-func SaveSnapshot(rl *structures.RemoteList, lastLogTimestamp time.Time) error {
-	filePath := filepath.Join(snapshotsDir, snapshotFileName)
+type storedSnapshot struct {
+	RemoteList struct {
+		Namespaces map[string]struct {
+			Lists map[string]storedList
+		}
+		Lists map[string]storedList // Formato anterior aos namespaces.
+	}
+	LastLogTimestamp time.Time
+	LastLSN          uint64
+}
+
+// saveMu serializa os salvamentos (periódicos e forçados pelo Admin) e protege lastSaved.
+var saveMu sync.Mutex
+
+// lastSaved é o snapshot mais recente salvo ou carregado, a versão do
+// RemoteList que ele contém e quantos incrementais há na cadeia até ele.
+var lastSaved struct {
+	SavedSnapshot
+	version uint64
+	chain   int
+	valid   bool
+}
+
+// DefaultFullEvery é o intervalo entre snapshots completos quando a
+// configuração não define outro: um completo a cada 10 snapshots, e
+// incrementais entre eles.
+const DefaultFullEvery = 10
+
+var fullEvery atomic.Int64
+
+// SetFullSnapshotInterval define a cada quantos snapshots um é completo; os
+// demais são incrementais sobre o anterior. 1 desativa os incrementais e 0
+// usa DefaultFullEvery.
+func SetFullSnapshotInterval(n int) {
+	if n <= 0 {
+		n = DefaultFullEvery
+	}
+	fullEvery.Store(int64(n))
+}
+
+func fullSnapshotInterval() int {
+	if n := fullEvery.Load(); n > 0 {
+		return int(n)
+	}
+	return DefaultFullEvery
+}
+
+var (
+	lastSnapshotMu sync.Mutex
+	lastSnapshotAt time.Time // Horário do último snapshot salvo ou carregado.
+)
+
+// LastSnapshotTime retorna o horário do último snapshot salvo (ou do arquivo
+// carregado). Um salvamento dispensado por falta de alterações também conta.
+func LastSnapshotTime() time.Time {
+	lastSnapshotMu.Lock()
+	defer lastSnapshotMu.Unlock()
+	return lastSnapshotAt
+}
+
+func setLastSnapshotTime(t time.Time) {
+	lastSnapshotMu.Lock()
+	lastSnapshotAt = t
+	lastSnapshotMu.Unlock()
+}
 
-	rl.Mu.RLock() // Protege o RemoteList para leitura consistente.
-	defer rl.Mu.RUnlock()
+// SavedSnapshot descreve o resultado de SaveSnapshot.
+type SavedSnapshot struct {
+	Path         string    // Arquivo da geração.
+	CoveredUntil time.Time // Timestamp da última operação do log coberta.
+	LSN          uint64    // LSN do log no momento do snapshot.
+	Delta        bool      // Snapshot incremental, sobre a geração anterior.
+	Skipped      bool      // O estado não mudou desde a geração anterior, que continua valendo.
+}
+
+// SaveSnapshot salva o RemoteList em uma nova geração de snapshot, nomeada
+// pelo LSN informado (o último do log), e descarta as gerações que a política de retenção não
+// mantém. A geração é incremental, com as listas alteradas desde o último
+// snapshot, até que a cadeia atinja o intervalo de SetFullSnapshotInterval;
+// então é salvo um snapshot completo, que consolida a cadeia. rl deve manter
+// o registro de alterações (é o caso de uma cópia feita com Clone). Se o
+// estado não mudou desde o último snapshot, nada é gravado e o snapshot
+// anterior é retornado com Skipped. O arquivo é gravado à parte e renomeado,
+// para que um snapshot incompleto nunca seja carregado.
+func SaveSnapshot(rl *structures.RemoteList, lastLogTimestamp time.Time, lsn uint64) (SavedSnapshot, error) {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	// A versão é lida antes da gravação: uma alteração concorrente, no pior
+	// caso, faz o próximo snapshot ser salvo sem necessidade.
+	version := rl.Version()
+	if lastSaved.valid && lastSaved.version == version {
+		if _, err := currentFS().Stat(lastSaved.Path); err == nil {
+			setLastSnapshotTime(Now())
+			skipped := lastSaved.SavedSnapshot
+			skipped.Skipped = true
+			return skipped, nil
+		}
+	}
 
-	content := SnapshotContent{RemoteList: rl, LastLogTimestamp: lastLogTimestamp}
+	start := time.Now()
+	delta := lastSaved.valid && lastSaved.chain+1 < fullSnapshotInterval() &&
+		lsn > lastSaved.LSN && isGeneration(lastSaved.Path, lastSaved.LSN)
 
-	f, err := os.Create(filePath)
+	var filePath string
+	var size int64
+	var err error
+	if delta {
+		filePath = snapshotDeltaPath(lsn)
+		size, err = WriteDeltaSnapshot(filePath, rl, lastSaved.version, lastSaved.LSN, lastLogTimestamp, lsn)
+	} else {
+		filePath = snapshotGenerationPath(lsn)
+		size, err = WriteSnapshot(filePath, rl, lastLogTimestamp, lsn)
+	}
 	if err != nil {
-		return fmt.Errorf("erro ao criar arquivo de snapshot %s: %w", filePath, err)
+		return SavedSnapshot{}, err
 	}
-	defer f.Close()
+	metrics.SnapshotSizeBytes.Set(float64(size))
+	metrics.SnapshotDuration.Observe(time.Since(start).Seconds())
+	setLastSnapshotTime(Now())
 
-	writer := gzip.NewWriter(f)
-	defer writer.Close()
+	saved := SavedSnapshot{Path: filePath, CoveredUntil: lastLogTimestamp, LSN: lsn, Delta: delta}
+	chain := 0
+	if delta {
+		chain = lastSaved.chain + 1
+	}
+	lastSaved.SavedSnapshot, lastSaved.version, lastSaved.chain, lastSaved.valid = saved, version, chain, true
 
-	encoder := json.NewEncoder(writer)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(content); err != nil {
-		return fmt.Errorf("erro ao codificar conteúdo do snapshot: %w", err)
+	removed, err := pruneSnapshots(currentRetention())
+	if err != nil {
+		logging.Warnf("Erro ao aplicar a retenção de snapshots: %v", err)
+	}
+	kind := "completo"
+	if delta {
+		kind = "incremental"
 	}
+	fmt.Printf("Snapshot %s salvo em %s (Cobre logs até: %s; %d gerações antigas removidas).\n", kind, filePath, lastLogTimestamp.Format(time.RFC3339), len(removed))
+	return saved, nil
+}
 
-	fmt.Printf("Snapshot salvo e comprimido em %s (Cobre logs até: %s).\n", filePath, lastLogTimestamp.Format(time.RFC3339))
-	return nil
+// WriteSnapshot grava o RemoteList em filePath, no formato binário e de forma
+// atômica, e retorna o tamanho do arquivo. lastLSN é o último LSN atribuído,
+// para que o servidor continue a sequência do log.
+func WriteSnapshot(filePath string, rl *structures.RemoteList, lastLogTimestamp time.Time, lastLSN uint64) (int64, error) {
+	return writeAtomically(filePath, func(w io.Writer) error {
+		return encodeBinarySnapshot(w, rl, lastLogTimestamp, lastLSN)
+	})
 }
 
-// LoadSnapshot carrega o RemoteList e o timestamp do último log do arquivo comprimido.
-func LoadSnapshot() (*structures.RemoteList, time.Time, error) {
-	filePath := filepath.Join(snapshotsDir, snapshotFileName)
+// WriteDeltaSnapshot grava em filePath, de forma atômica, um snapshot
+// incremental com as listas de rl alteradas após a versão since, sobre o
+// snapshot de LSN parentLSN, e retorna o tamanho do arquivo.
+func WriteDeltaSnapshot(filePath string, rl *structures.RemoteList, since, parentLSN uint64, lastLogTimestamp time.Time, lastLSN uint64) (int64, error) {
+	return writeAtomically(filePath, func(w io.Writer) error {
+		return encodeBinaryDelta(w, rl, since, parentLSN, lastLogTimestamp, lastLSN)
+	})
+}
 
-	f, err := os.Open(filePath)
+// writeAtomically grava o conteúdo produzido por encode em um arquivo
+// temporário no mesmo diretório e o renomeia para filePath após o fsync.
+func writeAtomically(filePath string, encode func(io.Writer) error) (int64, error) {
+	dir, base := filepath.Split(filePath)
+	if dir == "" {
+		dir = "."
+	}
+	fsys := currentFS()
+	f, err := fsys.CreateTemp(dir, base+".tmp-*")
 	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Println("Nenhum snapshot encontrado. Iniciando com um RemoteList vazio.")
-			return structures.NewRemoteList(), time.Time{}, nil
-		}
-		return nil, time.Time{}, fmt.Errorf("erro ao abrir arquivo de snapshot %s: %w", filePath, err)
+		return 0, fmt.Errorf("erro ao criar arquivo de snapshot %s: %w", filePath, err)
 	}
+	defer fsys.Remove(f.Name()) // Sem efeito após o rename.
 	defer f.Close()
 
-	reader, err := gzip.NewReader(f)
+	if err := encode(f); err != nil {
+		return 0, fmt.Errorf("erro ao gravar snapshot %s: %w", filePath, err)
+	}
+	if err := f.Sync(); err != nil {
+		return 0, fmt.Errorf("erro ao sincronizar snapshot: %w", err)
+	}
+	info, err := f.Stat()
 	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("erro ao criar leitor gzip para snapshot %s: %w", filePath, err)
+		return 0, fmt.Errorf("erro ao consultar snapshot: %w", err)
+	}
+	if err := fsys.Rename(f.Name(), filePath); err != nil {
+		return 0, fmt.Errorf("erro ao substituir snapshot %s: %w", filePath, err)
+	}
+	fsys.SyncDir(dir) // Melhor esforço, como em writeFileAtomic.
+	return info.Size(), nil
+}
+
+// Formatos de snapshot informados em SnapshotInfo.Format.
+const (
+	SnapshotFormatBinary = "binário v1"
+	SnapshotFormatDelta  = "incremental v1"
+	SnapshotFormatJSON   = "JSON (gzip)"
+)
+
+// SnapshotInfo descreve um arquivo de snapshot lido por ReadSnapshot.
+type SnapshotInfo struct {
+	Format           string    // SnapshotFormatBinary, SnapshotFormatDelta ou SnapshotFormatJSON.
+	LastLogTimestamp time.Time // Timestamp do último log coberto.
+	LastLSN          uint64    // Último LSN atribuído quando o snapshot foi salvo.
+	ModTime          time.Time // Horário de modificação do arquivo.
+	CompressedBytes  int64     // Tamanho do arquivo.
+	RawBytes         int64     // Tamanho do conteúdo descomprimido (no binário, igual ao do arquivo).
+	Delta            bool      // Snapshot incremental.
+	Parent           uint64    // LSN do snapshot pai (só em incrementais).
+	Base             string    // Snapshot completo em que a cadeia começa (o próprio, se completo).
+	Deltas           int       // Incrementais aplicados sobre Base, incluindo este.
+}
+
+// ReadSnapshot lê e valida o snapshot em path, sem alterar o estado do
+// processo. O formato é reconhecido pelos primeiros bytes: o binário é lido
+// em fluxo, lista a lista; o JSON comprimido é lido até o fim, para que o
+// checksum do gzip seja conferido, e dados após o JSON são rejeitados. Um
+// snapshot incremental é aplicado sobre o pai, procurado no mesmo diretório
+// e lido da mesma forma, até o snapshot completo que inicia a cadeia.
+func ReadSnapshot(path string) (*structures.RemoteList, SnapshotInfo, error) {
+	var info SnapshotInfo
+
+	f, err := openFile(path)
+	if err != nil {
+		return nil, info, fmt.Errorf("erro ao abrir arquivo de snapshot %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if stat, err := f.Stat(); err == nil {
+		info.ModTime = stat.ModTime()
+		info.CompressedBytes = stat.Size()
+	}
+
+	buffered := bufio.NewReader(f)
+	if magic, _ := buffered.Peek(len(binarySnapshotMagic)); isBinarySnapshot(magic) {
+		decoder := &binaryDecoder{r: buffered}
+		if err := decoder.header(&info); err != nil {
+			return nil, info, fmt.Errorf("snapshot %s inválido: %w", path, err)
+		}
+		info.Format, info.Base = SnapshotFormatBinary, path
+		remoteList := structures.NewRemoteList()
+		if info.Delta {
+			info.Format = SnapshotFormatDelta
+			parentPath, ok := findGeneration(filepath.Dir(path), info.Parent)
+			if !ok {
+				return nil, info, fmt.Errorf("snapshot incremental %s: pai (LSN %d) não encontrado", path, info.Parent)
+			}
+			parent, parentInfo, err := ReadSnapshot(parentPath)
+			if err != nil {
+				return nil, info, fmt.Errorf("snapshot incremental %s: pai inválido: %w", path, err)
+			}
+			remoteList, info.Base, info.Deltas = parent, parentInfo.Base, parentInfo.Deltas+1
+		}
+		if err := decoder.records(remoteList, info.Delta); err != nil {
+			return nil, info, fmt.Errorf("snapshot %s inválido: %w", path, err)
+		}
+		info.RawBytes = decoder.bytes
+		return remoteList, info, nil
+	}
+
+	info.Format, info.Base = SnapshotFormatJSON, path
+	reader, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, info, fmt.Errorf("erro ao criar leitor gzip para snapshot %s: %w", path, err)
 	}
 	defer reader.Close()
 
-	decoder := json.NewDecoder(reader)
-	var content SnapshotContent
-	if err := decoder.Decode(&content); err != nil {
-		return nil, time.Time{}, fmt.Errorf("erro ao decodificar conteúdo do snapshot de %s: %w", filePath, err)
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, info, fmt.Errorf("erro ao descomprimir snapshot %s: %w", path, err)
 	}
+	info.RawBytes = int64(len(data))
 
-	// Reinicializa mutexes das listas carregadas.
-	reconstructedLists := make(map[string]*structures.SpecificList)
+	var content storedSnapshot
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, info, fmt.Errorf("erro ao decodificar conteúdo do snapshot de %s: %w", path, err)
+	}
+	info.LastLogTimestamp = content.LastLogTimestamp
+	info.LastLSN = content.LastLSN
+
+	// Reconstrói as listas (com mutexes e contadores de elementos inicializados).
+	remoteList := structures.NewRemoteList()
 	for listID, loadedList := range content.RemoteList.Lists {
-		reconstructedLists[listID] = structures.NewSpecificList(loadedList.Elements)
+		remoteList.Restore(structures.DefaultNamespace, listID, loadedList.Elements)
+	}
+	for namespace, loadedNamespace := range content.RemoteList.Namespaces {
+		for listID, loadedList := range loadedNamespace.Lists {
+			remoteList.Restore(namespace, listID, loadedList.Elements)
+		}
+	}
+	return remoteList, info, nil
+}
+
+// readSnapshotHeader lê apenas o cabeçalho do snapshot binário em path.
+func readSnapshotHeader(path string) (SnapshotInfo, error) {
+	var info SnapshotInfo
+	f, err := openFile(path)
+	if err != nil {
+		return info, err
 	}
-	content.RemoteList.Lists = reconstructedLists
+	defer f.Close()
+
+	decoder := &binaryDecoder{r: bufio.NewReader(f)}
+	err = decoder.header(&info)
+	return info, err
+}
 
-	fmt.Printf("Snapshot carregado de %s (Cobre logs até: %s).\n", filePath, content.LastLogTimestamp.Format(time.RFC3339))
-	return content.RemoteList, content.LastLogTimestamp, nil
-}
\ No newline at end of file
+// LoadSnapshot carrega a geração de snapshot válida mais nova e retorna o
+// RemoteList e a descrição do snapshot (com o timestamp e o LSN cobertos).
+// Gerações que não puderem ser lidas são ignoradas, com um aviso; se nenhuma
+// for válida, retorna erro.
+func LoadSnapshot() (*structures.RemoteList, SnapshotInfo, error) {
+	files, err := Snapshots()
+	if err != nil {
+		return nil, SnapshotInfo{}, err
+	}
+	if len(files) == 0 {
+		fmt.Println("Nenhum snapshot encontrado. Iniciando com um RemoteList vazio.")
+		return structures.NewRemoteList(), SnapshotInfo{}, nil
+	}
+
+	for _, file := range files {
+		remoteList, info, err := ReadSnapshot(file.Path)
+		if err != nil {
+			logging.Warnf("Ignorando snapshot inválido: %v", err)
+			continue
+		}
+		setLastSnapshotTime(info.ModTime)
+
+		saveMu.Lock()
+		lastSaved.SavedSnapshot = SavedSnapshot{Path: file.Path, CoveredUntil: info.LastLogTimestamp, LSN: info.LastLSN, Delta: info.Delta}
+		lastSaved.version, lastSaved.chain, lastSaved.valid = remoteList.Version(), info.Deltas, true
+		saveMu.Unlock()
+
+		fmt.Printf("Snapshot carregado de %s (Cobre logs até: %s).\n", file.Path, info.LastLogTimestamp.Format(time.RFC3339))
+		return remoteList, info, nil
+	}
+	return nil, SnapshotInfo{}, fmt.Errorf("nenhum dos %d snapshots em %s é válido", len(files), snapshotsDir)
+}
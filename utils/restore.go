@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// RecoveryTarget é o ponto até onde o log é aplicado na recuperação: um
+// instante, um LSN ou ambos (vale o que vier primeiro). Zero não limita.
+type RecoveryTarget struct {
+	Time time.Time
+	LSN  uint64
+}
+
+// lsnTargetPrefix identifica um alvo por LSN em ParseRecoveryTarget.
+const lsnTargetPrefix = "lsn:"
+
+// ParseRecoveryTarget interpreta "lsn:<número>" ou um horário em RFC3339.
+func ParseRecoveryTarget(value string) (RecoveryTarget, error) {
+	if rest, ok := strings.CutPrefix(value, lsnTargetPrefix); ok {
+		lsn, err := strconv.ParseUint(rest, 10, 64)
+		if err != nil || lsn == 0 {
+			return RecoveryTarget{}, fmt.Errorf("LSN %q inválido", rest)
+		}
+		return RecoveryTarget{LSN: lsn}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return RecoveryTarget{}, fmt.Errorf("alvo %q inválido: use lsn:<número> ou um horário em RFC3339", value)
+	}
+	return RecoveryTarget{Time: t}, nil
+}
+
+// String descreve o alvo.
+func (t RecoveryTarget) String() string {
+	switch {
+	case t.Time.IsZero() && t.LSN == 0:
+		return "fim do log"
+	case t.Time.IsZero():
+		return fmt.Sprintf("LSN %d", t.LSN)
+	case t.LSN == 0:
+		return t.Time.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%s ou LSN %d", t.Time.Format(time.RFC3339Nano), t.LSN)
+}
+
+// includes indica se a entrada está dentro do alvo.
+func (t RecoveryTarget) includes(entry LogEntry) bool {
+	return (t.Time.IsZero() || !entry.Timestamp.After(t.Time)) && (t.LSN == 0 || entry.LSN <= t.LSN)
+}
+
+// covers indica se um snapshot pode ser o ponto de partida para o alvo, ou
+// seja, se não contém operações posteriores a ele.
+func (t RecoveryTarget) covers(info SnapshotInfo) bool {
+	return (t.Time.IsZero() || !info.LastLogTimestamp.After(t.Time)) && (t.LSN == 0 || info.LastLSN <= t.LSN)
+}
+
+// RestoreInfo descreve uma recuperação feita por RestoreAt.
+type RestoreInfo struct {
+	Snapshot       string    // Snapshot usado como ponto de partida (vazio se nenhum).
+	Since          time.Time // Timestamp coberto pelo snapshot.
+	Applied        int       // Alterações do log aplicadas (leituras registradas não contam).
+	Skipped        int       // Linhas inválidas ignoradas.
+	LastApplied    LogEntry  // Última alteração aplicada.
+	LogLastLSN     uint64    // Maior LSN encontrado no log (aplicado ou não).
+	SnapshotTooNew bool      // Se algum snapshot foi ignorado por ser posterior ao alvo.
+}
+
+// RestoreAt reconstrói o RemoteList no ponto target a partir de um snapshot
+// anterior ao alvo e do log em logPath, sem alterar o estado do processo. Com
+// snapshotPath vazio, usa a geração válida mais nova anterior ao alvo; caso
+// contrário, só o snapshot informado (se existir e for anterior ao alvo). Sem
+// snapshot utilizável, parte de uma lista vazia, o que exige o log completo,
+// desde o LSN 1 (sem compactação).
+func RestoreAt(snapshotPath, logPath string, target RecoveryTarget) (*structures.RemoteList, RestoreInfo, error) {
+	var info RestoreInfo
+	rl := structures.NewRemoteList()
+
+	candidates := []string{snapshotPath}
+	if snapshotPath == "" {
+		files, err := Snapshots()
+		if err != nil {
+			return nil, info, err
+		}
+		candidates = candidates[:0]
+		for _, file := range files {
+			candidates = append(candidates, file.Path)
+		}
+	}
+	for _, path := range candidates {
+		loaded, snapshot, err := ReadSnapshot(path)
+		if err != nil {
+			if snapshotPath != "" && !errors.Is(err, fs.ErrNotExist) {
+				return nil, info, err
+			}
+			continue // Gerações inválidas são ignoradas, como em LoadSnapshot.
+		}
+		if target.covers(snapshot) {
+			rl, info.Snapshot, info.Since = loaded, path, snapshot.LastLogTimestamp
+			break
+		}
+		info.SnapshotTooNew = true
+	}
+
+	first := true
+	err := ScanLog(logPath, func(record LogRecord) error {
+		if record.Entry.LSN > info.LogLastLSN {
+			info.LogLastLSN = record.Entry.LSN
+		}
+		if record.Err != nil {
+			info.Skipped++
+			return nil
+		}
+		entry := record.Entry
+		if first && info.Snapshot == "" && entry.LSN != 1 {
+			return fmt.Errorf("o log começa no LSN %d (foi compactado); é preciso um snapshot anterior a %s", entry.LSN, target)
+		}
+		first = false
+		if entry.Timestamp.After(info.Since) && target.includes(entry) && entry.Operation != "Get/Size" {
+			ApplyLogEntry(rl, entry)
+			info.Applied++
+			info.LastApplied = entry
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	return rl, info, nil
+}
+
+// CommitRestore torna rl o estado persistido do servidor após uma recuperação
+// até um ponto anterior ao fim do log: o log e as gerações de snapshot atuais
+// são preservados com o sufixo ".pre-restore-<horário>" e substituídos por uma
+// nova geração com rl e um log vazio. O snapshot novo registra logLastLSN,
+// para que os LSNs novos não repitam os do log preservado. Deve ser chamada
+// antes de LoadSnapshot, que então carrega o estado recuperado.
+func CommitRestore(rl *structures.RemoteList, logLastLSN uint64) error {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	// O snapshot novo é gravado antes de mexer nos arquivos atuais.
+	restored := filepath.Join(snapshotsDir, "remote_list_snapshot.restore")
+	if _, err := WriteSnapshot(restored, rl, Now(), logLastLSN); err != nil {
+		return err
+	}
+
+	files, err := Snapshots()
+	if err != nil {
+		return err
+	}
+	paths := []string{LogPath()}
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	suffix := ".pre-restore-" + time.Now().Format("20060102T150405")
+	for _, path := range paths {
+		if err := currentFS().Rename(path, path+suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("erro ao preservar %s: %w", path, err)
+		}
+	}
+	if err := currentFS().Rename(restored, snapshotGenerationPath(logLastLSN)); err != nil {
+		return fmt.Errorf("erro ao instalar snapshot recuperado: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRestoreAtCountsOnlyMutations confere que as leituras registradas no log
+// são ignoradas: não contam em Applied nem viram LastApplied.
+func TestRestoreAtCountsOnlyMutations(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []LogEntry{
+		{Operation: "Append", ListID: "a", Value: 1},
+		{Operation: "Get/Size", ListID: "a", Index: 0},
+		{Operation: "Push", ListID: "a", Values: []int{2, 3}},
+		{Operation: "Get/Size", ListID: "a", Index: -1},
+		{Operation: "Remove", ListID: "a"},
+		{Operation: "Append", ListID: "b", Value: 4},
+		{Operation: "Delete", ListID: "b"},
+		{Operation: "Get/Size", ListID: "a", Index: 1},
+	}
+	var lines []string
+	for i := range entries {
+		entries[i].LSN = uint64(i + 1)
+		entries[i].Timestamp = start.Add(time.Duration(i) * time.Second)
+		lines = append(lines, formatLogEntry(entries[i]))
+	}
+	logPath := filepath.Join(t.TempDir(), "operations.log")
+	if err := os.WriteFile(logPath, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		target      RecoveryTarget
+		applied     int
+		lastApplied uint64
+		want        map[string][]int
+	}{
+		{"fim do log", RecoveryTarget{}, 5, 7, map[string][]int{"default/a": {1, 2}}},
+		{"depois de uma leitura", RecoveryTarget{LSN: 4}, 2, 3, map[string][]int{"default/a": {1, 2, 3}}},
+		{"só leituras após o início", RecoveryTarget{LSN: 2}, 1, 1, map[string][]int{"default/a": {1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl, info, err := RestoreAt(filepath.Join(t.TempDir(), "inexistente"), logPath, tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Applied != tt.applied || info.LastApplied.LSN != tt.lastApplied {
+				t.Errorf("%d alterações aplicadas, a última no LSN %d; esperado %d e LSN %d",
+					info.Applied, info.LastApplied.LSN, tt.applied, tt.lastApplied)
+			}
+			if info.LogLastLSN != uint64(len(entries)) {
+				t.Errorf("maior LSN do log %d, esperado %d", info.LogLastLSN, len(entries))
+			}
+			if got := snapshotState(rl); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("estado %v, esperado %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// Formato binário de snapshot (versão 1), gravado e lido em fluxo:
+//
+//	cabeçalho:   "RLSNAP" <versão> <LastLSN> <LastLogTimestamp> <crc32>
+//	incremental: "RLDELT" <versão> <LastLSN> <LastLogTimestamp> <LSN do pai> <crc32>
+//	lista:       'L' <namespace> <ID> <quantidade> <blocos...> <crc32>
+//	remoção:     'D' <namespace> <ID> <crc32> (só em incrementais)
+//	bloco:       <n> <n diferenças entre elementos consecutivos>
+//	fim:         'E' <quantidade de registros> <crc32>
+//
+// Números são varints (as diferenças, com sinal), textos têm o tamanho como
+// prefixo e cada crc32 (Castagnoli, little-endian) cobre o registro desde o
+// checksum anterior. Cada registro é conferido antes de ser aplicado, e o
+// arquivo só é aceito por inteiro se o registro final for encontrado.
+//
+// Um snapshot incremental contém apenas as listas alteradas (por inteiro) e
+// removidas desde o snapshot pai, identificado pelo LSN, que por sua vez
+// pode ser outro incremental; a cadeia termina em um snapshot completo.
+
+const (
+	binarySnapshotMagic   = "RLSNAP"
+	binaryDeltaMagic      = "RLDELT"
+	binarySnapshotVersion = 1
+
+	snapshotListTag   = 'L'
+	snapshotDeleteTag = 'D'
+	snapshotEndTag    = 'E'
+
+	snapshotChunkSize    = 4096    // Máximo de elementos por bloco.
+	maxSnapshotStringLen = 1 << 16 // Limite para namespaces e IDs, contra tamanhos corrompidos.
+)
+
+var snapshotCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// binaryEncoder grava o snapshot binário e acumula o checksum do registro atual.
+type binaryEncoder struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func newBinaryEncoder(w io.Writer) *binaryEncoder {
+	return &binaryEncoder{w: bufio.NewWriter(w), crc: crc32.New(snapshotCRCTable)}
+}
+
+func (e *binaryEncoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(p)
+	e.crc.Write(p)
+}
+
+func (e *binaryEncoder) uvarint(v uint64) {
+	e.write(e.buf[:binary.PutUvarint(e.buf[:], v)])
+}
+
+func (e *binaryEncoder) varint(v int64) {
+	e.write(e.buf[:binary.PutVarint(e.buf[:], v)])
+}
+
+func (e *binaryEncoder) bytes(p []byte) {
+	e.uvarint(uint64(len(p)))
+	e.write(p)
+}
+
+// checksum encerra o registro atual gravando seu crc32.
+func (e *binaryEncoder) checksum() {
+	if e.err != nil {
+		return
+	}
+	binary.LittleEndian.PutUint32(e.buf[:4], e.crc.Sum32())
+	_, e.err = e.w.Write(e.buf[:4])
+	e.crc.Reset()
+}
+
+// header grava o início do cabeçalho; o chamador completa os campos
+// específicos do tipo de snapshot e encerra com checksum.
+func (e *binaryEncoder) header(magic string, lastLogTimestamp time.Time, lastLSN uint64) error {
+	timestamp, err := lastLogTimestamp.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("erro ao codificar timestamp do snapshot: %w", err)
+	}
+	e.write([]byte(magic))
+	e.write([]byte{binarySnapshotVersion})
+	e.uvarint(lastLSN)
+	e.bytes(timestamp)
+	return nil
+}
+
+// list grava o registro de uma lista, em blocos de snapshotChunkSize elementos.
+func (e *binaryEncoder) list(namespace, listID string, elements []int) {
+	e.write([]byte{snapshotListTag})
+	e.bytes([]byte(namespace))
+	e.bytes([]byte(listID))
+	e.uvarint(uint64(len(elements)))
+	prev := 0
+	for start := 0; start < len(elements); start += snapshotChunkSize {
+		chunk := elements[start:min(start+snapshotChunkSize, len(elements))]
+		e.uvarint(uint64(len(chunk)))
+		for _, v := range chunk {
+			e.varint(int64(v - prev))
+			prev = v
+		}
+	}
+	e.checksum()
+}
+
+// end grava o registro final e descarrega o buffer.
+func (e *binaryEncoder) end(records uint64) error {
+	e.write([]byte{snapshotEndTag})
+	e.uvarint(records)
+	e.checksum()
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
+
+// encodeBinarySnapshot grava rl no formato binário, uma lista por vez.
+func encodeBinarySnapshot(w io.Writer, rl *structures.RemoteList, lastLogTimestamp time.Time, lastLSN uint64) error {
+	e := newBinaryEncoder(w)
+	if err := e.header(binarySnapshotMagic, lastLogTimestamp, lastLSN); err != nil {
+		return err
+	}
+	e.checksum()
+
+	var lists uint64
+	err := rl.EachList(func(namespace, listID string, elements []int) error {
+		e.list(namespace, listID, elements)
+		lists++
+		return e.err
+	})
+	if err != nil {
+		return err
+	}
+	return e.end(lists)
+}
+
+// encodeBinaryDelta grava um snapshot incremental com as listas de rl
+// alteradas após a versão since, sobre o snapshot pai de LSN parentLSN.
+func encodeBinaryDelta(w io.Writer, rl *structures.RemoteList, since, parentLSN uint64, lastLogTimestamp time.Time, lastLSN uint64) error {
+	e := newBinaryEncoder(w)
+	if err := e.header(binaryDeltaMagic, lastLogTimestamp, lastLSN); err != nil {
+		return err
+	}
+	e.uvarint(parentLSN)
+	e.checksum()
+
+	var records uint64
+	err := rl.EachChangedList(since, func(namespace, listID string, elements []int, exists bool) error {
+		if exists {
+			e.list(namespace, listID, elements)
+		} else {
+			e.write([]byte{snapshotDeleteTag})
+			e.bytes([]byte(namespace))
+			e.bytes([]byte(listID))
+			e.checksum()
+		}
+		records++
+		return e.err
+	})
+	if err != nil {
+		return err
+	}
+	return e.end(records)
+}
+
+// binaryDecoder lê o snapshot binário e acumula o checksum do registro atual.
+type binaryDecoder struct {
+	r     *bufio.Reader
+	crc   uint32
+	bytes int64 // Bytes lidos.
+}
+
+// ReadByte implementa io.ByteReader, para binary.ReadUvarint e ReadVarint.
+func (d *binaryDecoder) ReadByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	d.bytes++
+	d.crc = crc32.Update(d.crc, snapshotCRCTable, []byte{b})
+	return b, nil
+}
+
+func (d *binaryDecoder) read(n int) ([]byte, error) {
+	p := make([]byte, n)
+	if _, err := io.ReadFull(d.r, p); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	d.bytes += int64(n)
+	d.crc = crc32.Update(d.crc, snapshotCRCTable, p)
+	return p, nil
+}
+
+func (d *binaryDecoder) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(d)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		err = fmt.Errorf("varint inválido: %w", err)
+	}
+	return v, err
+}
+
+func (d *binaryDecoder) text() (string, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if n > maxSnapshotStringLen {
+		return "", fmt.Errorf("texto de %d bytes excede o limite de %d", n, maxSnapshotStringLen)
+	}
+	p, err := d.read(int(n))
+	return string(p), err
+}
+
+// checksum lê o crc32 que encerra o registro e o compara com o calculado.
+func (d *binaryDecoder) checksum(record string) error {
+	want := d.crc
+	var stored [4]byte
+	if _, err := io.ReadFull(d.r, stored[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	d.bytes += 4
+	d.crc = 0
+	if got := binary.LittleEndian.Uint32(stored[:]); got != want {
+		return fmt.Errorf("checksum de %s não confere (gravado %08x, calculado %08x)", record, got, want)
+	}
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// isBinarySnapshot indica se o início do arquivo é o de um snapshot binário,
+// completo ou incremental.
+func isBinarySnapshot(magic []byte) bool {
+	return string(magic) == binarySnapshotMagic || string(magic) == binaryDeltaMagic
+}
+
+// header lê o cabeçalho, preenchendo info (inclusive Delta e Parent).
+func (d *binaryDecoder) header(info *SnapshotInfo) error {
+	header, err := d.read(len(binarySnapshotMagic) + 1)
+	if err != nil {
+		return fmt.Errorf("cabeçalho: %w", err)
+	}
+	magic := header[:len(binarySnapshotMagic)]
+	if !isBinarySnapshot(magic) {
+		return errors.New("não é um snapshot binário")
+	}
+	if version := header[len(binarySnapshotMagic)]; version != binarySnapshotVersion {
+		return fmt.Errorf("versão %d do formato binário não suportada", version)
+	}
+	info.Delta = string(magic) == binaryDeltaMagic
+	if info.LastLSN, err = d.uvarint(); err != nil {
+		return fmt.Errorf("cabeçalho: %w", err)
+	}
+	timestamp, err := d.text()
+	if err != nil {
+		return fmt.Errorf("cabeçalho: %w", err)
+	}
+	if err := info.LastLogTimestamp.UnmarshalBinary([]byte(timestamp)); err != nil {
+		return fmt.Errorf("cabeçalho: timestamp inválido: %w", err)
+	}
+	if info.Delta {
+		if info.Parent, err = d.uvarint(); err != nil {
+			return fmt.Errorf("cabeçalho: %w", err)
+		}
+		if info.Parent >= info.LastLSN {
+			return fmt.Errorf("cabeçalho: LSN do pai (%d) não é anterior ao do snapshot (%d)", info.Parent, info.LastLSN)
+		}
+	}
+	return d.checksum("cabeçalho")
+}
+
+// records lê os registros após o cabeçalho e os aplica em rl assim que cada
+// um é conferido, sem manter o arquivo inteiro em memória. Remoções só são
+// aceitas em snapshots incrementais.
+func (d *binaryDecoder) records(rl *structures.RemoteList, delta bool) error {
+	var records uint64
+	for {
+		tag, err := d.ReadByte()
+		if err != nil {
+			return fmt.Errorf("após %d registros: %w", records, err)
+		}
+		switch {
+		case tag == snapshotEndTag:
+			count, err := d.uvarint()
+			if err != nil {
+				return fmt.Errorf("registro final: %w", err)
+			}
+			if err := d.checksum("registro final"); err != nil {
+				return err
+			}
+			if count != records {
+				return fmt.Errorf("registro final indica %d registros, mas foram lidos %d", count, records)
+			}
+			if _, err := d.r.ReadByte(); err != io.EOF {
+				return errors.New("dados após o registro final")
+			}
+			return nil
+
+		case tag == snapshotListTag:
+			namespace, listID, elements, err := d.list()
+			if err != nil {
+				return fmt.Errorf("registro %d: %w", records+1, err)
+			}
+			rl.Restore(namespace, listID, elements)
+
+		case tag == snapshotDeleteTag && delta:
+			namespace, listID, err := d.deletion()
+			if err != nil {
+				return fmt.Errorf("registro %d: %w", records+1, err)
+			}
+			rl.RestoreDeleted(namespace, listID)
+
+		default:
+			return fmt.Errorf("registro desconhecido 0x%02x após %d registros", tag, records)
+		}
+		records++
+	}
+}
+
+// deletion lê uma remoção, após a marca de registro, e confere seu checksum.
+func (d *binaryDecoder) deletion() (namespace, listID string, err error) {
+	if namespace, err = d.text(); err != nil {
+		return
+	}
+	if listID, err = d.text(); err != nil {
+		return
+	}
+	err = d.checksum(fmt.Sprintf("remoção da lista '%s' (namespace '%s')", listID, namespace))
+	return
+}
+
+// list lê uma lista, após a marca de registro, e confere seu checksum.
+func (d *binaryDecoder) list() (namespace, listID string, elements []int, err error) {
+	if namespace, err = d.text(); err != nil {
+		return
+	}
+	if listID, err = d.text(); err != nil {
+		return
+	}
+	total, err := d.uvarint()
+	if err != nil {
+		return
+	}
+
+	// A capacidade cresce bloco a bloco, para que uma quantidade corrompida
+	// não provoque uma alocação enorme.
+	elements = make([]int, 0, min(total, snapshotChunkSize))
+	prev := int64(0)
+	for uint64(len(elements)) < total {
+		var n uint64
+		if n, err = d.uvarint(); err != nil {
+			return
+		}
+		if n == 0 || n > snapshotChunkSize || n > total-uint64(len(elements)) {
+			err = fmt.Errorf("bloco de %d elementos inválido (%d de %d lidos)", n, len(elements), total)
+			return
+		}
+		for i := uint64(0); i < n; i++ {
+			var delta int64
+			if delta, err = binary.ReadVarint(d); err != nil {
+				return
+			}
+			prev += delta
+			elements = append(elements, int(prev))
+		}
+	}
+	err = d.checksum(fmt.Sprintf("lista '%s' (namespace '%s')", listID, namespace))
+	return
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// snapshotState retorna o conteúdo das listas, por "namespace/lista".
+func snapshotState(rl *structures.RemoteList) map[string][]int {
+	lists := make(map[string][]int)
+	rl.EachList(func(namespace, listID string, elements []int) error {
+		lists[namespace+"/"+listID] = append([]int(nil), elements...)
+		return nil
+	})
+	return lists
+}
+
+// push acrescenta values à lista, criando-a se preciso.
+func push(t *testing.T, rl *structures.RemoteList, namespace, listID string, values ...int) {
+	t.Helper()
+	if err := rl.Push(structures.PushArgs{Namespace: namespace, ListID: listID, Values: values}, new(int)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBinarySnapshotRoundTrip(t *testing.T) {
+	long := make([]int, 2*snapshotChunkSize+3) // Três blocos.
+	for i := range long {
+		long[i] = i * i % 1000
+	}
+	tests := []struct {
+		name  string
+		lists map[string][]int // Por "namespace/lista".
+	}{
+		{"sem listas", map[string][]int{}},
+		{"lista vazia", map[string][]int{"default/a": {}}},
+		{"negativos e extremos", map[string][]int{"default/a": {-1, 0, 1 << 40, -(1 << 40), 7}}},
+		{"vários blocos", map[string][]int{"default/longa": long}},
+		{"namespaces", map[string][]int{"default/a": {1}, "teamA/a": {2, 3}, "teamB/pedidos:2024/jan": {4}}},
+	}
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := structures.NewRemoteList()
+			for key, elements := range tt.lists {
+				namespace, listID, _ := strings.Cut(key, "/")
+				rl.Restore(namespace, listID, elements)
+			}
+			path := generationPath(t.TempDir(), 42, "snap")
+			if _, err := WriteSnapshot(path, rl, timestamp, 42); err != nil {
+				t.Fatal(err)
+			}
+			got, info, err := ReadSnapshot(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := snapshotState(rl); !reflect.DeepEqual(snapshotState(got), want) {
+				t.Fatalf("lido %v, esperado %v", snapshotState(got), want)
+			}
+			if info.Format != SnapshotFormatBinary || info.LastLSN != 42 || !info.LastLogTimestamp.Equal(timestamp) || info.Delta {
+				t.Fatalf("cabeçalho lido: %+v", info)
+			}
+		})
+	}
+}
+
+// TestBinarySnapshotCorruption confere que qualquer byte alterado ou
+// faltando, ou dados a mais, fazem a leitura falhar em vez de carregar um
+// estado parcial.
+func TestBinarySnapshotCorruption(t *testing.T) {
+	dir := t.TempDir()
+	rl := structures.NewRemoteList()
+	push(t, rl, structures.DefaultNamespace, "a", 1, 2, 3)
+	push(t, rl, "teamA", "b", -4)
+	base := generationPath(dir, 10, "snap")
+	if _, err := WriteSnapshot(base, rl, time.Now(), 10); err != nil {
+		t.Fatal(err)
+	}
+	since := rl.Version()
+	push(t, rl, structures.DefaultNamespace, "a", 5)
+	if err := rl.Delete(structures.DeleteArgs{Namespace: "teamA", ListID: "b"}, new(bool)); err != nil {
+		t.Fatal(err)
+	}
+	delta := generationPath(dir, 20, "delta")
+	if _, err := WriteDeltaSnapshot(delta, rl, since, 10, time.Now(), 20); err != nil {
+		t.Fatal(err)
+	}
+
+	parent, err := os.ReadFile(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{base, delta} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		type corruption struct {
+			name string
+			data []byte
+		}
+		tests := []corruption{
+			{"dados após o registro final", append(append([]byte(nil), data...), 0)},
+		}
+		for i := range data {
+			flipped := append([]byte(nil), data...)
+			flipped[i] ^= 0x01
+			tests = append(tests,
+				corruption{"byte " + strconv.Itoa(i) + " alterado", flipped},
+				corruption{"cortado no byte " + strconv.Itoa(i), data[:i]})
+		}
+		for _, tt := range tests {
+			// O pai do incremental fica no mesmo diretório, íntegro.
+			dir := t.TempDir()
+			if err := os.WriteFile(generationPath(dir, 10, "snap"), parent, 0644); err != nil {
+				t.Fatal(err)
+			}
+			corrupted := filepath.Join(dir, filepath.Base(path))
+			if err := os.WriteFile(corrupted, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got, _, err := ReadSnapshot(corrupted); err == nil {
+				t.Errorf("%s, %s: lido %v sem erro", filepath.Base(path), tt.name, snapshotState(got))
+			}
+		}
+	}
+}
+
+// TestDeltaChain confere que um incremental é aplicado sobre a cadeia de pais
+// até o snapshot completo, com remoções e recriações no meio.
+func TestDeltaChain(t *testing.T) {
+	dir := t.TempDir()
+	rl := structures.NewRemoteList()
+	push(t, rl, structures.DefaultNamespace, "a", 1)
+	push(t, rl, structures.DefaultNamespace, "b", 2)
+	push(t, rl, "teamA", "c", 3)
+	base := generationPath(dir, 10, "snap")
+	if _, err := WriteSnapshot(base, rl, time.Now(), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	// Primeiro incremental: altera a, remove b e cria d.
+	since := rl.Version()
+	push(t, rl, structures.DefaultNamespace, "a", 4)
+	rl.Delete(structures.DeleteArgs{ListID: "b"}, new(bool))
+	push(t, rl, "teamA", "d", 5)
+	if _, err := WriteDeltaSnapshot(generationPath(dir, 20, "delta"), rl, since, 10, time.Now(), 20); err != nil {
+		t.Fatal(err)
+	}
+	afterFirst := snapshotState(rl)
+
+	// Segundo incremental: recria b, remove c e não toca em a.
+	since = rl.Version()
+	push(t, rl, structures.DefaultNamespace, "b", 6)
+	rl.Delete(structures.DeleteArgs{Namespace: "teamA", ListID: "c"}, new(bool))
+	last := generationPath(dir, 30, "delta")
+	if _, err := WriteDeltaSnapshot(last, rl, since, 20, time.Now(), 30); err != nil {
+		t.Fatal(err)
+	}
+
+	got, info, err := ReadSnapshot(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := snapshotState(rl); !reflect.DeepEqual(snapshotState(got), want) {
+		t.Fatalf("lido %v, esperado %v", snapshotState(got), want)
+	}
+	if info.Format != SnapshotFormatDelta || info.Base != base || info.Deltas != 2 || info.Parent != 20 || info.LastLSN != 30 {
+		t.Fatalf("cabeçalho lido: %+v", info)
+	}
+
+	got, info, err = ReadSnapshot(generationPath(dir, 20, "delta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snapshotState(got), afterFirst) || info.Deltas != 1 {
+		t.Fatalf("primeiro incremental: lido %v (%d incrementais), esperado %v", snapshotState(got), info.Deltas, afterFirst)
+	}
+
+	// Sem o pai (ou com o pai inválido), a cadeia não é carregada.
+	if err := os.WriteFile(base, []byte("RLSNAP"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadSnapshot(last); err == nil {
+		t.Fatal("cadeia lida com o snapshot completo corrompido")
+	}
+	if err := os.Remove(base); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadSnapshot(last); err == nil {
+		t.Fatal("cadeia lida sem o snapshot completo")
+	}
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Gerações de snapshot: cada snapshot é salvo em um arquivo próprio, nomeado
+// pelo LSN do log no momento do salvamento, e os antigos são descartados
+// conforme a política de retenção.
+
+// SnapshotFile é um arquivo de snapshot no diretório de snapshots.
+type SnapshotFile struct {
+	Path    string
+	LSN     uint64    // LSN do log quando o snapshot foi salvo (0 no arquivo anterior às gerações).
+	ModTime time.Time // Horário em que o arquivo foi gravado.
+	Size    int64     // Tamanho em bytes.
+	Delta   bool      // Snapshot incremental.
+	Parent  uint64    // LSN da geração da qual o incremental depende.
+}
+
+// snapshotGenerationPattern reconhece os arquivos de geração: completos,
+// incrementais ou em JSON comprimido (anteriores ao formato binário);
+// temporários e arquivos preservados por uma recuperação (.pre-restore-*)
+// não casam.
+var snapshotGenerationPattern = regexp.MustCompile(`^remote_list_snapshot-(\d+)\.(snap|delta|json\.gz)$`)
+
+// snapshotGenerationExtensions são as extensões das gerações, na ordem de
+// busca de findGeneration.
+var snapshotGenerationExtensions = []string{"snap", "delta", "json.gz"}
+
+// snapshotGenerationPath retorna o caminho da geração com o LSN informado. O
+// LSN tem largura fixa para que a ordem alfabética seja a ordem das gerações.
+func snapshotGenerationPath(lsn uint64) string {
+	return generationPath(snapshotsDir, lsn, "snap")
+}
+
+// snapshotDeltaPath retorna o caminho do snapshot incremental com o LSN informado.
+func snapshotDeltaPath(lsn uint64) string {
+	return generationPath(snapshotsDir, lsn, "delta")
+}
+
+func generationPath(dir string, lsn uint64, extension string) string {
+	return filepath.Join(dir, fmt.Sprintf("remote_list_snapshot-%020d.%s", lsn, extension))
+}
+
+// findGeneration procura em dir a geração com o LSN informado.
+func findGeneration(dir string, lsn uint64) (string, bool) {
+	for _, extension := range snapshotGenerationExtensions {
+		if path := generationPath(dir, lsn, extension); isGeneration(path, lsn) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// isGeneration indica se path é um arquivo de geração existente com o LSN
+// informado no nome (o snapshot anterior às gerações não é).
+func isGeneration(path string, lsn uint64) bool {
+	match := snapshotGenerationPattern.FindStringSubmatch(filepath.Base(path))
+	if match == nil {
+		return false
+	}
+	if n, _ := strconv.ParseUint(match[1], 10, 64); n != lsn {
+		return false
+	}
+	info, err := currentFS().Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Snapshots lista os snapshots do diretório de snapshots, do mais novo para o
+// mais antigo. O arquivo único usado antes das gerações, se existir, é
+// tratado como a geração mais antiga.
+func Snapshots() ([]SnapshotFile, error) {
+	entries, err := currentFS().ReadDir(snapshotsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("erro ao listar snapshots em %s: %w", snapshotsDir, err)
+	}
+
+	var files []SnapshotFile
+	for _, entry := range entries {
+		var lsn uint64
+		var delta bool
+		if match := snapshotGenerationPattern.FindStringSubmatch(entry.Name()); match != nil {
+			lsn, _ = strconv.ParseUint(match[1], 10, 64)
+			delta = match[2] == "delta"
+		} else if entry.Name() != snapshotFileName {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		file := SnapshotFile{
+			Path:    filepath.Join(snapshotsDir, entry.Name()),
+			LSN:     lsn,
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+			Delta:   delta,
+		}
+		if delta {
+			// Um cabeçalho ilegível deixa Parent zerado; o incremental é
+			// rejeitado ao ser carregado.
+			header, _ := readSnapshotHeader(file.Path)
+			file.Parent = header.Parent
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].LSN != files[j].LSN {
+			return files[i].LSN > files[j].LSN
+		}
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	return files, nil
+}
+
+// SnapshotRetention define quais gerações são mantidas: as KeepLast mais
+// novas e, além delas, a mais nova de cada uma das últimas KeepHourly horas e
+// KeepDaily dias que tenham snapshots.
+type SnapshotRetention struct {
+	KeepLast   int
+	KeepHourly int
+	KeepDaily  int
+}
+
+// DefaultKeepLast é a quantidade de gerações mantidas quando KeepLast é zero.
+const DefaultKeepLast = 3
+
+var (
+	retentionMu sync.Mutex
+	retention   = SnapshotRetention{KeepLast: DefaultKeepLast}
+)
+
+// SetSnapshotRetention troca a política de retenção, aplicada a partir do
+// próximo snapshot salvo.
+func SetSnapshotRetention(r SnapshotRetention) {
+	if r.KeepLast <= 0 {
+		r.KeepLast = DefaultKeepLast
+	}
+	retentionMu.Lock()
+	retention = r
+	retentionMu.Unlock()
+}
+
+func currentRetention() SnapshotRetention {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+	return retention
+}
+
+// Keep retorna os caminhos das gerações que a política mantém. files deve
+// estar ordenado do mais novo para o mais antigo, como em Snapshots. As
+// gerações das quais um incremental mantido depende também são mantidas.
+func (r SnapshotRetention) Keep(files []SnapshotFile) map[string]bool {
+	keep := make(map[string]bool)
+	for i := 0; i < len(files) && i < r.KeepLast; i++ {
+		keep[files[i].Path] = true
+	}
+	keepNewestPerPeriod(files, r.KeepHourly, keep, func(t time.Time) string { return t.Format("2006-01-02T15") })
+	keepNewestPerPeriod(files, r.KeepDaily, keep, func(t time.Time) string { return t.Format("2006-01-02") })
+
+	// files vai do mais novo para o mais antigo, e o pai de um incremental é
+	// sempre mais antigo: uma passagem basta para manter as cadeias inteiras.
+	byLSN := make(map[uint64]SnapshotFile, len(files))
+	for _, file := range files {
+		if _, ok := byLSN[file.LSN]; !ok {
+			byLSN[file.LSN] = file
+		}
+	}
+	for _, file := range files {
+		if !keep[file.Path] || !file.Delta {
+			continue
+		}
+		if parent, ok := byLSN[file.Parent]; ok && parent.LSN < file.LSN {
+			keep[parent.Path] = true
+		}
+	}
+	return keep
+}
+
+// keepNewestPerPeriod marca a geração mais nova de cada um dos n períodos
+// mais recentes, conforme a chave de período de cada horário.
+func keepNewestPerPeriod(files []SnapshotFile, n int, keep map[string]bool, period func(time.Time) string) {
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if len(seen) >= n {
+			return
+		}
+		key := period(file.ModTime.Local())
+		if !seen[key] {
+			seen[key] = true
+			keep[file.Path] = true
+		}
+	}
+}
+
+// pruneSnapshots remove as gerações que a política não mantém e retorna os
+// arquivos removidos.
+func pruneSnapshots(r SnapshotRetention) ([]string, error) {
+	files, err := Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	keep := r.Keep(files)
+
+	var removed []string
+	for _, file := range files {
+		if keep[file.Path] {
+			continue
+		}
+		if err := currentFS().Remove(file.Path); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("erro ao remover snapshot %s: %w", file.Path, err)
+		}
+		removed = append(removed, file.Path)
+	}
+	return removed, nil
+}
+
+// CompactionPoint retorna o timestamp coberto pela geração válida mais
+// antiga. O log precisa ser mantido a partir dele para que qualquer geração
+// retida possa ser usada na recuperação (se a mais nova estiver corrompida)
+// ou em uma recuperação para um ponto no tempo.
+func CompactionPoint() (time.Time, error) {
+	files, err := Snapshots()
+	if err != nil {
+		return time.Time{}, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if _, info, err := ReadSnapshot(files[i].Path); err == nil {
+			return info.LastLogTimestamp, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("nenhum snapshot válido em %s", snapshotsDir)
+}
@@ -0,0 +1,145 @@
+// Package workload grava as chamadas recebidas pelo servidor em JSON lines
+// (uma chamada por linha) e as lê de volta, para que replay.go as reexecute
+// contra outro servidor e compare as respostas.
+package workload
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"sd-miniprojeto-1/structures"
+)
+
+// Call é uma chamada gravada.
+type Call struct {
+	Seq        uint64               `json:"seq"`              // Ordem de término no servidor.
+	Start      time.Time            `json:"start"`            // Chegada da chamada.
+	Duration   time.Duration        `json:"duration_ns"`      // Tempo até a resposta.
+	Session    uint64               `json:"session"`          // Conexão de origem; chamadas da mesma conexão são sequenciais.
+	Client     string               `json:"client,omitempty"` // Cliente autenticado (vazio se o token não for válido).
+	RemoteAddr string               `json:"remote_addr"`      // Endereço de origem.
+	Protocol   string               `json:"protocol"`         // "rpc" ou "resp".
+	Method     string               `json:"method"`           // Append, Get, Remove, Size, Range, GetAt, Delete, Keys, Ping, uma agregação (Sum...) ou uma busca (IndexOf...).
+	Args       json.RawMessage      `json:"args"`             // Argumentos, como structures.AppendArgs etc.
+	Reply      json.RawMessage      `json:"reply,omitempty"`  // Resposta, se não houve erro.
+	Code       structures.ErrorCode `json:"code,omitempty"`   // Código do erro tipado, se houve erro.
+	Error      string               `json:"error,omitempty"`  // Mensagem do erro.
+}
+
+// Recorder grava chamadas em um arquivo, uma por linha. É seguro para uso
+// concorrente; as linhas não são sincronizadas no disco a cada chamada.
+type Recorder struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	seq  uint64
+	err  error // Primeira falha de escrita; as seguintes são ignoradas.
+}
+
+// NewRecorder abre (ou cria) o arquivo de gravação, acrescentando ao final.
+func NewRecorder(path string) (*Recorder, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("erro ao criar diretório da gravação: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir arquivo de gravação %s: %w", path, err)
+	}
+	return &Recorder{path: path, f: f}, nil
+}
+
+// Path retorna o arquivo da gravação.
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Record grava a chamada, atribuindo a ela o próximo número de sequência. A
+// resposta só é gravada se err for nil. Retorna a primeira falha de escrita
+// da gravação, se houver.
+func (r *Recorder) Record(call Call, args, reply any, err error) error {
+	encodedArgs, encodeErr := json.Marshal(args)
+	if encodeErr != nil {
+		return fmt.Errorf("erro ao codificar argumentos de %s: %w", call.Method, encodeErr)
+	}
+	call.Args = encodedArgs
+	if err == nil {
+		if call.Reply, encodeErr = json.Marshal(reply); encodeErr != nil {
+			return fmt.Errorf("erro ao codificar resposta de %s: %w", call.Method, encodeErr)
+		}
+	} else {
+		call.Error = err.Error()
+		var typed *structures.Error
+		if errors.As(err, &typed) {
+			call.Code = typed.Code
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.seq++
+	call.Seq = r.seq
+	line, _ := json.Marshal(call)
+	// Uma escrita por linha, sem buffer: se o processo cair, só a última
+	// linha pode ficar incompleta.
+	if _, err := r.f.Write(append(line, '\n')); err != nil {
+		r.err = fmt.Errorf("erro ao escrever no arquivo de gravação %s: %w", r.path, err)
+		return r.err
+	}
+	return nil
+}
+
+// Close fecha o arquivo.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// Read lê uma gravação. Uma última linha incompleta (processo interrompido
+// durante a escrita) é ignorada; outras linhas inválidas são erro.
+func Read(r io.Reader) ([]Call, error) {
+	var calls []Call
+	reader := bufio.NewReader(r)
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var call Call
+			if jsonErr := json.Unmarshal(line, &call); jsonErr != nil {
+				return nil, fmt.Errorf("linha %d inválida: %w", number, jsonErr)
+			}
+			calls = append(calls, call)
+		}
+		if err == io.EOF {
+			return calls, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReadFile lê a gravação do arquivo.
+func ReadFile(path string) ([]Call, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	calls, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return calls, nil
+}